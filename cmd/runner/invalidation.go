@@ -0,0 +1,34 @@
+package runner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// setupInvalidationBus shares cache invalidations with the other instances
+// using the same database, as configured by settings.CacheInvalidation.
+func setupInvalidationBus(ctx context.Context, settings *settings.Settings, driver store.Driver, s *store.Store) {
+	switch strings.ToLower(settings.CacheInvalidation) {
+	case "none":
+		return
+	case "poll":
+		if settings.Driver == "memory" {
+			// nothing is shared with other processes.
+			return
+		}
+		s.UseInvalidationBus(ctx, store.NewPollingBus(driver, common.NowUTC, store.PollingBusOptions{
+			Interval:  settings.CacheInvalidationInterval,
+			Retention: settings.CacheInvalidationRetention,
+			BatchSize: 500,
+		}))
+		log.Info().Dur("interval", settings.CacheInvalidationInterval).Msg("Polling for cache invalidations")
+	default:
+		log.Fatal().Str("cache_invalidation", settings.CacheInvalidation).Msg("Unknown cache invalidation mode, use poll or none")
+	}
+}
@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"context"
 	"sync"
 
 	"github.com/rs/zerolog/log"
@@ -15,7 +16,9 @@ import (
 type Runner struct {
 	settings *settings.Settings
 	srv      *server.Server
-	mu       sync.Mutex
+	// cancel stops background work, e.g. the cache invalidation bus.
+	cancel context.CancelFunc
+	mu     sync.Mutex
 }
 
 func NewRunner(s *settings.Settings) Runner {
@@ -36,14 +39,43 @@ func (runner *Runner) Run() {
 	// setup Database driver
 	dbDriver := db.NewDBDriver(runner.settings, common.NowUTC)
 
+	// make sure the schema matches this binary
+	checkSchema(runner.settings, dbDriver)
+
 	// set up store
-	storeInstance := store.New(dbDriver, common.NowUTC)
+	storeInstance := store.New(dbDriver, common.NowUTC, store.Options{
+		Cache: store.CacheOptions{
+			UserCacheSize:    runner.settings.UserCacheSize,
+			UserCacheTTL:     runner.settings.UserCacheTTL,
+			SessionCacheSize: runner.settings.SessionCacheSize,
+			SessionCacheTTL:  runner.settings.SessionCacheTTL,
+		},
+		Sessions: store.SessionOptions{
+			Default: store.SessionPolicy{
+				IdleTimeout: runner.settings.SessionIdleTimeout,
+				MaxLifetime: runner.settings.SessionMaxLifetime,
+			},
+			Remember: store.SessionPolicy{
+				IdleTimeout: runner.settings.SessionRememberIdleTimeout,
+				MaxLifetime: runner.settings.SessionRememberMaxLifetime,
+			},
+			RefreshInterval: runner.settings.SessionRefreshInterval,
+		},
+		Tokens: store.TokenOptions{
+			RefreshTokenTTL: runner.settings.RefreshTokenTTL,
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	setupInvalidationBus(ctx, runner.settings, dbDriver, storeInstance)
+	startSessionSweeper(ctx, runner.settings, storeInstance)
+	keys := setupSigningKeys(ctx, runner.settings, storeInstance)
 
 	// setup server
-	srv := server.NewServer(runner.settings, storeInstance)
+	srv := server.NewServer(runner.settings, storeInstance, keys)
 
 	runner.mu.Lock()
 	runner.srv = srv
+	runner.cancel = cancel
 	runner.mu.Unlock()
 
 	srv.Start()
@@ -52,9 +84,12 @@ func (runner *Runner) Run() {
 
 func (runner *Runner) Stop() {
 	runner.mu.Lock()
-	srv := runner.srv
+	srv, cancel := runner.srv, runner.cancel
 	runner.mu.Unlock()
 	if srv != nil {
 		srv.Stop()
 	}
+	if cancel != nil {
+		cancel()
+	}
 }
@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/db/migrator"
+)
+
+// checkSchema compares the database schema version with the embedded migrations.
+// A schema that is behind or dirty is fatal unless settings.SchemaCheck is "warn".
+func checkSchema(settings *settings.Settings, driver store.Driver) {
+	warnOnly := strings.ToLower(settings.SchemaCheck) == "warn"
+	logEvent := log.Fatal
+	if warnOnly {
+		logEvent = log.Warn
+	}
+
+	status, err := migrator.CheckSchema(settings.Driver, driver.GetDB())
+	if errors.Is(err, migrator.ErrNotSupported) {
+		return
+	}
+	if err != nil {
+		logEvent().Err(err).Msg("Failed to check schema version, run `user-service migrate`")
+		return
+	}
+
+	switch {
+	case status.Dirty:
+		logEvent().Uint("version", status.Version).
+			Msg("Schema is dirty, fix it with `user-service migrate force`")
+	case status.Behind():
+		logEvent().Uint("version", status.Version).
+			Uint("latest", status.Latest).
+			Msg("Schema is behind this binary, run `user-service migrate`")
+	case status.Ahead():
+		log.Warn().Uint("version", status.Version).
+			Uint("latest", status.Latest).
+			Msg("Schema is newer than this binary")
+	default:
+		log.Info().Uint("version", status.Version).Msg("Schema is up to date")
+	}
+}
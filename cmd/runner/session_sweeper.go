@@ -0,0 +1,25 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// startSessionSweeper deletes expired and revoked sessions and tokens in the background
+// until ctx is done. A SESSION_GC_INTERVAL of 0 disables it.
+func startSessionSweeper(ctx context.Context, settings *settings.Settings, s *store.Store) {
+	if settings.SessionGCInterval <= 0 {
+		return
+	}
+	go s.RunSessionSweeper(ctx, store.SessionSweeperOptions{
+		Interval:  settings.SessionGCInterval,
+		Retention: settings.SessionGCRetention,
+		BatchSize: settings.SessionGCBatchSize,
+	})
+	log.Info().Dur("interval", settings.SessionGCInterval).Dur("retention", settings.SessionGCRetention).
+		Msg("Purging expired and revoked sessions and tokens")
+}
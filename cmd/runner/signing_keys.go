@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// ErrFileSigningKeys is returned by RotateSigningKey when the keys are read from files.
+var ErrFileSigningKeys = errors.New("signing keys are read from JWT_SIGNING_KEY_FILE, rotate the files instead")
+
+// setupSigningKeys loads the keys that sign and verify JWTs and reloads them
+// in the background until ctx is done, to pick up keys rotated elsewhere.
+func setupSigningKeys(ctx context.Context, settings *settings.Settings, s *store.Store) *jwtUtils.KeySet {
+	load := storeKeyLoader(settings, s)
+	source := "database"
+	if settings.JWTSigningKeyFile != "" {
+		load, source = fileKeyLoader(settings), "files"
+	}
+
+	keys, err := jwtUtils.NewKeySet(ctx, load)
+	if err != nil {
+		log.Fatal().Err(err).Str("source", source).Msg("Failed to load JWT signing keys")
+	}
+	if settings.JWTKeyRetention < settings.AccessTokenTTL {
+		log.Warn().Dur("retention", settings.JWTKeyRetention).Dur("access_token_ttl", settings.AccessTokenTTL).
+			Msg("JWT_KEY_RETENTION is shorter than ACCESS_TOKEN_TTL, tokens will be rejected after a rotation")
+	}
+	if settings.JWTKeyReloadInterval > 0 {
+		go keys.Run(ctx, settings.JWTKeyReloadInterval)
+	}
+
+	signing := keys.SigningKey()
+	log.Info().Str("source", source).Str("kid", signing.ID).Str("algorithm", signing.Algorithm).
+		Msg("JWT signing keys loaded")
+	return keys
+}
+
+// fileKeyLoader reads the PEM keys configured in settings.
+func fileKeyLoader(settings *settings.Settings) jwtUtils.KeyLoader {
+	return func(ctx context.Context) (*jwtUtils.Key, []*jwtUtils.Key, error) {
+		data, err := os.ReadFile(settings.JWTSigningKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		signing, err := jwtUtils.ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", settings.JWTSigningKeyFile, err)
+		}
+
+		verification := make([]*jwtUtils.Key, 0, len(settings.JWTVerificationKeyFiles))
+		for _, path := range settings.JWTVerificationKeyFiles {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			key, err := jwtUtils.ParseKeyPEM(data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			verification = append(verification, key)
+		}
+		return signing, verification, nil
+	}
+}
+
+// storeKeyLoader reads the keys stored in the database, the newest key still signing
+// signs new tokens. A first key is generated if there is none.
+func storeKeyLoader(settings *settings.Settings, s *store.Store) jwtUtils.KeyLoader {
+	return func(ctx context.Context) (*jwtUtils.Key, []*jwtUtils.Key, error) {
+		retiredAfter := common.NowUTC().Add(-settings.JWTKeyRetention)
+		list, err := s.ListSigningKeys(ctx, &store.FindSigningKey{RetiredAfter: &retiredAfter})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var (
+			signing *jwtUtils.Key
+			keys    = make([]*jwtUtils.Key, 0, len(list))
+		)
+		for _, info := range list {
+			key, err := jwtUtils.OpenPrivateKey(info.PrivateKey, settings.SecretKey)
+			if err != nil {
+				return nil, nil, fmt.Errorf("signing key %s: %w", info.ID, err)
+			}
+			if signing == nil && info.RetiredAt == nil {
+				signing = key
+			}
+			keys = append(keys, key)
+		}
+		if signing == nil {
+			if signing, err = createSigningKey(ctx, settings, s); err != nil {
+				return nil, nil, err
+			}
+			log.Info().Str("kid", signing.ID).Msg("Generated a JWT signing key")
+		}
+		return signing, keys, nil
+	}
+}
+
+func createSigningKey(ctx context.Context, settings *settings.Settings, s *store.Store) (*jwtUtils.Key, error) {
+	key, err := jwtUtils.GenerateKey(settings.JWTAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := jwtUtils.SealPrivateKey(key, settings.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.CreateSigningKey(ctx, &store.CreateSigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: sealed,
+	}); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RotateSigningKey generates a key that signs the tokens issued from now on and retires
+// the others, they keep verifying tokens for JWT_KEY_RETENTION. Keys retired longer
+// than that are deleted. Running instances pick up the new key on their next reload.
+func RotateSigningKey(ctx context.Context, settings *settings.Settings, s *store.Store) (*jwtUtils.Key, error) {
+	if settings.JWTSigningKeyFile != "" {
+		return nil, ErrFileSigningKeys
+	}
+
+	key, err := createSigningKey(ctx, settings, s)
+	if err != nil {
+		return nil, fmt.Errorf("create signing key: %w", err)
+	}
+	if _, err := s.RetireSigningKeys(ctx, key.ID); err != nil {
+		return nil, fmt.Errorf("retire signing keys: %w", err)
+	}
+	if _, err := s.DeleteSigningKeys(ctx, common.NowUTC().Add(-settings.JWTKeyRetention)); err != nil {
+		return nil, fmt.Errorf("delete retired signing keys: %w", err)
+	}
+	return key, nil
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
-	migrate "github.com/golang-migrate/migrate/v4"
-	migrateMySQL "github.com/golang-migrate/migrate/v4/database/mysql"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/sagarsuperuser/userprofile/cmd/runner"
 	"github.com/sagarsuperuser/userprofile/internal/common"
 	"github.com/sagarsuperuser/userprofile/server/settings"
-	mysqlDriver "github.com/sagarsuperuser/userprofile/store/db/mysql"
+	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/db"
+	"github.com/sagarsuperuser/userprofile/store/db/migrator"
+)
+
+const (
+	migrateUsage = "usage: user-service migrate [status|up|down N|goto V|force V]"
+	keysUsage    = "usage: user-service keys rotate"
 )
 
 func main() {
@@ -27,11 +34,15 @@ func main() {
 	case "serve":
 		runServer(settings)
 	case "migrate":
-		if err := runMigrations(settings); err != nil {
+		if err := runMigrations(settings, args[2:]); err != nil {
 			log.Fatalf("migration failed: %v", err)
 		}
+	case "keys":
+		if err := runKeys(settings, args[2:]); err != nil {
+			log.Fatalf("keys failed: %v", err)
+		}
 	default:
-		log.Fatalf("unknown command %q (use serve|migrate)", cmd)
+		log.Fatalf("unknown command %q (use serve|migrate|keys)", cmd)
 	}
 }
 
@@ -40,22 +51,91 @@ func runServer(settings *settings.Settings) {
 	r.Run()
 }
 
-func runMigrations(settings *settings.Settings) error {
-	drv := mysqlDriver.NewDB(settings, common.NowUTC).(*mysqlDriver.DB)
-	defer drv.Close()
+func runMigrations(settings *settings.Settings, args []string) error {
+	subcmd := "up"
+	if len(args) > 0 {
+		subcmd = strings.ToLower(args[0])
+		args = args[1:]
+	}
+
+	drv := db.NewDBDriver(settings, common.NowUTC)
+	m, err := migrator.New(settings.Driver, drv.GetDB())
+	if errors.Is(err, migrator.ErrNotSupported) {
+		drv.Close()
+		log.Printf("nothing to migrate for the %s driver", settings.Driver)
+		return nil
+	}
+	if err != nil {
+		drv.Close()
+		return err
+	}
+	// closes the driver database as well
+	defer m.Close()
 
-	inst, err := migrateMySQL.WithInstance(drv.GetDB(), &migrateMySQL.Config{})
+	switch subcmd {
+	case "status":
+		// handled below
+	case "up":
+		err = m.Up()
+	case "down":
+		n := 1
+		if len(args) > 0 {
+			v, perr := strconv.Atoi(args[0])
+			if perr != nil {
+				return fmt.Errorf("invalid number of migrations %q: %w", args[0], perr)
+			}
+			n = v
+		}
+		err = m.Down(n)
+	case "goto":
+		if len(args) == 0 {
+			return errors.New(migrateUsage)
+		}
+		v, perr := strconv.ParseUint(args[0], 10, 64)
+		if perr != nil {
+			return fmt.Errorf("invalid version %q: %w", args[0], perr)
+		}
+		err = m.Goto(uint(v))
+	case "force":
+		if len(args) == 0 {
+			return errors.New(migrateUsage)
+		}
+		v, perr := strconv.Atoi(args[0])
+		if perr != nil {
+			return fmt.Errorf("invalid version %q: %w", args[0], perr)
+		}
+		err = m.Force(v)
+	default:
+		return errors.New(migrateUsage)
+	}
 	if err != nil {
-		return fmt.Errorf("init migrate instance: %w", err)
+		return fmt.Errorf("%s: %w", subcmd, err)
 	}
 
-	m, err := migrate.NewWithDatabaseInstance("file://store/db/mysql/migrations", "mysql", inst)
+	status, err := m.Status()
 	if err != nil {
-		return fmt.Errorf("init migrate: %w", err)
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	log.Printf("schema version %d of %d (driver: %s, dirty: %t)", status.Version, status.Latest, settings.Driver, status.Dirty)
+	return nil
+}
+
+func runKeys(settings *settings.Settings, args []string) error {
+	if len(args) == 0 || strings.ToLower(args[0]) != "rotate" {
+		return errors.New(keysUsage)
+	}
+
+	if settings.Driver == "memory" {
+		return errors.New("the memory driver keeps its keys in the serving process, nothing to rotate")
 	}
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("apply migrations: %w", err)
+
+	s := store.New(db.NewDBDriver(settings, common.NowUTC), common.NowUTC, store.Options{})
+	defer s.Close()
+
+	key, err := runner.RotateSigningKey(context.Background(), settings, s)
+	if err != nil {
+		return err
 	}
-	log.Printf("migrations applied (source: file://store/db/mysql/migrations)")
+	log.Printf("new signing key %s (%s), running instances switch to it within %s", key.ID, key.Algorithm, settings.JWTKeyReloadInterval)
 	return nil
 }
@@ -0,0 +1,215 @@
+// Package cache provides a bounded LRU cache with per-entry expiry and
+// coalescing of concurrent loads for the same key.
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+)
+
+// Options configures a Cache.
+type Options struct {
+	// Size is the maximum number of entries, 0 disables caching.
+	Size int
+	// TTL is how long an entry stays valid, 0 means until evicted.
+	TTL time.Duration
+	// Now defaults to common.NowUTC.
+	Now common.NowFunc
+}
+
+// Stats are the counters of a Cache since it was created.
+type Stats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+	Size        int    `json:"size"`
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// Cache is a size-bounded LRU cache safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	size int
+	ttl  time.Duration
+	now  common.NowFunc
+
+	mu    sync.Mutex
+	ll    *list.List // front is most recently used
+	items map[K]*list.Element
+	// epoch changes on every Set and Delete, see GetOrLoad.
+	epoch uint64
+
+	group singleflight.Group
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// New creates a new Cache.
+func New[K comparable, V any](opts Options) *Cache[K, V] {
+	now := opts.Now
+	if now == nil {
+		now = common.NowUTC
+	}
+	return &Cache[K, V]{
+		size:  opts.Size,
+		ttl:   opts.TTL,
+		now:   now,
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+// Get returns the value cached for key.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if !e.expiresAt.IsZero() && !c.now().Before(e.expiresAt) {
+		c.removeElement(el)
+		c.expirations.Add(1)
+		c.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// Set caches value for key, evicting the least recently used entry when full.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	c.set(key, value)
+}
+
+// GetOrLoad returns the cached value for key, or calls load and caches its result.
+// Concurrent misses for the same key share a single load call.
+// Errors are returned to all waiting callers and never cached.
+func (c *Cache[K, V]) GetOrLoad(key K, load func() (V, error)) (V, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+
+	v, err, _ := c.group.Do(fmt.Sprint(key), func() (any, error) {
+		c.mu.Lock()
+		epoch := c.epoch
+		c.mu.Unlock()
+
+		v, err := load()
+		if err != nil {
+			return v, err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// a write while loading means the loaded value may already be stale.
+		if c.epoch == epoch {
+			c.set(key, v)
+		}
+		return v, nil
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return v.(V), nil
+}
+
+// Delete removes key from the cache.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// DeleteFunc removes all entries for which del returns true.
+func (c *Cache[K, V]) DeleteFunc(del func(key K, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*entry[K, V]); del(e.key, e.value) {
+			c.removeElement(el)
+		}
+		el = next
+	}
+}
+
+// Len returns the number of cached entries, expired ones included.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+		Size:        c.Len(),
+	}
+}
+
+// set expects c.mu to be held.
+func (c *Cache[K, V]) set(key K, value V) {
+	if c.size <= 0 {
+		return
+	}
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = c.now().Add(c.ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expiresAt = value, expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	for c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// removeElement expects c.mu to be held.
+func (c *Cache[K, V]) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
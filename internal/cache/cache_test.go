@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestLRUEviction(t *testing.T) {
+	c := New[int, string](Options{Size: 2})
+	c.Set(1, "one")
+	c.Set(2, "two")
+	c.Get(1) // 2 is now least recently used
+	c.Set(3, "three")
+
+	if _, ok := c.Get(2); ok {
+		t.Errorf("Get(2) found an evicted entry")
+	}
+	for _, key := range []int{1, 3} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("Get(%d) missed", key)
+		}
+	}
+	if st := c.Stats(); st.Evictions != 1 || st.Size != 2 || st.Hits != 3 || st.Misses != 1 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	c := New[int, string](Options{Size: 10, TTL: time.Minute, Now: clock.now})
+	c.Set(1, "one")
+
+	clock.t = clock.t.Add(59 * time.Second)
+	if _, ok := c.Get(1); !ok {
+		t.Errorf("Get before expiry missed")
+	}
+	clock.t = clock.t.Add(time.Second)
+	if _, ok := c.Get(1); ok {
+		t.Errorf("Get after expiry found the entry")
+	}
+	if st := c.Stats(); st.Expirations != 1 || st.Size != 0 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+func TestZeroSizeDisablesCaching(t *testing.T) {
+	c := New[int, string](Options{})
+	c.Set(1, "one")
+	if _, ok := c.Get(1); ok {
+		t.Errorf("Get found an entry in a disabled cache")
+	}
+}
+
+func TestDeleteFunc(t *testing.T) {
+	c := New[int, string](Options{Size: 10})
+	for i := range 5 {
+		c.Set(i, "v")
+	}
+	c.DeleteFunc(func(key int, _ string) bool { return key%2 == 0 })
+	if got := c.Len(); got != 2 {
+		t.Errorf("Len after DeleteFunc = %d, want 2", got)
+	}
+}
+
+func TestGetOrLoadCoalesces(t *testing.T) {
+	c := New[int, int](Options{Size: 10})
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func() (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.GetOrLoad(1, load); err != nil || v != 42 {
+				t.Errorf("GetOrLoad = (%d, %v), want 42", v, err)
+			}
+		}()
+	}
+	// give the goroutines time to pile up on the same key
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("load called %d times, want 1", n)
+	}
+	if v, ok := c.Get(1); !ok || v != 42 {
+		t.Errorf("Get after GetOrLoad = (%d, %t)", v, ok)
+	}
+}
+
+func TestGetOrLoadErrorsAreNotCached(t *testing.T) {
+	c := New[int, int](Options{Size: 10})
+	errLoad := errors.New("boom")
+	if _, err := c.GetOrLoad(1, func() (int, error) { return 0, errLoad }); !errors.Is(err, errLoad) {
+		t.Fatalf("GetOrLoad error = %v, want %v", err, errLoad)
+	}
+	if v, err := c.GetOrLoad(1, func() (int, error) { return 7, nil }); err != nil || v != 7 {
+		t.Errorf("GetOrLoad after error = (%d, %v), want 7", v, err)
+	}
+}
+
+func TestDeleteDuringLoadSkipsCaching(t *testing.T) {
+	c := New[int, string](Options{Size: 10})
+	v, err := c.GetOrLoad(1, func() (string, error) {
+		c.Delete(1) // e.g. a session revoked while it was being read
+		return "stale", nil
+	})
+	if err != nil || v != "stale" {
+		t.Fatalf("GetOrLoad = (%q, %v)", v, err)
+	}
+	if _, ok := c.Get(1); ok {
+		t.Errorf("value loaded across a Delete was cached")
+	}
+}
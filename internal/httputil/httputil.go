@@ -3,7 +3,9 @@ package httputil
 import (
 	"context"
 	"encoding/json"
+	"net"
 	"net/http"
+	"strings"
 )
 
 // APIVersionKey is the client's requested API version.
@@ -35,3 +37,25 @@ func VersionFromContext(ctx context.Context) string {
 
 	return ""
 }
+
+// ClientIP returns the IP address of the client that sent r.
+// X-Forwarded-For is only trusted on requests from a loopback address,
+// such as the ones the web frontend makes to the API.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return host
+	}
+
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		// the last entry was added by the proxy closest to us
+		parts := strings.Split(fwd, ",")
+		if ip := strings.TrimSpace(parts[len(parts)-1]); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	return host
+}
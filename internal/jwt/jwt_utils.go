@@ -1,10 +1,12 @@
 package jwtUtils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,50 +16,94 @@ import (
 const (
 	// issuer is the issuer of the jwt token.
 	Issuer = "userservice"
-	// Signing key section. For now, this is only used for signing, not for verifying since we only
-	// have 1 version. But it will be used to maintain backward compatibility if we change the signing mechanism.
-	KeyID = "v1"
 	// AccessTokenAudienceName is the audience name of the access token.
 	AccessTokenAudienceName = "user.access-token"
-	AccessTokenDuration     = 24 * time.Hour
+	// ClientAccessTokenAudienceName is the audience of the access tokens issued to
+	// OAuth clients, they are only accepted by the userinfo endpoint.
+	ClientAccessTokenAudienceName = "user.client-access-token"
+	// ServiceAccountSubjectPrefix starts the subject of the access tokens issued to
+	// service accounts, user ids are numeric so the two can't be mistaken for one another.
+	ServiceAccountSubjectPrefix = "service:"
 )
 
 var (
 	ErrJWTValidate       = errors.New("failed to validate jwt token")
 	ErrJWTUserIDNotFound = errors.New("user id not found/malformed  in token")
+	ErrJWTRevoked        = errors.New("jwt token has been revoked")
 )
 
 type ClaimsMessage struct {
 	Name string `json:"name"`
+	// Scope and ClientID are set on the tokens issued to OAuth clients.
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func ValidateAccessToken(req *http.Request, secret string) (*jwt.Token, error) {
-	extractor := request.AuthorizationHeaderExtractor
-	keyFunc := func(t *jwt.Token) (any, error) {
-		if kid, ok := t.Header["kid"].(string); ok {
-			if kid == "v1" {
-				return []byte(secret), nil
-			}
-		}
-		return nil, fmt.Errorf("unexpected access token kid=%v", t.Header["kid"])
+// UserClaims are the standard OpenID Connect claims about a user,
+// each one is only set when its scope was granted.
+type UserClaims struct {
+	Email       string `json:"email,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Picture     string `json:"picture,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token.
+type IDTokenClaims struct {
+	Nonce    string           `json:"nonce,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	UserClaims
+	jwt.RegisteredClaims
+}
+
+// ValidateAccessToken parses and verifies the bearer token of req at now.
+func ValidateAccessToken(req *http.Request, keys *KeySet, now time.Time) (*jwt.Token, error) {
+	raw, err := request.AuthorizationHeaderExtractor.ExtractToken(req)
+	if err != nil {
+		return nil, err
 	}
+	return ParseAccessToken(req.Context(), raw, keys, now)
+}
 
+// ParseAccessToken parses and verifies an access token signed by one of keys, its
+// expiry is checked against now, the clock the token was issued with.
+func ParseAccessToken(ctx context.Context, raw string, keys *KeySet, now time.Time) (*jwt.Token, error) {
+	return parseToken(ctx, raw, AccessTokenAudienceName, keys, now)
+}
+
+// ParseClientAccessToken parses and verifies an access token issued to an OAuth client.
+func ParseClientAccessToken(ctx context.Context, raw string, keys *KeySet, now time.Time) (*jwt.Token, error) {
+	return parseToken(ctx, raw, ClientAccessTokenAudienceName, keys, now)
+}
+
+func parseToken(ctx context.Context, raw, audience string, keys *KeySet, now time.Time) (*jwt.Token, error) {
 	// It is heavily
 	// encouraged to use this option in order to prevent attacks such as
 	// https://auth0.com/blog/critical-vulnerabilities-in-json-web-token-libraries/.
-	parserOption := jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()})
-	parser := jwt.NewParser(parserOption)
-
-	var options []request.ParseFromRequestOption
-	options = append(options, request.WithClaims(ClaimsMessage{}))
-	options = append(options, request.WithParser(parser))
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	return parser.ParseWithClaims(raw, &ClaimsMessage{}, keyFunc(ctx, keys))
+}
 
-	token, err := request.ParseFromRequest(req, extractor, keyFunc, options...)
-	if err != nil {
-		return nil, err
+// keyFunc looks the verification key up by the "kid" header of the token.
+func keyFunc(ctx context.Context, keys *KeySet) jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := keys.VerificationKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("token signed with %s, key %s is for %s", t.Method.Alg(), kid, key.Algorithm)
+		}
+		return key.Public, nil
 	}
-	return token, nil
 }
 
 func GetUserIDFromToken(token *jwt.Token) (int64, error) {
@@ -74,31 +120,83 @@ func GetUserIDFromToken(token *jwt.Token) (int64, error) {
 
 }
 
-// GenerateAccessToken generates an access token.
-func GenerateAccessToken(username string, userID int64, secret []byte) (string, error) {
-	return generateToken(username, userID, AccessTokenAudienceName, secret)
+// GetServiceAccountIDFromToken returns the service account a token was issued to,
+// false for the tokens of users.
+func GetServiceAccountIDFromToken(token *jwt.Token) (string, bool) {
+	subject, err := token.Claims.GetSubject()
+	if err != nil {
+		return "", false
+	}
+	id, ok := strings.CutPrefix(subject, ServiceAccountSubjectPrefix)
+	return id, ok && id != ""
 }
 
-// generateToken generates a jwt token.
-func generateToken(username string, userID int64, audience string, secret []byte) (string, error) {
-	now := time.Now()
-	registeredClaims := jwt.RegisteredClaims{
-		Issuer:   Issuer,
-		Audience: jwt.ClaimStrings{audience},
-		IssuedAt: jwt.NewNumericDate(now),
-		Subject:  fmt.Sprint(userID),
+// GetClaimsFromToken returns the claims of a token parsed by ValidateAccessToken.
+func GetClaimsFromToken(token *jwt.Token) (*ClaimsMessage, error) {
+	claims, ok := token.Claims.(*ClaimsMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected claims type %T", token.Claims)
 	}
+	return claims, nil
+}
 
-	registeredClaims.ExpiresAt = jwt.NewNumericDate(now.Add(AccessTokenDuration))
-	// Declare the token with the HS256 algorithm used for signing, and the claims.
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &ClaimsMessage{
+// GenerateAccessToken generates an access token identified by jti, valid from now until expiresAt.
+func GenerateAccessToken(username string, userID int64, jti string, now, expiresAt time.Time, key *Key) (string, error) {
+	return generateToken(username, userID, AccessTokenAudienceName, jti, now, expiresAt, key)
+}
+
+// GenerateClientAccessToken generates an access token for the scopes a user granted an OAuth client.
+func GenerateClientAccessToken(userID int64, clientID, scope, jti string, now, expiresAt time.Time, key *Key) (string, error) {
+	return signToken(&ClaimsMessage{
+		Scope:            scope,
+		ClientID:         clientID,
+		RegisteredClaims: registeredClaims(strconv.FormatInt(userID, 10), ClientAccessTokenAudienceName, jti, now, expiresAt),
+	}, key)
+}
+
+// GenerateServiceAccessToken generates an access token for the scopes of a service account,
+// it is accepted by the API routes that allow service accounts.
+func GenerateServiceAccessToken(serviceAccountID, name, scope, jti string, now, expiresAt time.Time, key *Key) (string, error) {
+	return signToken(&ClaimsMessage{
+		Name:             name,
+		Scope:            scope,
+		ClientID:         serviceAccountID,
+		RegisteredClaims: registeredClaims(ServiceAccountSubjectPrefix+serviceAccountID, AccessTokenAudienceName, jti, now, expiresAt),
+	}, key)
+}
+
+// GenerateIDToken signs an ID token, the caller sets all the claims.
+func GenerateIDToken(claims *IDTokenClaims, key *Key) (string, error) {
+	return signToken(claims, key)
+}
+
+// generateToken generates a jwt token signed with key.
+func generateToken(username string, userID int64, audience, jti string, now, expiresAt time.Time, key *Key) (string, error) {
+	return signToken(&ClaimsMessage{
 		Name:             username,
-		RegisteredClaims: registeredClaims,
-	})
-	token.Header["kid"] = KeyID
+		RegisteredClaims: registeredClaims(strconv.FormatInt(userID, 10), audience, jti, now, expiresAt),
+	}, key)
+}
+
+func registeredClaims(subject, audience, jti string, now, expiresAt time.Time) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    Issuer,
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		Subject:   subject,
+		ID:        jti,
+	}
+}
+
+// signToken signs claims with key and names the key in the "kid" header.
+func signToken(claims jwt.Claims, key *Key) (string, error) {
+	// Declare the token with the algorithm of the key used for signing, and the claims.
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
 
 	// Create the JWT string.
-	tokenString, err := token.SignedString(secret)
+	tokenString, err := token.SignedString(key.Private)
 	if err != nil {
 		return "", err
 	}
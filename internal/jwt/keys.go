@@ -0,0 +1,293 @@
+package jwtUtils
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// AlgorithmRS256 signs with 2048 bit RSA keys.
+	AlgorithmRS256 = "RS256"
+	// AlgorithmEdDSA signs with Ed25519 keys.
+	AlgorithmEdDSA = "EdDSA"
+
+	rsaKeyBits = 2048
+)
+
+var ErrUnsupportedKey = errors.New("unsupported key type, use RSA or Ed25519")
+
+// Key is a key pair used to sign or verify tokens.
+type Key struct {
+	// ID is the "kid" header of the tokens signed with the key,
+	// the RFC 7638 thumbprint of its public key.
+	ID        string
+	Algorithm string
+	// Private is nil for keys that only verify tokens.
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// JWK is the JSON Web Key (RFC 7517) of a public key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set, as served on /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// GenerateKey generates a new signing key for algorithm.
+func GenerateKey(algorithm string) (*Key, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(private)
+	case AlgorithmEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(private)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q, use %s or %s", algorithm, AlgorithmRS256, AlgorithmEdDSA)
+	}
+}
+
+// NewKey returns the signing key of private.
+func NewKey(private crypto.Signer) (*Key, error) {
+	key, err := NewVerificationKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.Private = private
+	return key, nil
+}
+
+// NewVerificationKey returns a key that only verifies tokens.
+func NewVerificationKey(public crypto.PublicKey) (*Key, error) {
+	key := &Key{Public: public}
+	switch public.(type) {
+	case *rsa.PublicKey:
+		key.Algorithm = AlgorithmRS256
+	case ed25519.PublicKey:
+		key.Algorithm = AlgorithmEdDSA
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	// RFC 7638: SHA-256 of the required members in lexicographic order
+	jwk := key.JWK()
+	var members any
+	switch key.Algorithm {
+	case AlgorithmRS256:
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case AlgorithmEdDSA:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	key.ID = base64.RawURLEncoding.EncodeToString(sum[:])
+	return key, nil
+}
+
+// JWK returns the public JSON Web Key of k.
+func (k *Key) JWK() JWK {
+	jwk := JWK{Use: "sig", Alg: k.Algorithm, Kid: k.ID}
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+func (k *Key) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// ParsePrivateKeyPEM parses a PKCS #8 or PKCS #1 (RSA) private key.
+func ParsePrivateKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	var (
+		private any
+		err     error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+	return NewKey(signer)
+}
+
+// ParseKeyPEM parses a PKIX public key, private keys are accepted too
+// and only their public key is kept.
+func ParseKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if block.Type != "PUBLIC KEY" {
+		key, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return NewVerificationKey(key.Public)
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return NewVerificationKey(public)
+}
+
+// ParseJWK returns the verification key of a public JSON Web Key published by
+// another issuer. The key keeps the "kid" of the JWK, if any, as its ID.
+func ParseJWK(jwk JWK) (*Key, error) {
+	var public crypto.PublicKey
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid exponent")
+		}
+		rsaKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if rsaKey.N.BitLen() < rsaKeyBits || rsaKey.E < 3 {
+			return nil, fmt.Errorf("RSA keys must have at least %d bits", rsaKeyBits)
+		}
+		public = rsaKey
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		public = ed25519.PublicKey(x)
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	key, err := NewVerificationKey(public)
+	if err != nil {
+		return nil, err
+	}
+	// e.g. an RSA key for PS256
+	if jwk.Alg != "" && jwk.Alg != key.Algorithm {
+		return nil, ErrUnsupportedKey
+	}
+	if jwk.Kid != "" {
+		key.ID = jwk.Kid
+	}
+	return key, nil
+}
+
+// SealPrivateKey encrypts the private key of k with AES-GCM under a key derived from secret.
+func SealPrivateKey(k *Key, secret string) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, der, nil), nil
+}
+
+// OpenPrivateKey decrypts a key sealed by SealPrivateKey.
+func OpenPrivateKey(sealed []byte, secret string) (*Key, error) {
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed key is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	der, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key, was the secret key changed? %w", err)
+	}
+	private, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+	return NewKey(signer)
+}
+
+func newAEAD(secret string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
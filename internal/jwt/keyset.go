@@ -0,0 +1,135 @@
+package jwtUtils
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// minReloadInterval throttles the reloads triggered by tokens signed with unknown keys.
+const minReloadInterval = 10 * time.Second
+
+var ErrUnknownKey = errors.New("token signed with an unknown key")
+
+// KeyLoader returns the key that signs new tokens and the other keys
+// that still verify tokens signed before a rotation.
+type KeyLoader func(ctx context.Context) (signing *Key, verification []*Key, err error)
+
+// KeySet holds the keys of the tokens issued by this service, reloaded with a KeyLoader
+// so that keys rotated by another instance are picked up.
+type KeySet struct {
+	load KeyLoader
+	now  func() time.Time
+
+	mu       sync.RWMutex
+	signing  *Key
+	keys     map[string]*Key
+	loadedAt time.Time
+
+	// only one load at a time
+	loadMu sync.Mutex
+}
+
+// NewKeySet loads the keys once, the signing key is required.
+func NewKeySet(ctx context.Context, load KeyLoader) (*KeySet, error) {
+	ks := &KeySet{load: load, now: time.Now}
+	if err := ks.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload replaces the keys with the ones returned by the loader.
+func (ks *KeySet) Reload(ctx context.Context) error {
+	ks.loadMu.Lock()
+	defer ks.loadMu.Unlock()
+
+	signing, verification, err := ks.load(ctx)
+	if err != nil {
+		return err
+	}
+	if signing == nil || signing.Private == nil {
+		return errors.New("no signing key")
+	}
+	keys := map[string]*Key{signing.ID: signing}
+	for _, key := range verification {
+		if _, ok := keys[key.ID]; !ok {
+			keys[key.ID] = key
+		}
+	}
+
+	ks.mu.Lock()
+	ks.signing, ks.keys, ks.loadedAt = signing, keys, ks.now()
+	ks.mu.Unlock()
+	return nil
+}
+
+// Run reloads the keys every interval until ctx is done.
+func (ks *KeySet) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := ks.Reload(ctx); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("Failed to reload signing keys")
+		}
+	}
+}
+
+// SigningKey returns the key that signs new tokens.
+func (ks *KeySet) SigningKey() *Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.signing
+}
+
+// VerificationKey returns the key with the given kid. Unknown kids trigger a
+// reload, the key may have just been rotated in by another instance.
+func (ks *KeySet) VerificationKey(ctx context.Context, kid string) (*Key, error) {
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	loadedAt := ks.loadedAt
+	ks.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if ks.now().Sub(loadedAt) < minReloadInterval {
+		return nil, ErrUnknownKey
+	}
+	if err := ks.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// JWKS returns the public keys, the signing key first.
+func (ks *KeySet) JWKS() *JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	jwks := &JWKS{Keys: []JWK{ks.signing.JWK()}}
+	others := make([]JWK, 0, len(ks.keys)-1)
+	for kid, key := range ks.keys {
+		if kid != ks.signing.ID {
+			others = append(others, key.JWK())
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].Kid < others[j].Kid })
+	jwks.Keys = append(jwks.Keys, others...)
+	return jwks
+}
@@ -0,0 +1,199 @@
+package jwtUtils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func staticLoader(signing *Key, verification ...*Key) KeyLoader {
+	return func(ctx context.Context) (*Key, []*Key, error) {
+		return signing, verification, nil
+	}
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			ctx := context.Background()
+			key, err := GenerateKey(alg)
+			if err != nil {
+				t.Fatalf("GenerateKey: %v", err)
+			}
+			keys, err := NewKeySet(ctx, staticLoader(key))
+			if err != nil {
+				t.Fatalf("NewKeySet: %v", err)
+			}
+
+			now := time.Now()
+			raw, err := GenerateAccessToken("jane@example.com", 42, "jti-1", now, now.Add(time.Minute), keys.SigningKey())
+			if err != nil {
+				t.Fatalf("GenerateAccessToken: %v", err)
+			}
+			token, err := ParseAccessToken(ctx, raw, keys, now)
+			if err != nil {
+				t.Fatalf("ParseAccessToken: %v", err)
+			}
+			if kid := token.Header["kid"]; kid != key.ID {
+				t.Errorf("kid = %v, want %s", kid, key.ID)
+			}
+			if userID, err := GetUserIDFromToken(token); err != nil || userID != 42 {
+				t.Errorf("GetUserIDFromToken = (%d, %v), want 42", userID, err)
+			}
+
+			if jwks := keys.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].Kid != key.ID || jwks.Keys[0].Alg != alg {
+				t.Errorf("JWKS = %+v", jwks)
+			}
+		})
+	}
+}
+
+func TestServiceAccessToken(t *testing.T) {
+	ctx := context.Background()
+	key, _ := GenerateKey(AlgorithmEdDSA)
+	keys, err := NewKeySet(ctx, staticLoader(key))
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	now := time.Now()
+	raw, err := GenerateServiceAccessToken("abc", "Billing", "users:read", "jti-1", now, now.Add(time.Minute), key)
+	if err != nil {
+		t.Fatalf("GenerateServiceAccessToken: %v", err)
+	}
+	token, err := ParseAccessToken(ctx, raw, keys, now)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if id, ok := GetServiceAccountIDFromToken(token); !ok || id != "abc" {
+		t.Errorf("GetServiceAccountIDFromToken = (%q, %v), want abc", id, ok)
+	}
+	// never taken for a user
+	if userID, err := GetUserIDFromToken(token); err == nil {
+		t.Errorf("GetUserIDFromToken = %d, want an error", userID)
+	}
+
+	raw, _ = GenerateAccessToken("jane@example.com", 42, "jti-2", now, now.Add(time.Minute), key)
+	token, _ = ParseAccessToken(ctx, raw, keys, now)
+	if id, ok := GetServiceAccountIDFromToken(token); ok {
+		t.Errorf("GetServiceAccountIDFromToken(user token) = %q, want false", id)
+	}
+}
+
+func TestParseAccessTokenRejectsOtherKeys(t *testing.T) {
+	ctx := context.Background()
+	key, _ := GenerateKey(AlgorithmEdDSA)
+	other, _ := GenerateKey(AlgorithmEdDSA)
+	keys, err := NewKeySet(ctx, staticLoader(key))
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	now := time.Now()
+	raw, _ := GenerateAccessToken("jane@example.com", 42, "jti-1", now, now.Add(time.Minute), other)
+	if _, err := ParseAccessToken(ctx, raw, keys, now); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("ParseAccessToken error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestParseAccessTokenAtNow(t *testing.T) {
+	ctx := context.Background()
+	key, _ := GenerateKey(AlgorithmEdDSA)
+	keys, err := NewKeySet(ctx, staticLoader(key))
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	// the expiry is checked against the clock passed in, not the wall clock
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	raw, _ := GenerateAccessToken("jane@example.com", 42, "jti-1", now, now.Add(time.Minute), key)
+	if _, err := ParseAccessToken(ctx, raw, keys, now.Add(time.Minute-time.Second)); err != nil {
+		t.Errorf("ParseAccessToken(before expiry): %v", err)
+	}
+	if _, err := ParseAccessToken(ctx, raw, keys, now.Add(time.Minute)); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("ParseAccessToken(expired) error = %v, want %v", err, jwt.ErrTokenExpired)
+	}
+}
+
+func TestVerificationKeyReloadsUnknownKid(t *testing.T) {
+	ctx := context.Background()
+	first, _ := GenerateKey(AlgorithmEdDSA)
+	second, _ := GenerateKey(AlgorithmEdDSA)
+
+	// rotated by another instance after the first load
+	current := []*Key{first}
+	keys, err := NewKeySet(ctx, func(ctx context.Context) (*Key, []*Key, error) {
+		return current[0], current, nil
+	})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	current = []*Key{second, first}
+
+	clock := time.Now()
+	keys.now = func() time.Time { return clock }
+	if _, err := keys.VerificationKey(ctx, second.ID); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("VerificationKey within minReloadInterval error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	clock = clock.Add(minReloadInterval)
+	if key, err := keys.VerificationKey(ctx, second.ID); err != nil || key.ID != second.ID {
+		t.Fatalf("VerificationKey = (%v, %v), want the rotated key", key, err)
+	}
+	if keys.SigningKey().ID != second.ID {
+		t.Errorf("SigningKey was not reloaded")
+	}
+	if jwks := keys.JWKS(); len(jwks.Keys) != 2 || jwks.Keys[0].Kid != second.ID {
+		t.Errorf("JWKS = %+v, want the signing key first", jwks)
+	}
+}
+
+func TestSealPrivateKey(t *testing.T) {
+	key, _ := GenerateKey(AlgorithmRS256)
+	sealed, err := SealPrivateKey(key, "secret")
+	if err != nil {
+		t.Fatalf("SealPrivateKey: %v", err)
+	}
+	opened, err := OpenPrivateKey(sealed, "secret")
+	if err != nil {
+		t.Fatalf("OpenPrivateKey: %v", err)
+	}
+	if opened.ID != key.ID || opened.Algorithm != AlgorithmRS256 {
+		t.Errorf("OpenPrivateKey = %s/%s, want %s/%s", opened.ID, opened.Algorithm, key.ID, key.Algorithm)
+	}
+	if _, err := OpenPrivateKey(sealed, "other secret"); err == nil {
+		t.Errorf("OpenPrivateKey with the wrong secret succeeded")
+	}
+}
+
+func TestParseJWK(t *testing.T) {
+	for _, alg := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := GenerateKey(alg)
+			if err != nil {
+				t.Fatalf("GenerateKey: %v", err)
+			}
+			jwk := key.JWK()
+			jwk.Kid = "provider-kid"
+			parsed, err := ParseJWK(jwk)
+			if err != nil {
+				t.Fatalf("ParseJWK: %v", err)
+			}
+			if parsed.ID != "provider-kid" || parsed.Algorithm != alg || parsed.Private != nil {
+				t.Errorf("ParseJWK = (%q, %q)", parsed.ID, parsed.Algorithm)
+			}
+			if parsed.JWK().N != jwk.N || parsed.JWK().X != jwk.X {
+				t.Errorf("ParseJWK returned another public key")
+			}
+
+			// the key can't be used with another algorithm
+			jwk.Alg = "PS256"
+			if _, err := ParseJWK(jwk); !errors.Is(err, ErrUnsupportedKey) {
+				t.Errorf("ParseJWK(PS256) error = %v, want %v", err, ErrUnsupportedKey)
+			}
+		})
+	}
+}
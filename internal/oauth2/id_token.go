@@ -0,0 +1,164 @@
+package oauth2Utils
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+)
+
+const (
+	// minKeysRefreshInterval throttles the JWKS fetches triggered by unknown keys.
+	minKeysRefreshInterval = 10 * time.Second
+	// idTokenLeeway tolerates clock skew with the provider.
+	idTokenLeeway = time.Minute
+)
+
+var errUnknownProviderKey = errors.New("id token signed with an unknown key")
+
+// idTokenClaims are the claims of a provider's ID token we check
+// (OpenID Connect Core 1.0 section 3.1.3.7).
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string `json:"nonce"`
+	AuthorizedParty string `json:"azp,omitempty"`
+}
+
+// idTokenVerifier verifies the ID tokens issued to us by an OpenID Connect provider.
+type idTokenVerifier struct {
+	// issuers the iss claim can be, Google has two.
+	issuers  []string
+	clientID string
+	keys     *remoteKeySet
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of raw.
+func (v *idTokenVerifier) verify(ctx context.Context, raw, nonce string) (*idTokenClaims, error) {
+	if raw == "" {
+		return nil, errors.New("no id token in the token response")
+	}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwtUtils.AlgorithmRS256, jwtUtils.AlgorithmEdDSA}),
+		jwt.WithAudience(v.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+	claims := &idTokenClaims{}
+	if _, err := parser.ParseWithClaims(raw, claims, v.keyFunc(ctx)); err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(v.issuers, claims.Issuer) {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != v.clientID {
+		return nil, fmt.Errorf("id token authorized for %q", claims.AuthorizedParty)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+// keyFunc looks the verification key up by the "kid" header of the token.
+func (v *idTokenVerifier) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := v.keys.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("token signed with %s, key %s is for %s", t.Method.Alg(), kid, key.Algorithm)
+		}
+		return key.Public, nil
+	}
+}
+
+// remoteKeySet caches the JSON Web Key Set of a provider. It is fetched on first
+// use and again when a token is signed with an unknown key, since providers rotate them.
+type remoteKeySet struct {
+	url    string
+	client *http.Client
+	now    func() time.Time
+
+	// group lets the callers missing a key at the same time share one fetch,
+	// mu is not held while fetching so cached keys stay available meanwhile.
+	group singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]*jwtUtils.Key
+	fetchedAt time.Time
+}
+
+func newRemoteKeySet(url string) *remoteKeySet {
+	return &remoteKeySet{url: url, client: http.DefaultClient, now: time.Now}
+}
+
+// key returns the key with the given kid. Keys of a type we can't verify are ignored.
+func (ks *remoteKeySet) key(ctx context.Context, kid string) (*jwtUtils.Key, error) {
+	keys, fresh := ks.cached()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if fresh {
+		return nil, errUnknownProviderKey
+	}
+
+	v, err, _ := ks.group.Do(ks.url, func() (any, error) {
+		// another caller may have fetched the keys since they were looked up
+		if keys, fresh := ks.cached(); fresh {
+			return keys, nil
+		}
+		return ks.fetch(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := v.(map[string]*jwtUtils.Key)[kid]; ok {
+		return key, nil
+	}
+	return nil, errUnknownProviderKey
+}
+
+// cached returns the keys fetched last and whether they are too recent to fetch again.
+func (ks *remoteKeySet) cached() (map[string]*jwtUtils.Key, bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.keys, !ks.fetchedAt.IsZero() && ks.now().Sub(ks.fetchedAt) < minKeysRefreshInterval
+}
+
+// fetch downloads the key set and replaces the cached keys with it.
+func (ks *remoteKeySet) fetch(ctx context.Context) (map[string]*jwtUtils.Key, error) {
+	var jwks jwtUtils.JWKS
+	if err := getJSON(ctx, ks.client, ks.url, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+	keys := make(map[string]*jwtUtils.Key, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwtUtils.ParseJWK(jwk); err == nil {
+			keys[key.ID] = key
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys, ks.fetchedAt = keys, ks.now()
+	return keys, nil
+}
@@ -0,0 +1,174 @@
+package oauth2Utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+)
+
+// testProvider serves the JWKS of key.
+func testProvider(t *testing.T, key *jwtUtils.Key, fetches *atomic.Int32) *idTokenVerifier {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(jwtUtils.JWKS{Keys: []jwtUtils.JWK{key.JWK()}})
+	}))
+	t.Cleanup(srv.Close)
+
+	keys := newRemoteKeySet(srv.URL)
+	keys.client = srv.Client()
+	return &idTokenVerifier{issuers: []string{"https://id.example.com"}, clientID: "client-1", keys: keys}
+}
+
+func signIDToken(t *testing.T, key *jwtUtils.Key, claims *idTokenClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+	raw, err := token.SignedString(key.Private)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return raw
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	ctx := context.Background()
+	key, err := jwtUtils.GenerateKey(jwtUtils.AlgorithmRS256)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	var fetches atomic.Int32
+	v := testProvider(t, key, &fetches)
+
+	now := time.Now()
+	valid := func() *idTokenClaims {
+		return &idTokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "https://id.example.com",
+				Subject:   "sub-1",
+				Audience:  jwt.ClaimStrings{"client-1"},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+			Nonce: "nonce-1",
+		}
+	}
+
+	claims, err := v.verify(ctx, signIDToken(t, key, valid()), "nonce-1")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if claims.Subject != "sub-1" {
+		t.Errorf("subject = %q, want sub-1", claims.Subject)
+	}
+
+	other, _ := jwtUtils.GenerateKey(jwtUtils.AlgorithmRS256)
+	tests := []struct {
+		name  string
+		key   *jwtUtils.Key
+		nonce string
+		edit  func(c *idTokenClaims)
+	}{
+		{"wrong nonce", key, "nonce-2", func(c *idTokenClaims) {}},
+		{"wrong issuer", key, "nonce-1", func(c *idTokenClaims) { c.Issuer = "https://evil.example.com" }},
+		{"wrong audience", key, "nonce-1", func(c *idTokenClaims) { c.Audience = jwt.ClaimStrings{"client-2"} }},
+		{"other authorized party", key, "nonce-1", func(c *idTokenClaims) {
+			c.Audience, c.AuthorizedParty = jwt.ClaimStrings{"client-1", "client-2"}, "client-2"
+		}},
+		{"expired", key, "nonce-1", func(c *idTokenClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour)) }},
+		{"unknown key", other, "nonce-1", func(c *idTokenClaims) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.edit(c)
+			if _, err := v.verify(ctx, signIDToken(t, tt.key, c), tt.nonce); err == nil {
+				t.Errorf("verify accepted a token with %s", tt.name)
+			}
+		})
+	}
+	if _, err := v.verify(ctx, "", "nonce-1"); err == nil {
+		t.Errorf("verify accepted a missing token")
+	}
+
+	// unknown keys refresh the keys, at most every minKeysRefreshInterval
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+	v.keys.now = func() time.Time { return now.Add(minKeysRefreshInterval + time.Second) }
+	if _, err := v.verify(ctx, signIDToken(t, other, valid()), "nonce-1"); err == nil {
+		t.Errorf("verify accepted a token signed with an unknown key")
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
+
+func TestRemoteKeySetSharesFetches(t *testing.T) {
+	ctx := context.Background()
+	key, err := jwtUtils.GenerateKey(jwtUtils.AlgorithmRS256)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	var fetches atomic.Int32
+	fetching, release := make(chan struct{}, 1), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			select {
+			case fetching <- struct{}{}:
+			default:
+			}
+			<-release
+		}
+		json.NewEncoder(w).Encode(jwtUtils.JWKS{Keys: []jwtUtils.JWK{key.JWK()}})
+	}))
+	t.Cleanup(srv.Close)
+	ks := newRemoteKeySet(srv.URL)
+	ks.client = srv.Client()
+	if _, err := ks.key(ctx, key.ID); err != nil {
+		t.Fatalf("key: %v", err)
+	}
+
+	// tokens signed with an unknown key wait for a single fetch
+	now := time.Now()
+	ks.now = func() time.Time { return now.Add(minKeysRefreshInterval) }
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			if _, err := ks.key(ctx, "unknown"); !errors.Is(err, errUnknownProviderKey) {
+				t.Errorf("key(unknown) error = %v, want %v", err, errUnknownProviderKey)
+			}
+		})
+	}
+	<-fetching
+
+	// while the known keys are still served
+	done := make(chan error)
+	go func() {
+		_, err := ks.key(ctx, key.ID)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("key(known) during a fetch: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("key(known) waited for the fetch")
+	}
+	close(release)
+	wg.Wait()
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
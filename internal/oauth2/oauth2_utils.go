@@ -1,98 +1,223 @@
 package oauth2Utils
 
 import (
-	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
+	"strconv"
 	"time"
 
-	"github.com/rs/zerolog/log"
-
-	"github.com/sagarsuperuser/userprofile/server/settings"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
 const (
 	// __Host- cookies must be Secure, Path=/, and must not include Domain.
 	// https://developer.mozilla.org/en-US/docs/Web/API/Document/cookie#cookie_prefixes
-	OauthTempCookieName    = "__Host-oauth_tmp"
-	GoogleUserInfoEndpoint = "https://www.googleapis.com/oauth2/v3/userinfo"
+	OauthTempCookieName = "__Host-oauth_tmp"
+	OAuthLinkCookieName = "__Host-oauth_link"
+)
+
+var (
+	ErrInvalidOAuthTemp = errors.New("invalid or expired oauth cookie")
+	ErrStateMismatch    = errors.New("oauth state mismatch")
 )
 
-type oauthTemp struct {
+// OAuthTemp binds a sign-in started with a provider to the browser that started it.
+type OAuthTemp struct {
+	Provider string `json:"provider"`
 	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
 	Verifier string `json:"verifier"`
+	// LinkUserID is the signed in user who adds the provider to their account,
+	// 0 when signing in.
+	LinkUserID int64 `json:"link_user_id,omitempty"`
+	// ExpiresAt is a unix time, the cookie is only trusted until then.
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// PendingLink is a provider identity that is linked to the user with its email once
+// they log in with their password, since the email alone doesn't prove ownership.
+type PendingLink struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email"`
+	// ExpiresAt is a unix time, the cookie is only trusted until then.
+	ExpiresAt int64 `json:"expires_at"`
 }
 
 type ProviderUserInfoResp struct {
-	Sub   string `json:"sub"`
-	Email string `json:"email"`
-	Name  string `json:"name"`
+	Sub           string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// UnmarshalJSON accepts email_verified as a boolean or as the string "true" or
+// "false", which some providers send instead.
+func (r *ProviderUserInfoResp) UnmarshalJSON(data []byte) error {
+	type plain ProviderUserInfoResp
+	v := struct {
+		*plain
+		EmailVerified any `json:"email_verified"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch verified := v.EmailVerified.(type) {
+	case nil:
+		r.EmailVerified = false
+	case bool:
+		r.EmailVerified = verified
+	case string:
+		b, err := strconv.ParseBool(verified)
+		if err != nil {
+			return fmt.Errorf("invalid email_verified %q", verified)
+		}
+		r.EmailVerified = b
+	default:
+		return fmt.Errorf("invalid email_verified %v", verified)
+	}
+	return nil
 }
 
-// TODO: support multiple providers
-// NewOAuth2Config initializes a new OAuth2 Config for Google provider.
-func NewOAuth2Config(s *settings.Settings) *oauth2.Config {
-	u, err := url.Parse(s.OAuth2RedirectURL)
+// NewOAuthTemp returns random state, nonce and PKCE verifier for a sign-in with provider
+// started at now.
+func NewOAuthTemp(provider string, now time.Time, ttl time.Duration) (*OAuthTemp, error) {
+	state, err := randomString()
 	if err != nil {
-		log.Fatal().Err(err).Msg("OAuth2Config failed to parse redirect url")
+		return nil, err
 	}
-	return &oauth2.Config{
-		ClientID:     s.OAuth2ClientID,
-		ClientSecret: s.OAuth2ClientSecret,
-		RedirectURL:  u.String(),
-		Scopes:       []string{"openid", "email", "profile"},
-		Endpoint:     google.Endpoint,
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
 	}
+	return &OAuthTemp{
+		Provider:  provider,
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  oauth2.GenerateVerifier(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}, nil
 }
 
-func SetOAuthTempCookie(w http.ResponseWriter, state, verifier string, ttl time.Duration) error {
-	v := oauthTemp{
-		State:    state,
-		Verifier: verifier,
+// CheckState reports whether state is the one sent to the provider, in constant time.
+func (t *OAuthTemp) CheckState(state string) error {
+	if subtle.ConstantTimeCompare([]byte(t.State), []byte(state)) != 1 {
+		return ErrStateMismatch
 	}
+	return nil
+}
+
+// SetOAuthTempCookie stores temp in a cookie encrypted with a key derived from secret,
+// so that the browser can neither read the nonce and verifier nor forge them.
+func SetOAuthTempCookie(w http.ResponseWriter, secret string, temp *OAuthTemp, now time.Time) error {
+	return setSealedCookie(w, OauthTempCookieName, secret, temp, time.Unix(temp.ExpiresAt, 0).Sub(now))
+}
+
+// ReadOAuthTempCookie returns the OAuthTemp sealed by SetOAuthTempCookie,
+// ErrInvalidOAuthTemp if it was tampered with or has expired at now.
+func ReadOAuthTempCookie(r *http.Request, secret string, now time.Time) (*OAuthTemp, error) {
+	ret := new(OAuthTemp)
+	if err := readSealedCookie(r, OauthTempCookieName, secret, ret); err != nil {
+		return nil, err
+	}
+	if ret.State == "" || ret.Nonce == "" || ret.Verifier == "" || now.Unix() >= ret.ExpiresAt {
+		return nil, ErrInvalidOAuthTemp
+	}
+	return ret, nil
+}
+
+func ClearOAuthTempCookie(w http.ResponseWriter) {
+	clearCookie(w, OauthTempCookieName)
+}
+
+// SetPendingLinkCookie stores link in a cookie encrypted with a key derived from secret.
+func SetPendingLinkCookie(w http.ResponseWriter, secret string, link *PendingLink, now time.Time) error {
+	return setSealedCookie(w, OAuthLinkCookieName, secret, link, time.Unix(link.ExpiresAt, 0).Sub(now))
+}
+
+// ReadPendingLinkCookie returns the PendingLink sealed by SetPendingLinkCookie,
+// ErrInvalidOAuthTemp if it was tampered with or has expired at now.
+func ReadPendingLinkCookie(r *http.Request, secret string, now time.Time) (*PendingLink, error) {
+	ret := new(PendingLink)
+	if err := readSealedCookie(r, OAuthLinkCookieName, secret, ret); err != nil {
+		return nil, err
+	}
+	if ret.Provider == "" || ret.Subject == "" || now.Unix() >= ret.ExpiresAt {
+		return nil, ErrInvalidOAuthTemp
+	}
+	return ret, nil
+}
+
+func ClearPendingLinkCookie(w http.ResponseWriter) {
+	clearCookie(w, OAuthLinkCookieName)
+}
+
+// setSealedCookie stores v as JSON sealed with AES-GCM, the cookie name
+// is authenticated too so that one cookie can't be swapped for another.
+func setSealedCookie(w http.ResponseWriter, name, secret string, v any, maxAge time.Duration) error {
 	raw, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
+	aead, err := newCookieAEAD(secret)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := aead.Seal(nonce, nonce, raw, []byte(name))
+
 	http.SetCookie(w, &http.Cookie{
-		Name:     OauthTempCookieName,
-		Value:    base64.RawURLEncoding.EncodeToString(raw),
+		Name:     name,
+		Value:    base64.RawURLEncoding.EncodeToString(sealed),
 		Path:     "/",
 		HttpOnly: true,
 		Secure:   true, // end to end oauth2 flow only works for https
 		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(ttl.Seconds()),
+		MaxAge:   int(maxAge.Seconds()),
 	})
 	return nil
 }
 
-func ReadOAuthTempCookie(r *http.Request) (*oauthTemp, error) {
-	c, err := r.Cookie(OauthTempCookieName)
+func readSealedCookie(r *http.Request, name, secret string, v any) error {
+	c, err := r.Cookie(name)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	raw, err := base64.RawURLEncoding.DecodeString(c.Value)
+	sealed, err := base64.RawURLEncoding.DecodeString(c.Value)
 	if err != nil {
-		return nil, err
+		return ErrInvalidOAuthTemp
 	}
-	ret := new(oauthTemp)
-	if err := json.Unmarshal(raw, ret); err != nil {
-		return nil, err
+	aead, err := newCookieAEAD(secret)
+	if err != nil {
+		return err
 	}
-	if ret.State == "" || ret.Verifier == "" {
-		return nil, fmt.Errorf("invalid oauth temp cookie")
+	if len(sealed) < aead.NonceSize() {
+		return ErrInvalidOAuthTemp
 	}
-	return ret, nil
+	raw, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+	if err != nil {
+		return ErrInvalidOAuthTemp
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return ErrInvalidOAuthTemp
+	}
+	return nil
 }
 
-func ClearOAuthTempCookie(w http.ResponseWriter) {
+func clearCookie(w http.ResponseWriter, name string) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     OauthTempCookieName,
+		Name:     name,
 		Value:    "",
 		Path:     "/",
 		HttpOnly: true,
@@ -102,23 +227,21 @@ func ClearOAuthTempCookie(w http.ResponseWriter) {
 	})
 }
 
-// client underlying HTTP transport is responsible for adding access token to request.
-func FetchProviderUserInfo(ctx context.Context, client *http.Client) (*ProviderUserInfoResp, error) {
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, GoogleUserInfoEndpoint, nil)
-	resp, err := client.Do(req)
+// newCookieAEAD derives the cookie key from secret, distinct from the key
+// that encrypts the signing keys.
+func newCookieAEAD(secret string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(OauthTempCookieName + ":" + secret))
+	block, err := aes.NewCipher(sum[:])
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Userinfo status %d", resp.StatusCode)
-	}
-	var user ProviderUserInfoResp
-	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return nil, err
-	}
-	if user.Email == "" {
-		return nil, fmt.Errorf("Missing email")
+	return cipher.NewGCM(block)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-	return &user, nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
@@ -0,0 +1,111 @@
+package oauth2Utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// now is the fixed time the cookies are created and read at.
+var now = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// roundTrip sets temp in a cookie and returns the request sending it back.
+func roundTrip(t *testing.T, temp *OAuthTemp, secret string) *http.Request {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if err := SetOAuthTempCookie(rec, secret, temp, now); err != nil {
+		t.Fatalf("SetOAuthTempCookie: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/google/callback", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestOAuthTempCookie(t *testing.T) {
+	temp, err := NewOAuthTemp(ProviderGoogle, now, time.Minute)
+	if err != nil {
+		t.Fatalf("NewOAuthTemp: %v", err)
+	}
+	other, _ := NewOAuthTemp(ProviderGoogle, now, time.Minute)
+	if temp.State == other.State || temp.Nonce == other.Nonce || temp.State == temp.Nonce {
+		t.Fatalf("state and nonce must be random")
+	}
+
+	got, err := ReadOAuthTempCookie(roundTrip(t, temp, "secret"), "secret", now)
+	if err != nil {
+		t.Fatalf("ReadOAuthTempCookie: %v", err)
+	}
+	if *got != *temp {
+		t.Errorf("ReadOAuthTempCookie = %+v, want %+v", got, temp)
+	}
+	if err := got.CheckState(temp.State); err != nil {
+		t.Errorf("CheckState: %v", err)
+	}
+	if err := got.CheckState(other.State); !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("CheckState(other) error = %v, want %v", err, ErrStateMismatch)
+	}
+
+	// the cookie can't be read with another secret
+	if _, err := ReadOAuthTempCookie(roundTrip(t, temp, "secret"), "other", now); !errors.Is(err, ErrInvalidOAuthTemp) {
+		t.Errorf("ReadOAuthTempCookie(other secret) error = %v, want %v", err, ErrInvalidOAuthTemp)
+	}
+
+	// nor changed
+	req := roundTrip(t, temp, "secret")
+	c, _ := req.Cookie(OauthTempCookieName)
+	tampered := httptest.NewRequest(http.MethodGet, "/", nil)
+	tampered.AddCookie(&http.Cookie{Name: OauthTempCookieName, Value: c.Value[:len(c.Value)-2] + "AA"})
+	if _, err := ReadOAuthTempCookie(tampered, "secret", now); !errors.Is(err, ErrInvalidOAuthTemp) {
+		t.Errorf("ReadOAuthTempCookie(tampered) error = %v, want %v", err, ErrInvalidOAuthTemp)
+	}
+
+	// nor used once expired
+	req = roundTrip(t, temp, "secret")
+	if _, err := ReadOAuthTempCookie(req, "secret", now.Add(time.Minute-time.Second)); err != nil {
+		t.Errorf("ReadOAuthTempCookie(before expiry): %v", err)
+	}
+	if _, err := ReadOAuthTempCookie(req, "secret", now.Add(time.Minute)); !errors.Is(err, ErrInvalidOAuthTemp) {
+		t.Errorf("ReadOAuthTempCookie(expired) error = %v, want %v", err, ErrInvalidOAuthTemp)
+	}
+}
+
+func TestPendingLinkCookie(t *testing.T) {
+	link := &PendingLink{
+		Provider:  ProviderGitHub,
+		Subject:   "42",
+		Email:     "a@example.com",
+		ExpiresAt: now.Add(time.Minute).Unix(),
+	}
+	rec := httptest.NewRecorder()
+	if err := SetPendingLinkCookie(rec, "secret", link, now); err != nil {
+		t.Fatalf("SetPendingLinkCookie: %v", err)
+	}
+	c := rec.Result().Cookies()[0]
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	req.AddCookie(c)
+	got, err := ReadPendingLinkCookie(req, "secret", now)
+	if err != nil {
+		t.Fatalf("ReadPendingLinkCookie: %v", err)
+	}
+	if *got != *link {
+		t.Errorf("ReadPendingLinkCookie = %+v, want %+v", got, link)
+	}
+	if c.MaxAge != 60 {
+		t.Errorf("cookie MaxAge = %d, want 60", c.MaxAge)
+	}
+	if _, err := ReadPendingLinkCookie(req, "secret", now.Add(time.Minute)); !errors.Is(err, ErrInvalidOAuthTemp) {
+		t.Errorf("ReadPendingLinkCookie(expired) error = %v, want %v", err, ErrInvalidOAuthTemp)
+	}
+
+	// the value of one cookie is rejected under the name of the other
+	swapped := httptest.NewRequest(http.MethodGet, "/", nil)
+	swapped.AddCookie(&http.Cookie{Name: OauthTempCookieName, Value: c.Value})
+	if _, err := ReadOAuthTempCookie(swapped, "secret", now); !errors.Is(err, ErrInvalidOAuthTemp) {
+		t.Errorf("ReadOAuthTempCookie(pending link) error = %v, want %v", err, ErrInvalidOAuthTemp)
+	}
+}
@@ -0,0 +1,359 @@
+package oauth2Utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"golang.org/x/oauth2/google"
+
+	"github.com/sagarsuperuser/userprofile/server/settings"
+)
+
+const (
+	ProviderGoogle = "google"
+	ProviderGitHub = "github"
+
+	GoogleUserInfoEndpoint = "https://www.googleapis.com/oauth2/v3/userinfo"
+	GoogleJWKSURL          = "https://www.googleapis.com/oauth2/v3/certs"
+	GitHubAPIURL           = "https://api.github.com"
+
+	// providerTimeout bounds the discovery and userinfo requests made to providers.
+	providerTimeout = 10 * time.Second
+)
+
+// providerNamePattern is what a provider name must look like, it is part of
+// the login URLs and stored in auth_identities.provider.
+var providerNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// reservedProviderNames can't be used to name the generic OpenID Connect provider.
+var reservedProviderNames = []string{"local", ProviderGoogle, ProviderGitHub}
+
+// ValidProviderName reports whether name can name an identity provider.
+func ValidProviderName(name string) bool {
+	return providerNamePattern.MatchString(name)
+}
+
+// Provider is an external identity provider users sign in with.
+type Provider struct {
+	// Name identifies the provider in URLs and in the identities of users.
+	Name        string
+	DisplayName string
+	Config      *oauth2.Config
+	// idToken verifies the ID tokens of OpenID Connect providers, nil for plain OAuth 2.0 ones.
+	idToken *idTokenVerifier
+	// fetchUser returns the signed in user, client adds the access token to requests.
+	fetchUser func(ctx context.Context, client *http.Client) (*ProviderUserInfoResp, error)
+}
+
+// AuthCodeURL returns the URL of the provider's consent page for the sign-in temp.
+func (p *Provider) AuthCodeURL(temp *OAuthTemp) string {
+	opts := []oauth2.AuthCodeOption{oauth2.AccessTypeOnline, oauth2.S256ChallengeOption(temp.Verifier)}
+	if p.idToken != nil {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", temp.Nonce))
+	}
+	return p.Config.AuthCodeURL(temp.State, opts...)
+}
+
+// Exchange trades code for tokens and returns the signed in user. The ID token of
+// OpenID Connect providers must be valid, carry the nonce of temp and name the same
+// subject as the userinfo endpoint.
+func (p *Provider) Exchange(ctx context.Context, code string, temp *OAuthTemp) (*ProviderUserInfoResp, error) {
+	ctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+
+	tok, err := p.Config.Exchange(ctx, code, oauth2.VerifierOption(temp.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange call failed: %w", err)
+	}
+	var subject string
+	if p.idToken != nil {
+		raw, _ := tok.Extra("id_token").(string)
+		claims, err := p.idToken.verify(ctx, raw, temp.Nonce)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id token: %w", err)
+		}
+		subject = claims.Subject
+	}
+
+	user, err := p.fetchUser(ctx, p.Config.Client(ctx, tok))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch userinfo: %w", err)
+	}
+	if user.Sub == "" || user.Email == "" {
+		return nil, errors.New("missing subject or email")
+	}
+	// OpenID Connect Core 1.0 section 5.3.2
+	if p.idToken != nil && user.Sub != subject {
+		return nil, errors.New("userinfo subject does not match the id token")
+	}
+	return user, nil
+}
+
+// Registry holds the identity providers enabled in the settings.
+type Registry struct {
+	providers []*Provider
+}
+
+// NewRegistry returns the providers whose client id is set, in the order google,
+// github, then the generic OpenID Connect provider. Providers that are misconfigured,
+// or whose discovery document can't be read, are logged and left out.
+func NewRegistry(ctx context.Context, s *settings.Settings) *Registry {
+	r := &Registry{}
+	add := func(name string, p *Provider, err error) {
+		if err != nil {
+			log.Error().Err(err).Str("provider", name).Msg("Identity provider disabled")
+			return
+		}
+		r.providers = append(r.providers, p)
+	}
+
+	if s.OAuth2ClientID != "" {
+		p, err := newGoogleProvider(s)
+		add(ProviderGoogle, p, err)
+	}
+	if s.OAuth2GitHubClientID != "" {
+		p, err := newGitHubProvider(s)
+		add(ProviderGitHub, p, err)
+	}
+	if s.OAuth2OIDCClientID != "" {
+		p, err := newOIDCProvider(ctx, s)
+		add(s.OAuth2OIDCName, p, err)
+	}
+	return r
+}
+
+// Get returns the enabled provider called name.
+func (r *Registry) Get(name string) (*Provider, bool) {
+	for _, p := range r.providers {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// List returns the enabled providers.
+func (r *Registry) List() []*Provider {
+	return r.providers
+}
+
+// redirectURL returns configured, or the frontend callback of the provider name
+// when it is empty.
+func redirectURL(s *settings.Settings, name, configured string) (string, error) {
+	if configured == "" {
+		configured = strings.TrimSuffix(s.OIDCIssuer, "/") + "/ui/oauth2/" + name + "/callback"
+	}
+	u, err := url.Parse(configured)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect url: %w", err)
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return "", fmt.Errorf("redirect url %q is not absolute", configured)
+	}
+	return u.String(), nil
+}
+
+func newGoogleProvider(s *settings.Settings) (*Provider, error) {
+	redirect, err := redirectURL(s, ProviderGoogle, s.OAuth2RedirectURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		Name:        ProviderGoogle,
+		DisplayName: "Google",
+		// https://developers.google.com/identity/openid-connect/openid-connect#validatinganidtoken
+		idToken: &idTokenVerifier{
+			issuers:  []string{"https://accounts.google.com", "accounts.google.com"},
+			clientID: s.OAuth2ClientID,
+			keys:     newRemoteKeySet(GoogleJWKSURL),
+		},
+		Config: &oauth2.Config{
+			ClientID:     s.OAuth2ClientID,
+			ClientSecret: s.OAuth2ClientSecret,
+			RedirectURL:  redirect,
+			Scopes:       []string{"openid", "email", "profile"},
+			Endpoint:     google.Endpoint,
+		},
+		fetchUser: func(ctx context.Context, client *http.Client) (*ProviderUserInfoResp, error) {
+			return fetchUserInfo(ctx, client, GoogleUserInfoEndpoint)
+		},
+	}, nil
+}
+
+func newGitHubProvider(s *settings.Settings) (*Provider, error) {
+	redirect, err := redirectURL(s, ProviderGitHub, s.OAuth2GitHubRedirectURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		Name:        ProviderGitHub,
+		DisplayName: "GitHub",
+		Config: &oauth2.Config{
+			ClientID:     s.OAuth2GitHubClientID,
+			ClientSecret: s.OAuth2GitHubClientSecret,
+			RedirectURL:  redirect,
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint:     github.Endpoint,
+		},
+		fetchUser: func(ctx context.Context, client *http.Client) (*ProviderUserInfoResp, error) {
+			return fetchGitHubUser(ctx, client, GitHubAPIURL)
+		},
+	}, nil
+}
+
+// discoveryDocument holds the fields of an OpenID Connect discovery document we use.
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func newOIDCProvider(ctx context.Context, s *settings.Settings) (*Provider, error) {
+	name := s.OAuth2OIDCName
+	if !ValidProviderName(name) {
+		return nil, fmt.Errorf("invalid provider name %q", name)
+	}
+	for _, reserved := range reservedProviderNames {
+		if name == reserved {
+			return nil, fmt.Errorf("provider name %q is reserved", name)
+		}
+	}
+	if s.OAuth2OIDCIssuer == "" {
+		return nil, errors.New("issuer is required")
+	}
+	redirect, err := redirectURL(s, name, s.OAuth2OIDCRedirectURL)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := discover(ctx, http.DefaultClient, s.OAuth2OIDCIssuer)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := s.OAuth2OIDCDisplayName
+	if displayName == "" {
+		displayName = name
+	}
+	// without openid there is no ID token to verify
+	scopes := s.OAuth2OIDCScopes
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	return &Provider{
+		Name:        name,
+		DisplayName: displayName,
+		idToken: &idTokenVerifier{
+			issuers:  []string{doc.Issuer},
+			clientID: s.OAuth2OIDCClientID,
+			keys:     newRemoteKeySet(doc.JWKSURI),
+		},
+		Config: &oauth2.Config{
+			ClientID:     s.OAuth2OIDCClientID,
+			ClientSecret: s.OAuth2OIDCClientSecret,
+			RedirectURL:  redirect,
+			Scopes:       scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  doc.AuthorizationEndpoint,
+				TokenURL: doc.TokenEndpoint,
+			},
+		},
+		fetchUser: func(ctx context.Context, client *http.Client) (*ProviderUserInfoResp, error) {
+			return fetchUserInfo(ctx, client, doc.UserInfoEndpoint)
+		},
+	}, nil
+}
+
+// discover reads the discovery document of issuer (OpenID Connect Discovery 1.0 section 4).
+func discover(ctx context.Context, client *http.Client, issuer string) (*discoveryDocument, error) {
+	ctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+
+	issuer = strings.TrimSuffix(issuer, "/")
+	var doc discoveryDocument
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery document is for issuer %q, want %q", doc.Issuer, issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.UserInfoEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("discovery document lacks the authorization, token, userinfo endpoint or jwks_uri")
+	}
+	return &doc, nil
+}
+
+// fetchUserInfo calls the OpenID Connect userinfo endpoint.
+func fetchUserInfo(ctx context.Context, client *http.Client, endpoint string) (*ProviderUserInfoResp, error) {
+	var user ProviderUserInfoResp
+	if err := getJSON(ctx, client, endpoint, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// fetchGitHubUser reads the GitHub user and its primary email, GitHub has no
+// userinfo endpoint and the email of the profile may be hidden or unverified.
+func fetchGitHubUser(ctx context.Context, client *http.Client, apiURL string) (*ProviderUserInfoResp, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, client, apiURL+"/user", &user); err != nil {
+		return nil, err
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, apiURL+"/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	ret := &ProviderUserInfoResp{
+		Sub:  strconv.FormatInt(user.ID, 10),
+		Name: user.Name,
+	}
+	if ret.Name == "" {
+		ret.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			ret.Email, ret.EmailVerified = e.Email, e.Verified
+		}
+	}
+	return ret, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
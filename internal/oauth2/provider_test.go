@@ -0,0 +1,128 @@
+package oauth2Utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sagarsuperuser/userprofile/server/settings"
+)
+
+func TestDiscover(t *testing.T) {
+	var issuer string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"userinfo_endpoint":      issuer + "/userinfo",
+			"jwks_uri":               issuer + "/jwks",
+		})
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	issuer = srv.URL
+	doc, err := discover(ctx, srv.Client(), srv.URL+"/")
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if doc.TokenEndpoint != srv.URL+"/token" || doc.UserInfoEndpoint != srv.URL+"/userinfo" {
+		t.Errorf("discover = %+v", doc)
+	}
+
+	// the document must be about the configured issuer
+	issuer = "https://evil.example.com"
+	if _, err := discover(ctx, srv.Client(), srv.URL); err == nil {
+		t.Errorf("discover accepted the document of another issuer")
+	}
+}
+
+func TestFetchUserInfo(t *testing.T) {
+	var verified any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"sub": "42", "email": "jane@example.com", "email_verified": verified, "name": "Jane"})
+	}))
+	defer srv.Close()
+
+	// some providers send email_verified as a string
+	for _, tc := range []struct {
+		verified any
+		want     bool
+	}{
+		{true, true},
+		{false, false},
+		{"true", true},
+		{"false", false},
+		{nil, false},
+	} {
+		verified = tc.verified
+		user, err := fetchUserInfo(context.Background(), srv.Client(), srv.URL)
+		if err != nil {
+			t.Fatalf("fetchUserInfo(email_verified %#v): %v", tc.verified, err)
+		}
+		if user.Sub != "42" || user.Email != "jane@example.com" || user.Name != "Jane" || user.EmailVerified != tc.want {
+			t.Errorf("fetchUserInfo(email_verified %#v) = %+v, want verified %v", tc.verified, user, tc.want)
+		}
+	}
+
+	verified = "yes please"
+	if _, err := fetchUserInfo(context.Background(), srv.Client(), srv.URL); err == nil {
+		t.Errorf("fetchUserInfo accepted email_verified %q", verified)
+	}
+}
+
+func TestFetchGitHubUser(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			json.NewEncoder(w).Encode(map[string]any{"id": 42, "login": "jane", "name": ""})
+		case "/user/emails":
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"email": "old@example.com", "primary": false, "verified": true},
+				{"email": "jane@example.com", "primary": true, "verified": true},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	user, err := fetchGitHubUser(context.Background(), srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("fetchGitHubUser: %v", err)
+	}
+	if user.Sub != "42" || user.Email != "jane@example.com" || !user.EmailVerified || user.Name != "jane" {
+		t.Errorf("fetchGitHubUser = %+v", user)
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	s := &settings.Settings{
+		OIDCIssuer:           "https://id.example.com",
+		OAuth2ClientID:       "google-client",
+		OAuth2RedirectURL:    "://bad",
+		OAuth2GitHubClientID: "github-client",
+		OAuth2OIDCClientID:   "oidc-client",
+		OAuth2OIDCName:       "github",
+	}
+	r := NewRegistry(context.Background(), s)
+
+	// google has a bad redirect url and the oidc provider a reserved name
+	if list := r.List(); len(list) != 1 || list[0].Name != ProviderGitHub {
+		t.Fatalf("List = %d providers, want only github", len(list))
+	}
+	p, ok := r.Get(ProviderGitHub)
+	if !ok || p.Config.RedirectURL != "https://id.example.com/ui/oauth2/github/callback" {
+		t.Errorf("Get(github) = %v", p)
+	}
+	if _, ok := r.Get(ProviderGoogle); ok {
+		t.Errorf("Get(google) found a disabled provider")
+	}
+}
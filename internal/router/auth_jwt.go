@@ -2,15 +2,27 @@ package router
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/sagarsuperuser/userprofile/errdefs"
 	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+var (
+	ErrServiceAccountNotAllowed = errors.New("service accounts are not allowed to access this resource")
+	ErrInsufficientScope        = errors.New("the access token lacks the scopes required by this resource")
 )
 
 type userIDContextKey struct{}
 
-// UserIDFromContext retrieves user info from context.
+type accessTokenContextKey struct{}
+
+// UserIDFromContext retrieves the id of the authenticated user from context,
+// it is set by both AuthJWT and AuthSession. It is 0 for service accounts.
 func UserIDFromContext(ctx context.Context) int64 {
 	if ctx == nil {
 		return 0
@@ -21,25 +33,128 @@ func UserIDFromContext(ctx context.Context) int64 {
 	return 0
 }
 
+// AccessTokenClaimsFromContext retrieves the claims of the access token
+// checked by AuthJWT, nil for session authenticated requests.
+func AccessTokenClaimsFromContext(ctx context.Context) *jwtUtils.ClaimsMessage {
+	if ctx == nil {
+		return nil
+	}
+	if val := ctx.Value(accessTokenContextKey{}); val != nil {
+		return val.(*jwtUtils.ClaimsMessage)
+	}
+	return nil
+}
+
 // AuthJWT wraps a route to enforce JWT auth.
-func AuthJWT(secret string) RouteWrapper {
+// Revoked tokens are rejected with 401, tokens of disabled users with 403.
+// Service accounts are only let through when scopes are given and their token
+// was granted all of them; handlers tell them apart with PrincipalFromContext.
+func AuthJWT(s *store.Store, keys *jwtUtils.KeySet, scopes ...string) RouteWrapper {
 	return func(route Route) Route {
 		return localRoute{
 			method: route.Method(),
 			path:   route.Path(),
 			handler: func(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
-				token, err := jwtUtils.ValidateAccessToken(req, secret)
+				token, err := jwtUtils.ValidateAccessToken(req, keys, s.Now())
 				if err != nil {
 					return errdefs.Unauthorized(jwtUtils.ErrJWTValidate)
 				}
+				claims, err := jwtUtils.GetClaimsFromToken(token)
+				if err != nil || claims.ID == "" {
+					// tokens without a jti could never be revoked
+					return errdefs.Unauthorized(jwtUtils.ErrJWTValidate)
+				}
+				revoked, err := s.IsAccessTokenRevoked(ctx, claims.ID)
+				if err != nil {
+					return errdefs.System(err)
+				}
+				if revoked {
+					return errdefs.Unauthorized(jwtUtils.ErrJWTRevoked)
+				}
+				ctx = context.WithValue(ctx, accessTokenContextKey{}, claims)
+
+				if serviceAccountID, ok := jwtUtils.GetServiceAccountIDFromToken(token); ok {
+					principal, err := serviceAccountPrincipal(ctx, s, serviceAccountID, claims, scopes)
+					if err != nil {
+						return err
+					}
+					ctx = context.WithValue(ctx, principalContextKey{}, principal)
+					return route.Handler()(ctx, rw, req, vars)
+				}
+
 				userID, err := jwtUtils.GetUserIDFromToken(token)
 				if err != nil {
 					return errdefs.Unauthorized(jwtUtils.ErrJWTUserIDNotFound)
 				}
+				if _, err := activeUser(ctx, s, userID); err != nil {
+					return err
+				}
 
-				ctx = context.WithValue(ctx, userIDContextKey{}, userID)
+				ctx = withUser(ctx, userID)
 				return route.Handler()(ctx, rw, req, vars)
 			},
 		}
 	}
 }
+
+// serviceAccountPrincipal checks that the service account behind a token still exists,
+// that its owner is active and that the token was granted scopes. The scopes of the
+// token are cut down to the current permissions of the owner, who may have been demoted.
+func serviceAccountPrincipal(ctx context.Context, s *store.Store, id string, claims *jwtUtils.ClaimsMessage, scopes []string) (*Principal, error) {
+	if len(scopes) == 0 {
+		return nil, errdefs.Forbidden(ErrServiceAccountNotAllowed)
+	}
+	account, err := s.GetServiceAccount(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrServiceAccountNotFound) {
+			return nil, errdefs.Unauthorized(err)
+		}
+		return nil, errdefs.System(err)
+	}
+	if _, err := activeUser(ctx, s, account.OwnerID); err != nil {
+		return nil, err
+	}
+	granted, err := s.UserPermissions(ctx, account.OwnerID)
+	if err != nil {
+		return nil, errdefs.System(err)
+	}
+
+	principal := &Principal{
+		Type:             PrincipalServiceAccount,
+		ServiceAccountID: account.ID,
+	}
+	for _, scope := range strings.Fields(claims.Scope) {
+		if slices.Contains(granted, store.Permission(scope)) {
+			principal.Scopes = append(principal.Scopes, scope)
+		}
+	}
+	if !principal.HasScopes(scopes...) {
+		return nil, errdefs.Forbidden(ErrInsufficientScope)
+	}
+	return principal, nil
+}
+
+// AuthSessionOrJWT wraps a route to accept either an access token or a session.
+// Requests with an Authorization header go through AuthJWT, or AuthPersonalAccessToken
+// for personal access tokens, others through AuthSession.
+// Handlers read the user with UserIDFromContext, scopes are passed to both token wrappers.
+func AuthSessionOrJWT(s *store.Store, keys *jwtUtils.KeySet, scopes ...string) RouteWrapper {
+	return func(route Route) Route {
+		jwtRoute := AuthJWT(s, keys, scopes...)(route)
+		patRoute := AuthPersonalAccessToken(s, scopes...)(route)
+		sessionRoute := AuthSession(s)(route)
+		return localRoute{
+			method: route.Method(),
+			path:   route.Path(),
+			handler: func(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+				if strings.HasPrefix(bearerToken(req), store.PersonalAccessTokenPrefix) {
+					return patRoute.Handler()(ctx, rw, req, vars)
+				}
+				if req.Header.Get("Authorization") != "" {
+					return jwtRoute.Handler()(ctx, rw, req, vars)
+				}
+				return sessionRoute.Handler()(ctx, rw, req, vars)
+			},
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+var ErrPersonalAccessTokenNotAllowed = errors.New("personal access tokens are not allowed to access this resource")
+
+// AuthPersonalAccessToken wraps a route to enforce personal access token auth,
+// read from the Authorization: Bearer header. Like service accounts, tokens are
+// only let through when scopes are given and the token was granted all of them.
+// Unknown, expired and revoked tokens are rejected with 401, tokens of disabled users with 403.
+func AuthPersonalAccessToken(s *store.Store, scopes ...string) RouteWrapper {
+	return func(route Route) Route {
+		return localRoute{
+			method: route.Method(),
+			path:   route.Path(),
+			handler: func(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+				pat, err := s.AuthenticatePersonalAccessToken(ctx, bearerToken(req))
+				if err != nil {
+					if errors.Is(err, store.ErrPersonalAccessTokenNotFound) {
+						return errdefs.Unauthorized(err)
+					}
+					return errdefs.System(err)
+				}
+				if len(scopes) == 0 {
+					return errdefs.Forbidden(ErrPersonalAccessTokenNotAllowed)
+				}
+				principal := &Principal{Type: PrincipalUser, UserID: pat.UserID, Scopes: pat.Scopes}
+				if !principal.HasScopes(scopes...) {
+					return errdefs.Forbidden(ErrInsufficientScope)
+				}
+				if _, err := activeUser(ctx, s, pat.UserID); err != nil {
+					return err
+				}
+
+				ctx = context.WithValue(ctx, principalContextKey{}, principal)
+				ctx = context.WithValue(ctx, userIDContextKey{}, pat.UserID)
+				return route.Handler()(ctx, rw, req, vars)
+			},
+		}
+	}
+}
+
+// bearerToken returns the token of an Authorization: Bearer header, or "".
+func bearerToken(req *http.Request) string {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
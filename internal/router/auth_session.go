@@ -5,7 +5,10 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/rs/zerolog/log"
+
 	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
 	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
 	"github.com/sagarsuperuser/userprofile/store"
 )
@@ -34,26 +37,66 @@ func readCookie(r *http.Request, name string) string {
 	return c.Value
 }
 
-// AuthSession wraps a route to enforce session authentication.
-func AuthSession(store *store.Store) RouteWrapper {
+// activeUser loads the user behind a session or token and rejects disabled users.
+func activeUser(ctx context.Context, s *store.Store, userID int64) (*store.UserInfo, error) {
+	user, err := s.GetUser(ctx, &store.FindUser{ID: &userID})
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return nil, errdefs.Unauthorized(err)
+		}
+		return nil, errdefs.System(err)
+	}
+	if user.Status == store.StatusDisabled {
+		return nil, errdefs.Forbidden(store.ErrUserDisabled)
+	}
+	return user, nil
+}
+
+// AuthenticateSession returns the active session behind the session cookie of req and
+// its user. Sessions are extended as they are used and the cookie is reissued with the
+// new expiry. Sessions of disabled users are rejected with 403.
+func AuthenticateSession(ctx context.Context, s *store.Store, rw http.ResponseWriter, req *http.Request) (*store.SessionInfo, *store.UserInfo, error) {
+	token := readCookie(req, sessionUtils.SessionCookieName)
+	if token == "" {
+		return nil, nil, errdefs.Unauthorized(sessionUtils.ErrSessionCookieNotFound)
+	}
+	sess, err := s.GetActiveSessionByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, sessionUtils.ErrSesssionExpired) {
+			return nil, nil, errdefs.Unauthorized(err)
+		}
+		return nil, nil, errdefs.System(err)
+	}
+	user, err := activeUser(ctx, s, sess.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshed, err := s.RefreshSession(ctx, sess, httputil.ClientIP(req))
+	if err != nil {
+		// the session is still valid until its current expiry
+		log.Warn().Err(err).Int64("session_id", sess.ID).Msg("Failed to refresh session")
+	} else if !refreshed.ExpiresAt.Equal(sess.ExpiresAt) {
+		// keep the cookie alive as long as the session
+		sessionUtils.SetSessionCookie(rw, req, refreshed.ExpiresAt, token)
+		sess = refreshed
+	}
+	return sess, user, nil
+}
+
+// AuthSession wraps a route to enforce session authentication, see AuthenticateSession.
+func AuthSession(s *store.Store) RouteWrapper {
 	return func(route Route) Route {
 		return localRoute{
 			method: route.Method(),
 			path:   route.Path(),
 			handler: func(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
-				token := readCookie(req, sessionUtils.SessionCookieName)
-				if token == "" {
-					return errdefs.Unauthorized(sessionUtils.ErrSessionCookieNotFound)
-				}
-				sess, err := store.GetActiveSessionByToken(ctx, token)
+				sess, _, err := AuthenticateSession(ctx, s, rw, req)
 				if err != nil {
-					if errors.Is(err, sessionUtils.ErrSesssionExpired) {
-						return errdefs.Unauthorized(err)
-					}
-					return errdefs.System(err)
+					return err
 				}
 
 				ctx = context.WithValue(ctx, sessionContextKey{}, sess)
+				ctx = withUser(ctx, sess.UserID)
 				return route.Handler()(ctx, rw, req, vars)
 			},
 		}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"context"
+	"slices"
+)
+
+// PrincipalType tells human users from machine callers.
+type PrincipalType string
+
+const (
+	PrincipalUser           PrincipalType = "user"
+	PrincipalServiceAccount PrincipalType = "service_account"
+)
+
+// Principal is who a request is authenticated as.
+type Principal struct {
+	Type PrincipalType
+	// UserID is set for users.
+	UserID int64
+	// ServiceAccountID is set for service accounts.
+	ServiceAccountID string
+	// Scopes granted to the access token of a service account or to the
+	// personal access token of a user, other users are governed by their role instead.
+	Scopes []string
+}
+
+// IsServiceAccount reports whether the request comes from a service account.
+func (p *Principal) IsServiceAccount() bool {
+	return p.Type == PrincipalServiceAccount
+}
+
+// HasScopes reports whether the principal was granted all of scopes.
+func (p *Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+type principalContextKey struct{}
+
+// PrincipalFromContext retrieves the principal of the request from context,
+// it is set by AuthJWT, AuthPersonalAccessToken and AuthSession.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	if ctx == nil {
+		return nil
+	}
+	if val := ctx.Value(principalContextKey{}); val != nil {
+		return val.(*Principal)
+	}
+	return nil
+}
+
+// withUser sets a user principal and the user id in ctx.
+func withUser(ctx context.Context, userID int64) context.Context {
+	ctx = context.WithValue(ctx, principalContextKey{}, &Principal{Type: PrincipalUser, UserID: userID})
+	return context.WithValue(ctx, userIDContextKey{}, userID)
+}
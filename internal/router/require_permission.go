@@ -0,0 +1,65 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+var ErrMissingPermission = errors.New("you don't have the permissions required by this resource")
+
+// RequirePermission wraps a route to only let principals holding all of permissions through.
+// Users get the permissions of their role, service accounts those matching the scopes of
+// their token. It reads the principal, so it must be listed before the authentication wrapper
+// when creating the route (wrappers run last to first).
+func RequirePermission(s *store.Store, permissions ...store.Permission) RouteWrapper {
+	return func(route Route) Route {
+		return localRoute{
+			method: route.Method(),
+			path:   route.Path(),
+			handler: func(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+				principal := PrincipalFromContext(ctx)
+				if principal == nil {
+					return errdefs.System(errors.New("principal not found in context"))
+				}
+				allowed, err := HasPermissions(ctx, s, principal, permissions...)
+				if err != nil {
+					return errdefs.System(err)
+				}
+				if !allowed {
+					return errdefs.Forbidden(ErrMissingPermission)
+				}
+
+				return route.Handler()(ctx, rw, req, vars)
+			},
+		}
+	}
+}
+
+// HasPermissions reports whether principal holds all of permissions,
+// for handlers that need finer checks than RequirePermission.
+func HasPermissions(ctx context.Context, s *store.Store, principal *Principal, permissions ...store.Permission) (bool, error) {
+	if principal.IsServiceAccount() {
+		for _, permission := range permissions {
+			if !principal.HasScopes(string(permission)) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	granted, err := s.UserPermissions(ctx, principal.UserID)
+	if err != nil {
+		return false, err
+	}
+	for _, permission := range permissions {
+		if !slices.Contains(granted, permission) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -9,7 +9,6 @@ import (
 )
 
 const (
-	SessionDuration   = 24 * time.Hour
 	SessionCookieName = "sid"
 )
 
@@ -0,0 +1,357 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	oauth2Utils "github.com/sagarsuperuser/userprofile/internal/oauth2"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const maxAdminListLimit = 200
+
+type UpdateRoleRequest struct {
+	Role store.Role `json:"role"`
+}
+
+func (update UpdateRoleRequest) Validate() error {
+	if !roleNamePattern.MatchString(string(update.Role)) {
+		return fmt.Errorf("invalid role %q", string(update.Role))
+	}
+	return nil
+}
+
+type UpdateStatusRequest struct {
+	Status store.UserStatus `json:"status"`
+}
+
+func (update UpdateStatusRequest) Validate() error {
+	switch update.Status {
+	case store.StatusActive, store.StatusDisabled:
+		return nil
+	default:
+		return fmt.Errorf("invalid status %q", update.Status)
+	}
+}
+
+type UserListResp struct {
+	Users []*UserResp `json:"users"`
+	// NextCursor fetches the next page, empty on the last page.
+	NextCursor string `json:"next_cursor"`
+}
+
+// ListUsers godoc
+//
+//	@Summary	List users
+//	@Tags		admin
+//	@Produce	json
+//	@Param		email			query		string	false	"Exact email"
+//	@Param		email_prefix	query		string	false	"Email prefix, case-insensitive"
+//	@Param		name_prefix		query		string	false	"Full name prefix, case-insensitive"
+//	@Param		role			query		string	false	"Role"
+//	@Param		status			query		string	false	"Status"
+//	@Param		provider		query		string	false	"Linked identity provider"
+//	@Param		created_after	query		string	false	"RFC 3339 time"
+//	@Param		created_before	query		string	false	"RFC 3339 time"
+//	@Param		updated_after	query		string	false	"RFC 3339 time"
+//	@Param		updated_before	query		string	false	"RFC 3339 time"
+//	@Param		sort			query		string	false	"created_desc (default), created_asc, updated_desc, updated_asc, email_asc or email_desc"
+//	@Param		cursor			query		string	false	"next_cursor of the previous page"
+//	@Param		limit			query		int		false	"Page size, 50 by default"
+//	@Success	200				{object}	UserListResp	"Users"
+//	@Failure	400				{object}	nil				"Invalid filter or cursor"
+//	@Failure	403				{object}	nil				"Not an admin"
+//	@Router		/v1/admin/users [GET]
+func (s *APIV1Service) ListUsers(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	find, err := parseAdminFindUser(req)
+	if err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+
+	page, err := s.Store.ListUsersPage(ctx, find)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) || errors.Is(err, store.ErrInvalidSort) {
+			return errdefs.InvalidParameter(err)
+		}
+		return errdefs.System(fmt.Errorf("failed to list users: %w", err))
+	}
+
+	resp := &UserListResp{Users: make([]*UserResp, 0, len(page.Users)), NextCursor: page.NextCursor}
+	for _, user := range page.Users {
+		resp.Users = append(resp.Users, newUserResp(user))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
+}
+
+// GetUser godoc
+//
+//	@Summary	Get a user
+//	@Tags		admin
+//	@Produce	json
+//	@Success	200	{object}	UserResp	"User"
+//	@Failure	404	{object}	nil			"User not found"
+//	@Router		/v1/admin/users/{id} [GET]
+func (s *APIV1Service) GetUser(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	userID, err := userIDFromVars(vars)
+	if err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+
+	user, err := s.Store.GetUser(ctx, &store.FindUser{ID: &userID})
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return errdefs.NotFound(err)
+		}
+		return errdefs.System(err)
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, newUserResp(user))
+}
+
+// UpdateUserRole godoc
+//
+//	@Summary	Change the role of a user
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Success	200	{object}	UserResp	"Updated user"
+//	@Failure	400	{object}	nil			"Unknown role"
+//	@Failure	403	{object}	nil			"The caller lacks permissions of the current or new role"
+//	@Failure	404	{object}	nil			"User not found"
+//	@Failure	409	{object}	nil			"Admins can't change their own role"
+//	@Router		/v1/admin/users/{id}/role [PUT]
+func (s *APIV1Service) UpdateUserRole(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	userID, err := userIDFromVars(vars)
+	if err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	roleReq := UpdateRoleRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&roleReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if err := roleReq.Validate(); err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	if err := checkNotSelf(ctx, userID, "change your own role"); err != nil {
+		return err
+	}
+	if _, err := s.manageableUser(ctx, userID); err != nil {
+		return err
+	}
+	// nobody can hand out more than they are allowed to do themselves either.
+	if err := s.checkCanAssignRole(ctx, roleReq.Role); err != nil {
+		return err
+	}
+
+	return s.adminUpdateUser(ctx, rw, &store.UpdateUser{ID: userID, Role: &roleReq.Role})
+}
+
+// UpdateUserStatus godoc
+//
+//	@Summary	Enable or disable a user
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Success	200	{object}	UserResp	"Updated user"
+//	@Failure	403	{object}	nil			"The caller lacks permissions of the user's role"
+//	@Failure	404	{object}	nil			"User not found"
+//	@Failure	409	{object}	nil			"Admins can't change their own status"
+//	@Router		/v1/admin/users/{id}/status [PUT]
+func (s *APIV1Service) UpdateUserStatus(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	userID, err := userIDFromVars(vars)
+	if err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	statusReq := UpdateStatusRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&statusReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if err := statusReq.Validate(); err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	if err := checkNotSelf(ctx, userID, "change your own status"); err != nil {
+		return err
+	}
+	if _, err := s.manageableUser(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.adminUpdateUser(ctx, rw, &store.UpdateUser{ID: userID, Status: &statusReq.Status})
+}
+
+// DeleteUser godoc
+//
+//	@Summary	Delete a user
+//	@Tags		admin
+//	@Success	204	{object}	nil	"User deleted"
+//	@Failure	403	{object}	nil	"The caller lacks permissions of the user's role"
+//	@Failure	404	{object}	nil	"User not found"
+//	@Failure	409	{object}	nil	"Admins can't delete themselves"
+//	@Router		/v1/admin/users/{id} [DELETE]
+func (s *APIV1Service) DeleteUser(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	userID, err := userIDFromVars(vars)
+	if err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	if err := checkNotSelf(ctx, userID, "delete yourself"); err != nil {
+		return err
+	}
+	if _, err := s.manageableUser(ctx, userID); err != nil {
+		return err
+	}
+
+	deleted, err := s.Store.DeleteUser(ctx, &store.DeleteUser{ID: userID})
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to delete user: %w", err))
+	}
+	if !deleted {
+		return errdefs.NotFound(store.ErrUserNotFound)
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// GetCacheStats godoc
+//
+//	@Summary	Get store cache counters
+//	@Tags		admin
+//	@Produce	json
+//	@Success	200	{object}	store.CacheStats	"Hits, misses and evictions per cache"
+//	@Router		/v1/admin/stats/cache [GET]
+func (s *APIV1Service) GetCacheStats(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	return httputil.WriteRawJSON(rw, http.StatusOK, s.Store.CacheStats())
+}
+
+// manageableUser returns the user with userID if the caller has every permission of
+// its role: nobody can disable, delete or demote a user more privileged than them.
+func (s *APIV1Service) manageableUser(ctx context.Context, userID int64) (*store.UserInfo, error) {
+	user, err := s.Store.GetUser(ctx, &store.FindUser{ID: &userID})
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return nil, errdefs.NotFound(err)
+		}
+		return nil, errdefs.System(err)
+	}
+	if err := s.checkCanAssignRole(ctx, user.Role); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *APIV1Service) adminUpdateUser(ctx context.Context, rw http.ResponseWriter, update *store.UpdateUser) error {
+	user, err := s.Store.UpdateUser(ctx, update)
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return errdefs.NotFound(err)
+		}
+		if errors.Is(err, store.ErrRoleNotFound) {
+			return errdefs.InvalidParameter(err)
+		}
+		return errdefs.System(fmt.Errorf("failed to update user: %w", err))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, newUserResp(user))
+}
+
+func parseAdminFindUser(req *http.Request) (*store.FindUser, error) {
+	q := req.URL.Query()
+	find := &store.FindUser{}
+
+	if v := q.Get("email"); v != "" {
+		find.Email = &v
+	}
+	if v := q.Get("email_prefix"); v != "" {
+		find.EmailPrefix = &v
+	}
+	if v := q.Get("name_prefix"); v != "" {
+		find.NamePrefix = &v
+	}
+	if v := q.Get("role"); v != "" {
+		role := store.Role(v)
+		if err := (UpdateRoleRequest{Role: role}).Validate(); err != nil {
+			return nil, err
+		}
+		find.Role = &role
+	}
+	if v := q.Get("status"); v != "" {
+		status := store.UserStatus(v)
+		if err := (UpdateStatusRequest{Status: status}).Validate(); err != nil {
+			return nil, err
+		}
+		find.Status = &status
+	}
+	if v := q.Get("provider"); v != "" {
+		provider := store.Provider(v)
+		// identities of providers that were disabled since can still be found.
+		if !oauth2Utils.ValidProviderName(v) {
+			return nil, fmt.Errorf("invalid provider %q", v)
+		}
+		find.Provider = &provider
+	}
+
+	for name, dst := range map[string]**time.Time{
+		"created_after":  &find.CreatedAfter,
+		"created_before": &find.CreatedBefore,
+		"updated_after":  &find.UpdatedAfter,
+		"updated_before": &find.UpdatedBefore,
+	} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*dst = &t
+		}
+	}
+
+	find.Sort = store.UserSort(q.Get("sort"))
+	if !find.Sort.Valid() {
+		return nil, fmt.Errorf("invalid sort %q", q.Get("sort"))
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := store.DecodeUserCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		find.Cursor = cursor
+	}
+
+	limit := store.DefaultUserPageSize
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAdminListLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxAdminListLimit)
+		}
+		limit = n
+	}
+	find.Limit = &limit
+
+	return find, nil
+}
+
+func userIDFromVars(vars map[string]string) (int64, error) {
+	userID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil || userID < 1 {
+		return 0, fmt.Errorf("invalid user id %q", vars["id"])
+	}
+	return userID, nil
+}
+
+// checkNotSelf keeps admins from locking themselves out.
+func checkNotSelf(ctx context.Context, userID int64, action string) error {
+	principal := router.PrincipalFromContext(ctx)
+	if principal == nil {
+		return errdefs.System(errors.New("principal not found in context"))
+	}
+	if !principal.IsServiceAccount() && principal.UserID == userID {
+		return errdefs.Conflict(fmt.Errorf("you can't %s", action))
+	}
+	return nil
+}
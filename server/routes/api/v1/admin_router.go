@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+type adminRouter struct {
+	backend *APIV1Service
+	routes  []router.Route
+}
+
+// NewAdminRouter initializes a router for admin endpoints.
+func NewAdminRouter(svc *APIV1Service) router.Router {
+	r := &adminRouter{backend: svc}
+	r.initRoutes()
+	return r
+}
+
+func (ar *adminRouter) Routes() []router.Route {
+	return ar.routes
+}
+
+func (ar *adminRouter) initRoutes() {
+	// the caller is authenticated first, then its permissions are checked.
+	permission := func(permissions ...store.Permission) router.RouteWrapper {
+		return router.RequirePermission(ar.backend.Store, permissions...)
+	}
+	sessionMW := router.AuthSession(ar.backend.Store)
+	// user management is open to service accounts with the matching scope as well.
+	usersReadMW := router.AuthSessionOrJWT(ar.backend.Store, ar.backend.Keys, ScopeUsersRead)
+	usersWriteMW := router.AuthSessionOrJWT(ar.backend.Store, ar.backend.Keys, ScopeUsersWrite)
+	ar.routes = []router.Route{
+		router.NewGetRoute("/admin/users", ar.backend.ListUsers, permission(store.PermissionUsersRead), usersReadMW),
+		router.NewGetRoute("/admin/users/{id:[0-9]+}", ar.backend.GetUser, permission(store.PermissionUsersRead), usersReadMW),
+		router.NewPutRoute("/admin/users/{id:[0-9]+}/role", ar.backend.UpdateUserRole, permission(store.PermissionUsersWrite), usersWriteMW),
+		router.NewPutRoute("/admin/users/{id:[0-9]+}/status", ar.backend.UpdateUserStatus, permission(store.PermissionUsersWrite), usersWriteMW),
+		router.NewDeleteRoute("/admin/users/{id:[0-9]+}", ar.backend.DeleteUser, permission(store.PermissionUsersWrite), usersWriteMW),
+		router.NewGetRoute("/admin/roles", ar.backend.ListRoles, permission(store.PermissionRolesRead), sessionMW),
+		router.NewPostRoute("/admin/roles", ar.backend.CreateRole, permission(store.PermissionRolesWrite), sessionMW),
+		router.NewGetRoute("/admin/roles/{name}", ar.backend.GetRole, permission(store.PermissionRolesRead), sessionMW),
+		router.NewPatchRoute("/admin/roles/{name}", ar.backend.UpdateRole, permission(store.PermissionRolesWrite), sessionMW),
+		router.NewDeleteRoute("/admin/roles/{name}", ar.backend.DeleteRole, permission(store.PermissionRolesWrite), sessionMW),
+		router.NewGetRoute("/admin/permissions", ar.backend.ListPermissions, permission(store.PermissionRolesRead), sessionMW),
+		router.NewGetRoute("/admin/stats/cache", ar.backend.GetCacheStats, permission(store.PermissionStatsRead), sessionMW),
+		router.NewPostRoute("/admin/oauth/clients", ar.backend.CreateOAuthClient, permission(store.PermissionOAuthClientsWrite), sessionMW),
+		router.NewGetRoute("/admin/oauth/clients", ar.backend.ListOAuthClients, permission(store.PermissionOAuthClientsRead), sessionMW),
+		router.NewDeleteRoute("/admin/oauth/clients/{client_id}", ar.backend.DeleteOAuthClient, permission(store.PermissionOAuthClientsWrite), sessionMW),
+		router.NewPostRoute("/admin/service-accounts", ar.backend.CreateServiceAccount, permission(store.PermissionServiceAccountsWrite), sessionMW),
+		router.NewGetRoute("/admin/service-accounts", ar.backend.ListServiceAccounts, permission(store.PermissionServiceAccountsRead), sessionMW),
+		router.NewDeleteRoute("/admin/service-accounts/{client_id}", ar.backend.DeleteServiceAccount, permission(store.PermissionServiceAccountsWrite), sessionMW),
+	}
+}
@@ -0,0 +1,199 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// callAdmin calls the admin route registered for method and path, e.g. PUT
+// "/admin/users/{id:[0-9]+}/status", as the caller authenticated by auth.
+func callAdmin(t *testing.T, svc *APIV1Service, auth func(*http.Request) *http.Request, method, path string, vars map[string]string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return callRouter(t, NewAdminRouter(svc), auth, method, path, vars, body)
+}
+
+// asSession authenticates requests with a new session of user.
+func asSession(t *testing.T, svc *APIV1Service, user *store.UserInfo) func(*http.Request) *http.Request {
+	token := createTestSession(t, svc, user)
+	return func(req *http.Request) *http.Request { return withSession(req, token) }
+}
+
+// asServiceAccount authenticates requests with an access token of a service account
+// of owner, allowed scopes.
+func asServiceAccount(t *testing.T, svc *APIV1Service, owner *store.UserInfo, scopes ...string) func(*http.Request) *http.Request {
+	creds := registerServiceAccount(t, svc, owner, scopes...)
+	now := svc.Store.Now()
+	token, err := jwtUtils.GenerateServiceAccessToken(creds.id, "test", strings.Join(scopes, " "), uuid.NewString(), now, now.Add(time.Minute), svc.Keys.SigningKey())
+	if err != nil {
+		t.Fatalf("GenerateServiceAccessToken: %v", err)
+	}
+	return func(req *http.Request) *http.Request {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+}
+
+func userVars(user *store.UserInfo) map[string]string {
+	return map[string]string{"id": strconv.FormatInt(user.ID, 10)}
+}
+
+func TestAdminCannotManageMorePrivilegedUsers(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	_, err := svc.Store.CreateRole(ctx, &store.CreateRole{
+		Name:        "manager",
+		Permissions: []store.Permission{store.PermissionUsersRead, store.PermissionUsersWrite},
+	})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	admin := createTestUser(t, svc, "admin@example.com", store.RoleAdmin)
+	manager := createTestUser(t, svc, "manager@example.com", "manager")
+	const (
+		statusPath = "/admin/users/{id:[0-9]+}/status"
+		userPath   = "/admin/users/{id:[0-9]+}"
+	)
+
+	for name, auth := range map[string]func(*http.Request) *http.Request{
+		"custom role":     asSession(t, svc, manager),
+		"service account": asServiceAccount(t, svc, manager, ScopeUsersWrite),
+	} {
+		if rec := callAdmin(t, svc, auth, http.MethodPut, statusPath, userVars(admin), `{"status":"disabled"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("%s disabling an admin: status %d, want 403", name, rec.Code)
+		}
+		if rec := callAdmin(t, svc, auth, http.MethodDelete, userPath, userVars(admin), ""); rec.Code != http.StatusForbidden {
+			t.Errorf("%s deleting an admin: status %d, want 403", name, rec.Code)
+		}
+
+		// users with fewer permissions are still managed
+		user := createTestUser(t, svc, strings.ReplaceAll(name, " ", ".")+"@example.com", store.RoleUser)
+		if rec := callAdmin(t, svc, auth, http.MethodPut, statusPath, userVars(user), `{"status":"disabled"}`); rec.Code != http.StatusOK {
+			t.Errorf("%s disabling a user: status %d, want 200", name, rec.Code)
+		}
+		if rec := callAdmin(t, svc, auth, http.MethodDelete, userPath, userVars(user), ""); rec.Code != http.StatusNoContent {
+			t.Errorf("%s deleting a user: status %d, want 204", name, rec.Code)
+		}
+	}
+
+	got, err := svc.Store.GetUser(ctx, &store.FindUser{ID: &admin.ID})
+	if err != nil || got.Status != store.StatusActive {
+		t.Errorf("admin = %+v, %v, want it active", got, err)
+	}
+	if rec := callAdmin(t, svc, asSession(t, svc, admin), http.MethodPut, statusPath, userVars(manager), `{"status":"disabled"}`); rec.Code != http.StatusOK {
+		t.Errorf("admin disabling a manager: status %d, want 200", rec.Code)
+	}
+}
+
+func TestAdminUserRoutes(t *testing.T) {
+	svc, _ := newTestService(t)
+	admin := createTestUser(t, svc, "admin@example.com", store.RoleAdmin)
+	support := createTestUser(t, svc, "support@example.com", store.RoleSupport)
+	jane := createTestUser(t, svc, "jane@example.com", store.RoleUser)
+	unknown := map[string]string{"id": "999"}
+	const (
+		usersPath  = "/admin/users"
+		userPath   = "/admin/users/{id:[0-9]+}"
+		rolePath   = "/admin/users/{id:[0-9]+}/role"
+		statusPath = "/admin/users/{id:[0-9]+}/status"
+	)
+	anonymous := func(req *http.Request) *http.Request { return req }
+	asAdmin, asSupport, asJane := asSession(t, svc, admin), asSession(t, svc, support), asSession(t, svc, jane)
+
+	tests := []struct {
+		name   string
+		auth   func(*http.Request) *http.Request
+		method string
+		path   string
+		vars   map[string]string
+		body   string
+		want   int
+	}{
+		{"anonymous list", anonymous, http.MethodGet, usersPath, nil, "", http.StatusUnauthorized},
+		// the permission gate
+		{"user list", asJane, http.MethodGet, usersPath, nil, "", http.StatusForbidden},
+		{"user get", asJane, http.MethodGet, userPath, userVars(jane), "", http.StatusForbidden},
+		{"user role", asJane, http.MethodPut, rolePath, userVars(jane), `{"role":"admin"}`, http.StatusForbidden},
+		{"support list", asSupport, http.MethodGet, usersPath, nil, "", http.StatusOK},
+		{"support get", asSupport, http.MethodGet, userPath, userVars(jane), "", http.StatusOK},
+		{"support role", asSupport, http.MethodPut, rolePath, userVars(jane), `{"role":"support"}`, http.StatusForbidden},
+		{"support status", asSupport, http.MethodPut, statusPath, userVars(jane), `{"status":"disabled"}`, http.StatusForbidden},
+		{"support delete", asSupport, http.MethodDelete, userPath, userVars(jane), "", http.StatusForbidden},
+		// admins can't lock themselves out
+		{"own role", asAdmin, http.MethodPut, rolePath, userVars(admin), `{"role":"user"}`, http.StatusConflict},
+		{"own status", asAdmin, http.MethodPut, statusPath, userVars(admin), `{"status":"disabled"}`, http.StatusConflict},
+		{"own delete", asAdmin, http.MethodDelete, userPath, userVars(admin), "", http.StatusConflict},
+		// unknown users
+		{"get unknown", asAdmin, http.MethodGet, userPath, unknown, "", http.StatusNotFound},
+		{"role unknown", asAdmin, http.MethodPut, rolePath, unknown, `{"role":"user"}`, http.StatusNotFound},
+		{"status unknown", asAdmin, http.MethodPut, statusPath, unknown, `{"status":"disabled"}`, http.StatusNotFound},
+		{"delete unknown", asAdmin, http.MethodDelete, userPath, unknown, "", http.StatusNotFound},
+		{"unknown role", asAdmin, http.MethodPut, rolePath, userVars(jane), `{"role":"owner"}`, http.StatusBadRequest},
+		{"invalid status", asAdmin, http.MethodPut, statusPath, userVars(jane), `{"status":"gone"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := callAdmin(t, svc, tt.auth, tt.method, tt.path, tt.vars, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d, body %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+	for _, user := range []*store.UserInfo{admin, support, jane} {
+		got, err := svc.Store.GetUser(context.Background(), &store.FindUser{ID: &user.ID})
+		if err != nil || got.Role != user.Role || got.Status != store.StatusActive {
+			t.Errorf("user %s = %+v, %v, want it unchanged", user.Email, got, err)
+		}
+	}
+
+	rec := callAdmin(t, svc, asAdmin, http.MethodGet, usersPath, nil, "")
+	list := &UserListResp{}
+	if err := json.NewDecoder(rec.Body).Decode(list); err != nil || len(list.Users) != 3 {
+		t.Fatalf("ListUsers = %d users, %v, want 3", len(list.Users), err)
+	}
+	rec = callAdmin(t, svc, asAdmin, http.MethodPut, rolePath, userVars(jane), `{"role":"support"}`)
+	got := &UserResp{}
+	if err := json.NewDecoder(rec.Body).Decode(got); err != nil || rec.Code != http.StatusOK || got.Role != store.RoleSupport {
+		t.Errorf("UpdateUserRole = %d %+v, want jane made support", rec.Code, got)
+	}
+	if rec := callAdmin(t, svc, asAdmin, http.MethodDelete, userPath, userVars(jane), ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DeleteUser: status %d, want 204", rec.Code)
+	}
+	if rec := callAdmin(t, svc, asAdmin, http.MethodGet, userPath, userVars(jane), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GetUser(deleted): status %d, want 404", rec.Code)
+	}
+}
+
+func TestListUsersRejectsMalformedCursor(t *testing.T) {
+	svc, _ := newTestService(t)
+	admin := createTestUser(t, svc, "admin@example.com", store.RoleAdmin)
+	createTestUser(t, svc, "jane@example.com", store.RoleUser)
+	asAdmin := asSession(t, svc, admin)
+	list := func(query string) *httptest.ResponseRecorder {
+		return callRouter(t, NewAdminRouter(svc), func(req *http.Request) *http.Request {
+			req.URL.RawQuery = query
+			return asAdmin(req)
+		}, http.MethodGet, "/admin/users", nil, "")
+	}
+
+	rec := list("limit=1")
+	page := &UserListResp{}
+	if err := json.NewDecoder(rec.Body).Decode(page); err != nil || page.NextCursor == "" {
+		t.Fatalf("ListUsers(limit=1) = %d, cursor %q, %v, want a next page", rec.Code, page.NextCursor, err)
+	}
+	for _, query := range []string{"cursor=garbage!", "cursor=" + page.NextCursor + "&sort=email_asc"} {
+		if rec := list(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("ListUsers(%s): status %d, want 400", query, rec.Code)
+		}
+	}
+	if rec := list("limit=1&cursor=" + page.NextCursor); rec.Code != http.StatusOK {
+		t.Errorf("ListUsers(next page): status %d, want 200", rec.Code)
+	}
+}
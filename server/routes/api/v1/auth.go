@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rs/zerolog/log"
 	"github.com/sagarsuperuser/userprofile/errdefs"
 	"github.com/sagarsuperuser/userprofile/internal/common"
 	"github.com/sagarsuperuser/userprofile/internal/httputil"
@@ -17,7 +18,14 @@ import (
 	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
 	"github.com/sagarsuperuser/userprofile/store"
 	"golang.org/x/crypto/bcrypt"
-	"golang.org/x/oauth2"
+)
+
+const (
+	// oauthTempTTL is how long users have to sign in with an identity provider.
+	oauthTempTTL = 5 * time.Minute
+	// pendingLinkTTL is how long users have to log in with their password to link
+	// the provider they tried to sign in with.
+	pendingLinkTTL = 10 * time.Minute
 )
 
 type SignupReq struct {
@@ -46,6 +54,8 @@ func (s *SignupReq) Validate() error {
 type LoginReq struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// RememberMe selects the longer session lifetime.
+	RememberMe bool `json:"remember_me"`
 }
 
 func (s *LoginReq) Validate() error {
@@ -61,6 +71,15 @@ type LoginResp struct {
 	AuthToken string `json:"auth_token"`
 }
 
+// newCreateSession records the device the session is created from.
+func newCreateSession(req *http.Request, userID int64) *store.CreateSession {
+	return &store.CreateSession{
+		UserID:    userID,
+		IP:        httputil.ClientIP(req),
+		UserAgent: req.UserAgent(),
+	}
+}
+
 func (s *APIV1Service) SignUp(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
 	signup := SignupReq{}
 	if err := json.NewDecoder(req.Body).Decode(&signup); err != nil {
@@ -97,7 +116,7 @@ func (s *APIV1Service) SignUp(ctx context.Context, rw http.ResponseWriter, req *
 		return errdefs.System(err)
 	}
 
-	csResult, err := s.Store.CreateSession(ctx, user.ID)
+	csResult, err := s.Store.CreateSession(ctx, newCreateSession(req, user.ID))
 	if err != nil {
 		err = fmt.Errorf("failed to create sesssion: %w", err)
 		return errdefs.System(err)
@@ -117,85 +136,160 @@ func (s *APIV1Service) LogIn(ctx context.Context, rw http.ResponseWriter, req *h
 		return errdefs.InvalidParameter(err)
 	}
 
-	user, err := s.Store.GetUser(ctx, &store.FindUser{Email: &loginReq.Username})
+	user, err := s.authenticatePassword(ctx, loginReq.Username, loginReq.Password)
+	if err != nil {
+		return err
+	}
+	s.linkPendingIdentity(ctx, rw, req, user)
+
+	create := newCreateSession(req, user.ID)
+	create.Remember = loginReq.RememberMe
+	csResult, err := s.Store.CreateSession(ctx, create)
+	if err != nil {
+		err = fmt.Errorf("failed to create sesssion: %w", err)
+		return errdefs.System(err)
+	}
+	sessionUtils.SetSessionCookie(rw, req, csResult.Session.ExpiresAt, csResult.Token)
+	return httputil.WriteRawJSON(rw, http.StatusOK, newUserResp(user))
+}
+
+// authenticatePassword returns the active user with the given email and password.
+func (s *APIV1Service) authenticatePassword(ctx context.Context, email, password string) (*store.UserInfo, error) {
+	user, err := s.Store.GetUser(ctx, &store.FindUser{Email: &email})
 	if err != nil {
 		if errors.Is(err, store.ErrUserNotFound) {
-			return errdefs.Unauthorized(errors.New("invalid credentials, please try again"))
+			return nil, errdefs.Unauthorized(errors.New("invalid credentials, please try again"))
 		}
-		return errdefs.System(err)
+		return nil, errdefs.System(err)
 	}
 
 	// Compare the stored hashed password, with the password that is received.
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(loginReq.Password)); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
 		// If the two passwords don't match, return a 401 status.
-		return errdefs.Unauthorized(errors.New("invalid credentials, please try again"))
+		return nil, errdefs.Unauthorized(errors.New("invalid credentials, please try again"))
 	}
+	if user.Status == store.StatusDisabled {
+		return nil, errdefs.Forbidden(store.ErrUserDisabled)
+	}
+	return user, nil
+}
 
-	csResult, err := s.Store.CreateSession(ctx, user.ID)
-	if err != nil {
-		err = fmt.Errorf("failed to create sesssion: %w", err)
-		return errdefs.System(err)
+type AuthProviderResp struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+type AuthProviderListResp struct {
+	Providers []*AuthProviderResp `json:"providers"`
+}
+
+// ListAuthProviders returns the external identity providers users can sign in with.
+func (s *APIV1Service) ListAuthProviders(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	resp := AuthProviderListResp{Providers: make([]*AuthProviderResp, 0)}
+	for _, p := range s.Providers.List() {
+		resp.Providers = append(resp.Providers, &AuthProviderResp{Name: p.Name, DisplayName: p.DisplayName})
 	}
-	sessionUtils.SetSessionCookie(rw, req, csResult.Session.ExpiresAt, csResult.Token)
-	return httputil.WriteRawJSON(rw, http.StatusOK, newUserResp(user))
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
 }
 
-func (s *APIV1Service) Oauth2Login(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
-	verifier := oauth2.GenerateVerifier()
-	state := "state"
+// identityProvider returns the enabled provider named in the route.
+func (s *APIV1Service) identityProvider(vars map[string]string) (*oauth2Utils.Provider, error) {
+	provider, ok := s.Providers.Get(vars["provider"])
+	if !ok {
+		return nil, errdefs.NotFound(fmt.Errorf("unknown identity provider %q", vars["provider"]))
+	}
+	return provider, nil
+}
 
-	if err := oauth2Utils.SetOAuthTempCookie(rw, state, verifier, 5*time.Minute); err != nil {
+func (s *APIV1Service) Oauth2Login(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	provider, err := s.identityProvider(vars)
+	if err != nil {
+		return err
+	}
+	now := s.Store.Now()
+	temp, err := oauth2Utils.NewOAuthTemp(provider.Name, now, oauthTempTTL)
+	if err != nil {
+		return errdefs.System(err)
+	}
+	if err := oauth2Utils.SetOAuthTempCookie(rw, s.Settings.SecretKey, temp, now); err != nil {
 		err = fmt.Errorf("failed to set oauth temp cookie: %w", err)
 		return errdefs.System(err)
 	}
 
-	url := s.OAuthConfig.AuthCodeURL(
-		state,
-		oauth2.AccessTypeOnline,
-		oauth2.S256ChallengeOption(verifier),
-	)
-
-	http.Redirect(rw, req, url, http.StatusFound)
+	http.Redirect(rw, req, provider.AuthCodeURL(temp), http.StatusFound)
 	return nil
 }
 
 func (s *APIV1Service) Oauth2Callback(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	provider, err := s.identityProvider(vars)
+	if err != nil {
+		return err
+	}
+	// the cookie is only good for one attempt, whatever its outcome.
+	temp, err := oauth2Utils.ReadOAuthTempCookie(req, s.Settings.SecretKey, s.Store.Now())
+	oauth2Utils.ClearOAuthTempCookie(rw)
+	if err != nil {
+		return errdefs.Unauthorized(fmt.Errorf("sign-in expired, please try again: %w", err))
+	}
+
 	q := req.URL.Query()
+	if e := q.Get("error"); e != "" {
+		return errdefs.Unauthorized(fmt.Errorf("sign-in was not completed: %s", e))
+	}
 	code := q.Get("code")
 	state := q.Get("state")
 	if code == "" || state == "" {
 		return errdefs.InvalidParameter(errors.New("missing code/state"))
 	}
-
-	cookie, err := oauth2Utils.ReadOAuthTempCookie(req)
-	if err != nil {
-		err := fmt.Errorf("temp cookie read failed: %w", err)
-		return errdefs.System(err)
+	if temp.Provider != provider.Name {
+		return errdefs.Unauthorized(fmt.Errorf("sign-in was started with %q", temp.Provider))
 	}
-
-	tok, err := s.OAuthConfig.Exchange(ctx, code, oauth2.VerifierOption(cookie.Verifier))
-	if err != nil {
-		err = fmt.Errorf("exchange call failed: %w", err)
+	if err := temp.CheckState(state); err != nil {
 		return errdefs.Unauthorized(err)
 	}
 
-	client := s.OAuthConfig.Client(ctx, tok)
-	googleUser, err := oauth2Utils.FetchProviderUserInfo(ctx, client)
+	providerUser, err := provider.Exchange(ctx, code, temp)
 	if err != nil {
-		err = fmt.Errorf("failed to fetch userinfo: %w", err)
 		return errdefs.Unauthorized(err)
 	}
+	if temp.LinkUserID != 0 {
+		return s.completeIdentityLink(ctx, rw, req, provider, temp, providerUser)
+	}
 	// upsert user in DB
-	user, err := s.Store.UpsertGoogleUser(ctx, googleUser.Email, googleUser.Sub)
+	user, err := s.Store.UpsertProviderUser(ctx, &store.UpsertProviderUser{
+		Provider:      store.Provider(provider.Name),
+		Subject:       providerUser.Sub,
+		Email:         providerUser.Email,
+		EmailVerified: providerUser.EmailVerified,
+	})
 	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrProviderEmailNotVerified):
+			return errdefs.Forbidden(err)
+		case errors.Is(err, store.ErrIdentityNotLinked):
+			// linked by the next password login, see linkPendingIdentity
+			now := s.Store.Now()
+			link := &oauth2Utils.PendingLink{
+				Provider:  provider.Name,
+				Subject:   providerUser.Sub,
+				Email:     providerUser.Email,
+				ExpiresAt: now.Add(pendingLinkTTL).Unix(),
+			}
+			if err := oauth2Utils.SetPendingLinkCookie(rw, s.Settings.SecretKey, link, now); err != nil {
+				return errdefs.System(fmt.Errorf("failed to set pending link cookie: %w", err))
+			}
+			return errdefs.Conflict(err)
+		}
 		err = fmt.Errorf("failed to create/update user: %w", err)
 		return errdefs.System(err)
 	}
 
-	oauth2Utils.ClearOAuthTempCookie(rw)
+	if user.Status == store.StatusDisabled {
+		return errdefs.Forbidden(store.ErrUserDisabled)
+	}
 
 	// create session token
-	csResult, err := s.Store.CreateSession(ctx, user.ID)
+	csResult, err := s.Store.CreateSession(ctx, newCreateSession(req, user.ID))
 	if err != nil {
 		err = fmt.Errorf("failed to create sesssion: %w", err)
 		return errdefs.System(err)
@@ -204,6 +298,29 @@ func (s *APIV1Service) Oauth2Callback(ctx context.Context, rw http.ResponseWrite
 	return httputil.WriteRawJSON(rw, http.StatusOK, newUserResp(user))
 }
 
+// linkPendingIdentity links the provider identity the user tried to sign in with
+// before logging in with their password, if it has the email of user. Failures
+// are only logged, the login itself succeeded.
+func (s *APIV1Service) linkPendingIdentity(ctx context.Context, rw http.ResponseWriter, req *http.Request, user *store.UserInfo) {
+	link, err := oauth2Utils.ReadPendingLinkCookie(req, s.Settings.SecretKey, s.Store.Now())
+	if errors.Is(err, http.ErrNoCookie) {
+		return
+	}
+	oauth2Utils.ClearPendingLinkCookie(rw)
+	if err != nil || !strings.EqualFold(link.Email, user.Email) {
+		return
+	}
+
+	err = s.Store.LinkIdentity(ctx, &store.LinkIdentity{
+		UserID:   user.ID,
+		Provider: store.Provider(link.Provider),
+		Subject:  link.Subject,
+	})
+	if err != nil {
+		log.Warn().Err(err).Int64("user_id", user.ID).Str("provider", link.Provider).Msg("Failed to link identity")
+	}
+}
+
 func (s *APIV1Service) LogOut(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
 	// get session info from context
 	sInfo := router.SessionInfoFromContext(ctx)
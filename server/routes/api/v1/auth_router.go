@@ -1,6 +1,13 @@
 package v1
 
-import "github.com/sagarsuperuser/userprofile/internal/router"
+import (
+	"context"
+	"net/http"
+
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	oauth2Utils "github.com/sagarsuperuser/userprofile/internal/oauth2"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+)
 
 type authRouter struct {
 	backend *APIV1Service
@@ -23,11 +30,26 @@ func (ar *authRouter) Routes() []router.Route {
 func (ar *authRouter) initRoutes() {
 	// protect routes with session middleware as a RouteWrapper
 	sessionMW := router.AuthSession(ar.backend.Store)
+	jwtMW := router.AuthJWT(ar.backend.Store, ar.backend.Keys)
 	ar.routes = []router.Route{
 		router.NewPostRoute("/auth/signup", ar.backend.SignUp),
 		router.NewPostRoute("/auth/login", ar.backend.LogIn),
-		router.NewGetRoute("/oauth2/login", ar.backend.Oauth2Login),
-		router.NewGetRoute("/oauth2/callback", ar.backend.Oauth2Callback),
+		router.NewGetRoute("/auth/providers", ar.backend.ListAuthProviders),
+		router.NewGetRoute("/oauth2/{provider:[a-z][a-z0-9_-]*}/login", ar.backend.Oauth2Login),
+		router.NewGetRoute("/oauth2/{provider:[a-z][a-z0-9_-]*}/callback", ar.backend.Oauth2Callback),
+		// before providers were pluggable only google was supported, at these paths.
+		router.NewGetRoute("/oauth2/login", withProvider(oauth2Utils.ProviderGoogle, ar.backend.Oauth2Login)),
+		router.NewGetRoute("/oauth2/callback", withProvider(oauth2Utils.ProviderGoogle, ar.backend.Oauth2Callback)),
 		router.NewPostRoute("/auth/logout", ar.backend.LogOut, sessionMW),
+		router.NewPostRoute("/auth/token", ar.backend.IssueToken),
+		router.NewPostRoute("/auth/token/refresh", ar.backend.RefreshToken),
+		router.NewPostRoute("/auth/token/revoke", ar.backend.RevokeToken, jwtMW),
+	}
+}
+
+// withProvider serves handler as the route of the identity provider name.
+func withProvider(name string, handler httputil.APIFunc) httputil.APIFunc {
+	return func(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+		return handler(ctx, rw, req, map[string]string{"provider": name})
 	}
 }
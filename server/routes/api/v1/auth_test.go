@@ -0,0 +1,81 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	oauth2Utils "github.com/sagarsuperuser/userprofile/internal/oauth2"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func TestOauth2CallbackRejectsExpiredSignIn(t *testing.T) {
+	svc, clock := newTestService(t)
+	withGitHub(svc)
+	vars := map[string]string{"provider": oauth2Utils.ProviderGitHub}
+
+	rec := httptest.NewRecorder()
+	if err := svc.Oauth2Login(context.Background(), rec, httptest.NewRequest(http.MethodGet, "/oauth2/github/login", nil), vars); err != nil {
+		t.Fatalf("Oauth2Login: %v", err)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+
+	// the cookie is checked against the service clock, not the wall clock
+	clock.Advance(oauthTempTTL)
+	callback := "/oauth2/github/callback?" + url.Values{"code": {"code"}, "state": {location.Query().Get("state")}}.Encode()
+	req := httptest.NewRequest(http.MethodGet, callback, nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	err = svc.Oauth2Callback(context.Background(), httptest.NewRecorder(), req, vars)
+	if !errors.Is(err, oauth2Utils.ErrInvalidOAuthTemp) {
+		t.Errorf("Oauth2Callback(expired) error = %v, want %v", err, oauth2Utils.ErrInvalidOAuthTemp)
+	}
+}
+
+func TestLinkPendingIdentityExpires(t *testing.T) {
+	svc, clock := newTestService(t)
+	ctx := context.Background()
+	user := createTestUser(t, svc, "jane@example.com", store.RoleUser)
+
+	// pendingLinkRequest returns a login request carrying a link to subject made now
+	pendingLinkRequest := func(subject string) *http.Request {
+		link := &oauth2Utils.PendingLink{
+			Provider:  oauth2Utils.ProviderGitHub,
+			Subject:   subject,
+			Email:     user.Email,
+			ExpiresAt: clock.Now().Add(pendingLinkTTL).Unix(),
+		}
+		rec := httptest.NewRecorder()
+		if err := oauth2Utils.SetPendingLinkCookie(rec, svc.Settings.SecretKey, link, clock.Now()); err != nil {
+			t.Fatalf("SetPendingLinkCookie: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+		for _, c := range rec.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		return req
+	}
+
+	req := pendingLinkRequest("gh-expired")
+	clock.Advance(pendingLinkTTL)
+	svc.linkPendingIdentity(ctx, httptest.NewRecorder(), req, user)
+	if list, _ := svc.Store.ListIdentities(ctx, user.ID); len(list) != 1 {
+		t.Fatalf("expired pending link was linked: %d identities", len(list))
+	}
+
+	svc.linkPendingIdentity(ctx, httptest.NewRecorder(), pendingLinkRequest("gh-1"), user)
+	list, err := svc.Store.ListIdentities(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ListIdentities: %v", err)
+	}
+	if len(list) != 2 || list[1].Subject != "gh-1" {
+		t.Errorf("identities = %d, want the local one and github gh-1", len(list))
+	}
+}
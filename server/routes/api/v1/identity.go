@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	oauth2Utils "github.com/sagarsuperuser/userprofile/internal/oauth2"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+type IdentityResp struct {
+	Provider    string `json:"provider"`
+	DisplayName string `json:"display_name"`
+	HasPassword bool   `json:"has_password"`
+	// Usable is false for identities of providers that are no longer enabled.
+	Usable    bool      `json:"usable"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IdentityListResp struct {
+	Identities []*IdentityResp `json:"identities"`
+	// Linkable are the enabled providers the user has no identity with yet.
+	Linkable []*AuthProviderResp `json:"linkable"`
+}
+
+type LinkIdentityResp struct {
+	// AuthorizationURL is where to send the browser to sign in with the provider.
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// usableProviders are the providers users can sign in with, see store.DeleteIdentity.
+func (s *APIV1Service) usableProviders() []store.Provider {
+	list := []store.Provider{store.ProviderLocal}
+	for _, p := range s.Providers.List() {
+		list = append(list, store.Provider(p.Name))
+	}
+	return list
+}
+
+func (s *APIV1Service) newIdentityResp(identity *store.Identity) *IdentityResp {
+	resp := &IdentityResp{
+		Provider:    string(identity.Provider),
+		DisplayName: string(identity.Provider),
+		HasPassword: identity.HasPassword,
+		CreatedAt:   identity.CreatedAt,
+	}
+	if identity.Provider == store.ProviderLocal {
+		resp.DisplayName = "Password"
+		resp.Usable = identity.HasPassword
+	} else if p, ok := s.Providers.Get(string(identity.Provider)); ok {
+		resp.DisplayName = p.DisplayName
+		resp.Usable = true
+	}
+	return resp
+}
+
+// ListIdentities godoc
+//
+//	@Summary	List the ways the current user can sign in
+//	@Tags		user
+//	@Produce	json
+//	@Success	200	{object}	IdentityListResp	"Identities, oldest first, and the providers that can be linked"
+//	@Router		/v1/user/identities [GET]
+func (s *APIV1Service) ListIdentities(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	list, err := s.Store.ListIdentities(ctx, router.UserIDFromContext(ctx))
+	if err != nil {
+		return errdefs.System(err)
+	}
+
+	resp := IdentityListResp{
+		Identities: make([]*IdentityResp, 0, len(list)),
+		Linkable:   make([]*AuthProviderResp, 0),
+	}
+	linked := make(map[store.Provider]bool, len(list))
+	for _, identity := range list {
+		resp.Identities = append(resp.Identities, s.newIdentityResp(identity))
+		linked[identity.Provider] = true
+	}
+	for _, p := range s.Providers.List() {
+		if !linked[store.Provider(p.Name)] {
+			resp.Linkable = append(resp.Linkable, &AuthProviderResp{Name: p.Name, DisplayName: p.DisplayName})
+		}
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
+}
+
+// LinkIdentity godoc
+//
+//	@Summary	Start adding an identity provider to the current user
+//	@Description	The browser signs in with the provider at authorization_url, the provider's
+//	@Description	redirect to the usual callback links the identity to the user who is still signed in.
+//	@Tags		user
+//	@Produce	json
+//	@Success	200	{object}	LinkIdentityResp	"Where to sign in with the provider"
+//	@Failure	404	{object}	nil					"Unknown provider"
+//	@Failure	409	{object}	nil					"The user already has an identity with the provider"
+//	@Router		/v1/user/identities/{provider}/link [POST]
+func (s *APIV1Service) LinkIdentity(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	provider, err := s.identityProvider(vars)
+	if err != nil {
+		return err
+	}
+	userID := router.UserIDFromContext(ctx)
+	list, err := s.Store.ListIdentities(ctx, userID)
+	if err != nil {
+		return errdefs.System(err)
+	}
+	for _, identity := range list {
+		if identity.Provider == store.Provider(provider.Name) {
+			return errdefs.Conflict(fmt.Errorf("%s is already linked to your account", provider.DisplayName))
+		}
+	}
+
+	now := s.Store.Now()
+	temp, err := oauth2Utils.NewOAuthTemp(provider.Name, now, oauthTempTTL)
+	if err != nil {
+		return errdefs.System(err)
+	}
+	temp.LinkUserID = userID
+	if err := oauth2Utils.SetOAuthTempCookie(rw, s.Settings.SecretKey, temp, now); err != nil {
+		return errdefs.System(fmt.Errorf("failed to set oauth temp cookie: %w", err))
+	}
+
+	rw.Header().Set("Cache-Control", "no-store")
+	return httputil.WriteRawJSON(rw, http.StatusOK, LinkIdentityResp{AuthorizationURL: provider.AuthCodeURL(temp)})
+}
+
+// completeIdentityLink links the identity signed in with to the user who started
+// linking it, as long as the same user is still signed in with this browser.
+func (s *APIV1Service) completeIdentityLink(ctx context.Context, rw http.ResponseWriter, req *http.Request, provider *oauth2Utils.Provider, temp *oauth2Utils.OAuthTemp, providerUser *oauth2Utils.ProviderUserInfoResp) error {
+	_, user, err := router.AuthenticateSession(ctx, s.Store, rw, req)
+	if err != nil {
+		return err
+	}
+	if user.ID != temp.LinkUserID {
+		return errdefs.Unauthorized(errors.New("linking was started by another user, please try again"))
+	}
+
+	err = s.Store.LinkIdentity(ctx, &store.LinkIdentity{
+		UserID:   user.ID,
+		Provider: store.Provider(provider.Name),
+		Subject:  providerUser.Sub,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrIdentityAlreadyLinked) {
+			return errdefs.Conflict(fmt.Errorf("this %s account is already linked to an account", provider.DisplayName))
+		}
+		return errdefs.System(fmt.Errorf("failed to link identity: %w", err))
+	}
+
+	user, err = s.Store.GetUser(ctx, &store.FindUser{ID: &user.ID})
+	if err != nil {
+		return errdefs.System(err)
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, newUserResp(user))
+}
+
+// DeleteIdentity godoc
+//
+//	@Summary	Remove a way the current user signs in
+//	@Description	"local" removes the password. The last identity of an enabled provider,
+//	@Description	or the password, can't be removed. Needs a session, access tokens are refused.
+//	@Tags		user
+//	@Success	204	{object}	nil	"Identity removed"
+//	@Failure	401	{object}	nil	"Not signed in with a session"
+//	@Failure	404	{object}	nil	"The user has no identity with the provider"
+//	@Failure	409	{object}	nil	"It is the last way to sign in"
+//	@Router		/v1/user/identities/{provider} [DELETE]
+func (s *APIV1Service) DeleteIdentity(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	err := s.Store.DeleteIdentity(ctx, &store.DeleteIdentity{
+		UserID:   router.UserIDFromContext(ctx),
+		Provider: store.Provider(vars["provider"]),
+		Usable:   s.usableProviders(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrIdentityNotFound):
+			return errdefs.NotFound(err)
+		case errors.Is(err, store.ErrLastLoginMethod):
+			return errdefs.Conflict(err)
+		}
+		return errdefs.System(fmt.Errorf("failed to delete identity: %w", err))
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
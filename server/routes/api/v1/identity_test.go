@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	oauth2Utils "github.com/sagarsuperuser/userprofile/internal/oauth2"
+	"github.com/sagarsuperuser/userprofile/server/httpstatus"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const identityPath = "/user/identities/{provider:[a-z][a-z0-9_-]*}"
+
+// withGitHub enables the GitHub provider on svc.
+func withGitHub(svc *APIV1Service) {
+	svc.Settings.OAuth2GitHubClientID = "client"
+	svc.Settings.OAuth2GitHubRedirectURL = "https://app.example.com/ui/oauth2/github/callback"
+	svc.Providers = oauth2Utils.NewRegistry(context.Background(), svc.Settings)
+}
+
+func linkTestIdentity(t *testing.T, svc *APIV1Service, user *store.UserInfo, provider store.Provider, subject string) {
+	t.Helper()
+	err := svc.Store.LinkIdentity(context.Background(), &store.LinkIdentity{UserID: user.ID, Provider: provider, Subject: subject})
+	if err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+}
+
+func providerVars(provider string) map[string]string {
+	return map[string]string{"provider": provider}
+}
+
+func TestDeleteIdentity(t *testing.T) {
+	svc, _ := newTestService(t)
+	withGitHub(svc)
+	jane := createTestUser(t, svc, "jane@example.com", store.RoleUser)
+	session := asSession(t, svc, jane)
+
+	// an access token alone doesn't change how the user signs in
+	bearer := func(req *http.Request) *http.Request {
+		req.Header.Set("Authorization", "Bearer "+accessToken(t, svc, jane, time.Minute))
+		return req
+	}
+	linkTestIdentity(t, svc, jane, store.ProviderGitHub, "gh-jane")
+	if rec := callRouter(t, NewUserRouter(svc), bearer, http.MethodDelete, identityPath, providerVars(oauth2Utils.ProviderGitHub), ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("DeleteIdentity with an access token: status %d, want 401", rec.Code)
+	}
+
+	for _, provider := range []string{"gitlab", "unknown"} {
+		if rec := callRouter(t, NewUserRouter(svc), session, http.MethodDelete, identityPath, providerVars(provider), ""); rec.Code != http.StatusNotFound {
+			t.Errorf("DeleteIdentity(%s): status %d, want 404", provider, rec.Code)
+		}
+	}
+	if rec := callRouter(t, NewUserRouter(svc), session, http.MethodDelete, identityPath, providerVars(string(store.ProviderLocal)), ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DeleteIdentity(local): status %d, want 204", rec.Code)
+	}
+	if rec := callRouter(t, NewUserRouter(svc), session, http.MethodDelete, identityPath, providerVars(oauth2Utils.ProviderGitHub), ""); rec.Code != http.StatusConflict {
+		t.Errorf("DeleteIdentity(last login method): status %d, want 409", rec.Code)
+	}
+	if list, _ := svc.Store.ListIdentities(context.Background(), jane.ID); len(list) != 1 {
+		t.Errorf("identities = %d, want github left", len(list))
+	}
+}
+
+func TestLinkIdentityConflicts(t *testing.T) {
+	svc, clock := newTestService(t)
+	withGitHub(svc)
+	jane := createTestUser(t, svc, "jane@example.com", store.RoleUser)
+	john := createTestUser(t, svc, "john@example.com", store.RoleUser)
+	linkTestIdentity(t, svc, john, store.ProviderGitHub, "gh-john")
+	const linkPath = "/user/identities/{provider:[a-z][a-z0-9_-]*}/link"
+
+	if rec := callRouter(t, NewUserRouter(svc), asSession(t, svc, jane), http.MethodPost, linkPath, providerVars("unknown"), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("LinkIdentity(unknown): status %d, want 404", rec.Code)
+	}
+	if rec := callRouter(t, NewUserRouter(svc), asSession(t, svc, john), http.MethodPost, linkPath, providerVars(oauth2Utils.ProviderGitHub), ""); rec.Code != http.StatusConflict {
+		t.Errorf("LinkIdentity(already linked): status %d, want 409", rec.Code)
+	}
+
+	// the GitHub account signed in with is linked to another user
+	provider, _ := svc.Providers.Get(oauth2Utils.ProviderGitHub)
+	temp, err := oauth2Utils.NewOAuthTemp(provider.Name, clock.Now(), oauthTempTTL)
+	if err != nil {
+		t.Fatalf("NewOAuthTemp: %v", err)
+	}
+	temp.LinkUserID = jane.ID
+	req := withSession(httptest.NewRequest(http.MethodGet, "/oauth2/github/callback", nil), createTestSession(t, svc, jane))
+	err = svc.completeIdentityLink(context.Background(), httptest.NewRecorder(), req, provider, temp, &oauth2Utils.ProviderUserInfoResp{Sub: "gh-john"})
+	if status := httpstatus.FromError(err); status != http.StatusConflict {
+		t.Errorf("completeIdentityLink(linked to another user): status %d, want 409", status)
+	}
+	if list, _ := svc.Store.ListIdentities(context.Background(), jane.ID); len(list) != 1 {
+		t.Errorf("jane has %d identities, want the local one", len(list))
+	}
+}
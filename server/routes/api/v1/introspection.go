@@ -0,0 +1,352 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// Kinds of tokens understood by the introspection and revocation endpoints,
+// also accepted as token_type_hint.
+const (
+	tokenTypeAccessToken  = "access_token"
+	tokenTypeRefreshToken = "refresh_token"
+	tokenTypeSession      = "session"
+)
+
+// IntrospectionResp is the answer of the introspection endpoint (RFC 7662 section 2.2),
+// only Active is set for inactive tokens.
+type IntrospectionResp struct {
+	Active bool `json:"active"`
+	// TokenType is access_token, refresh_token or session.
+	TokenType string `json:"token_type,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	// ClientID is the OAuth client or service account the token was issued to.
+	ClientID string   `json:"client_id,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Subject  string   `json:"sub,omitempty"`
+	Audience []string `json:"aud,omitempty"`
+	Issuer   string   `json:"iss,omitempty"`
+	JTI      string   `json:"jti,omitempty"`
+	Exp      int64    `json:"exp,omitempty"`
+	Iat      int64    `json:"iat,omitempty"`
+}
+
+// tokenCaller is the client calling the introspection or revocation endpoint.
+type tokenCaller struct {
+	clientID string
+	// revokesUserTokens is set for service accounts whose scopes and owner both have the
+	// users:write permission. Only they may revoke tokens issued to no client, i.e. the
+	// sessions, refresh tokens and access tokens users get from this service directly.
+	revokesUserTokens bool
+}
+
+// introspectedToken is a token that is neither expired nor revoked.
+type introspectedToken struct {
+	tokenType string
+	subject   string
+	// userID is the user behind the token, or the owner of the service account.
+	userID    int64
+	clientID  string
+	scope     string
+	issuedAt  time.Time
+	expiresAt time.Time
+	// set depending on tokenType
+	claims  *jwtUtils.ClaimsMessage
+	refresh *store.RefreshTokenInfo
+	session *store.SessionInfo
+}
+
+// Introspect godoc
+//
+//	@Summary	Tell whether a token is active, and what it grants (RFC 7662)
+//	@Description	Form encoded. Understands access tokens, refresh tokens and session tokens; token_type_hint is access_token, refresh_token or session.
+//	@Description	Callers authenticate as a confidential OAuth client or a service account.
+//	@Tags		oidc
+//	@Accept		x-www-form-urlencoded
+//	@Produce	json
+//	@Success	200	{object}	IntrospectionResp	"Token state"
+//	@Failure	401	{object}	nil					"Invalid client credentials"
+//	@Router		/oauth2/introspect [POST]
+func (s *APIV1Service) Introspect(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	if err := req.ParseForm(); err != nil {
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidRequest, "malformed form body")
+	}
+	if _, basic, err := s.authenticateTokenCaller(ctx, req); err != nil {
+		return invalidClient(rw, basic, err)
+	}
+	raw := req.PostForm.Get("token")
+	if raw == "" {
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidRequest, "token is required")
+	}
+
+	rw.Header().Set("Cache-Control", "no-store")
+	token, err := s.introspectToken(ctx, raw, req.PostForm.Get("token_type_hint"))
+	if err != nil {
+		return errdefs.System(err)
+	}
+	if token == nil {
+		return httputil.WriteRawJSON(rw, http.StatusOK, &IntrospectionResp{Active: false})
+	}
+	user, err := s.Store.GetUser(ctx, &store.FindUser{ID: &token.userID})
+	if err != nil && !errors.Is(err, store.ErrUserNotFound) {
+		return errdefs.System(err)
+	}
+	if user == nil || user.Status == store.StatusDisabled {
+		return httputil.WriteRawJSON(rw, http.StatusOK, &IntrospectionResp{Active: false})
+	}
+
+	resp := &IntrospectionResp{
+		Active:    true,
+		TokenType: token.tokenType,
+		Scope:     token.scope,
+		ClientID:  token.clientID,
+		Subject:   token.subject,
+		Exp:       token.expiresAt.Unix(),
+		Iat:       token.issuedAt.Unix(),
+	}
+	if !strings.HasPrefix(token.subject, jwtUtils.ServiceAccountSubjectPrefix) {
+		resp.Username = user.Email
+	}
+	if token.claims != nil {
+		resp.Audience = token.claims.Audience
+		resp.Issuer = token.claims.Issuer
+		resp.JTI = token.claims.ID
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
+}
+
+// Revoke godoc
+//
+//	@Summary	Revoke a token (RFC 7009)
+//	@Description	Form encoded. Access tokens are denylisted, refresh tokens are revoked along with their family and sessions are signed out.
+//	@Description	Tokens issued to an OAuth client or service account can only be revoked by it. Tokens issued to no client (sessions,
+//	@Description	first-party refresh and access tokens) can only be revoked by service accounts with the users:write scope, whose owner
+//	@Description	has that permission too. Unknown and inactive tokens, and tokens the caller may not revoke, are ignored (RFC 7009 section 2.2).
+//	@Tags		oidc
+//	@Accept		x-www-form-urlencoded
+//	@Success	200	{object}	nil	"Token revoked, or already inactive"
+//	@Failure	400	{object}	nil	"OAuth error"
+//	@Failure	401	{object}	nil	"Invalid client credentials"
+//	@Router		/oauth2/revoke [POST]
+func (s *APIV1Service) Revoke(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	if err := req.ParseForm(); err != nil {
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidRequest, "malformed form body")
+	}
+	caller, basic, err := s.authenticateTokenCaller(ctx, req)
+	if err != nil {
+		return invalidClient(rw, basic, err)
+	}
+	raw := req.PostForm.Get("token")
+	if raw == "" {
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidRequest, "token is required")
+	}
+
+	token, err := s.introspectToken(ctx, raw, req.PostForm.Get("token_type_hint"))
+	if err != nil {
+		return errdefs.System(err)
+	}
+	// answer the same as for an unknown token, so callers can't probe the tokens of others
+	if token == nil || !caller.mayRevoke(token) {
+		rw.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	switch token.tokenType {
+	case tokenTypeAccessToken:
+		// tokens of service accounts are recorded without a user
+		var userID int64
+		if !strings.HasPrefix(token.subject, jwtUtils.ServiceAccountSubjectPrefix) {
+			userID = token.userID
+		}
+		err = s.Store.RevokeAccessToken(ctx, &store.RevokeAccessToken{
+			JTI:       token.claims.ID,
+			UserID:    userID,
+			ExpiresAt: token.expiresAt,
+		})
+	case tokenTypeRefreshToken:
+		_, err = s.Store.RevokeRefreshTokenFamily(ctx, token.refresh.FamilyID)
+	case tokenTypeSession:
+		_, err = s.Store.RevokeSession(ctx, token.session)
+	}
+	if err != nil {
+		return errdefs.System(err)
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// mayRevoke reports whether the caller may revoke token.
+func (c *tokenCaller) mayRevoke(token *introspectedToken) bool {
+	if token.clientID == "" {
+		return c.revokesUserTokens
+	}
+	return token.clientID == c.clientID
+}
+
+// authenticateTokenCaller authenticates the confidential OAuth client or the service
+// account calling the introspection or revocation endpoint.
+func (s *APIV1Service) authenticateTokenCaller(ctx context.Context, req *http.Request) (*tokenCaller, bool, error) {
+	clientID, secret, basic := clientCredentials(req)
+	account, err := s.Store.AuthenticateServiceAccount(ctx, clientID, secret)
+	if err == nil {
+		caller := &tokenCaller{clientID: account.ID}
+		if caller.revokesUserTokens, err = s.serviceAccountHas(ctx, account, store.PermissionUsersWrite); err != nil {
+			return nil, basic, err
+		}
+		return caller, basic, nil
+	}
+	if !errors.Is(err, store.ErrInvalidClientCredentials) {
+		return nil, basic, err
+	}
+
+	client, err := s.Store.AuthenticateOAuthClient(ctx, clientID, secret)
+	if err != nil {
+		return nil, basic, err
+	}
+	if client.IsPublic() {
+		return nil, basic, store.ErrInvalidClientCredentials
+	}
+	return &tokenCaller{clientID: client.ID}, basic, nil
+}
+
+// serviceAccountHas reports whether account was granted permission and its owner,
+// still active, holds it too.
+func (s *APIV1Service) serviceAccountHas(ctx context.Context, account *store.ServiceAccountInfo, permission store.Permission) (bool, error) {
+	if !slices.Contains(account.Scopes, string(permission)) {
+		return false, nil
+	}
+	owner, err := s.Store.GetUser(ctx, &store.FindUser{ID: &account.OwnerID})
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if owner.Status == store.StatusDisabled {
+		return false, nil
+	}
+	granted, err := s.Store.UserPermissions(ctx, owner.ID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(granted, permission), nil
+}
+
+// introspectToken looks raw up as every kind of token, starting with hint, and
+// returns nil if it is unknown, expired or revoked.
+func (s *APIV1Service) introspectToken(ctx context.Context, raw, hint string) (*introspectedToken, error) {
+	lookups := map[string]func(context.Context, string) (*introspectedToken, error){
+		tokenTypeAccessToken:  s.introspectAccessToken,
+		tokenTypeRefreshToken: s.introspectRefreshToken,
+		tokenTypeSession:      s.introspectSession,
+	}
+	order := []string{tokenTypeAccessToken, tokenTypeRefreshToken, tokenTypeSession}
+	if i := slices.Index(order, hint); i > 0 {
+		order = slices.Insert(slices.Delete(order, i, i+1), 0, hint)
+	}
+
+	for _, tokenType := range order {
+		token, err := lookups[tokenType](ctx, raw)
+		if err != nil || token != nil {
+			return token, err
+		}
+	}
+	return nil, nil
+}
+
+func (s *APIV1Service) introspectAccessToken(ctx context.Context, raw string) (*introspectedToken, error) {
+	// opaque tokens are never JWTs, don't bother parsing them
+	if strings.Count(raw, ".") != 2 {
+		return nil, nil
+	}
+	parsed, err := jwtUtils.ParseAccessToken(ctx, raw, s.Keys, s.Store.Now())
+	if err != nil {
+		if parsed, err = jwtUtils.ParseClientAccessToken(ctx, raw, s.Keys, s.Store.Now()); err != nil {
+			return nil, nil
+		}
+	}
+	claims, err := jwtUtils.GetClaimsFromToken(parsed)
+	if err != nil || claims.ID == "" || claims.IssuedAt == nil {
+		return nil, nil
+	}
+	revoked, err := s.Store.IsAccessTokenRevoked(ctx, claims.ID)
+	if err != nil || revoked {
+		return nil, err
+	}
+
+	token := &introspectedToken{
+		tokenType: tokenTypeAccessToken,
+		subject:   claims.Subject,
+		clientID:  claims.ClientID,
+		scope:     claims.Scope,
+		issuedAt:  claims.IssuedAt.Time,
+		expiresAt: claims.ExpiresAt.Time,
+		claims:    claims,
+	}
+	if serviceAccountID, ok := jwtUtils.GetServiceAccountIDFromToken(parsed); ok {
+		account, err := s.Store.GetServiceAccount(ctx, serviceAccountID)
+		if err != nil {
+			if errors.Is(err, store.ErrServiceAccountNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		token.userID = account.OwnerID
+		return token, nil
+	}
+	if token.userID, err = jwtUtils.GetUserIDFromToken(parsed); err != nil {
+		return nil, nil
+	}
+	return token, nil
+}
+
+func (s *APIV1Service) introspectRefreshToken(ctx context.Context, raw string) (*introspectedToken, error) {
+	refresh, err := s.Store.GetRefreshToken(ctx, raw)
+	if err != nil {
+		if errors.Is(err, store.ErrRefreshTokenNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !refresh.IsActive(s.Store.Now()) {
+		return nil, nil
+	}
+	return &introspectedToken{
+		tokenType: tokenTypeRefreshToken,
+		subject:   strconv.FormatInt(refresh.UserID, 10),
+		userID:    refresh.UserID,
+		issuedAt:  refresh.CreatedAt,
+		expiresAt: refresh.ExpiresAt,
+		refresh:   refresh,
+	}, nil
+}
+
+func (s *APIV1Service) introspectSession(ctx context.Context, raw string) (*introspectedToken, error) {
+	session, err := s.Store.GetActiveSessionByToken(ctx, raw)
+	if err != nil {
+		if errors.Is(err, sessionUtils.ErrSesssionExpired) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &introspectedToken{
+		tokenType: tokenTypeSession,
+		subject:   strconv.FormatInt(session.UserID, 10),
+		userID:    session.UserID,
+		issuedAt:  session.CreatedAt,
+		expiresAt: session.ExpiresAt,
+		session:   session,
+	}, nil
+}
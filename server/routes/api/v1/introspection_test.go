@@ -0,0 +1,268 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// credentials of a caller of the introspection and revocation endpoints.
+type credentials struct{ id, secret string }
+
+// callTokenEndpoint posts form to handler, authenticated with creds if set.
+func callTokenEndpoint(t *testing.T, handler httputil.APIFunc, creds credentials, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/introspect", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if creds.id != "" {
+		req.SetBasicAuth(creds.id, creds.secret)
+	}
+	rec := httptest.NewRecorder()
+	if err := handler(context.Background(), rec, req, nil); err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	return rec
+}
+
+func introspect(t *testing.T, svc *APIV1Service, creds credentials, token, hint string) *IntrospectionResp {
+	t.Helper()
+	rec := callTokenEndpoint(t, svc.Introspect, creds, url.Values{"token": {token}, "token_type_hint": {hint}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Introspect status = %d, body %s", rec.Code, rec.Body)
+	}
+	resp := &IntrospectionResp{}
+	if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+		t.Fatalf("decode introspection: %v", err)
+	}
+	return resp
+}
+
+func revoke(t *testing.T, svc *APIV1Service, creds credentials, token string) {
+	t.Helper()
+	rec := callTokenEndpoint(t, svc.Revoke, creds, url.Values{"token": {token}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Revoke status = %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+// tokenFixture holds a token of every kind for one user.
+type tokenFixture struct {
+	user    *store.UserInfo
+	session string
+	refresh string
+	access  string
+}
+
+func newTokenFixture(t *testing.T, svc *APIV1Service, email string) *tokenFixture {
+	t.Helper()
+	ctx := context.Background()
+	user := createTestUser(t, svc, email, store.RoleUser)
+	session, err := svc.Store.CreateSession(ctx, &store.CreateSession{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	refresh, err := svc.Store.CreateRefreshToken(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	return &tokenFixture{
+		user:    user,
+		session: session.Token,
+		refresh: refresh.Token,
+		access:  accessToken(t, svc, user, svc.Settings.AccessTokenTTL),
+	}
+}
+
+// accessToken signs a first-party access token for user that expires after ttl.
+func accessToken(t *testing.T, svc *APIV1Service, user *store.UserInfo, ttl time.Duration) string {
+	t.Helper()
+	now := svc.Store.Now()
+	raw, err := jwtUtils.GenerateAccessToken(user.Email, user.ID, uuid.NewString(), now, now.Add(ttl), svc.Keys.SigningKey())
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	return raw
+}
+
+func registerServiceAccount(t *testing.T, svc *APIV1Service, owner *store.UserInfo, scopes ...string) credentials {
+	t.Helper()
+	result, err := svc.Store.RegisterServiceAccount(context.Background(), &store.RegisterServiceAccount{
+		Name: "test", Scopes: scopes, OwnerID: owner.ID,
+	})
+	if err != nil {
+		t.Fatalf("RegisterServiceAccount: %v", err)
+	}
+	return credentials{result.ServiceAccount.ID, result.Secret}
+}
+
+func registerOAuthClient(t *testing.T, svc *APIV1Service, public bool) credentials {
+	t.Helper()
+	result, err := svc.Store.RegisterOAuthClient(context.Background(), &store.RegisterOAuthClient{
+		Name: "app", RedirectURIs: []string{"https://app.example.com/callback"}, Public: public,
+	})
+	if err != nil {
+		t.Fatalf("RegisterOAuthClient: %v", err)
+	}
+	return credentials{result.Client.ID, result.Secret}
+}
+
+func TestTokenEndpointsRejectUnauthenticatedCallers(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens := newTokenFixture(t, svc, "jane@example.com")
+	admin := createTestUser(t, svc, "admin@example.com", store.RoleAdmin)
+	account := registerServiceAccount(t, svc, admin, ScopeUsersWrite)
+	public := registerOAuthClient(t, svc, true)
+
+	for name, creds := range map[string]credentials{
+		"no credentials": {},
+		"wrong secret":   {account.id, "wrong"},
+		"unknown client": {"unknown", "secret"},
+		"public client":  {public.id, ""},
+	} {
+		for endpoint, handler := range map[string]httputil.APIFunc{"introspect": svc.Introspect, "revoke": svc.Revoke} {
+			rec := callTokenEndpoint(t, handler, creds, url.Values{"token": {tokens.session}})
+			if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), oauthErrInvalidClient) {
+				t.Errorf("%s with %s: status %d, body %s, want 401 invalid_client", endpoint, name, rec.Code, rec.Body)
+			}
+		}
+	}
+	if resp := introspect(t, svc, account, tokens.session, ""); !resp.Active {
+		t.Errorf("session revoked by an unauthenticated caller")
+	}
+}
+
+func TestIntrospectTokenTypeHint(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens := newTokenFixture(t, svc, "jane@example.com")
+	admin := createTestUser(t, svc, "admin@example.com", store.RoleAdmin)
+	caller := registerServiceAccount(t, svc, admin, ScopeUsersRead)
+
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{tokens.session, tokenTypeSession},
+		{tokens.refresh, tokenTypeRefreshToken},
+		{tokens.access, tokenTypeAccessToken},
+	}
+	// a wrong hint only changes the lookup order, every kind is still found
+	for _, tc := range tests {
+		for _, hint := range []string{"", tokenTypeAccessToken, tokenTypeRefreshToken, tokenTypeSession, "unknown"} {
+			resp := introspect(t, svc, caller, tc.token, hint)
+			if !resp.Active || resp.TokenType != tc.want {
+				t.Errorf("Introspect(%s, hint %q) = active %v, %q", tc.want, hint, resp.Active, resp.TokenType)
+				continue
+			}
+			if resp.Username != tokens.user.Email || resp.Exp == 0 {
+				t.Errorf("Introspect(%s, hint %q) = %+v, want the user and expiry", tc.want, hint, resp)
+			}
+		}
+	}
+
+	if resp := introspect(t, svc, caller, "unknown", tokenTypeSession); resp.Active {
+		t.Errorf("Introspect(unknown) = %+v, want inactive", resp)
+	}
+}
+
+func TestIntrospectInactiveTokens(t *testing.T) {
+	ctx := context.Background()
+	svc, clock := newTestService(t)
+	admin := createTestUser(t, svc, "admin@example.com", store.RoleAdmin)
+	caller := registerServiceAccount(t, svc, admin, ScopeUsersRead)
+
+	inactive := func(name, token string) {
+		t.Helper()
+		if resp := introspect(t, svc, caller, token, ""); resp.Active || resp.TokenType != "" || resp.Subject != "" {
+			t.Errorf("Introspect(%s) = %+v, want only active false", name, resp)
+		}
+	}
+
+	// expired
+	jane := newTokenFixture(t, svc, "jane@example.com")
+	inactive("expired access token", accessToken(t, svc, jane.user, -time.Minute))
+	clock.Advance(store.DefaultTokenOptions.RefreshTokenTTL + store.DefaultSessionOptions.Remember.MaxLifetime)
+	inactive("expired session", jane.session)
+	inactive("expired refresh token", jane.refresh)
+
+	// revoked
+	john := newTokenFixture(t, svc, "john@example.com")
+	if _, err := svc.Store.RevokeUserSessions(ctx, john.user.ID); err != nil {
+		t.Fatalf("RevokeUserSessions: %v", err)
+	}
+	if _, err := svc.Store.RevokeUserRefreshTokens(ctx, john.user.ID); err != nil {
+		t.Fatalf("RevokeUserRefreshTokens: %v", err)
+	}
+	inactive("revoked session", john.session)
+	inactive("revoked refresh token", john.refresh)
+	revoke(t, svc, registerServiceAccount(t, svc, admin, ScopeUsersWrite), john.access)
+	inactive("revoked access token", john.access)
+
+	// tokens of disabled users
+	ann := newTokenFixture(t, svc, "ann@example.com")
+	disabled := store.StatusDisabled
+	if _, err := svc.Store.UpdateUser(ctx, &store.UpdateUser{ID: ann.user.ID, Status: &disabled}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	inactive("session of a disabled user", ann.session)
+	inactive("refresh token of a disabled user", ann.refresh)
+	inactive("access token of a disabled user", ann.access)
+}
+
+func TestRevokeOnlyOwnTokens(t *testing.T) {
+	svc, _ := newTestService(t)
+	jane := newTokenFixture(t, svc, "jane@example.com")
+	admin := createTestUser(t, svc, "admin@example.com", store.RoleAdmin)
+	support := createTestUser(t, svc, "support@example.com", store.RoleSupport)
+	reader := registerServiceAccount(t, svc, admin, ScopeUsersRead)
+	client := registerOAuthClient(t, svc, false)
+	other := registerOAuthClient(t, svc, false)
+
+	active := func(name, token string, want bool) {
+		t.Helper()
+		if resp := introspect(t, svc, reader, token, ""); resp.Active != want {
+			t.Errorf("%s: active = %v, want %v", name, resp.Active, want)
+		}
+	}
+
+	// tokens issued to a client can only be revoked by it, others get the RFC 7009 no-op
+	now := svc.Store.Now()
+	clientToken, err := jwtUtils.GenerateClientAccessToken(jane.user.ID, client.id, "openid", uuid.NewString(), now, now.Add(time.Minute), svc.Keys.SigningKey())
+	if err != nil {
+		t.Fatalf("GenerateClientAccessToken: %v", err)
+	}
+	revoke(t, svc, other, clientToken)
+	revoke(t, svc, registerServiceAccount(t, svc, admin, ScopeUsersWrite), clientToken)
+	active("client token revoked by others", clientToken, true)
+	revoke(t, svc, client, clientToken)
+	active("client token revoked by its client", clientToken, false)
+
+	// tokens issued to no client need a service account holding users:write
+	demoted := registerServiceAccount(t, svc, support, ScopeUsersWrite)
+	for name, caller := range map[string]credentials{
+		"oauth client":                  client,
+		"service account without scope": reader,
+		"service account of a support":  demoted,
+	} {
+		for kind, token := range map[string]string{"session": jane.session, "refresh token": jane.refresh, "access token": jane.access} {
+			revoke(t, svc, caller, token)
+			active(kind+" revoked by "+name, token, true)
+		}
+	}
+
+	privileged := registerServiceAccount(t, svc, admin, ScopeUsersWrite)
+	for kind, token := range map[string]string{"session": jane.session, "refresh token": jane.refresh, "access token": jane.access} {
+		revoke(t, svc, privileged, token)
+		active(kind+" revoked by a privileged service account", token, false)
+	}
+}
@@ -0,0 +1,162 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const maxRedirectURIs = 10
+
+type CreateOAuthClientReq struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// Public clients (single page and native apps) get no secret and rely on PKCE alone.
+	Public bool `json:"public"`
+}
+
+func (c *CreateOAuthClientReq) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" || len(c.Name) > 100 {
+		return errors.New("name is required, maximum length is 100")
+	}
+	if len(c.RedirectURIs) == 0 || len(c.RedirectURIs) > maxRedirectURIs {
+		return fmt.Errorf("between 1 and %d redirect_uris are required", maxRedirectURIs)
+	}
+	for _, uri := range c.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type OAuthClientResp struct {
+	ClientID string `json:"client_id"`
+	// ClientSecret is only returned once, when the client is created.
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type OAuthClientListResp struct {
+	Clients []*OAuthClientResp `json:"clients"`
+}
+
+func newOAuthClientResp(c *store.OAuthClientInfo) *OAuthClientResp {
+	return &OAuthClientResp{
+		ClientID:     c.ID,
+		Name:         c.Name,
+		RedirectURIs: c.RedirectURIs,
+		Public:       c.IsPublic(),
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+// CreateOAuthClient godoc
+//
+//	@Summary	Register an application that signs its users in with OpenID Connect
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Success	201	{object}	OAuthClientResp	"Client, with its secret"
+//	@Failure	400	{object}	nil				"Invalid name or redirect URIs"
+//	@Router		/v1/admin/oauth/clients [POST]
+func (s *APIV1Service) CreateOAuthClient(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	createReq := CreateOAuthClientReq{}
+	if err := json.NewDecoder(req.Body).Decode(&createReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if err := createReq.Validate(); err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+
+	result, err := s.Store.RegisterOAuthClient(ctx, &store.RegisterOAuthClient{
+		Name:         createReq.Name,
+		RedirectURIs: createReq.RedirectURIs,
+		Public:       createReq.Public,
+	})
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to create oauth client: %w", err))
+	}
+
+	resp := newOAuthClientResp(result.Client)
+	resp.ClientSecret = result.Secret
+	rw.Header().Set("Cache-Control", "no-store")
+	return httputil.WriteRawJSON(rw, http.StatusCreated, resp)
+}
+
+// ListOAuthClients godoc
+//
+//	@Summary	List OpenID Connect clients
+//	@Tags		admin
+//	@Produce	json
+//	@Success	200	{object}	OAuthClientListResp	"Clients, oldest first"
+//	@Router		/v1/admin/oauth/clients [GET]
+func (s *APIV1Service) ListOAuthClients(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	list, err := s.Store.ListOAuthClients(ctx)
+	if err != nil {
+		return errdefs.System(err)
+	}
+
+	resp := OAuthClientListResp{Clients: make([]*OAuthClientResp, 0, len(list))}
+	for _, c := range list {
+		resp.Clients = append(resp.Clients, newOAuthClientResp(c))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
+}
+
+// DeleteOAuthClient godoc
+//
+//	@Summary	Delete an OpenID Connect client
+//	@Description	Consents given to the client are deleted, tokens already issued stay valid until they expire.
+//	@Tags		admin
+//	@Success	204	{object}	nil	"Client deleted"
+//	@Failure	404	{object}	nil	"Client not found"
+//	@Router		/v1/admin/oauth/clients/{client_id} [DELETE]
+func (s *APIV1Service) DeleteOAuthClient(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	if err := s.Store.DeleteOAuthClient(ctx, vars["client_id"]); err != nil {
+		if errors.Is(err, store.ErrOAuthClientNotFound) {
+			return errdefs.NotFound(err)
+		}
+		return errdefs.System(fmt.Errorf("failed to delete oauth client: %w", err))
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// validateRedirectURI accepts absolute https URIs without a fragment,
+// plain http only on loopback addresses for local development.
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("redirect_uri %q is not an absolute URI", uri)
+	}
+	if u.Fragment != "" || strings.ContainsAny(uri, " #") {
+		return fmt.Errorf("redirect_uri %q must not contain a fragment or spaces", uri)
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("redirect_uri %q must use https", uri)
+		}
+	default:
+		return fmt.Errorf("redirect_uri %q must use https", uri)
+	}
+	return nil
+}
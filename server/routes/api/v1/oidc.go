@@ -0,0 +1,582 @@
+package v1
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang-jwt/jwt/v5/request"
+	"github.com/google/uuid"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// Scopes supported by the OpenID Connect provider.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopePhone   = "phone"
+)
+
+var supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopePhone}
+
+// OAuth 2.0 error codes (RFC 6749 section 4.1.2.1 and 5.2, RFC 6750 section 3.1)
+const (
+	oauthErrInvalidRequest          = "invalid_request"
+	oauthErrInvalidClient           = "invalid_client"
+	oauthErrInvalidGrant            = "invalid_grant"
+	oauthErrUnsupportedGrantType    = "unsupported_grant_type"
+	oauthErrUnsupportedResponseType = "unsupported_response_type"
+	oauthErrInvalidScope            = "invalid_scope"
+	oauthErrAccessDenied            = "access_denied"
+	oauthErrConsentRequired         = "consent_required"
+	oauthErrInvalidToken            = "invalid_token"
+	oauthErrUnauthorizedClient      = "unauthorized_client"
+)
+
+// Grant types of the /oauth2/token endpoint
+const (
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeClientCredentials = "client_credentials"
+)
+
+// AuthorizeReq is the authorization request of a client, forwarded by the
+// /oauth2/authorize page along with the answer of the user on the consent screen.
+type AuthorizeReq struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	Nonce               string `json:"nonce"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	// Prompt "consent" always shows the consent screen, "none" never does.
+	Prompt string `json:"prompt"`
+	// Consent is "allow" or "deny" once the user answered the consent screen.
+	Consent string `json:"consent"`
+	// ConsentToken is the one of the consent screen, it must come with Consent.
+	ConsentToken string `json:"consent_token"`
+}
+
+type AuthorizeResp struct {
+	// RedirectTo sends the user back to the client, with a code or an error.
+	RedirectTo string `json:"redirect_to,omitempty"`
+	// ConsentRequired asks for the consent screen, listing Scopes for ClientName.
+	ConsentRequired bool     `json:"consent_required,omitempty"`
+	ClientName      string   `json:"client_name,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+	// ConsentToken is posted back with the answer, it ties the answer to the
+	// session and the request the consent screen was shown for.
+	ConsentToken string `json:"consent_token,omitempty"`
+}
+
+type OIDCTokenResp struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime of the access token in seconds.
+	ExpiresIn int64 `json:"expires_in"`
+	// IDToken is only issued for the authorization_code grant.
+	IDToken string `json:"id_token,omitempty"`
+	Scope   string `json:"scope"`
+}
+
+type UserInfoResp struct {
+	Subject string `json:"sub"`
+	jwtUtils.UserClaims
+}
+
+type OpenIDConfigurationResp struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type oauthErrorResp struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// Authorize godoc
+//
+//	@Summary	Answer the authorization request of an OAuth client for the signed in user
+//	@Description	Used by the /oauth2/authorize page. Returns where to redirect the user, or asks for the consent screen.
+//	@Tags		oidc
+//	@Accept		json
+//	@Produce	json
+//	@Success	200	{object}	AuthorizeResp	"Redirect or consent screen"
+//	@Failure	400	{object}	nil				"Unknown client or redirect_uri, the user must not be redirected"
+//	@Failure	403	{object}	nil				"consent without the consent_token of the consent screen"
+//	@Router		/v1/oidc/authorize [POST]
+func (s *APIV1Service) Authorize(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	authReq := AuthorizeReq{}
+	if err := json.NewDecoder(req.Body).Decode(&authReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+
+	// errors about the client are shown to the user, the others are sent to the client
+	client, err := s.Store.GetOAuthClient(ctx, authReq.ClientID)
+	if err != nil {
+		if errors.Is(err, store.ErrOAuthClientNotFound) {
+			return errdefs.InvalidParameter(errors.New("unknown client_id"))
+		}
+		return errdefs.System(err)
+	}
+	if !client.HasRedirectURI(authReq.RedirectURI) {
+		return errdefs.InvalidParameter(errors.New("redirect_uri is not registered for this client"))
+	}
+	redirectError := func(code, description string) error {
+		return httputil.WriteRawJSON(rw, http.StatusOK, &AuthorizeResp{
+			RedirectTo: s.authorizeRedirect(&authReq, url.Values{"error": {code}, "error_description": {description}}),
+		})
+	}
+
+	if authReq.ResponseType != "code" {
+		return redirectError(oauthErrUnsupportedResponseType, "only the code response type is supported")
+	}
+	scopes := parseScopes(authReq.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return redirectError(oauthErrInvalidScope, "the openid scope is required")
+	}
+	if authReq.CodeChallengeMethod != "S256" || !validPKCEValue(authReq.CodeChallenge) {
+		return redirectError(oauthErrInvalidRequest, "PKCE with the S256 method is required")
+	}
+
+	userID := router.UserIDFromContext(ctx)
+	session := router.SessionInfoFromContext(ctx)
+	if authReq.Consent != "" && !s.checkConsentToken(session, &authReq, scopes) {
+		return errdefs.Forbidden(errors.New("the consent screen was not answered, please try again"))
+	}
+	switch authReq.Consent {
+	case "deny":
+		return redirectError(oauthErrAccessDenied, "the user denied the request")
+	case "allow":
+		if err := s.Store.GrantOAuthConsent(ctx, userID, client.ID, scopes); err != nil {
+			return errdefs.System(fmt.Errorf("failed to save consent: %w", err))
+		}
+	case "":
+		consent, err := s.Store.GetOAuthConsent(ctx, userID, client.ID)
+		if err != nil && !errors.Is(err, store.ErrOAuthConsentNotFound) {
+			return errdefs.System(err)
+		}
+		if consent == nil || !consent.Covers(scopes) || authReq.Prompt == "consent" {
+			if authReq.Prompt == "none" {
+				return redirectError(oauthErrConsentRequired, "the user has not allowed the requested scopes")
+			}
+			return httputil.WriteRawJSON(rw, http.StatusOK, &AuthorizeResp{
+				ConsentRequired: true,
+				ClientName:      client.Name,
+				Scopes:          scopes,
+				ConsentToken:    s.consentToken(session, &authReq, scopes),
+			})
+		}
+	default:
+		return errdefs.InvalidParameter(fmt.Errorf("invalid consent %q", authReq.Consent))
+	}
+
+	authTime := s.Store.Now()
+	if session != nil {
+		authTime = session.CreatedAt
+	}
+	code, err := s.Store.CreateAuthorizationCode(ctx, &store.CreateAuthorizationCode{
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   authReq.RedirectURI,
+		Scopes:        scopes,
+		Nonce:         authReq.Nonce,
+		CodeChallenge: authReq.CodeChallenge,
+		AuthTime:      authTime,
+	})
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to create authorization code: %w", err))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, &AuthorizeResp{
+		RedirectTo: s.authorizeRedirect(&authReq, url.Values{"code": {code}}),
+	})
+}
+
+// OIDCToken godoc
+//
+//	@Summary	Exchange an authorization code, or service account credentials, for an access token
+//	@Description	Form encoded (RFC 6749). grant_type authorization_code also returns an ID token. Confidential clients authenticate with HTTP Basic or client_secret in the body, public clients send their client_id.
+//	@Description	grant_type client_credentials is for service accounts, scope defaults to all the scopes of the account.
+//	@Tags		oidc
+//	@Accept		x-www-form-urlencoded
+//	@Produce	json
+//	@Success	200	{object}	OIDCTokenResp	"Tokens"
+//	@Failure	400	{object}	nil				"OAuth error"
+//	@Failure	401	{object}	nil				"Invalid client credentials"
+//	@Router		/oauth2/token [POST]
+func (s *APIV1Service) OIDCToken(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	if err := req.ParseForm(); err != nil {
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidRequest, "malformed form body")
+	}
+	switch grantType := req.PostForm.Get("grant_type"); grantType {
+	case grantTypeAuthorizationCode:
+		return s.authorizationCodeGrant(ctx, rw, req)
+	case grantTypeClientCredentials:
+		return s.clientCredentialsGrant(ctx, rw, req)
+	default:
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrUnsupportedGrantType, fmt.Sprintf("unsupported grant_type %q", grantType))
+	}
+}
+
+func (s *APIV1Service) authorizationCodeGrant(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+	clientID, secret, basic := clientCredentials(req)
+	client, err := s.Store.AuthenticateOAuthClient(ctx, clientID, secret)
+	if err != nil {
+		return invalidClient(rw, basic, err)
+	}
+
+	code, err := s.Store.UseAuthorizationCode(ctx, req.PostForm.Get("code"))
+	if err != nil {
+		if errors.Is(err, store.ErrAuthorizationCodeNotFound) {
+			return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidGrant, err.Error())
+		}
+		return errdefs.System(err)
+	}
+	if code.ClientID != client.ID || code.RedirectURI != req.PostForm.Get("redirect_uri") {
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidGrant, "the code was issued to another client or redirect_uri")
+	}
+	if !verifyPKCE(code.CodeChallenge, req.PostForm.Get("code_verifier")) {
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidGrant, "invalid code_verifier")
+	}
+
+	user, err := s.Store.GetUser(ctx, &store.FindUser{ID: &code.UserID})
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidGrant, err.Error())
+		}
+		return errdefs.System(err)
+	}
+	if user.Status == store.StatusDisabled {
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidGrant, store.ErrUserDisabled.Error())
+	}
+
+	now := s.Store.Now()
+	expiresAt := now.Add(s.Settings.AccessTokenTTL)
+	key := s.Keys.SigningKey()
+	scope := strings.Join(code.Scopes, " ")
+	accessToken, err := jwtUtils.GenerateClientAccessToken(user.ID, client.ID, scope, uuid.NewString(), now, expiresAt, key)
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to sign access token: %w", err))
+	}
+	idToken, err := jwtUtils.GenerateIDToken(&jwtUtils.IDTokenClaims{
+		Nonce:      code.Nonce,
+		AuthTime:   jwt.NewNumericDate(code.AuthTime),
+		UserClaims: userClaims(user, code.Scopes),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.oidcIssuer(),
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  jwt.ClaimStrings{client.ID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}, key)
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to sign id token: %w", err))
+	}
+
+	return s.writeOAuthTokens(rw, &OIDCTokenResp{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.Settings.AccessTokenTTL / time.Second),
+		IDToken:     idToken,
+		Scope:       scope,
+	})
+}
+
+// clientCredentialsGrant issues an access token to a service account, no refresh
+// token: the account can authenticate again at any time (RFC 6749 section 4.4.3).
+func (s *APIV1Service) clientCredentialsGrant(ctx context.Context, rw http.ResponseWriter, req *http.Request) error {
+	clientID, secret, basic := clientCredentials(req)
+	account, err := s.Store.AuthenticateServiceAccount(ctx, clientID, secret)
+	if err != nil {
+		return invalidClient(rw, basic, err)
+	}
+
+	owner, err := s.Store.GetUser(ctx, &store.FindUser{ID: &account.OwnerID})
+	if err != nil {
+		return errdefs.System(err)
+	}
+	if owner.Status == store.StatusDisabled {
+		return writeOAuthError(rw, http.StatusBadRequest, oauthErrUnauthorizedClient, "the owner of the service account is disabled")
+	}
+
+	scopes := account.Scopes
+	if requested := strings.Fields(req.PostForm.Get("scope")); len(requested) > 0 {
+		if !account.AllowsScopes(requested) {
+			return writeOAuthError(rw, http.StatusBadRequest, oauthErrInvalidScope, "the service account is not allowed the requested scopes")
+		}
+		slices.Sort(requested)
+		scopes = slices.Compact(requested)
+	}
+
+	now := s.Store.Now()
+	scope := strings.Join(scopes, " ")
+	accessToken, err := jwtUtils.GenerateServiceAccessToken(
+		account.ID,
+		account.Name,
+		scope,
+		uuid.NewString(),
+		now,
+		now.Add(s.Settings.AccessTokenTTL),
+		s.Keys.SigningKey(),
+	)
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to sign access token: %w", err))
+	}
+
+	return s.writeOAuthTokens(rw, &OIDCTokenResp{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.Settings.AccessTokenTTL / time.Second),
+		Scope:       scope,
+	})
+}
+
+func (s *APIV1Service) writeOAuthTokens(rw http.ResponseWriter, resp *OIDCTokenResp) error {
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
+}
+
+// UserInfo godoc
+//
+//	@Summary	Claims about the user who granted the access token of an OAuth client
+//	@Tags		oidc
+//	@Produce	json
+//	@Success	200	{object}	UserInfoResp	"Claims allowed by the scopes of the token"
+//	@Failure	401	{object}	nil				"Invalid access token"
+//	@Router		/oauth2/userinfo [GET]
+func (s *APIV1Service) UserInfo(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	invalidToken := func(description string) error {
+		rw.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error=%q, error_description=%q`, oauthErrInvalidToken, description))
+		return writeOAuthError(rw, http.StatusUnauthorized, oauthErrInvalidToken, description)
+	}
+
+	raw, err := request.OAuth2Extractor.ExtractToken(req)
+	if err != nil {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		return errdefs.Unauthorized(errors.New("access token is required"))
+	}
+	token, err := jwtUtils.ParseClientAccessToken(ctx, raw, s.Keys, s.Store.Now())
+	if err != nil {
+		return invalidToken(jwtUtils.ErrJWTValidate.Error())
+	}
+	claims, err := jwtUtils.GetClaimsFromToken(token)
+	if err != nil || claims.ID == "" {
+		return invalidToken(jwtUtils.ErrJWTValidate.Error())
+	}
+	revoked, err := s.Store.IsAccessTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return errdefs.System(err)
+	}
+	if revoked {
+		return invalidToken(jwtUtils.ErrJWTRevoked.Error())
+	}
+	userID, err := jwtUtils.GetUserIDFromToken(token)
+	if err != nil {
+		return invalidToken(jwtUtils.ErrJWTUserIDNotFound.Error())
+	}
+
+	user, err := s.Store.GetUser(ctx, &store.FindUser{ID: &userID})
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return invalidToken(err.Error())
+		}
+		return errdefs.System(err)
+	}
+	if user.Status == store.StatusDisabled {
+		return invalidToken(store.ErrUserDisabled.Error())
+	}
+
+	return httputil.WriteRawJSON(rw, http.StatusOK, &UserInfoResp{
+		Subject:    strconv.FormatInt(user.ID, 10),
+		UserClaims: userClaims(user, strings.Fields(claims.Scope)),
+	})
+}
+
+// GetOpenIDConfiguration godoc
+//
+//	@Summary	OpenID Connect discovery document
+//	@Tags		oidc
+//	@Produce	json
+//	@Success	200	{object}	OpenIDConfigurationResp	"Provider metadata"
+//	@Router		/.well-known/openid-configuration [GET]
+func (s *APIV1Service) GetOpenIDConfiguration(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	issuer := s.oidcIssuer()
+	rw.Header().Set("Cache-Control", "public, max-age=3600")
+	return httputil.WriteRawJSON(rw, http.StatusOK, &OpenIDConfigurationResp{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth2/authorize",
+		TokenEndpoint:                     issuer + "/oauth2/token",
+		UserInfoEndpoint:                  issuer + "/oauth2/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             issuer + "/oauth2/introspect",
+		RevocationEndpoint:                issuer + "/oauth2/revoke",
+		ScopesSupported:                   slices.Concat(supportedScopes, serviceAccountScopes),
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{grantTypeAuthorizationCode, grantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{jwtUtils.AlgorithmRS256, jwtUtils.AlgorithmEdDSA},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "name", "picture", "phone_number"},
+	})
+}
+
+func (s *APIV1Service) oidcIssuer() string {
+	return strings.TrimSuffix(s.Settings.OIDCIssuer, "/")
+}
+
+// consentToken authenticates the consent screen shown to session for the client,
+// redirect_uri and scopes of authReq, so that a link or a form of another site
+// can't answer it on behalf of the user.
+func (s *APIV1Service) consentToken(session *store.SessionInfo, authReq *AuthorizeReq, scopes []string) string {
+	if session == nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte("oauth_consent:"+s.Settings.SecretKey))
+	mac.Write(session.TokenHash[:])
+	for _, v := range []string{authReq.ClientID, authReq.RedirectURI, strings.Join(scopes, " ")} {
+		mac.Write([]byte{0})
+		mac.Write([]byte(v))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkConsentToken reports whether authReq carries the consentToken of its consent screen.
+func (s *APIV1Service) checkConsentToken(session *store.SessionInfo, authReq *AuthorizeReq, scopes []string) bool {
+	want := s.consentToken(session, authReq, scopes)
+	return want != "" && hmac.Equal([]byte(want), []byte(authReq.ConsentToken))
+}
+
+// authorizeRedirect returns the redirect_uri of authReq with params, its state and the issuer.
+func (s *APIV1Service) authorizeRedirect(authReq *AuthorizeReq, params url.Values) string {
+	if authReq.State != "" {
+		params.Set("state", authReq.State)
+	}
+	// RFC 9207, lets clients talking to several providers tell them apart
+	params.Set("iss", s.oidcIssuer())
+
+	sep := "?"
+	if strings.Contains(authReq.RedirectURI, "?") {
+		sep = "&"
+	}
+	return authReq.RedirectURI + sep + params.Encode()
+}
+
+// userClaims returns the claims about user allowed by scopes.
+func userClaims(user *store.UserInfo, scopes []string) jwtUtils.UserClaims {
+	var claims jwtUtils.UserClaims
+	if slices.Contains(scopes, ScopeEmail) {
+		claims.Email = user.Email
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		if user.FullName != nil {
+			claims.Name = *user.FullName
+		}
+		if user.AvatarURL != nil {
+			claims.Picture = *user.AvatarURL
+		}
+	}
+	if slices.Contains(scopes, ScopePhone) && user.Telephone != nil {
+		claims.PhoneNumber = *user.Telephone
+	}
+	return claims
+}
+
+// parseScopes returns the supported scopes of a space separated list, unknown ones are ignored.
+func parseScopes(scope string) []string {
+	scopes := make([]string, 0, len(supportedScopes))
+	for _, s := range supportedScopes {
+		if slices.Contains(strings.Fields(scope), s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// validPKCEValue checks the length and alphabet of a code verifier or challenge (RFC 7636).
+func validPKCEValue(v string) bool {
+	if len(v) < 43 || len(v) > 128 {
+		return false
+	}
+	for _, c := range v {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func verifyPKCE(challenge, verifier string) bool {
+	if !validPKCEValue(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+// clientCredentials reads the client credentials from the Authorization header
+// (client_secret_basic) or the form body (client_secret_post, or none for public clients).
+func clientCredentials(req *http.Request) (id, secret string, basic bool) {
+	if id, secret, ok := req.BasicAuth(); ok {
+		// RFC 6749 section 2.3.1, both are form encoded
+		if v, err := url.QueryUnescape(id); err == nil {
+			id = v
+		}
+		if v, err := url.QueryUnescape(secret); err == nil {
+			secret = v
+		}
+		return id, secret, true
+	}
+	return req.PostForm.Get("client_id"), req.PostForm.Get("client_secret"), false
+}
+
+// invalidClient answers a failed client authentication.
+func invalidClient(rw http.ResponseWriter, basic bool, err error) error {
+	if !errors.Is(err, store.ErrInvalidClientCredentials) {
+		return errdefs.System(err)
+	}
+	if basic {
+		rw.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+	}
+	return writeOAuthError(rw, http.StatusUnauthorized, oauthErrInvalidClient, err.Error())
+}
+
+// writeOAuthError writes an error response as defined by RFC 6749 section 5.2.
+func writeOAuthError(rw http.ResponseWriter, status int, code, description string) error {
+	rw.Header().Set("Cache-Control", "no-store")
+	return httputil.WriteRawJSON(rw, status, &oauthErrorResp{Error: code, ErrorDescription: description})
+}
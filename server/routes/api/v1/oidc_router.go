@@ -0,0 +1,32 @@
+package v1
+
+import "github.com/sagarsuperuser/userprofile/internal/router"
+
+type oidcRouter struct {
+	backend *APIV1Service
+	routes  []router.Route
+}
+
+// NewOIDCRouter initializes a router for the OpenID Connect provider endpoints.
+// The authorization endpoint itself is the /oauth2/authorize page of the frontend.
+func NewOIDCRouter(svc *APIV1Service) router.Router {
+	r := &oidcRouter{backend: svc}
+	r.initRoutes()
+	return r
+}
+
+func (or *oidcRouter) Routes() []router.Route {
+	return or.routes
+}
+
+func (or *oidcRouter) initRoutes() {
+	sessionMW := router.AuthSession(or.backend.Store)
+	or.routes = []router.Route{
+		router.NewPostRoute("/oidc/authorize", or.backend.Authorize, sessionMW),
+		router.NewPostRoute("/oauth2/token", or.backend.OIDCToken),
+		router.NewGetRoute("/oauth2/userinfo", or.backend.UserInfo),
+		router.NewPostRoute("/oauth2/userinfo", or.backend.UserInfo),
+		router.NewPostRoute("/oauth2/introspect", or.backend.Introspect),
+		router.NewPostRoute("/oauth2/revoke", or.backend.Revoke),
+	}
+}
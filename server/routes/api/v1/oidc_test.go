@@ -0,0 +1,163 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// authorize posts authReq to the authorize endpoint with the session cookie token.
+func authorize(t *testing.T, svc *APIV1Service, token string, authReq AuthorizeReq) (int, *AuthorizeResp) {
+	t.Helper()
+	body, _ := json.Marshal(authReq)
+	req := withSession(httptest.NewRequest(http.MethodPost, "/v1/oidc/authorize", bytes.NewReader(body)), token)
+	rec := callRoute(t, req, nil, svc.Authorize, router.AuthSession(svc.Store))
+	resp := &AuthorizeResp{}
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+			t.Fatalf("decode authorize: %v", err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func TestAuthorizeConsentToken(t *testing.T) {
+	svc, _ := newTestService(t)
+	jane := createTestUser(t, svc, "jane@example.com", store.RoleUser)
+	session := createTestSession(t, svc, jane)
+	client := registerOAuthClient(t, svc, false)
+	authReq := AuthorizeReq{
+		ResponseType:        "code",
+		ClientID:            client.id,
+		RedirectURI:         "https://app.example.com/callback",
+		Scope:               "openid email",
+		CodeChallenge:       strings.Repeat("a", 43),
+		CodeChallengeMethod: "S256",
+	}
+
+	code, resp := authorize(t, svc, session, authReq)
+	if code != http.StatusOK || !resp.ConsentRequired || resp.ConsentToken == "" {
+		t.Fatalf("Authorize = %d %+v, want the consent screen with a consent token", code, resp)
+	}
+	consentToken := resp.ConsentToken
+
+	// an answer without the token of the consent screen, e.g. from a link, is rejected
+	authReq.Consent = "allow"
+	if code, _ := authorize(t, svc, session, authReq); code != http.StatusForbidden {
+		t.Errorf("Authorize(allow without token) = %d, want 403", code)
+	}
+	// as is the token of another session or request
+	authReq.ConsentToken = consentToken
+	if code, _ := authorize(t, svc, createTestSession(t, svc, jane), authReq); code != http.StatusForbidden {
+		t.Errorf("Authorize(token of another session) = %d, want 403", code)
+	}
+	authReq.Scope = "openid email phone"
+	if code, _ := authorize(t, svc, session, authReq); code != http.StatusForbidden {
+		t.Errorf("Authorize(token for other scopes) = %d, want 403", code)
+	}
+
+	authReq.Scope = "openid email"
+	code, resp = authorize(t, svc, session, authReq)
+	if code != http.StatusOK || !strings.Contains(resp.RedirectTo, "code=") {
+		t.Fatalf("Authorize(allow) = %d %+v, want a redirect with a code", code, resp)
+	}
+
+	// the consent is remembered
+	authReq.Consent, authReq.ConsentToken = "", ""
+	if code, resp = authorize(t, svc, session, authReq); code != http.StatusOK || resp.ConsentRequired {
+		t.Errorf("Authorize(after consent) = %d %+v, want a redirect", code, resp)
+	}
+}
+
+func TestAuthorizationCodeGrantUsesServiceClock(t *testing.T) {
+	svc, clock := newTestService(t)
+	jane := createTestUser(t, svc, "jane@example.com", store.RoleUser)
+	session := createTestSession(t, svc, jane)
+	signedInAt := clock.Now()
+	client := registerOAuthClient(t, svc, false)
+	verifier := strings.Repeat("v", 43)
+	challenge := sha256.Sum256([]byte(verifier))
+	authReq := AuthorizeReq{
+		ResponseType:        "code",
+		ClientID:            client.id,
+		RedirectURI:         "https://app.example.com/callback",
+		Scope:               "openid",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(challenge[:]),
+		CodeChallengeMethod: "S256",
+	}
+	_, resp := authorize(t, svc, session, authReq)
+	authReq.Consent, authReq.ConsentToken = "allow", resp.ConsentToken
+	clock.Advance(time.Minute)
+	_, resp = authorize(t, svc, session, authReq)
+	redirect, err := url.Parse(resp.RedirectTo)
+	if err != nil || redirect.Query().Get("code") == "" {
+		t.Fatalf("Authorize = %+v, want a redirect with a code", resp)
+	}
+
+	clock.Advance(time.Second)
+	rec := callTokenEndpoint(t, svc.OIDCToken, client, url.Values{
+		"grant_type":    {grantTypeAuthorizationCode},
+		"code":          {redirect.Query().Get("code")},
+		"redirect_uri":  {authReq.RedirectURI},
+		"code_verifier": {verifier},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("OIDCToken status = %d, body %s", rec.Code, rec.Body)
+	}
+	tokens := &OIDCTokenResp{}
+	if err := json.NewDecoder(rec.Body).Decode(tokens); err != nil {
+		t.Fatalf("decode tokens: %v", err)
+	}
+
+	// the tokens are stamped with the clock of the service, the one they are checked against
+	idToken := &jwtUtils.IDTokenClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokens.IDToken, idToken); err != nil {
+		t.Fatalf("parse id token: %v", err)
+	}
+	if !idToken.IssuedAt.Equal(clock.Now()) || !idToken.AuthTime.Equal(signedInAt) {
+		t.Errorf("id token issued at %v, auth_time %v, want %v and %v", idToken.IssuedAt, idToken.AuthTime, clock.Now(), signedInAt)
+	}
+	access, err := jwtUtils.ParseClientAccessToken(t.Context(), tokens.AccessToken, svc.Keys, clock.Now())
+	if err != nil {
+		t.Fatalf("ParseClientAccessToken: %v", err)
+	}
+	if iat, _ := access.Claims.GetIssuedAt(); !iat.Equal(clock.Now()) {
+		t.Errorf("access token issued at %v, want %v", iat, clock.Now())
+	}
+}
+
+func TestClientCredentialsGrantUsesServiceClock(t *testing.T) {
+	svc, clock := newTestService(t)
+	owner := createTestUser(t, svc, "jane@example.com", store.RoleUser)
+	creds := registerServiceAccount(t, svc, owner, ScopeUsersRead)
+
+	clock.Advance(time.Hour)
+	rec := callTokenEndpoint(t, svc.OIDCToken, creds, url.Values{"grant_type": {grantTypeClientCredentials}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("OIDCToken status = %d, body %s", rec.Code, rec.Body)
+	}
+	tokens := &OIDCTokenResp{}
+	if err := json.NewDecoder(rec.Body).Decode(tokens); err != nil {
+		t.Fatalf("decode tokens: %v", err)
+	}
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokens.AccessToken, claims); err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	if !claims.IssuedAt.Equal(clock.Now()) || !claims.ExpiresAt.Equal(clock.Now().Add(svc.Settings.AccessTokenTTL)) {
+		t.Errorf("access token issued at %v, expires at %v, want %v and %v", claims.IssuedAt, claims.ExpiresAt, clock.Now(), clock.Now().Add(svc.Settings.AccessTokenTTL))
+	}
+}
@@ -0,0 +1,179 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// Scopes personal access tokens can be granted, each one opens a set of user endpoints.
+const (
+	ScopeUserRead  = "user:read"
+	ScopeUserWrite = "user:write"
+)
+
+const (
+	defaultPersonalAccessTokenDays = 30
+	maxPersonalAccessTokens        = 50
+)
+
+var personalAccessTokenScopes = []string{ScopeUserRead, ScopeUserWrite}
+
+type CreatePersonalAccessTokenReq struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresInDays defaults to 30, maximum is 366.
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+func (c *CreatePersonalAccessTokenReq) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" || len(c.Name) > 100 {
+		return errors.New("name is required, maximum length is 100")
+	}
+	if len(c.Scopes) == 0 {
+		return errors.New("scopes are required")
+	}
+	for _, scope := range c.Scopes {
+		if !slices.Contains(personalAccessTokenScopes, scope) {
+			return fmt.Errorf("unknown scope %q, use %s", scope, strings.Join(personalAccessTokenScopes, ", "))
+		}
+	}
+	slices.Sort(c.Scopes)
+	c.Scopes = slices.Compact(c.Scopes)
+
+	maxDays := int(store.MaxPersonalAccessTokenLifetime / (24 * time.Hour))
+	if c.ExpiresInDays == 0 {
+		c.ExpiresInDays = defaultPersonalAccessTokenDays
+	}
+	if c.ExpiresInDays < 1 || c.ExpiresInDays > maxDays {
+		return fmt.Errorf("expires_in_days must be between 1 and %d", maxDays)
+	}
+	return nil
+}
+
+type PersonalAccessTokenResp struct {
+	ID int64 `json:"id"`
+	// Token is only returned once, when the token is created.
+	Token      string     `json:"token,omitempty"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type PersonalAccessTokenListResp struct {
+	Tokens []*PersonalAccessTokenResp `json:"tokens"`
+}
+
+func newPersonalAccessTokenResp(t *store.PersonalAccessTokenInfo) *PersonalAccessTokenResp {
+	return &PersonalAccessTokenResp{
+		ID:         t.ID,
+		Name:       t.Name,
+		Scopes:     t.Scopes,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+// CreatePersonalAccessToken godoc
+//
+//	@Summary	Create a personal access token for the current user
+//	@Description	The token is sent as Authorization: Bearer to the user endpoints its scopes open.
+//	@Tags		user
+//	@Accept		json
+//	@Produce	json
+//	@Success	201	{object}	PersonalAccessTokenResp	"Token, shown only once"
+//	@Failure	400	{object}	nil						"Invalid name, scopes or expiry"
+//	@Failure	409	{object}	nil						"Too many tokens"
+//	@Router		/v1/user/tokens [POST]
+func (s *APIV1Service) CreatePersonalAccessToken(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	createReq := CreatePersonalAccessTokenReq{}
+	if err := json.NewDecoder(req.Body).Decode(&createReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if err := createReq.Validate(); err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+
+	userID := router.UserIDFromContext(ctx)
+	list, err := s.Store.ListPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		return errdefs.System(err)
+	}
+	if len(list) >= maxPersonalAccessTokens {
+		return errdefs.Conflict(fmt.Errorf("a user can have at most %d personal access tokens", maxPersonalAccessTokens))
+	}
+
+	result, err := s.Store.IssuePersonalAccessToken(ctx, &store.IssuePersonalAccessToken{
+		UserID:    userID,
+		Name:      createReq.Name,
+		Scopes:    createReq.Scopes,
+		ExpiresAt: s.Store.Now().AddDate(0, 0, createReq.ExpiresInDays),
+	})
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to create personal access token: %w", err))
+	}
+
+	resp := newPersonalAccessTokenResp(result.PersonalAccessToken)
+	resp.Token = result.Token
+	rw.Header().Set("Cache-Control", "no-store")
+	return httputil.WriteRawJSON(rw, http.StatusCreated, resp)
+}
+
+// ListPersonalAccessTokens godoc
+//
+//	@Summary	List the personal access tokens of the current user
+//	@Description	Revoked tokens are left out, expired ones are listed until they are purged.
+//	@Tags		user
+//	@Produce	json
+//	@Success	200	{object}	PersonalAccessTokenListResp	"Tokens, newest first"
+//	@Router		/v1/user/tokens [GET]
+func (s *APIV1Service) ListPersonalAccessTokens(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	list, err := s.Store.ListPersonalAccessTokens(ctx, router.UserIDFromContext(ctx))
+	if err != nil {
+		return errdefs.System(err)
+	}
+
+	resp := PersonalAccessTokenListResp{Tokens: make([]*PersonalAccessTokenResp, 0, len(list))}
+	for _, t := range list {
+		resp.Tokens = append(resp.Tokens, newPersonalAccessTokenResp(t))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
+}
+
+// RevokePersonalAccessToken godoc
+//
+//	@Summary	Revoke a personal access token of the current user
+//	@Tags		user
+//	@Success	204	{object}	nil	"Token revoked"
+//	@Failure	404	{object}	nil	"Token not found"
+//	@Router		/v1/user/tokens/{id} [DELETE]
+func (s *APIV1Service) RevokePersonalAccessToken(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil || id < 1 {
+		return errdefs.InvalidParameter(fmt.Errorf("invalid token id %q", vars["id"]))
+	}
+	if err := s.Store.RevokePersonalAccessToken(ctx, router.UserIDFromContext(ctx), id); err != nil {
+		if errors.Is(err, store.ErrPersonalAccessTokenNotFound) {
+			return errdefs.NotFound(err)
+		}
+		return errdefs.System(fmt.Errorf("failed to revoke personal access token: %w", err))
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,33 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func TestCreatePersonalAccessTokenUsesServiceClock(t *testing.T) {
+	svc, clock := newTestService(t)
+	jane := createTestUser(t, svc, "jane@example.com", store.RoleUser)
+	session := createTestSession(t, svc, jane)
+
+	body := `{"name":"ci","scopes":["` + ScopeUserRead + `"],"expires_in_days":7}`
+	req := withSession(httptest.NewRequest(http.MethodPost, "/v1/user/tokens", strings.NewReader(body)), session)
+	rec := callRoute(t, req, nil, svc.CreatePersonalAccessToken, router.AuthSession(svc.Store))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreatePersonalAccessToken status = %d, body %s", rec.Code, rec.Body)
+	}
+	resp := &PersonalAccessTokenResp{}
+	if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+		t.Fatalf("decode token: %v", err)
+	}
+	// the expiry is counted from the clock the token is checked against
+	if want := clock.Now().AddDate(0, 0, 7); !resp.ExpiresAt.Equal(want) {
+		t.Errorf("token expires at %v, want %v", resp.ExpiresAt, want)
+	}
+}
@@ -0,0 +1,281 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+type CreateRoleReq struct {
+	Name        store.Role         `json:"name"`
+	Description string             `json:"description"`
+	Permissions []store.Permission `json:"permissions"`
+}
+
+func (c *CreateRoleReq) Validate() error {
+	if !roleNamePattern.MatchString(string(c.Name)) {
+		return errors.New("name must be 1 to 64 lowercase letters, digits, - or _, starting with a letter")
+	}
+	if c.Permissions == nil {
+		c.Permissions = []store.Permission{}
+	}
+	return validateRoleFields(c.Description, c.Permissions)
+}
+
+type UpdateRoleReq struct {
+	Description *string `json:"description"`
+	// Permissions replace the permissions of the role when given.
+	Permissions []store.Permission `json:"permissions"`
+}
+
+func (u *UpdateRoleReq) Validate() error {
+	var description string
+	if u.Description != nil {
+		description = *u.Description
+	}
+	return validateRoleFields(description, u.Permissions)
+}
+
+func validateRoleFields(description string, permissions []store.Permission) error {
+	if len(description) > 255 {
+		return errors.New("maximum length of description is 255")
+	}
+	for _, permission := range permissions {
+		if !slices.Contains(store.Permissions, permission) {
+			return fmt.Errorf("unknown permission %q", permission)
+		}
+	}
+	return nil
+}
+
+type RoleResp struct {
+	Name        store.Role         `json:"name"`
+	Description string             `json:"description"`
+	Builtin     bool               `json:"builtin"`
+	Permissions []store.Permission `json:"permissions"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+type RoleListResp struct {
+	Roles []*RoleResp `json:"roles"`
+}
+
+type PermissionListResp struct {
+	Permissions []store.Permission `json:"permissions"`
+}
+
+func newRoleResp(r *store.RoleInfo) *RoleResp {
+	permissions := r.Permissions
+	if permissions == nil {
+		permissions = []store.Permission{}
+	}
+	return &RoleResp{
+		Name:        r.Name,
+		Description: r.Description,
+		Builtin:     r.Builtin,
+		Permissions: permissions,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// ListRoles godoc
+//
+//	@Summary	List roles with their permissions
+//	@Tags		admin
+//	@Produce	json
+//	@Success	200	{object}	RoleListResp	"Roles, ordered by name"
+//	@Router		/v1/admin/roles [GET]
+func (s *APIV1Service) ListRoles(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	list, err := s.Store.ListRoles(ctx)
+	if err != nil {
+		return errdefs.System(err)
+	}
+
+	resp := RoleListResp{Roles: make([]*RoleResp, 0, len(list))}
+	for _, r := range list {
+		resp.Roles = append(resp.Roles, newRoleResp(r))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
+}
+
+// GetRole godoc
+//
+//	@Summary	Get a role
+//	@Tags		admin
+//	@Produce	json
+//	@Success	200	{object}	RoleResp	"Role"
+//	@Failure	404	{object}	nil			"Role not found"
+//	@Router		/v1/admin/roles/{name} [GET]
+func (s *APIV1Service) GetRole(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	role, err := s.Store.GetRole(ctx, store.Role(vars["name"]))
+	if err != nil {
+		if errors.Is(err, store.ErrRoleNotFound) {
+			return errdefs.NotFound(err)
+		}
+		return errdefs.System(err)
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, newRoleResp(role))
+}
+
+// CreateRole godoc
+//
+//	@Summary	Create a custom role
+//	@Description	Callers can only grant permissions they hold themselves.
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Success	201	{object}	RoleResp	"Role"
+//	@Failure	400	{object}	nil			"Invalid name, description or permissions"
+//	@Failure	403	{object}	nil			"The caller lacks some of the permissions"
+//	@Failure	409	{object}	nil			"Role already exists"
+//	@Router		/v1/admin/roles [POST]
+func (s *APIV1Service) CreateRole(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	createReq := CreateRoleReq{}
+	if err := json.NewDecoder(req.Body).Decode(&createReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if err := createReq.Validate(); err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	if err := checkHasPermissions(ctx, s.Store, createReq.Permissions); err != nil {
+		return err
+	}
+
+	role, err := s.Store.CreateRole(ctx, &store.CreateRole{
+		Name:        createReq.Name,
+		Description: strings.TrimSpace(createReq.Description),
+		Permissions: createReq.Permissions,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrRoleAlreadyExists) {
+			return errdefs.Conflict(err)
+		}
+		return errdefs.System(fmt.Errorf("failed to create role: %w", err))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusCreated, newRoleResp(role))
+}
+
+// UpdateRole godoc
+//
+//	@Summary	Change the description or permissions of a custom role
+//	@Description	Users with the role get the new permissions right away. Callers can only grant permissions they hold themselves.
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Success	200	{object}	RoleResp	"Updated role"
+//	@Failure	400	{object}	nil			"Invalid description or permissions"
+//	@Failure	403	{object}	nil			"Built-in role, or the caller lacks some of the permissions"
+//	@Failure	404	{object}	nil			"Role not found"
+//	@Router		/v1/admin/roles/{name} [PATCH]
+func (s *APIV1Service) UpdateRole(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	updateReq := UpdateRoleReq{}
+	if err := json.NewDecoder(req.Body).Decode(&updateReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if err := updateReq.Validate(); err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	if err := checkHasPermissions(ctx, s.Store, updateReq.Permissions); err != nil {
+		return err
+	}
+	if updateReq.Description != nil {
+		description := strings.TrimSpace(*updateReq.Description)
+		updateReq.Description = &description
+	}
+
+	role, err := s.Store.UpdateRole(ctx, &store.UpdateRole{
+		Name:        store.Role(vars["name"]),
+		Description: updateReq.Description,
+		Permissions: updateReq.Permissions,
+	})
+	if err != nil {
+		return roleError(err, "update")
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, newRoleResp(role))
+}
+
+// DeleteRole godoc
+//
+//	@Summary	Delete a custom role
+//	@Tags		admin
+//	@Success	204	{object}	nil	"Role deleted"
+//	@Failure	403	{object}	nil	"Built-in role"
+//	@Failure	404	{object}	nil	"Role not found"
+//	@Failure	409	{object}	nil	"Role is assigned to users"
+//	@Router		/v1/admin/roles/{name} [DELETE]
+func (s *APIV1Service) DeleteRole(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	if err := s.Store.DeleteRole(ctx, store.Role(vars["name"])); err != nil {
+		return roleError(err, "delete")
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// ListPermissions godoc
+//
+//	@Summary	List the permissions roles can be granted
+//	@Tags		admin
+//	@Produce	json
+//	@Success	200	{object}	PermissionListResp	"Permissions"
+//	@Router		/v1/admin/permissions [GET]
+func (s *APIV1Service) ListPermissions(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	return httputil.WriteRawJSON(rw, http.StatusOK, PermissionListResp{Permissions: store.Permissions})
+}
+
+func roleError(err error, action string) error {
+	switch {
+	case errors.Is(err, store.ErrRoleNotFound):
+		return errdefs.NotFound(err)
+	case errors.Is(err, store.ErrRoleBuiltin):
+		return errdefs.Forbidden(err)
+	case errors.Is(err, store.ErrRoleInUse):
+		return errdefs.Conflict(err)
+	default:
+		return errdefs.System(fmt.Errorf("failed to %s role: %w", action, err))
+	}
+}
+
+// checkCanAssignRole checks that the caller holds every permission of a role,
+// an unknown role is an invalid parameter.
+func (s *APIV1Service) checkCanAssignRole(ctx context.Context, name store.Role) error {
+	role, err := s.Store.GetRole(ctx, name)
+	if err != nil {
+		if errors.Is(err, store.ErrRoleNotFound) {
+			return errdefs.InvalidParameter(err)
+		}
+		return errdefs.System(err)
+	}
+	return checkHasPermissions(ctx, s.Store, role.Permissions)
+}
+
+// checkHasPermissions keeps callers from granting permissions they don't have.
+func checkHasPermissions(ctx context.Context, s *store.Store, permissions []store.Permission) error {
+	principal := router.PrincipalFromContext(ctx)
+	if principal == nil {
+		return errdefs.System(errors.New("principal not found in context"))
+	}
+	allowed, err := router.HasPermissions(ctx, s, principal, permissions...)
+	if err != nil {
+		return errdefs.System(err)
+	}
+	if !allowed {
+		return errdefs.Forbidden(router.ErrMissingPermission)
+	}
+	return nil
+}
@@ -1,23 +1,29 @@
 package v1
 
 import (
+	"context"
+
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
 	oauth2Utils "github.com/sagarsuperuser/userprofile/internal/oauth2"
 	"github.com/sagarsuperuser/userprofile/server/settings"
 	"github.com/sagarsuperuser/userprofile/store"
-	"golang.org/x/oauth2"
 )
 
 // APIV1Service holds shared dependencies for v1 routes.
 type APIV1Service struct {
-	Settings    *settings.Settings
-	Store       *store.Store
-	OAuthConfig *oauth2.Config
+	Settings *settings.Settings
+	Store    *store.Store
+	// Providers are the external identity providers users can sign in with.
+	Providers *oauth2Utils.Registry
+	// Keys sign and verify access tokens
+	Keys *jwtUtils.KeySet
 }
 
-func NewAPIV1Service(s *settings.Settings, store *store.Store) *APIV1Service {
+func NewAPIV1Service(s *settings.Settings, store *store.Store, keys *jwtUtils.KeySet) *APIV1Service {
 	return &APIV1Service{
-		Settings:    s,
-		Store:       store,
-		OAuthConfig: oauth2Utils.NewOAuth2Config(s),
+		Settings:  s,
+		Store:     store,
+		Providers: oauth2Utils.NewRegistry(context.Background(), s),
+		Keys:      keys,
 	}
 }
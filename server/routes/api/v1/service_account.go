@@ -0,0 +1,156 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// Scopes service accounts can be granted, each one opens a set of admin endpoints.
+// They double as the permissions of service accounts.
+const (
+	ScopeUsersRead  = string(store.PermissionUsersRead)
+	ScopeUsersWrite = string(store.PermissionUsersWrite)
+)
+
+var serviceAccountScopes = []string{ScopeUsersRead, ScopeUsersWrite}
+
+type CreateServiceAccountReq struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+func (c *CreateServiceAccountReq) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" || len(c.Name) > 100 {
+		return errors.New("name is required, maximum length is 100")
+	}
+	if len(c.Scopes) == 0 {
+		return errors.New("scopes are required")
+	}
+	for _, scope := range c.Scopes {
+		if !slices.Contains(serviceAccountScopes, scope) {
+			return fmt.Errorf("unknown scope %q, use %s", scope, strings.Join(serviceAccountScopes, ", "))
+		}
+	}
+	slices.Sort(c.Scopes)
+	c.Scopes = slices.Compact(c.Scopes)
+	return nil
+}
+
+type ServiceAccountResp struct {
+	ClientID string `json:"client_id"`
+	// ClientSecret is only returned once, when the account is created.
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	Scopes       []string  `json:"scopes"`
+	OwnerID      int64     `json:"owner_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ServiceAccountListResp struct {
+	ServiceAccounts []*ServiceAccountResp `json:"service_accounts"`
+}
+
+func newServiceAccountResp(a *store.ServiceAccountInfo) *ServiceAccountResp {
+	return &ServiceAccountResp{
+		ClientID:  a.ID,
+		Name:      a.Name,
+		Scopes:    a.Scopes,
+		OwnerID:   a.OwnerID,
+		CreatedAt: a.CreatedAt,
+	}
+}
+
+// CreateServiceAccount godoc
+//
+//	@Summary	Register a service account, owned by the current admin
+//	@Description	The account gets access tokens from POST /oauth2/token with the client_credentials grant.
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Success	201	{object}	ServiceAccountResp	"Service account, with its secret"
+//	@Failure	400	{object}	nil					"Invalid name or scopes"
+//	@Failure	403	{object}	nil					"Scopes the current admin doesn't have"
+//	@Router		/v1/admin/service-accounts [POST]
+func (s *APIV1Service) CreateServiceAccount(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	createReq := CreateServiceAccountReq{}
+	if err := json.NewDecoder(req.Body).Decode(&createReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if err := createReq.Validate(); err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	// scopes are permissions, nobody can hand out more than they hold
+	permissions := make([]store.Permission, 0, len(createReq.Scopes))
+	for _, scope := range createReq.Scopes {
+		permissions = append(permissions, store.Permission(scope))
+	}
+	if err := checkHasPermissions(ctx, s.Store, permissions); err != nil {
+		return err
+	}
+
+	result, err := s.Store.RegisterServiceAccount(ctx, &store.RegisterServiceAccount{
+		Name:    createReq.Name,
+		Scopes:  createReq.Scopes,
+		OwnerID: router.UserIDFromContext(ctx),
+	})
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to create service account: %w", err))
+	}
+
+	resp := newServiceAccountResp(result.ServiceAccount)
+	resp.ClientSecret = result.Secret
+	rw.Header().Set("Cache-Control", "no-store")
+	return httputil.WriteRawJSON(rw, http.StatusCreated, resp)
+}
+
+// ListServiceAccounts godoc
+//
+//	@Summary	List service accounts
+//	@Tags		admin
+//	@Produce	json
+//	@Success	200	{object}	ServiceAccountListResp	"Service accounts, oldest first"
+//	@Router		/v1/admin/service-accounts [GET]
+func (s *APIV1Service) ListServiceAccounts(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	list, err := s.Store.ListServiceAccounts(ctx)
+	if err != nil {
+		return errdefs.System(err)
+	}
+
+	resp := ServiceAccountListResp{ServiceAccounts: make([]*ServiceAccountResp, 0, len(list))}
+	for _, a := range list {
+		resp.ServiceAccounts = append(resp.ServiceAccounts, newServiceAccountResp(a))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
+}
+
+// DeleteServiceAccount godoc
+//
+//	@Summary	Delete a service account
+//	@Description	Access tokens already issued to the account are rejected from then on.
+//	@Tags		admin
+//	@Success	204	{object}	nil	"Service account deleted"
+//	@Failure	404	{object}	nil	"Service account not found"
+//	@Router		/v1/admin/service-accounts/{client_id} [DELETE]
+func (s *APIV1Service) DeleteServiceAccount(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	if err := s.Store.DeleteServiceAccount(ctx, vars["client_id"]); err != nil {
+		if errors.Is(err, store.ErrServiceAccountNotFound) {
+			return errdefs.NotFound(err)
+		}
+		return errdefs.System(fmt.Errorf("failed to delete service account: %w", err))
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
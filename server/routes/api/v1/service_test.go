@@ -0,0 +1,100 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
+	"github.com/sagarsuperuser/userprofile/server/httpstatus"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/db/memory"
+	"github.com/sagarsuperuser/userprofile/store/storetest"
+)
+
+// newTestService returns a service backed by the memory driver and a fake clock.
+func newTestService(t *testing.T) (*APIV1Service, *storetest.Clock) {
+	t.Helper()
+	clock := storetest.NewClock(time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+	s := &settings.Settings{
+		SecretKey:       "secret",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 24 * time.Hour,
+	}
+	st := store.New(memory.NewDB(s, clock.Now), clock.Now, store.Options{})
+
+	key, err := jwtUtils.GenerateKey(jwtUtils.AlgorithmEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	keys, err := jwtUtils.NewKeySet(context.Background(), func(context.Context) (*jwtUtils.Key, []*jwtUtils.Key, error) {
+		return key, nil, nil
+	})
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return &APIV1Service{Settings: s, Store: st, Keys: keys}, clock
+}
+
+func createTestUser(t *testing.T, svc *APIV1Service, email string, role store.Role) *store.UserInfo {
+	t.Helper()
+	user, err := svc.Store.CreateLocalUser(context.Background(), &store.CreateLocalUser{
+		Email:        email,
+		PasswordHash: "hash",
+		Status:       store.StatusActive,
+		Role:         role,
+	})
+	if err != nil {
+		t.Fatalf("CreateLocalUser(%q): %v", email, err)
+	}
+	return user
+}
+
+// callRoute calls handler behind wrappers like the router does, the status of an
+// error it returns is recorded along with its message.
+func callRoute(t *testing.T, req *http.Request, vars map[string]string, handler httputil.APIFunc, wrappers ...router.RouteWrapper) *httptest.ResponseRecorder {
+	t.Helper()
+	route := router.NewRoute(req.Method, req.URL.Path, handler, wrappers...)
+	rec := httptest.NewRecorder()
+	if err := route.Handler()(req.Context(), rec, req, vars); err != nil {
+		httputil.WriteRawJSON(rec, httpstatus.FromError(err), map[string]string{"message": err.Error()})
+	}
+	return rec
+}
+
+// callRouter calls the route of r registered for method and path with its
+// wrappers, as the caller authenticated by auth.
+func callRouter(t *testing.T, r router.Router, auth func(*http.Request) *http.Request, method, path string, vars map[string]string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	for _, route := range r.Routes() {
+		if route.Method() != method || route.Path() != path {
+			continue
+		}
+		req := auth(httptest.NewRequest(method, "/v1"+path, strings.NewReader(body)))
+		return callRoute(t, req, vars, route.Handler())
+	}
+	t.Fatalf("no route %s %s", method, path)
+	return nil
+}
+
+// withSession adds the session cookie to req.
+func withSession(req *http.Request, token string) *http.Request {
+	req.AddCookie(&http.Cookie{Name: sessionUtils.SessionCookieName, Value: token})
+	return req
+}
+
+func createTestSession(t *testing.T, svc *APIV1Service, user *store.UserInfo) string {
+	t.Helper()
+	result, err := svc.Store.CreateSession(context.Background(), &store.CreateSession{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return result.Token
+}
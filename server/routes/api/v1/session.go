@@ -0,0 +1,194 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+type SessionResp struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	// Current is true for the session that made the request.
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type SessionListResp struct {
+	Sessions []*SessionResp `json:"sessions"`
+}
+
+type RevokeSessionsResp struct {
+	Revoked int64 `json:"revoked"`
+}
+
+type UpdateSessionRequest struct {
+	Name *string `json:"name"`
+}
+
+func (update UpdateSessionRequest) Validate() error {
+	if update.Name == nil {
+		return errors.New("name is required")
+	}
+	if len(*update.Name) > 100 {
+		return errors.New("name is too long, maximum length is 100")
+	}
+	return nil
+}
+
+func newSessionResp(s *store.SessionInfo, current *store.SessionInfo) *SessionResp {
+	return &SessionResp{
+		ID:         s.ID,
+		Name:       s.Name,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		Current:    s.ID == current.ID,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+	}
+}
+
+// ListSessions godoc
+//
+//	@Summary	List the signed in devices of the logged in user
+//	@Tags		session
+//	@Produce	json
+//	@Success	200	{object}	SessionListResp	"Active sessions, most recently used first"
+//	@Router		/v1/sessions [GET]
+func (s *APIV1Service) ListSessions(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	sInfo := router.SessionInfoFromContext(ctx)
+	if sInfo == nil {
+		return errdefs.System(errors.New("session info not found in context"))
+	}
+
+	list, err := s.Store.ListSessions(ctx, sInfo.UserID)
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to list sessions: %w", err))
+	}
+
+	resp := &SessionListResp{Sessions: make([]*SessionResp, 0, len(list))}
+	for _, session := range list {
+		resp.Sessions = append(resp.Sessions, newSessionResp(session, sInfo))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, resp)
+}
+
+// UpdateSession godoc
+//
+//	@Summary	Name a device
+//	@Tags		session
+//	@Accept		json
+//	@Produce	json
+//	@Success	200	{object}	SessionResp	"Updated session"
+//	@Failure	404	{object}	nil			"Session not found"
+//	@Router		/v1/sessions/{id} [PATCH]
+func (s *APIV1Service) UpdateSession(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	sInfo := router.SessionInfoFromContext(ctx)
+	if sInfo == nil {
+		return errdefs.System(errors.New("session info not found in context"))
+	}
+	session, err := s.findUserSession(ctx, sInfo.UserID, vars)
+	if err != nil {
+		return err
+	}
+
+	uReq := UpdateSessionRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&uReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if err := uReq.Validate(); err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+	name := strings.TrimSpace(*uReq.Name)
+
+	updated, err := s.Store.UpdateSession(ctx, &store.UpdateSession{ID: session.ID, Name: &name})
+	if err != nil {
+		if errors.Is(err, store.ErrSessionNotFound) {
+			return errdefs.NotFound(err)
+		}
+		return errdefs.System(fmt.Errorf("failed to update session: %w", err))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, newSessionResp(updated, sInfo))
+}
+
+// RevokeSession godoc
+//
+//	@Summary	Sign out a device
+//	@Tags		session
+//	@Success	204	{object}	nil	"Session revoked"
+//	@Failure	404	{object}	nil	"Session not found"
+//	@Router		/v1/sessions/{id} [DELETE]
+func (s *APIV1Service) RevokeSession(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	sInfo := router.SessionInfoFromContext(ctx)
+	if sInfo == nil {
+		return errdefs.System(errors.New("session info not found in context"))
+	}
+	session, err := s.findUserSession(ctx, sInfo.UserID, vars)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.Store.RevokeSession(ctx, session); err != nil {
+		return errdefs.System(fmt.Errorf("failed to revoke session: %w", err))
+	}
+	// revoking the current session is a logout
+	if session.ID == sInfo.ID {
+		sessionUtils.ClearSessionCookie(rw)
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// RevokeOtherSessions godoc
+//
+//	@Summary	Sign out all other devices
+//	@Tags		session
+//	@Produce	json
+//	@Success	200	{object}	RevokeSessionsResp	"Number of revoked sessions"
+//	@Router		/v1/sessions/revoke-others [POST]
+func (s *APIV1Service) RevokeOtherSessions(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	sInfo := router.SessionInfoFromContext(ctx)
+	if sInfo == nil {
+		return errdefs.System(errors.New("session info not found in context"))
+	}
+
+	n, err := s.Store.RevokeOtherSessions(ctx, sInfo)
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to revoke sessions: %w", err))
+	}
+	return httputil.WriteRawJSON(rw, http.StatusOK, &RevokeSessionsResp{Revoked: n})
+}
+
+// findUserSession returns the session in vars, sessions of other users are not found.
+func (s *APIV1Service) findUserSession(ctx context.Context, userID int64, vars map[string]string) (*store.SessionInfo, error) {
+	sessionID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil || sessionID < 1 {
+		return nil, errdefs.InvalidParameter(fmt.Errorf("invalid session id %q", vars["id"]))
+	}
+
+	session, err := s.Store.GetSession(ctx, &store.FindSession{ID: &sessionID, UserID: &userID})
+	if err != nil {
+		if errors.Is(err, store.ErrSessionNotFound) {
+			return nil, errdefs.NotFound(err)
+		}
+		return nil, errdefs.System(err)
+	}
+	return session, nil
+}
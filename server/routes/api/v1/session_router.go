@@ -0,0 +1,29 @@
+package v1
+
+import "github.com/sagarsuperuser/userprofile/internal/router"
+
+type sessionRouter struct {
+	backend *APIV1Service
+	routes  []router.Route
+}
+
+// NewSessionRouter initializes a router for the device (session) endpoints.
+func NewSessionRouter(svc *APIV1Service) router.Router {
+	r := &sessionRouter{backend: svc}
+	r.initRoutes()
+	return r
+}
+
+func (sr *sessionRouter) Routes() []router.Route {
+	return sr.routes
+}
+
+func (sr *sessionRouter) initRoutes() {
+	sessionMW := router.AuthSession(sr.backend.Store)
+	sr.routes = []router.Route{
+		router.NewGetRoute("/sessions", sr.backend.ListSessions, sessionMW),
+		router.NewPostRoute("/sessions/revoke-others", sr.backend.RevokeOtherSessions, sessionMW),
+		router.NewPatchRoute("/sessions/{id:[0-9]+}", sr.backend.UpdateSession, sessionMW),
+		router.NewDeleteRoute("/sessions/{id:[0-9]+}", sr.backend.RevokeSession, sessionMW),
+	}
+}
@@ -0,0 +1,212 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sagarsuperuser/userprofile/errdefs"
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
+	"github.com/sagarsuperuser/userprofile/internal/router"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const (
+	// GrantTypePassword exchanges a username and password for tokens.
+	GrantTypePassword = "password"
+	// GrantTypeSession exchanges the session cookie of the request for tokens.
+	GrantTypeSession = "session"
+)
+
+type TokenReq struct {
+	GrantType string `json:"grant_type"`
+	// Username and Password are required by the password grant.
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (t *TokenReq) Validate() error {
+	switch t.GrantType {
+	case GrantTypePassword:
+		if strings.TrimSpace(t.Username) == "" || strings.TrimSpace(t.Password) == "" {
+			return errors.New("username or password is required")
+		}
+	case GrantTypeSession:
+	case "":
+		return errors.New("grant_type is required")
+	default:
+		return fmt.Errorf("unsupported grant_type %q", t.GrantType)
+	}
+	return nil
+}
+
+type RefreshTokenReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResp struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime of the access token in seconds.
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// IssueToken godoc
+//
+//	@Summary	Exchange credentials or a session for an access and a refresh token
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Success	200	{object}	TokenResp	"Tokens"
+//	@Failure	401	{object}	nil			"Invalid credentials or session"
+//	@Router		/v1/auth/token [POST]
+func (s *APIV1Service) IssueToken(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	tokenReq := TokenReq{}
+	if err := json.NewDecoder(req.Body).Decode(&tokenReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if err := tokenReq.Validate(); err != nil {
+		return errdefs.InvalidParameter(err)
+	}
+
+	var (
+		user *store.UserInfo
+		err  error
+	)
+	switch tokenReq.GrantType {
+	case GrantTypePassword:
+		user, err = s.authenticatePassword(ctx, tokenReq.Username, tokenReq.Password)
+	case GrantTypeSession:
+		_, user, err = router.AuthenticateSession(ctx, s.Store, rw, req)
+	}
+	if err != nil {
+		return err
+	}
+
+	refreshToken, err := s.Store.CreateRefreshToken(ctx, user.ID)
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to create refresh token: %w", err))
+	}
+	return s.writeTokens(rw, user, refreshToken)
+}
+
+// RefreshToken godoc
+//
+//	@Summary	Rotate a refresh token
+//	@Description	Every refresh token can be used once. Using it again revokes all the tokens rotated from the same grant.
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Success	200	{object}	TokenResp	"New tokens"
+//	@Failure	401	{object}	nil			"Invalid, expired or reused refresh token"
+//	@Router		/v1/auth/token/refresh [POST]
+func (s *APIV1Service) RefreshToken(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	refreshReq := RefreshTokenReq{}
+	if err := json.NewDecoder(req.Body).Decode(&refreshReq); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+	if refreshReq.RefreshToken == "" {
+		return errdefs.InvalidParameter(errors.New("refresh_token is required"))
+	}
+
+	refreshToken, err := s.Store.RotateRefreshToken(ctx, refreshReq.RefreshToken)
+	if err != nil {
+		if errors.Is(err, store.ErrRefreshTokenNotFound) || errors.Is(err, store.ErrRefreshTokenReused) {
+			return errdefs.Unauthorized(err)
+		}
+		return errdefs.System(fmt.Errorf("failed to rotate refresh token: %w", err))
+	}
+
+	userID := refreshToken.RefreshToken.UserID
+	user, err := s.Store.GetUser(ctx, &store.FindUser{ID: &userID})
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return errdefs.Unauthorized(err)
+		}
+		return errdefs.System(err)
+	}
+	if user.Status == store.StatusDisabled {
+		return errdefs.Forbidden(store.ErrUserDisabled)
+	}
+	return s.writeTokens(rw, user, refreshToken)
+}
+
+// RevokeToken godoc
+//
+//	@Summary	Revoke the access token of the request
+//	@Description	The refresh token in the body, if any, is revoked along with all the tokens rotated from the same grant.
+//	@Tags		auth
+//	@Accept		json
+//	@Success	204	{object}	nil	"Tokens revoked"
+//	@Router		/v1/auth/token/revoke [POST]
+func (s *APIV1Service) RevokeToken(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	claims := router.AccessTokenClaimsFromContext(ctx)
+	if claims == nil {
+		return errdefs.System(errors.New("access token claims not found in context"))
+	}
+	userID := router.UserIDFromContext(ctx)
+
+	// the body is optional
+	revokeReq := RefreshTokenReq{}
+	if err := json.NewDecoder(req.Body).Decode(&revokeReq); err != nil && !errors.Is(err, io.EOF) {
+		return errdefs.InvalidParameter(fmt.Errorf("malformed request body: %w", err))
+	}
+
+	if err := s.Store.RevokeAccessToken(ctx, &store.RevokeAccessToken{
+		JTI:       claims.ID,
+		UserID:    userID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		return errdefs.System(fmt.Errorf("failed to revoke access token: %w", err))
+	}
+
+	if revokeReq.RefreshToken != "" {
+		refreshToken, err := s.Store.GetRefreshToken(ctx, revokeReq.RefreshToken)
+		if err != nil && !errors.Is(err, store.ErrRefreshTokenNotFound) {
+			return errdefs.System(err)
+		}
+		// unknown tokens and tokens of other users are ignored
+		if err == nil && refreshToken.UserID == userID {
+			if _, err := s.Store.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID); err != nil {
+				return errdefs.System(fmt.Errorf("failed to revoke refresh token: %w", err))
+			}
+		}
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// writeTokens signs a new access token for user and writes it along with refreshToken.
+func (s *APIV1Service) writeTokens(rw http.ResponseWriter, user *store.UserInfo, refreshToken *store.CreateRefreshTokenResult) error {
+	now := s.Store.Now()
+	accessToken, err := jwtUtils.GenerateAccessToken(
+		user.Email,
+		user.ID,
+		uuid.NewString(),
+		now,
+		now.Add(s.Settings.AccessTokenTTL),
+		s.Keys.SigningKey(),
+	)
+	if err != nil {
+		return errdefs.System(fmt.Errorf("failed to sign access token: %w", err))
+	}
+
+	// tokens must not be cached by clients or proxies
+	rw.Header().Set("Cache-Control", "no-store")
+	return httputil.WriteRawJSON(rw, http.StatusOK, &TokenResp{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.Settings.AccessTokenTTL / time.Second),
+		RefreshToken: refreshToken.Token,
+	})
+}
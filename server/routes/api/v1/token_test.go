@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func TestIssueTokenWithSession(t *testing.T) {
+	svc, clock := newTestService(t)
+	tokens := newTokenFixture(t, svc, "jane@example.com")
+	admin := createTestUser(t, svc, "admin@example.com", store.RoleAdmin)
+	account := registerServiceAccount(t, svc, admin, ScopeUsersWrite)
+
+	// the session is extended like on any other request that uses it
+	clock.Advance(time.Hour)
+	req := httptest.NewRequest(http.MethodPost, "/v1/auth/token", strings.NewReader(`{"grant_type":"session"}`))
+	req.AddCookie(&http.Cookie{Name: sessionUtils.SessionCookieName, Value: tokens.session})
+	rec := httptest.NewRecorder()
+	if err := svc.IssueToken(context.Background(), rec, req, nil); err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+
+	reissued := false
+	for _, c := range rec.Result().Cookies() {
+		reissued = reissued || (c.Name == sessionUtils.SessionCookieName && c.Value == tokens.session)
+	}
+	if !reissued {
+		t.Errorf("session cookie was not reissued with the extended expiry")
+	}
+
+	resp := &TokenResp{}
+	if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+		t.Fatalf("decode tokens: %v", err)
+	}
+	got := introspect(t, svc, account, resp.AccessToken, "")
+	if !got.Active || got.Iat != clock.Now().Unix() {
+		t.Errorf("access token active %v issued at %d, want active and issued at %d", got.Active, got.Iat, clock.Now().Unix())
+	}
+}
+
+func TestDisabledUserIsRejected(t *testing.T) {
+	svc, clock := newTestService(t)
+	ctx := context.Background()
+	tokens := newTokenFixture(t, svc, "jane@example.com")
+	pat, err := svc.Store.IssuePersonalAccessToken(ctx, &store.IssuePersonalAccessToken{
+		UserID: tokens.user.ID, Name: "ci", Scopes: []string{ScopeUserRead}, ExpiresAt: clock.Now().Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("IssuePersonalAccessToken: %v", err)
+	}
+	admin := createTestUser(t, svc, "admin@example.com", store.RoleAdmin)
+	if rec := callAdmin(t, svc, asSession(t, svc, admin), http.MethodPut, "/admin/users/{id:[0-9]+}/status", userVars(tokens.user), `{"status":"disabled"}`); rec.Code != http.StatusOK {
+		t.Fatalf("disable jane: status %d", rec.Code)
+	}
+	// a session or refresh token that outlived the revocation, e.g. from a login
+	// racing the update, is turned down by the status check
+	lateSession := createTestSession(t, svc, tokens.user)
+	lateRefresh, err := svc.Store.CreateRefreshToken(ctx, tokens.user.ID)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	bearer := func(token string) func(*http.Request) *http.Request {
+		return func(req *http.Request) *http.Request {
+			req.Header.Set("Authorization", "Bearer "+token)
+			return req
+		}
+	}
+	session := func(token string) func(*http.Request) *http.Request {
+		return func(req *http.Request) *http.Request { return withSession(req, token) }
+	}
+	for _, tt := range []struct {
+		name string
+		auth func(*http.Request) *http.Request
+		want int
+	}{
+		{"access token", bearer(tokens.access), http.StatusForbidden},
+		{"personal access token", bearer(pat.Token), http.StatusForbidden},
+		{"revoked session", session(tokens.session), http.StatusUnauthorized},
+		{"session", session(lateSession), http.StatusForbidden},
+	} {
+		if rec := callRouter(t, NewUserRouter(svc), tt.auth, http.MethodGet, "/user/me", nil, ""); rec.Code != tt.want {
+			t.Errorf("GET /user/me with the %s of a disabled user: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	for name, tt := range map[string]struct {
+		token string
+		want  int
+	}{
+		"revoked": {tokens.refresh, http.StatusUnauthorized},
+		"late":    {lateRefresh.Token, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/token/refresh", strings.NewReader(`{"refresh_token":"`+tt.token+`"}`))
+		if rec := callRoute(t, req, nil, svc.RefreshToken); rec.Code != tt.want {
+			t.Errorf("RefreshToken(%s) of a disabled user: status %d, want %d", name, rec.Code, tt.want)
+		}
+	}
+}
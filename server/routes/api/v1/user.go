@@ -58,12 +58,12 @@ func (update UpdateUserRequest) Validate() error {
 //	@Failure	500	{object}	nil				"Failed to fetch user"
 //	@Router		/v1/user/me [GET]
 func (s *APIV1Service) GetCurrentUser(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
-	sInfo := router.SessionInfoFromContext(ctx)
-	if sInfo == nil {
-		return errdefs.System(errors.New("session info not found in context"))
+	userID := router.UserIDFromContext(ctx)
+	if userID == 0 {
+		return errdefs.System(errors.New("user id not found in context"))
 	}
 	// get user by user id.
-	user, err := s.Store.GetUser(ctx, &store.FindUser{ID: &sInfo.UserID})
+	user, err := s.Store.GetUser(ctx, &store.FindUser{ID: &userID})
 	if err != nil {
 		return errdefs.System(err)
 	}
@@ -83,13 +83,13 @@ func (s *APIV1Service) UpdateUser(ctx context.Context, rw http.ResponseWriter, r
 		return errdefs.InvalidParameter(err)
 	}
 
-	sInfo := router.SessionInfoFromContext(ctx)
-	if sInfo == nil {
-		return errdefs.Unauthorized(errors.New("session info not found in context"))
+	userID := router.UserIDFromContext(ctx)
+	if userID == 0 {
+		return errdefs.Unauthorized(errors.New("user id not found in context"))
 	}
 
 	userUpdate := &store.UpdateUser{
-		ID:        sInfo.UserID,
+		ID:        userID,
 		Email:     uReq.Email,
 		FullName:  uReq.FullName,
 		Telephone: uReq.Telephone,
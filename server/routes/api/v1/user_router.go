@@ -19,10 +19,22 @@ func (ur *userRouter) Routes() []router.Route {
 }
 
 func (ur *userRouter) initRoutes() {
-	// protect user routes with session middleware.
+	// user routes take a session cookie or a bearer access token, personal
+	// access tokens only where a scope is given.
+	readMW := router.AuthSessionOrJWT(ur.backend.Store, ur.backend.Keys, ScopeUserRead)
+	writeMW := router.AuthSessionOrJWT(ur.backend.Store, ur.backend.Keys, ScopeUserWrite)
+	authMW := router.AuthSessionOrJWT(ur.backend.Store, ur.backend.Keys)
+	// linking goes through the browser, which the OAuth temp cookie is bound to,
+	// and a leaked access token must not be enough to change how the user signs in.
 	sessionMW := router.AuthSession(ur.backend.Store)
 	ur.routes = []router.Route{
-		router.NewGetRoute("/user/me", ur.backend.GetCurrentUser, sessionMW),
-		router.NewPatchRoute("/user", ur.backend.UpdateUser, sessionMW),
+		router.NewGetRoute("/user/me", ur.backend.GetCurrentUser, readMW),
+		router.NewPatchRoute("/user", ur.backend.UpdateUser, writeMW),
+		router.NewGetRoute("/user/tokens", ur.backend.ListPersonalAccessTokens, authMW),
+		router.NewPostRoute("/user/tokens", ur.backend.CreatePersonalAccessToken, authMW),
+		router.NewDeleteRoute("/user/tokens/{id:[0-9]+}", ur.backend.RevokePersonalAccessToken, authMW),
+		router.NewGetRoute("/user/identities", ur.backend.ListIdentities, authMW),
+		router.NewPostRoute("/user/identities/{provider:[a-z][a-z0-9_-]*}/link", ur.backend.LinkIdentity, sessionMW),
+		router.NewDeleteRoute("/user/identities/{provider:[a-z][a-z0-9_-]*}", ur.backend.DeleteIdentity, sessionMW),
 	}
 }
@@ -0,0 +1,22 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
+)
+
+// GetJWKS godoc
+//
+//	@Summary	Public keys that verify the access tokens
+//	@Description	Tokens name their key in the "kid" header. Keys rotated out are listed until the tokens they signed expired.
+//	@Tags		auth
+//	@Produce	json
+//	@Success	200	{object}	jwtUtils.JWKS	"JSON Web Key Set"
+//	@Router		/.well-known/jwks.json [GET]
+func (s *APIV1Service) GetJWKS(ctx context.Context, rw http.ResponseWriter, req *http.Request, vars map[string]string) error {
+	// clients refetch the set when they see an unknown kid
+	rw.Header().Set("Cache-Control", "public, max-age=300")
+	return httputil.WriteRawJSON(rw, http.StatusOK, s.Keys.JWKS())
+}
@@ -0,0 +1,26 @@
+package v1
+
+import "github.com/sagarsuperuser/userprofile/internal/router"
+
+type wellKnownRouter struct {
+	backend *APIV1Service
+	routes  []router.Route
+}
+
+// NewWellKnownRouter initializes a router for the /.well-known discovery endpoints.
+func NewWellKnownRouter(svc *APIV1Service) router.Router {
+	r := &wellKnownRouter{backend: svc}
+	r.initRoutes()
+	return r
+}
+
+func (wr *wellKnownRouter) Routes() []router.Route {
+	return wr.routes
+}
+
+func (wr *wellKnownRouter) initRoutes() {
+	wr.routes = []router.Route{
+		router.NewGetRoute("/.well-known/jwks.json", wr.backend.GetJWKS),
+		router.NewGetRoute("/.well-known/openid-configuration", wr.backend.GetOpenIDConfiguration),
+	}
+}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/sagarsuperuser/userprofile/internal/httputil"
+	jwtUtils "github.com/sagarsuperuser/userprofile/internal/jwt"
 	"github.com/sagarsuperuser/userprofile/internal/router"
 	"github.com/sagarsuperuser/userprofile/internal/versions"
 	"github.com/sagarsuperuser/userprofile/server/httpstatus"
@@ -42,7 +43,7 @@ type Server struct {
 	middlewares []middlewares.Middleware
 }
 
-func NewServer(settings *settings.Settings, store *store.Store) *Server {
+func NewServer(settings *settings.Settings, store *store.Store, keys *jwtUtils.KeySet) *Server {
 	ret := new(Server)
 	ret.settings = settings
 	ret.store = store
@@ -89,7 +90,7 @@ func NewServer(settings *settings.Settings, store *store.Store) *Server {
 		})
 
 	// register api version 1 endpoints
-	apiV1Service := apiv1.NewAPIV1Service(settings, store)
+	apiV1Service := apiv1.NewAPIV1Service(settings, store, keys)
 	// TODO- read version from envs/config.
 	versionMW, err := middlewares.NewVersionMiddleware("dev", "1.0", "1.0")
 	if err != nil {
@@ -109,6 +110,10 @@ func NewServer(settings *settings.Settings, store *store.Store) *Server {
 	routers := []router.Router{
 		apiv1.NewAuthRouter(apiV1Service),
 		apiv1.NewUserRouter(apiV1Service),
+		apiv1.NewAdminRouter(apiV1Service),
+		apiv1.NewSessionRouter(apiV1Service),
+		apiv1.NewWellKnownRouter(apiV1Service),
+		apiv1.NewOIDCRouter(apiV1Service),
 	}
 	ret.router = ret.CreateMux(
 		context.Background(),
@@ -20,9 +20,33 @@ type Settings struct {
 	// DebugPort int    `envconfig:"DEBUG_PORT" default:"6070"`
 
 	// Driver is the database driver
-	// mysql only supported for now
+	// mysql, postgres, sqlite or memory (nothing is persisted)
 	Driver string `envconfig:"DRIVER" default:"mysql"`
 
+	// PostgreSQL settings
+	PostgresHost     string `envconfig:"POSTGRES_HOST" default:"127.0.0.1"`
+	PostgresPort     int    `envconfig:"POSTGRES_PORT" default:"5432"`
+	PostgresDatabase string `envconfig:"POSTGRES_DB" default:"appdb"`
+	PostgresUser     string `envconfig:"POSTGRES_USER" default:"appuser"`
+	PostgresPassword string `envconfig:"POSTGRES_PASSWORD" default:"password"`
+	PostgresSSLMode  string `envconfig:"POSTGRES_SSLMODE" default:"disable"`
+	// Timeouts
+	PostgresConnectTimeout time.Duration `envconfig:"POSTGRES_CONNECT_TIMEOUT" default:"5s"`
+	PostgresQueryTimeout   time.Duration `envconfig:"POSTGRES_QUERY_TIMEOUT" default:"5s"`
+	// Pool
+	PostgresMaxOpenConns    int           `envconfig:"POSTGRES_MAX_OPEN_CONNS" default:"25"`
+	PostgresMaxIdleConns    int           `envconfig:"POSTGRES_MAX_IDLE_CONNS" default:"10"`
+	PostgresConnMaxLifetime time.Duration `envconfig:"POSTGRES_CONN_MAX_LIFETIME" default:"30m"`
+	PostgresConnMaxIdleTime time.Duration `envconfig:"POSTGRES_CONN_MAX_IDLE_TIME" default:"5m"`
+
+	// SchemaCheck decides what happens when the database schema is behind
+	// the migrations embedded in the binary: "fail" refuses to serve, "warn" only logs.
+	SchemaCheck string `envconfig:"SCHEMA_CHECK" default:"fail"`
+
+	// SQLite settings
+	// SQLitePath is the database file, use ":memory:" for a throwaway database.
+	SQLitePath string `envconfig:"SQLITE_PATH" default:"userservice.db"`
+
 	// MySQL settings
 	MySQLHost     string `envconfig:"MYSQL_HOST" default:"127.0.0.1"`
 	MySQLPort     int    `envconfig:"MYSQL_PORT" default:"3306"`
@@ -38,19 +62,80 @@ type Settings struct {
 	MySQLConnMaxLifetime time.Duration `envconfig:"MYSQL_CONN_MAX_LIFETIME" default:"30m"`
 	MySQLConnMaxIdleTime time.Duration `envconfig:"MYSQL_CONN_MAX_IDLE_TIME" default:"5m"`
 
+	// Store cache settings, a size of 0 disables the cache.
+	// Entries are dropped after the TTL so changes made by other instances show up eventually.
+	UserCacheSize    int           `envconfig:"USER_CACHE_SIZE" default:"10000"`
+	UserCacheTTL     time.Duration `envconfig:"USER_CACHE_TTL" default:"5m"`
+	SessionCacheSize int           `envconfig:"SESSION_CACHE_SIZE" default:"10000"`
+	SessionCacheTTL  time.Duration `envconfig:"SESSION_CACHE_TTL" default:"1m"`
+	// CacheInvalidation shares cache invalidations between instances:
+	// "poll" polls a table of the store database, "none" is for single instances.
+	CacheInvalidation          string        `envconfig:"CACHE_INVALIDATION" default:"poll"`
+	CacheInvalidationInterval  time.Duration `envconfig:"CACHE_INVALIDATION_INTERVAL" default:"1s"`
+	CacheInvalidationRetention time.Duration `envconfig:"CACHE_INVALIDATION_RETENTION" default:"1h"`
+
+	// Session lifetime settings: a session expires after the idle timeout without use,
+	// and after the max lifetime in any case. "Remember me" logins get the longer ones.
+	SessionIdleTimeout         time.Duration `envconfig:"SESSION_IDLE_TIMEOUT" default:"24h"`
+	SessionMaxLifetime         time.Duration `envconfig:"SESSION_MAX_LIFETIME" default:"168h"`
+	SessionRememberIdleTimeout time.Duration `envconfig:"SESSION_REMEMBER_IDLE_TIMEOUT" default:"720h"`
+	SessionRememberMaxLifetime time.Duration `envconfig:"SESSION_REMEMBER_MAX_LIFETIME" default:"2160h"`
+	// SessionRefreshInterval is how often a session in use gets extended.
+	SessionRefreshInterval time.Duration `envconfig:"SESSION_REFRESH_INTERVAL" default:"1m"`
+	// Expired and revoked sessions are deleted every SessionGCInterval (0 disables it)
+	// once they are older than SessionGCRetention.
+	SessionGCInterval  time.Duration `envconfig:"SESSION_GC_INTERVAL" default:"1h"`
+	SessionGCRetention time.Duration `envconfig:"SESSION_GC_RETENTION" default:"168h"`
+	SessionGCBatchSize int           `envconfig:"SESSION_GC_BATCH_SIZE" default:"500"`
+
 	// Logging settings
 	LogLevel  string `envconfig:"LOG_LEVEL" default:"debug"`
 	LogFormat string `envconfig:"LOG_FORMAT" default:"text"`
 
-	// Secret key used for signing JWT tokens
+	// Secret key used to encrypt the JWT signing keys stored in the database
 	SecretKey string `envconfig:"SECRET_KEY" default:"secretkey"`
+	// JWTAlgorithm is the algorithm of generated signing keys, RS256 or EdDSA.
+	JWTAlgorithm string `envconfig:"JWT_ALGORITHM" default:"RS256"`
+	// JWTSigningKeyFile is a PEM private key that signs JWTs instead of the keys
+	// stored in the database, JWTVerificationKeyFiles are PEM keys that still verify them.
+	// Files are reloaded every JWTKeyReloadInterval.
+	JWTSigningKeyFile       string   `envconfig:"JWT_SIGNING_KEY_FILE" default:""`
+	JWTVerificationKeyFiles []string `envconfig:"JWT_VERIFICATION_KEY_FILES" default:""`
+	// JWTKeyRetention is how long a rotated key keeps verifying tokens,
+	// it should be longer than AccessTokenTTL.
+	JWTKeyRetention      time.Duration `envconfig:"JWT_KEY_RETENTION" default:"24h"`
+	JWTKeyReloadInterval time.Duration `envconfig:"JWT_KEY_RELOAD_INTERVAL" default:"1m"`
+	// Access tokens are short-lived JWTs, refresh tokens are opaque and
+	// rotated on every use, each new one valid for RefreshTokenTTL.
+	AccessTokenTTL  time.Duration `envconfig:"ACCESS_TOKEN_TTL" default:"15m"`
+	RefreshTokenTTL time.Duration `envconfig:"REFRESH_TOKEN_TTL" default:"720h"`
+
+	// OIDCIssuer is the public base URL of the service, the issuer of the ID tokens
+	// given to the OAuth clients registered by admins.
+	OIDCIssuer string `envconfig:"OIDC_ISSUER" default:"http://localhost:8080"`
 
 	// Origins is the list of allowed origins
 	Origins []string `envconfig:"ORIGINS" default:""`
-	// OAuth2 settings
+	// External identity providers users can sign in with. A provider is enabled when
+	// its client id is set; redirect URLs default to OIDCIssuer/ui/oauth2/<name>/callback.
+	// Google
 	OAuth2ClientID     string `envconfig:"OAUTH2_CLIENT_ID" default:""`
 	OAuth2ClientSecret string `envconfig:"OAUTH2_CLIENT_SECRET" default:""`
 	OAuth2RedirectURL  string `envconfig:"OAUTH2_REDIRECT_URL" default:""`
+	// GitHub
+	OAuth2GitHubClientID     string `envconfig:"OAUTH2_GITHUB_CLIENT_ID" default:""`
+	OAuth2GitHubClientSecret string `envconfig:"OAUTH2_GITHUB_CLIENT_SECRET" default:""`
+	OAuth2GitHubRedirectURL  string `envconfig:"OAUTH2_GITHUB_REDIRECT_URL" default:""`
+	// Any OpenID Connect provider, its endpoints are read from the discovery
+	// document of OAuth2OIDCIssuer on startup.
+	OAuth2OIDCIssuer       string   `envconfig:"OAUTH2_OIDC_ISSUER" default:""`
+	OAuth2OIDCClientID     string   `envconfig:"OAUTH2_OIDC_CLIENT_ID" default:""`
+	OAuth2OIDCClientSecret string   `envconfig:"OAUTH2_OIDC_CLIENT_SECRET" default:""`
+	OAuth2OIDCRedirectURL  string   `envconfig:"OAUTH2_OIDC_REDIRECT_URL" default:""`
+	OAuth2OIDCScopes       []string `envconfig:"OAUTH2_OIDC_SCOPES" default:"openid,email,profile"`
+	// OAuth2OIDCName names the provider in URLs and in auth_identities.provider.
+	OAuth2OIDCName        string `envconfig:"OAUTH2_OIDC_NAME" default:"oidc"`
+	OAuth2OIDCDisplayName string `envconfig:"OAUTH2_OIDC_DISPLAY_NAME" default:"Single sign-on"`
 }
 
 // NewSettings loads settings  by reading environment variables.
@@ -9,6 +9,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/rs/zerolog/log"
+
+	"github.com/sagarsuperuser/userprofile/internal/httputil"
 	apiv1 "github.com/sagarsuperuser/userprofile/server/routes/api/v1"
 	"github.com/sagarsuperuser/userprofile/server/settings"
 )
@@ -50,18 +53,29 @@ func (c *APIClient) Request(ctx context.Context, incoming *http.Request, method,
 		if ck := incoming.Header.Get("Cookie"); ck != "" {
 			req.Header.Set("Cookie", ck)
 		}
+		// the API records the device of each session
+		req.Header.Set("X-Forwarded-For", httputil.ClientIP(incoming))
+		if ua := incoming.UserAgent(); ua != "" {
+			req.Header.Set("User-Agent", ua)
+		}
 	}
 
 	return c.client.Do(req)
 }
 
-func (c *APIClient) FetchCurrentUser(ctx context.Context, r *http.Request) (*apiv1.UserResp, error) {
+// FetchCurrentUser returns nil when r has no valid session.
+// The session cookie reissued by the API is passed on to w.
+func (c *APIClient) FetchCurrentUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (*apiv1.UserResp, error) {
 	resp, err := c.Request(ctx, r, http.MethodGet, "/user/me", nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	for _, ck := range resp.Cookies() {
+		http.SetCookie(w, ck)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		io.Copy(io.Discard, resp.Body)
 		return nil, nil
@@ -73,3 +87,25 @@ func (c *APIClient) FetchCurrentUser(ctx context.Context, r *http.Request) (*api
 	}
 	return &user, nil
 }
+
+// FetchAuthProviders returns the identity providers enabled in the API, or none
+// when they can't be fetched so the password login keeps working.
+func (c *APIClient) FetchAuthProviders(ctx context.Context, r *http.Request) []*apiv1.AuthProviderResp {
+	resp, err := c.Request(ctx, r, http.MethodGet, "/auth/providers", nil)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to fetch identity providers")
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	var list apiv1.AuthProviderListResp
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		log.Warn().Err(err).Msg("Failed to decode identity providers")
+		return nil
+	}
+	return list.Providers
+}
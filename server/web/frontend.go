@@ -3,6 +3,7 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -11,6 +12,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 
+	oauth2Utils "github.com/sagarsuperuser/userprofile/internal/oauth2"
 	apiv1 "github.com/sagarsuperuser/userprofile/server/routes/api/v1"
 	"github.com/sagarsuperuser/userprofile/server/settings"
 )
@@ -27,11 +29,16 @@ type apiError struct {
 type loginPageData struct {
 	Title string
 	Error string
+	// Next is where to go once logged in, e.g. back to /oauth2/authorize.
+	Next string
+	// Providers are the enabled identity providers, one button each.
+	Providers []*apiv1.AuthProviderResp
 }
 
 type signupPageData struct {
-	Title string
-	Error string
+	Title     string
+	Error     string
+	Providers []*apiv1.AuthProviderResp
 }
 
 type profilePageData struct {
@@ -46,6 +53,43 @@ type profileFormData struct {
 	DisableEmail bool
 }
 
+type devicesPageData struct {
+	Title    string
+	Sessions []*apiv1.SessionResp
+	Error    string
+}
+
+type identitiesPageData struct {
+	Title      string
+	Identities []*apiv1.IdentityResp
+	Linkable   []*apiv1.AuthProviderResp
+	Error      string
+}
+
+type consentPageData struct {
+	Title      string
+	ClientName string
+	Scopes     []string
+	// Params are the parameters of the authorization request, posted back with the answer.
+	Params []consentParam
+	// ConsentToken is posted back too, the API only takes answers that carry it.
+	ConsentToken string
+	Error        string
+}
+
+type consentParam struct {
+	Name  string
+	Value string
+}
+
+// scopeDescriptions are shown on the consent screen.
+var scopeDescriptions = map[string]string{
+	apiv1.ScopeOpenID:  "Sign you in with your account",
+	apiv1.ScopeProfile: "See your name and picture",
+	apiv1.ScopeEmail:   "See your email address",
+	apiv1.ScopePhone:   "See your phone number",
+}
+
 type ctxUserKey struct{}
 
 const flashCookieName = "ui_flash"
@@ -76,31 +120,49 @@ func (f *Frontend) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/profile/edit", auth(f.editProfilePage)).Methods(http.MethodGet)
 	r.HandleFunc("/profile/edit", auth(f.handleProfileUpdate)).Methods(http.MethodPost)
 
+	r.HandleFunc("/devices", auth(f.devicesPage)).Methods(http.MethodGet)
+	r.HandleFunc("/devices/revoke-others", auth(f.handleRevokeOtherDevices)).Methods(http.MethodPost)
+	r.HandleFunc("/devices/{id:[0-9]+}/name", auth(f.handleRenameDevice)).Methods(http.MethodPost)
+	r.HandleFunc("/devices/{id:[0-9]+}/revoke", auth(f.handleRevokeDevice)).Methods(http.MethodPost)
+
+	r.HandleFunc("/identities", auth(f.identitiesPage)).Methods(http.MethodGet)
+	r.HandleFunc("/identities/{provider:[a-z][a-z0-9_-]*}/link", auth(f.handleLinkIdentity)).Methods(http.MethodPost)
+	r.HandleFunc("/identities/{provider:[a-z][a-z0-9_-]*}/remove", auth(f.handleRemoveIdentity)).Methods(http.MethodPost)
+
 	r.HandleFunc("/logout", f.handleLogout).Methods(http.MethodPost)
 
-	// OAuth callback endpoint - forwards to API then redirects to profile
+	// OpenID Connect authorization endpoint, with the consent screen
+	r.HandleFunc("/oauth2/authorize", f.authorizePage).Methods(http.MethodGet)
+	r.HandleFunc("/oauth2/authorize", f.handleAuthorize).Methods(http.MethodPost)
+
+	// OAuth callback endpoints - forward to API then redirect to profile.
+	// /ui/oauth2/callback is the google callback from before providers were pluggable.
+	r.HandleFunc("/ui/oauth2/{provider:[a-z][a-z0-9_-]*}/callback", f.handleOAuthCallback).Methods(http.MethodGet)
 	r.HandleFunc("/ui/oauth2/callback", f.handleOAuthCallback).Methods(http.MethodGet)
 }
 
 func (f *Frontend) loginPage(w http.ResponseWriter, r *http.Request) {
-	user, err := f.api.FetchCurrentUser(r.Context(), r)
+	next := safeNext(r.URL.Query().Get("next"))
+	user, err := f.api.FetchCurrentUser(r.Context(), w, r)
 	if err != nil {
 		f.serverError(w, err)
 		return
 	}
 	if user != nil {
-		http.Redirect(w, r, "/profile", http.StatusFound)
+		http.Redirect(w, r, orDefault(next, "/profile"), http.StatusFound)
 		return
 	}
 
 	f.templates.Render(w, "login.html", loginPageData{
-		Title: "Login",
-		Error: f.popFlash(w, r),
+		Title:     "Login",
+		Error:     f.popFlash(w, r),
+		Next:      next,
+		Providers: f.api.FetchAuthProviders(r.Context(), r),
 	})
 }
 
 func (f *Frontend) signupPage(w http.ResponseWriter, r *http.Request) {
-	user, err := f.api.FetchCurrentUser(r.Context(), r)
+	user, err := f.api.FetchCurrentUser(r.Context(), w, r)
 	if err != nil {
 		f.serverError(w, err)
 		return
@@ -111,8 +173,9 @@ func (f *Frontend) signupPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	f.templates.Render(w, "signup.html", signupPageData{
-		Title: "Sign Up",
-		Error: f.popFlash(w, r),
+		Title:     "Sign Up",
+		Error:     f.popFlash(w, r),
+		Providers: f.api.FetchAuthProviders(r.Context(), r),
 	})
 }
 
@@ -136,15 +199,158 @@ func (f *Frontend) editProfilePage(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (f *Frontend) devicesPage(w http.ResponseWriter, r *http.Request) {
+	resp, err := f.api.Request(r.Context(), r, http.MethodGet, "/sessions", nil)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		f.serverError(w, fmt.Errorf("list sessions: %s", readAPIMessage(resp, resp.Status)))
+		return
+	}
+	var list apiv1.SessionListResp
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		f.serverError(w, err)
+		return
+	}
+
+	setNoCacheHeaders(w)
+	f.templates.Render(w, "devices.html", devicesPageData{
+		Title:    "Devices",
+		Sessions: list.Sessions,
+		Error:    f.popFlash(w, r),
+	})
+}
+
+func (f *Frontend) handleRenameDevice(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		f.serverError(w, err)
+		return
+	}
+	payload := map[string]string{
+		"name": strings.TrimSpace(r.FormValue("name")),
+	}
+	resp, err := f.api.Request(r.Context(), r, http.MethodPatch, "/sessions/"+mux.Vars(r)["id"], payload)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		f.setFlash(w, readAPIMessage(resp, "Unable to rename the device"))
+	}
+	http.Redirect(w, r, "/devices", http.StatusFound)
+}
+
+func (f *Frontend) handleRevokeDevice(w http.ResponseWriter, r *http.Request) {
+	resp, err := f.api.Request(r.Context(), r, http.MethodDelete, "/sessions/"+mux.Vars(r)["id"], nil)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// the cookie is cleared when the current device signs out
+	f.copySetCookies(w, resp)
+	if resp.StatusCode != http.StatusNoContent {
+		f.setFlash(w, readAPIMessage(resp, "Unable to sign out the device"))
+	}
+	http.Redirect(w, r, "/devices", http.StatusFound)
+}
+
+func (f *Frontend) handleRevokeOtherDevices(w http.ResponseWriter, r *http.Request) {
+	resp, err := f.api.Request(r.Context(), r, http.MethodPost, "/sessions/revoke-others", nil)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		f.setFlash(w, readAPIMessage(resp, "Unable to sign out the other devices"))
+	}
+	http.Redirect(w, r, "/devices", http.StatusFound)
+}
+
+func (f *Frontend) identitiesPage(w http.ResponseWriter, r *http.Request) {
+	resp, err := f.api.Request(r.Context(), r, http.MethodGet, "/user/identities", nil)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		f.serverError(w, fmt.Errorf("list identities: %s", readAPIMessage(resp, resp.Status)))
+		return
+	}
+	var list apiv1.IdentityListResp
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		f.serverError(w, err)
+		return
+	}
+
+	setNoCacheHeaders(w)
+	f.templates.Render(w, "identities.html", identitiesPageData{
+		Title:      "Sign-in methods",
+		Identities: list.Identities,
+		Linkable:   list.Linkable,
+		Error:      f.popFlash(w, r),
+	})
+}
+
+func (f *Frontend) handleLinkIdentity(w http.ResponseWriter, r *http.Request) {
+	resp, err := f.api.Request(r.Context(), r, http.MethodPost, "/user/identities/"+mux.Vars(r)["provider"]+"/link", nil)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	f.copySetCookies(w, resp)
+	if resp.StatusCode != http.StatusOK {
+		f.setFlash(w, readAPIMessage(resp, "Unable to link the account"))
+		http.Redirect(w, r, "/identities", http.StatusFound)
+		return
+	}
+	var link apiv1.LinkIdentityResp
+	if err := json.NewDecoder(resp.Body).Decode(&link); err != nil {
+		f.serverError(w, err)
+		return
+	}
+	// the provider redirects back to /ui/oauth2/{provider}/callback
+	http.Redirect(w, r, link.AuthorizationURL, http.StatusFound)
+}
+
+func (f *Frontend) handleRemoveIdentity(w http.ResponseWriter, r *http.Request) {
+	resp, err := f.api.Request(r.Context(), r, http.MethodDelete, "/user/identities/"+mux.Vars(r)["provider"], nil)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		f.setFlash(w, readAPIMessage(resp, "Unable to remove the sign-in method"))
+	}
+	http.Redirect(w, r, "/identities", http.StatusFound)
+}
+
 func (f *Frontend) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		f.setFlash(w, "Malformed form submission")
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
-	payload := map[string]string{
-		"username": strings.TrimSpace(r.FormValue("username")),
-		"password": r.FormValue("password"),
+	next := safeNext(r.FormValue("next"))
+	payload := apiv1.LoginReq{
+		Username:   strings.TrimSpace(r.FormValue("username")),
+		Password:   r.FormValue("password"),
+		RememberMe: r.FormValue("remember_me") != "",
 	}
 	resp, err := f.api.Request(r.Context(), r, http.MethodPost, "/auth/login", payload)
 	if err != nil {
@@ -157,11 +363,108 @@ func (f *Frontend) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if resp.StatusCode != http.StatusOK {
 		msg := readAPIMessage(resp, "Invalid credentials, please try again")
 		f.setFlash(w, msg)
-		http.Redirect(w, r, "/login", http.StatusFound)
+		http.Redirect(w, r, loginURL(next), http.StatusFound)
 		return
 	}
 
-	http.Redirect(w, r, "/profile", http.StatusFound)
+	http.Redirect(w, r, orDefault(next, "/profile"), http.StatusFound)
+}
+
+func (f *Frontend) authorizePage(w http.ResponseWriter, r *http.Request) {
+	user, err := f.api.FetchCurrentUser(r.Context(), w, r)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	if user == nil {
+		http.Redirect(w, r, loginURL(r.URL.RequestURI()), http.StatusFound)
+		return
+	}
+	// the user answers the consent screen with its form, never with a link
+	params := r.URL.Query()
+	params.Del("consent")
+	params.Del("consent_token")
+	f.authorize(w, r, params)
+}
+
+func (f *Frontend) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		f.serverError(w, err)
+		return
+	}
+	f.authorize(w, r, r.PostForm)
+}
+
+// authorize asks the API to answer the authorization request in params, then
+// redirects back to the client or shows the consent screen.
+func (f *Frontend) authorize(w http.ResponseWriter, r *http.Request, params url.Values) {
+	payload := apiv1.AuthorizeReq{
+		ResponseType:        params.Get("response_type"),
+		ClientID:            params.Get("client_id"),
+		RedirectURI:         params.Get("redirect_uri"),
+		Scope:               params.Get("scope"),
+		State:               params.Get("state"),
+		Nonce:               params.Get("nonce"),
+		CodeChallenge:       params.Get("code_challenge"),
+		CodeChallengeMethod: params.Get("code_challenge_method"),
+		Prompt:              params.Get("prompt"),
+		Consent:             params.Get("consent"),
+		ConsentToken:        params.Get("consent_token"),
+	}
+	resp, err := f.api.Request(r.Context(), r, http.MethodPost, "/oidc/authorize", payload)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	f.copySetCookies(w, resp)
+	// the consent screen must not be framed by another site
+	setNoCacheHeaders(w)
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		// signed out in the meantime
+		params.Del("consent")
+		params.Del("consent_token")
+		http.Redirect(w, r, loginURL("/oauth2/authorize?"+params.Encode()), http.StatusFound)
+		return
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		f.templates.Render(w, "consent.html", consentPageData{
+			Title: "Authorization failed",
+			Error: readAPIMessage(resp, "Invalid authorization request"),
+		})
+		return
+	}
+
+	var authResp apiv1.AuthorizeResp
+	if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
+		f.serverError(w, err)
+		return
+	}
+	if !authResp.ConsentRequired {
+		http.Redirect(w, r, authResp.RedirectTo, http.StatusFound)
+		return
+	}
+
+	data := consentPageData{
+		Title:        "Authorize " + authResp.ClientName,
+		ClientName:   authResp.ClientName,
+		ConsentToken: authResp.ConsentToken,
+	}
+	for _, scope := range authResp.Scopes {
+		data.Scopes = append(data.Scopes, scopeDescriptions[scope])
+	}
+	for _, name := range []string{"response_type", "client_id", "redirect_uri", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+		if v := params.Get(name); v != "" {
+			data.Params = append(data.Params, consentParam{Name: name, Value: v})
+		}
+	}
+	f.templates.Render(w, "consent.html", data)
 }
 
 func (f *Frontend) handleSignup(w http.ResponseWriter, r *http.Request) {
@@ -239,7 +542,22 @@ func (f *Frontend) handleLogout(w http.ResponseWriter, r *http.Request) {
 }
 
 func (f *Frontend) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
-	endpoint := "/oauth2/callback"
+	provider := mux.Vars(r)["provider"]
+	if provider == "" {
+		provider = oauth2Utils.ProviderGoogle
+	}
+	// signed in users come back from linking the provider to their account
+	user, err := f.api.FetchCurrentUser(r.Context(), w, r)
+	if err != nil {
+		f.serverError(w, err)
+		return
+	}
+	done, failed := "/profile", "/login"
+	if user != nil {
+		done, failed = "/identities", "/identities"
+	}
+
+	endpoint := "/oauth2/" + provider + "/callback"
 	if raw := r.URL.RawQuery; raw != "" {
 		endpoint = endpoint + "?" + raw
 	}
@@ -252,18 +570,18 @@ func (f *Frontend) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
 
 	f.copySetCookies(w, resp)
 	if resp.StatusCode != http.StatusOK {
-		f.setFlash(w, readAPIMessage(resp, "Google sign-in failed"))
-		http.Redirect(w, r, "/login", http.StatusFound)
+		f.setFlash(w, readAPIMessage(resp, "Sign-in failed"))
+		http.Redirect(w, r, failed, http.StatusFound)
 		return
 	}
 
-	http.Redirect(w, r, "/profile", http.StatusFound)
+	http.Redirect(w, r, done, http.StatusFound)
 }
 
 // middleware
 func (f *Frontend) authenticated(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		user, err := f.api.FetchCurrentUser(r.Context(), r)
+		user, err := f.api.FetchCurrentUser(r.Context(), w, r)
 		if err != nil {
 			f.serverError(w, err)
 			return
@@ -334,6 +652,28 @@ func readAPIMessage(resp *http.Response, fallback string) string {
 	return apiErr.Message
 }
 
+// safeNext returns next if it is a path on this site, empty otherwise.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return ""
+	}
+	return next
+}
+
+func loginURL(next string) string {
+	if next == "" {
+		return "/login"
+	}
+	return "/login?next=" + url.QueryEscape(next)
+}
+
+func orDefault(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
 func stringPtr(v string) *string {
 	if strings.TrimSpace(v) == "" {
 		return nil
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	apiv1 "github.com/sagarsuperuser/userprofile/server/routes/api/v1"
+)
+
+// newTestFrontend returns a frontend talking to api instead of the API server.
+func newTestFrontend(t *testing.T, api http.Handler) *Frontend {
+	t.Helper()
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	// the templates are read relative to the root of the repository
+	t.Chdir("../..")
+	templates, err := NewTemplateStore()
+	if err != nil {
+		t.Fatalf("NewTemplateStore: %v", err)
+	}
+	return &Frontend{templates: templates, api: &APIClient{baseURL: srv.URL, client: srv.Client()}}
+}
+
+func TestAuthorizePageShowsConsentScreen(t *testing.T) {
+	var got []apiv1.AuthorizeReq
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /user/me", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(apiv1.UserResp{ID: 1, Email: "jane@example.com"})
+	})
+	mux.HandleFunc("POST /oidc/authorize", func(w http.ResponseWriter, r *http.Request) {
+		var authReq apiv1.AuthorizeReq
+		json.NewDecoder(r.Body).Decode(&authReq)
+		got = append(got, authReq)
+		if authReq.Consent != "" {
+			json.NewEncoder(w).Encode(apiv1.AuthorizeResp{RedirectTo: authReq.RedirectURI + "?code=code"})
+			return
+		}
+		json.NewEncoder(w).Encode(apiv1.AuthorizeResp{ConsentRequired: true, ClientName: "App", Scopes: []string{apiv1.ScopeOpenID}, ConsentToken: "consent-token"})
+	})
+	f := newTestFrontend(t, mux)
+
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {"app"},
+		"redirect_uri":  {"https://app.example.com/callback"},
+		"scope":         {"openid"},
+		"consent":       {"allow"},
+		"consent_token": {"consent-token"},
+	}
+	rec := httptest.NewRecorder()
+	f.authorizePage(rec, httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+params.Encode(), nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `name="consent_token" value="consent-token"`) {
+		t.Fatalf("GET with consent=allow: status %d, want the consent screen", rec.Code)
+	}
+	if len(got) != 1 || got[0].Consent != "" || got[0].ConsentToken != "" {
+		t.Fatalf("GET with consent=allow sent %+v to the API, want no consent", got)
+	}
+
+	// the form of the consent screen answers it
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/authorize", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	f.handleAuthorize(rec, req)
+	if rec.Code != http.StatusFound || len(got) != 2 || got[1].Consent != "allow" || got[1].ConsentToken != "consent-token" {
+		t.Errorf("POST consent: status %d, sent %+v, want a redirect after forwarding the consent", rec.Code, got)
+	}
+}
@@ -15,7 +15,7 @@ type TemplateStore struct {
 
 func NewTemplateStore() (*TemplateStore, error) {
 	tpls := make(map[string]*template.Template)
-	pages := []string{"login", "signup", "profile", "profile_edit"}
+	pages := []string{"login", "signup", "profile", "profile_edit", "devices", "identities", "consent"}
 	for _, p := range pages {
 		tpl, err := template.ParseFiles(
 			"server/templates/layout.html",
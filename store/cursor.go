@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort order")
+)
+
+// DefaultUserPageSize is used by ListUsersPage when FindUser.Limit is not set.
+const DefaultUserPageSize = 50
+
+// UserSort is the order users are listed in.
+// Every order breaks ties by user id in the same direction.
+type UserSort string
+
+const (
+	SortCreatedDesc UserSort = "created_desc"
+	SortCreatedAsc  UserSort = "created_asc"
+	SortUpdatedDesc UserSort = "updated_desc"
+	SortUpdatedAsc  UserSort = "updated_asc"
+	SortEmailAsc    UserSort = "email_asc"
+	SortEmailDesc   UserSort = "email_desc"
+)
+
+// OrDefault returns the sort, or SortCreatedDesc if it is empty.
+func (s UserSort) OrDefault() UserSort {
+	if s == "" {
+		return SortCreatedDesc
+	}
+	return s
+}
+
+func (s UserSort) Valid() bool {
+	switch s.OrDefault() {
+	case SortCreatedDesc, SortCreatedAsc, SortUpdatedDesc, SortUpdatedAsc, SortEmailAsc, SortEmailDesc:
+		return true
+	default:
+		return false
+	}
+}
+
+// Field returns the users column the sort orders by.
+func (s UserSort) Field() string {
+	switch s.OrDefault() {
+	case SortUpdatedDesc, SortUpdatedAsc:
+		return "updated_at"
+	case SortEmailAsc, SortEmailDesc:
+		return "email"
+	default:
+		return "created_at"
+	}
+}
+
+func (s UserSort) Desc() bool {
+	switch s.OrDefault() {
+	case SortCreatedDesc, SortUpdatedDesc, SortEmailDesc:
+		return true
+	default:
+		return false
+	}
+}
+
+// UserCursor is the position of the last user of a page.
+// The next page starts right after it in the same sort order.
+type UserCursor struct {
+	Sort  UserSort  `json:"s"`
+	Time  time.Time `json:"t,omitzero"`
+	Email string    `json:"e,omitempty"`
+	ID    int64     `json:"i"`
+}
+
+// NewUserCursor returns the cursor pointing right after user.
+func NewUserCursor(sort UserSort, user *UserInfo) *UserCursor {
+	c := &UserCursor{Sort: sort.OrDefault(), ID: user.ID}
+	switch c.Sort.Field() {
+	case "updated_at":
+		c.Time = user.UpdatedAt
+	case "email":
+		c.Email = user.Email
+	default:
+		c.Time = user.CreatedAt
+	}
+	return c
+}
+
+// Value returns the sort field value of the cursor, a time.Time or a string.
+func (c *UserCursor) Value() any {
+	if c.Sort.Field() == "email" {
+		return c.Email
+	}
+	return c.Time
+}
+
+// Encode returns the opaque form of the cursor handed out to clients.
+func (c *UserCursor) Encode() string {
+	buf, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// DecodeUserCursor parses a cursor returned by Encode.
+func DecodeUserCursor(s string) (*UserCursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &UserCursor{}
+	if err := json.Unmarshal(buf, c); err != nil || c.ID <= 0 || !c.Sort.Valid() {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+type UserPage struct {
+	Users []*UserInfo
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// ListUsersPage returns one page of users, find.Limit (or DefaultUserPageSize) at most.
+func (s *Store) ListUsersPage(ctx context.Context, find *FindUser) (*UserPage, error) {
+	sort := find.Sort.OrDefault()
+	if !sort.Valid() {
+		return nil, ErrInvalidSort
+	}
+	if c := find.Cursor; c != nil && c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+
+	limit := DefaultUserPageSize
+	if find.Limit != nil {
+		limit = *find.Limit
+	}
+	// fetch one more user to know whether there is a next page.
+	probe := *find
+	probe.Sort = sort
+	probeLimit := limit + 1
+	probe.Limit = &probeLimit
+
+	list, err := s.ListUsers(ctx, &probe)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Users: list}
+	if len(list) > limit {
+		page.Users = list[:limit]
+		if limit > 0 {
+			page.NextCursor = NewUserCursor(sort, list[limit-1]).Encode()
+		}
+	}
+	return page, nil
+}
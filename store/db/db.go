@@ -6,7 +6,10 @@ import (
 	"github.com/sagarsuperuser/userprofile/internal/common"
 	"github.com/sagarsuperuser/userprofile/server/settings"
 	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/db/memory"
 	"github.com/sagarsuperuser/userprofile/store/db/mysql"
+	"github.com/sagarsuperuser/userprofile/store/db/postgres"
+	"github.com/sagarsuperuser/userprofile/store/db/sqlite"
 )
 
 // NewDBDriver creates new db driver based on settings.
@@ -16,6 +19,12 @@ func NewDBDriver(settings *settings.Settings, now common.NowFunc) store.Driver {
 	switch settings.Driver {
 	case "mysql":
 		driver = mysql.NewDB(settings, now)
+	case "postgres":
+		driver = postgres.NewDB(settings, now)
+	case "sqlite":
+		driver = sqlite.NewDB(settings, now)
+	case "memory":
+		driver = memory.NewDB(settings, now)
 
 	default:
 		log.Fatal().Str("driver", settings.Driver).Msg("Unsupported DB driver")
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) LinkIdentity(ctx context.Context, link *store.LinkIdentity) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	user, ok := d.users[link.UserID]
+	if !ok {
+		return store.ErrUserNotFound
+	}
+	// uq_user_provider and uq_provider_subject
+	if _, ok := d.providerSubs[providerSub{link.Provider, link.Subject}]; ok || findIdentity(user, link.Provider) != nil {
+		return store.ErrIdentityAlreadyLinked
+	}
+
+	d.insertIdentity(user, link.Provider, link.Subject, "")
+	return nil
+}
+
+func (d *DB) ListIdentities(ctx context.Context, userID int64) ([]*store.Identity, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	user, ok := d.users[userID]
+	if !ok {
+		return []*store.Identity{}, nil
+	}
+	return newUserInfo(user).Identities, nil
+}
+
+func (d *DB) DeleteIdentity(ctx context.Context, del *store.DeleteIdentity) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	user, ok := d.users[del.UserID]
+	if !ok {
+		return store.ErrIdentityNotFound
+	}
+	identity := findIdentity(user, del.Provider)
+	if identity == nil {
+		return store.ErrIdentityNotFound
+	}
+	if !del.KeepsLoginMethod(newUserInfo(user).Identities) {
+		return store.ErrLastLoginMethod
+	}
+
+	// the email of a provider user follows the identity that created it, so it
+	// becomes the user's own to change once that identity is gone.
+	if user.identities[0] == identity {
+		user.emailLocked = false
+	}
+	user.identities = slices.DeleteFunc(user.identities, func(i *identityRow) bool { return i == identity })
+	if identity.provider != store.ProviderLocal {
+		delete(d.providerSubs, providerSub{identity.provider, identity.providerSubject})
+	}
+	return nil
+}
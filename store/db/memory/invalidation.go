@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateInvalidation(ctx context.Context, inv *store.Invalidation) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastInvalidationID++
+	row := *inv
+	row.ID = d.lastInvalidationID
+	row.CreatedAt = d.now()
+	d.invalidations = append(d.invalidations, &row)
+	return nil
+}
+
+func (d *DB) ListInvalidations(ctx context.Context, afterID int64, limit int) ([]*store.Invalidation, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]*store.Invalidation, 0)
+	for _, inv := range d.invalidations {
+		if len(list) == limit {
+			break
+		}
+		if inv.ID > afterID {
+			row := *inv
+			list = append(list, &row)
+		}
+	}
+	return list, nil
+}
+
+func (d *DB) LatestInvalidationID(ctx context.Context) (int64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if len(d.invalidations) == 0 {
+		return 0, nil
+	}
+	return d.invalidations[len(d.invalidations)-1].ID, nil
+}
+
+func (d *DB) DeleteInvalidations(ctx context.Context, before time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	kept := d.invalidations[:0]
+	for _, inv := range d.invalidations {
+		if !inv.CreatedAt.Before(before) {
+			kept = append(kept, inv)
+		}
+	}
+	n := int64(len(d.invalidations) - len(kept))
+	d.invalidations = kept
+	return n, nil
+}
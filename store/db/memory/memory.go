@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// DB is a goroutine-safe, in-memory store.Driver.
+// Nothing is persisted, all data is lost when the process exits.
+type DB struct {
+	mu       sync.RWMutex
+	settings *settings.Settings
+	now      common.NowFunc
+
+	users    map[int64]*userRow
+	sessions map[[32]byte]*store.SessionInfo
+	// refresh tokens by token hash, revoked access tokens by jti
+	refreshTokens       map[[32]byte]*store.RefreshTokenInfo
+	revokedAccessTokens map[string]time.Time
+	signingKeys         map[string]*store.SigningKeyInfo
+	// personal access tokens by token hash
+	personalAccessTokens map[[32]byte]*store.PersonalAccessTokenInfo
+	// OpenID Connect provider
+	oauthClients       map[string]*store.OAuthClientInfo
+	oauthConsents      map[oauthConsentKey]*store.OAuthConsentInfo
+	authorizationCodes map[[32]byte]*store.AuthorizationCodeInfo
+	serviceAccounts    map[string]*store.ServiceAccountInfo
+	roles              map[store.Role]*store.RoleInfo
+	// cache invalidation log, oldest first
+	invalidations []*store.Invalidation
+
+	// unique indexes
+	emails       map[string]int64      // lower(email) -> user id
+	providerSubs map[providerSub]int64 // (provider, provider_subject) -> user id
+
+	lastUserID         int64
+	lastIdentityID     int64
+	lastSessionID      int64
+	lastRefreshTokenID int64
+	lastPATID          int64
+	lastInvalidationID int64
+}
+
+type userRow struct {
+	id          int64
+	email       string
+	emailLocked bool
+	status      store.UserStatus
+	role        store.Role
+	fullName    string
+	telephone   string
+	avatarURL   string
+	identities  []*identityRow
+	createdAt   time.Time
+	updatedAt   time.Time
+}
+
+type identityRow struct {
+	id              int64
+	provider        store.Provider
+	providerSubject string
+	passwordHash    string
+	createdAt       time.Time
+	updatedAt       time.Time
+}
+
+// providerSub is the key of uq_provider_subject.
+type providerSub struct {
+	provider store.Provider
+	subject  string
+}
+
+func NewDB(settings *settings.Settings, now common.NowFunc) store.Driver {
+	driver := DB{
+		settings:             settings,
+		now:                  now,
+		users:                make(map[int64]*userRow),
+		sessions:             make(map[[32]byte]*store.SessionInfo),
+		refreshTokens:        make(map[[32]byte]*store.RefreshTokenInfo),
+		revokedAccessTokens:  make(map[string]time.Time),
+		signingKeys:          make(map[string]*store.SigningKeyInfo),
+		personalAccessTokens: make(map[[32]byte]*store.PersonalAccessTokenInfo),
+		oauthClients:         make(map[string]*store.OAuthClientInfo),
+		oauthConsents:        make(map[oauthConsentKey]*store.OAuthConsentInfo),
+		authorizationCodes:   make(map[[32]byte]*store.AuthorizationCodeInfo),
+		serviceAccounts:      make(map[string]*store.ServiceAccountInfo),
+		roles:                make(map[store.Role]*store.RoleInfo),
+		emails:               make(map[string]int64),
+		providerSubs:         make(map[providerSub]int64),
+	}
+
+	driver.seedRoles()
+
+	log.Warn().Msg("Using in-memory store, data will be lost on exit")
+	return &driver
+}
+
+// GetDB returns nil, there is no database/sql handle behind this driver.
+func (d *DB) GetDB() *sql.DB {
+	return nil
+}
+
+func (d *DB) Close() error {
+	return nil
+}
+
+func emailKey(email string) string {
+	return strings.ToLower(email)
+}
@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/storetest"
+)
+
+func TestDriver(t *testing.T) {
+	storetest.RunDriverTests(t, func(t *testing.T, now common.NowFunc) store.Driver {
+		return NewDB(nil, now)
+	})
+}
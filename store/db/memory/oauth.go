@@ -0,0 +1,206 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+type oauthConsentKey struct {
+	userID   int64
+	clientID string
+}
+
+func (d *DB) CreateOAuthClient(ctx context.Context, create *store.CreateOAuthClient) (*store.OAuthClientInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// primary key on oauth_clients(id)
+	if _, ok := d.oauthClients[create.ID]; ok {
+		return nil, fmt.Errorf("oauth client %q already exists", create.ID)
+	}
+
+	c := &store.OAuthClientInfo{
+		ID:           create.ID,
+		Name:         create.Name,
+		SecretHash:   slices.Clone(create.SecretHash),
+		RedirectURIs: slices.Clone(create.RedirectURIs),
+		CreatedAt:    d.now(),
+	}
+	d.oauthClients[c.ID] = c
+
+	return copyOAuthClient(c), nil
+}
+
+func (d *DB) GetOAuthClient(ctx context.Context, id string) (*store.OAuthClientInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	c, ok := d.oauthClients[id]
+	if !ok {
+		return nil, store.ErrOAuthClientNotFound
+	}
+	return copyOAuthClient(c), nil
+}
+
+func (d *DB) ListOAuthClients(ctx context.Context) ([]*store.OAuthClientInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]*store.OAuthClientInfo, 0, len(d.oauthClients))
+	for _, c := range d.oauthClients {
+		list = append(list, copyOAuthClient(c))
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (d *DB) DeleteOAuthClient(ctx context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.oauthClients[id]; !ok {
+		return store.ErrOAuthClientNotFound
+	}
+
+	// ON DELETE CASCADE
+	for key := range d.oauthConsents {
+		if key.clientID == id {
+			delete(d.oauthConsents, key)
+		}
+	}
+	for hash, c := range d.authorizationCodes {
+		if c.ClientID == id {
+			delete(d.authorizationCodes, hash)
+		}
+	}
+	delete(d.oauthClients, id)
+	return nil
+}
+
+func (d *DB) GetOAuthConsent(ctx context.Context, userID int64, clientID string) (*store.OAuthConsentInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	c, ok := d.oauthConsents[oauthConsentKey{userID, clientID}]
+	if !ok {
+		return nil, store.ErrOAuthConsentNotFound
+	}
+
+	res := *c
+	res.Scopes = slices.Clone(c.Scopes)
+	return &res, nil
+}
+
+func (d *DB) UpsertOAuthConsent(ctx context.Context, upsert *store.UpsertOAuthConsent) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// foreign keys on users(id) and oauth_clients(id)
+	if _, ok := d.users[upsert.UserID]; !ok {
+		return store.ErrUserNotFound
+	}
+	if _, ok := d.oauthClients[upsert.ClientID]; !ok {
+		return store.ErrOAuthClientNotFound
+	}
+
+	now := d.now()
+	key := oauthConsentKey{upsert.UserID, upsert.ClientID}
+	c, ok := d.oauthConsents[key]
+	if !ok {
+		c = &store.OAuthConsentInfo{UserID: upsert.UserID, ClientID: upsert.ClientID, CreatedAt: now}
+		d.oauthConsents[key] = c
+	}
+	c.Scopes = slices.Clone(upsert.Scopes)
+	c.UpdatedAt = now
+	return nil
+}
+
+func (d *DB) CreateAuthorizationCode(ctx context.Context, create *store.CreateAuthorizationCode) (*store.AuthorizationCodeInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// foreign keys on users(id) and oauth_clients(id)
+	if _, ok := d.users[create.UserID]; !ok {
+		return nil, store.ErrUserNotFound
+	}
+	if _, ok := d.oauthClients[create.ClientID]; !ok {
+		return nil, store.ErrOAuthClientNotFound
+	}
+
+	c := &store.AuthorizationCodeInfo{
+		CodeHash:      create.CodeHash,
+		ClientID:      create.ClientID,
+		UserID:        create.UserID,
+		RedirectURI:   create.RedirectURI,
+		Scopes:        slices.Clone(create.Scopes),
+		Nonce:         create.Nonce,
+		CodeChallenge: create.CodeChallenge,
+		AuthTime:      create.AuthTime,
+		ExpiresAt:     create.ExpiresAt,
+		CreatedAt:     d.now(),
+	}
+	d.authorizationCodes[c.CodeHash] = c
+
+	res := *c
+	res.Scopes = slices.Clone(c.Scopes)
+	return &res, nil
+}
+
+func (d *DB) GetAuthorizationCodeByHash(ctx context.Context, hash [32]byte) (*store.AuthorizationCodeInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	c, ok := d.authorizationCodes[hash]
+	if !ok {
+		return nil, store.ErrAuthorizationCodeNotFound
+	}
+
+	res := *c
+	res.Scopes = slices.Clone(c.Scopes)
+	return &res, nil
+}
+
+func (d *DB) UseAuthorizationCode(ctx context.Context, hash [32]byte) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	c, ok := d.authorizationCodes[hash]
+	if !ok || c.UsedAt != nil || !c.ExpiresAt.After(now) {
+		return false, nil
+	}
+	c.UsedAt = &now
+	return true, nil
+}
+
+func (d *DB) DeleteAuthorizationCodes(ctx context.Context, before time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var n int64
+	for hash, c := range d.authorizationCodes {
+		if c.ExpiresAt.Before(before) {
+			delete(d.authorizationCodes, hash)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func copyOAuthClient(c *store.OAuthClientInfo) *store.OAuthClientInfo {
+	res := *c
+	res.SecretHash = slices.Clone(c.SecretHash)
+	res.RedirectURIs = slices.Clone(c.RedirectURIs)
+	return &res
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreatePersonalAccessToken(ctx context.Context, create *store.CreatePersonalAccessToken) (*store.PersonalAccessTokenInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// foreign key on users(id), unique token_hash
+	if _, ok := d.users[create.UserID]; !ok {
+		return nil, store.ErrUserNotFound
+	}
+	if _, ok := d.personalAccessTokens[create.TokenHash]; ok {
+		return nil, fmt.Errorf("personal access token hash already exists")
+	}
+
+	d.lastPATID++
+	t := &store.PersonalAccessTokenInfo{
+		ID:        d.lastPATID,
+		UserID:    create.UserID,
+		Name:      create.Name,
+		TokenHash: create.TokenHash,
+		Scopes:    slices.Clone(create.Scopes),
+		ExpiresAt: create.ExpiresAt,
+		CreatedAt: d.now(),
+	}
+	d.personalAccessTokens[t.TokenHash] = t
+
+	return copyPersonalAccessToken(t), nil
+}
+
+func (d *DB) GetPersonalAccessTokenByHash(ctx context.Context, hash [32]byte) (*store.PersonalAccessTokenInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	t, ok := d.personalAccessTokens[hash]
+	if !ok {
+		return nil, store.ErrPersonalAccessTokenNotFound
+	}
+	return copyPersonalAccessToken(t), nil
+}
+
+func (d *DB) ListPersonalAccessTokens(ctx context.Context, userID int64) ([]*store.PersonalAccessTokenInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]*store.PersonalAccessTokenInfo, 0)
+	for _, t := range d.personalAccessTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			list = append(list, copyPersonalAccessToken(t))
+		}
+	}
+	slices.SortFunc(list, func(a, b *store.PersonalAccessTokenInfo) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return list, nil
+}
+
+func (d *DB) RevokePersonalAccessToken(ctx context.Context, userID, id int64) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, t := range d.personalAccessTokens {
+		if t.ID == id && t.UserID == userID && t.RevokedAt == nil {
+			now := d.now()
+			t.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *DB) TouchPersonalAccessToken(ctx context.Context, id int64, lastUsedAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, t := range d.personalAccessTokens {
+		if t.ID == id {
+			t.LastUsedAt = &lastUsedAt
+			return nil
+		}
+	}
+	return nil
+}
+
+func (d *DB) DeletePersonalAccessTokens(ctx context.Context, before time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var n int64
+	for hash, t := range d.personalAccessTokens {
+		if t.ExpiresAt.Before(before) || (t.RevokedAt != nil && t.RevokedAt.Before(before)) {
+			delete(d.personalAccessTokens, hash)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func copyPersonalAccessToken(t *store.PersonalAccessTokenInfo) *store.PersonalAccessTokenInfo {
+	res := *t
+	res.Scopes = slices.Clone(t.Scopes)
+	return &res
+}
@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateRole(ctx context.Context, create *store.CreateRole) (*store.RoleInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.roles[create.Name]; ok {
+		return nil, store.ErrRoleAlreadyExists
+	}
+	now := d.now()
+	role := &store.RoleInfo{
+		Name:        create.Name,
+		Description: create.Description,
+		Permissions: slices.Clone(create.Permissions),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	d.roles[role.Name] = role
+	return copyRole(role), nil
+}
+
+func (d *DB) GetRole(ctx context.Context, name store.Role) (*store.RoleInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	role, ok := d.roles[name]
+	if !ok {
+		return nil, store.ErrRoleNotFound
+	}
+	return copyRole(role), nil
+}
+
+func (d *DB) ListRoles(ctx context.Context) ([]*store.RoleInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]*store.RoleInfo, 0, len(d.roles))
+	for _, role := range d.roles {
+		list = append(list, copyRole(role))
+	}
+	slices.SortFunc(list, func(a, b *store.RoleInfo) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return list, nil
+}
+
+func (d *DB) UpdateRole(ctx context.Context, update *store.UpdateRole) (*store.RoleInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	role, ok := d.roles[update.Name]
+	if !ok {
+		return nil, store.ErrRoleNotFound
+	}
+	if v := update.Description; v != nil {
+		role.Description = *v
+	}
+	if update.Permissions != nil {
+		role.Permissions = slices.Clone(update.Permissions)
+	}
+	role.UpdatedAt = d.now()
+	return copyRole(role), nil
+}
+
+func (d *DB) DeleteRole(ctx context.Context, name store.Role) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	role, ok := d.roles[name]
+	if !ok || role.Builtin {
+		return false, nil
+	}
+	for _, user := range d.users {
+		if user.role == name {
+			return false, nil
+		}
+	}
+	delete(d.roles, name)
+	return true, nil
+}
+
+// seedRoles creates the built-in roles, like the migrations of the SQL drivers.
+func (d *DB) seedRoles() {
+	now := d.now()
+	for _, create := range store.BuiltinRoles {
+		permissions := slices.Clone(create.Permissions)
+		slices.Sort(permissions)
+		d.roles[create.Name] = &store.RoleInfo{
+			Name:        create.Name,
+			Description: create.Description,
+			Builtin:     true,
+			Permissions: permissions,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+	}
+}
+
+func copyRole(role *store.RoleInfo) *store.RoleInfo {
+	res := *role
+	res.Permissions = slices.Clone(role.Permissions)
+	return &res
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateServiceAccount(ctx context.Context, create *store.CreateServiceAccount) (*store.ServiceAccountInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// primary key on service_accounts(id), foreign key on users(id)
+	if _, ok := d.serviceAccounts[create.ID]; ok {
+		return nil, fmt.Errorf("service account %q already exists", create.ID)
+	}
+	if _, ok := d.users[create.OwnerID]; !ok {
+		return nil, store.ErrUserNotFound
+	}
+
+	a := &store.ServiceAccountInfo{
+		ID:         create.ID,
+		Name:       create.Name,
+		SecretHash: slices.Clone(create.SecretHash),
+		Scopes:     slices.Clone(create.Scopes),
+		OwnerID:    create.OwnerID,
+		CreatedAt:  d.now(),
+	}
+	d.serviceAccounts[a.ID] = a
+
+	return copyServiceAccount(a), nil
+}
+
+func (d *DB) GetServiceAccount(ctx context.Context, id string) (*store.ServiceAccountInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	a, ok := d.serviceAccounts[id]
+	if !ok {
+		return nil, store.ErrServiceAccountNotFound
+	}
+	return copyServiceAccount(a), nil
+}
+
+func (d *DB) ListServiceAccounts(ctx context.Context) ([]*store.ServiceAccountInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]*store.ServiceAccountInfo, 0, len(d.serviceAccounts))
+	for _, a := range d.serviceAccounts {
+		list = append(list, copyServiceAccount(a))
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (d *DB) DeleteServiceAccount(ctx context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.serviceAccounts[id]; !ok {
+		return store.ErrServiceAccountNotFound
+	}
+	delete(d.serviceAccounts, id)
+	return nil
+}
+
+func copyServiceAccount(a *store.ServiceAccountInfo) *store.ServiceAccountInfo {
+	res := *a
+	res.SecretHash = slices.Clone(a.SecretHash)
+	res.Scopes = slices.Clone(a.Scopes)
+	return &res
+}
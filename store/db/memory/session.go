@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateSession(ctx context.Context, create *store.CreateSession) (*store.SessionInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// foreign key on users(id)
+	if _, ok := d.users[create.UserID]; !ok {
+		return nil, store.ErrUserNotFound
+	}
+
+	now := d.now()
+	d.lastSessionID++
+	s := &store.SessionInfo{
+		ID:                d.lastSessionID,
+		UserID:            create.UserID,
+		TokenHash:         create.TokenHash,
+		IP:                create.IP,
+		UserAgent:         create.UserAgent,
+		Remember:          create.Remember,
+		ExpiresAt:         create.ExpiresAt,
+		AbsoluteExpiresAt: create.AbsoluteExpiresAt,
+		LastSeenAt:        now,
+		CreatedAt:         now,
+		RevokedAt:         nil,
+		IsActive:          true,
+	}
+	d.sessions[create.TokenHash] = s
+
+	res := *s
+	return &res, nil
+}
+
+func (d *DB) GetActiveSessionByHash(ctx context.Context, hash [32]byte) (*store.SessionInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	s, ok := d.sessions[hash]
+	if !ok || s.RevokedAt != nil || !s.ExpiresAt.After(d.now()) {
+		return nil, sessionUtils.ErrSesssionExpired
+	}
+
+	res := *s
+	return &res, nil
+}
+
+func (d *DB) ListSessions(ctx context.Context, find *store.FindSession) ([]*store.SessionInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]*store.SessionInfo, 0)
+	for _, s := range d.findSessions(find) {
+		res := *s
+		list = append(list, &res)
+	}
+	slices.SortFunc(list, func(a, b *store.SessionInfo) int {
+		if c := b.LastSeenAt.Compare(a.LastSeenAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return list, nil
+}
+
+func (d *DB) UpdateSession(ctx context.Context, update *store.UpdateSession) (*store.SessionInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	found := d.findSessions(&store.FindSession{ID: &update.ID})
+	if len(found) == 0 {
+		return nil, store.ErrSessionNotFound
+	}
+	s := found[0]
+	if v := update.Name; v != nil {
+		s.Name = *v
+	}
+	if v := update.IP; v != nil {
+		s.IP = *v
+	}
+	if v := update.LastSeenAt; v != nil {
+		s.LastSeenAt = *v
+	}
+	if v := update.ExpiresAt; v != nil {
+		s.ExpiresAt = *v
+	}
+
+	res := *s
+	return &res, nil
+}
+
+// findSessions returns the active sessions matching find, expects d.mu to be held.
+func (d *DB) findSessions(find *store.FindSession) []*store.SessionInfo {
+	now := d.now()
+	var list []*store.SessionInfo
+	for _, s := range d.sessions {
+		if s.RevokedAt != nil || !s.ExpiresAt.After(now) {
+			continue
+		}
+		if find.ID != nil && s.ID != *find.ID {
+			continue
+		}
+		if find.UserID != nil && s.UserID != *find.UserID {
+			continue
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+func (d *DB) RevokeSession(ctx context.Context, hash [32]byte) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.sessions[hash]
+	if !ok || s.RevokedAt != nil {
+		return false, nil // already revoked or not found
+	}
+	now := d.now()
+	s.RevokedAt = &now
+	s.IsActive = false
+	return true, nil
+}
+
+func (d *DB) RevokeUserSessions(ctx context.Context, userID int64) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	var n int64
+	for _, s := range d.sessions {
+		if s.UserID != userID || s.RevokedAt != nil {
+			continue
+		}
+		revokedAt := now
+		s.RevokedAt = &revokedAt
+		s.IsActive = false
+		n++
+	}
+	return n, nil
+}
+
+func (d *DB) DeleteSessions(ctx context.Context, before time.Time, limit int) ([][32]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var hashes [][32]byte
+	for hash, s := range d.sessions {
+		if len(hashes) == limit {
+			break
+		}
+		if s.ExpiresAt.Before(before) || (s.RevokedAt != nil && s.RevokedAt.Before(before)) {
+			delete(d.sessions, hash)
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateSigningKey(ctx context.Context, create *store.CreateSigningKey) (*store.SigningKeyInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// primary key on signing_keys(id)
+	if _, ok := d.signingKeys[create.ID]; ok {
+		return nil, fmt.Errorf("signing key %q already exists", create.ID)
+	}
+
+	k := &store.SigningKeyInfo{
+		ID:         create.ID,
+		Algorithm:  create.Algorithm,
+		PrivateKey: create.PrivateKey,
+		CreatedAt:  d.now(),
+	}
+	d.signingKeys[create.ID] = k
+
+	res := *k
+	return &res, nil
+}
+
+func (d *DB) ListSigningKeys(ctx context.Context, find *store.FindSigningKey) ([]*store.SigningKeyInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]*store.SigningKeyInfo, 0)
+	for _, k := range d.signingKeys {
+		if v := find.RetiredAfter; v != nil && k.RetiredAt != nil && !k.RetiredAt.After(*v) {
+			continue
+		}
+		res := *k
+		list = append(list, &res)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+func (d *DB) RetireSigningKeys(ctx context.Context, keepID string) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	var n int64
+	for id, k := range d.signingKeys {
+		if id != keepID && k.RetiredAt == nil {
+			k.RetiredAt = &now
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (d *DB) DeleteSigningKeys(ctx context.Context, retiredBefore time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var n int64
+	for id, k := range d.signingKeys {
+		if k.RetiredAt != nil && k.RetiredAt.Before(retiredBefore) {
+			delete(d.signingKeys, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateRefreshToken(ctx context.Context, create *store.CreateRefreshToken) (*store.RefreshTokenInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// foreign key on users(id)
+	if _, ok := d.users[create.UserID]; !ok {
+		return nil, store.ErrUserNotFound
+	}
+
+	d.lastRefreshTokenID++
+	t := &store.RefreshTokenInfo{
+		ID:        d.lastRefreshTokenID,
+		UserID:    create.UserID,
+		FamilyID:  create.FamilyID,
+		TokenHash: create.TokenHash,
+		ExpiresAt: create.ExpiresAt,
+		CreatedAt: d.now(),
+	}
+	d.refreshTokens[create.TokenHash] = t
+
+	res := *t
+	return &res, nil
+}
+
+func (d *DB) GetRefreshTokenByHash(ctx context.Context, hash [32]byte) (*store.RefreshTokenInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	t, ok := d.refreshTokens[hash]
+	if !ok {
+		return nil, store.ErrRefreshTokenNotFound
+	}
+
+	res := *t
+	return &res, nil
+}
+
+func (d *DB) UseRefreshToken(ctx context.Context, id int64) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	for _, t := range d.refreshTokens {
+		if t.ID != id {
+			continue
+		}
+		if !t.IsActive(now) {
+			return false, nil
+		}
+		t.UsedAt = &now
+		return true, nil
+	}
+	return false, nil
+}
+
+func (d *DB) RevokeRefreshTokenFamily(ctx context.Context, familyID string) (int64, error) {
+	return d.revokeRefreshTokens(func(t *store.RefreshTokenInfo) bool {
+		return t.FamilyID == familyID
+	}), nil
+}
+
+func (d *DB) RevokeUserRefreshTokens(ctx context.Context, userID int64) (int64, error) {
+	return d.revokeRefreshTokens(func(t *store.RefreshTokenInfo) bool {
+		return t.UserID == userID
+	}), nil
+}
+
+func (d *DB) revokeRefreshTokens(match func(t *store.RefreshTokenInfo) bool) int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	var n int64
+	for _, t := range d.refreshTokens {
+		if t.RevokedAt != nil || !match(t) {
+			continue
+		}
+		revokedAt := now
+		t.RevokedAt = &revokedAt
+		n++
+	}
+	return n
+}
+
+func (d *DB) DeleteRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var n int64
+	for hash, t := range d.refreshTokens {
+		if t.ExpiresAt.Before(before) || (t.RevokedAt != nil && t.RevokedAt.Before(before)) {
+			delete(d.refreshTokens, hash)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (d *DB) RevokeAccessToken(ctx context.Context, revoke *store.RevokeAccessToken) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.revokedAccessTokens[revoke.JTI]; !ok {
+		d.revokedAccessTokens[revoke.JTI] = revoke.ExpiresAt
+	}
+	return nil
+}
+
+func (d *DB) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, ok := d.revokedAccessTokens[jti]
+	return ok, nil
+}
+
+func (d *DB) DeleteRevokedAccessTokens(ctx context.Context, before time.Time) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var n int64
+	for jti, expiresAt := range d.revokedAccessTokens {
+		if expiresAt.Before(before) {
+			delete(d.revokedAccessTokens, jti)
+			n++
+		}
+	}
+	return n, nil
+}
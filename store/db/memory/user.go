@@ -0,0 +1,343 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateLocalUser(ctx context.Context, in *store.CreateLocalUser) (*store.UserInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.emails[emailKey(in.Email)]; ok {
+		return nil, store.ErrUserAlreadyExists
+	}
+
+	// users row (local => email is editable later, so email_locked=false)
+	user := d.insertUser(in.Email, false, in.Status, in.Role)
+
+	// local identity row
+	d.insertIdentity(user, store.ProviderLocal, "", in.PasswordHash)
+
+	return d.getUser(&store.FindUser{ID: &user.id})
+}
+
+func (d *DB) UpsertProviderUser(ctx context.Context, upsert *store.UpsertProviderUser) (*store.UserInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// 1) If the provider sub is already linked -> use same user
+	if userID, ok := d.providerSubs[providerSub{upsert.Provider, upsert.Subject}]; ok {
+		if upsert.EmailVerified {
+			d.syncProviderEmail(d.users[userID], upsert.Provider, upsert.Email)
+		}
+		return d.getUser(&store.FindUser{ID: &userID})
+	}
+	if !upsert.EmailVerified {
+		return nil, store.ErrProviderEmailNotVerified
+	}
+
+	// 2) sub not linked yet -> users with the same email must link it themselves
+	if _, ok := d.emails[emailKey(upsert.Email)]; ok {
+		return nil, store.ErrIdentityNotLinked
+	}
+
+	// 3) Create new provider user with its identity
+	user := d.insertUser(upsert.Email, true, store.StatusActive, store.RoleUser)
+	d.insertIdentity(user, upsert.Provider, upsert.Subject, "")
+
+	return d.getUser(&store.FindUser{ID: &user.id})
+}
+
+// syncProviderEmail updates the email of a user created by a provider to the one
+// the provider now has. Only the identity that created the user, its oldest one,
+// manages the email, and it is kept when another user has the new email already.
+func (d *DB) syncProviderEmail(user *userRow, provider store.Provider, email string) {
+	if !user.emailLocked || user.email == email {
+		return
+	}
+	if len(user.identities) == 0 || user.identities[0].provider != provider {
+		return
+	}
+	if id, ok := d.emails[emailKey(email)]; ok && id != user.id {
+		return
+	}
+	delete(d.emails, emailKey(user.email))
+	d.emails[emailKey(email)] = user.id
+	user.email = email
+	user.updatedAt = d.now()
+}
+
+func (d *DB) UpdateUser(ctx context.Context, update *store.UpdateUser) (*store.UserInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	user, ok := d.users[update.ID]
+	if !ok {
+		return nil, store.ErrUserNotFound
+	}
+
+	// check if email updation is locked
+	if update.Email != nil {
+		if user.emailLocked {
+			return nil, store.ErrEmailUpdateNotAllowed
+		}
+		if id, ok := d.emails[emailKey(*update.Email)]; ok && id != user.id {
+			return nil, store.ErrUserAlreadyExists
+		}
+	}
+
+	if v := update.FullName; v != nil {
+		user.fullName = *v
+	}
+	if v := update.Telephone; v != nil {
+		user.telephone = *v
+	}
+	if v := update.AvatarURL; v != nil {
+		user.avatarURL = *v
+	}
+	if v := update.Role; v != nil {
+		user.role = *v
+	}
+	if v := update.Status; v != nil {
+		user.status = *v
+	}
+	if v := update.Email; v != nil {
+		delete(d.emails, emailKey(user.email))
+		user.email = *v
+		d.emails[emailKey(user.email)] = user.id
+	}
+	user.updatedAt = d.now()
+
+	return d.getUser(&store.FindUser{ID: &update.ID})
+}
+
+func (d *DB) ListUsers(ctx context.Context, find *store.FindUser) ([]*store.UserInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.listUsers(find), nil
+}
+
+func (d *DB) GetUser(ctx context.Context, find *store.FindUser) (*store.UserInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.getUser(find)
+}
+
+func (d *DB) DeleteUser(ctx context.Context, del *store.DeleteUser) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	user, ok := d.users[del.ID]
+	if !ok {
+		return false, nil
+	}
+
+	// ON DELETE CASCADE
+	for _, identity := range user.identities {
+		if identity.provider != store.ProviderLocal {
+			delete(d.providerSubs, providerSub{identity.provider, identity.providerSubject})
+		}
+	}
+	for hash, s := range d.sessions {
+		if s.UserID == user.id {
+			delete(d.sessions, hash)
+		}
+	}
+	for hash, t := range d.refreshTokens {
+		if t.UserID == user.id {
+			delete(d.refreshTokens, hash)
+		}
+	}
+	for key := range d.oauthConsents {
+		if key.userID == user.id {
+			delete(d.oauthConsents, key)
+		}
+	}
+	for hash, c := range d.authorizationCodes {
+		if c.UserID == user.id {
+			delete(d.authorizationCodes, hash)
+		}
+	}
+	for hash, t := range d.personalAccessTokens {
+		if t.UserID == user.id {
+			delete(d.personalAccessTokens, hash)
+		}
+	}
+	for id, a := range d.serviceAccounts {
+		if a.OwnerID == user.id {
+			delete(d.serviceAccounts, id)
+		}
+	}
+	delete(d.emails, emailKey(user.email))
+	delete(d.users, user.id)
+	return true, nil
+}
+
+// getUser expects d.mu to be held.
+func (d *DB) getUser(find *store.FindUser) (*store.UserInfo, error) {
+	list := d.listUsers(find)
+	if len(list) != 1 {
+		return nil, store.ErrUserNotFound
+	}
+
+	return list[0], nil
+}
+
+// listUsers expects d.mu to be held.
+func (d *DB) listUsers(find *store.FindUser) []*store.UserInfo {
+	sortBy := find.Sort.OrDefault()
+	list := make([]*store.UserInfo, 0)
+	for _, user := range d.users {
+		if v := find.ID; v != nil && user.id != *v {
+			continue
+		}
+		if v := find.Email; v != nil && emailKey(user.email) != emailKey(*v) {
+			continue
+		}
+		if v := find.Role; v != nil && user.role != *v {
+			continue
+		}
+		if v := find.Status; v != nil && user.status != *v {
+			continue
+		}
+		if v := find.Provider; v != nil && findIdentity(user, *v) == nil {
+			continue
+		}
+		if v := find.EmailPrefix; v != nil && !hasPrefixFold(user.email, *v) {
+			continue
+		}
+		if v := find.NamePrefix; v != nil && !hasPrefixFold(user.fullName, *v) {
+			continue
+		}
+		if v := find.CreatedAfter; v != nil && !user.createdAt.After(*v) {
+			continue
+		}
+		if v := find.CreatedBefore; v != nil && !user.createdAt.Before(*v) {
+			continue
+		}
+		if v := find.UpdatedAfter; v != nil && !user.updatedAt.After(*v) {
+			continue
+		}
+		if v := find.UpdatedBefore; v != nil && !user.updatedAt.Before(*v) {
+			continue
+		}
+		info := newUserInfo(user)
+		if c := find.Cursor; c != nil && compareUsers(sortBy, info, c) <= 0 {
+			continue
+		}
+		list = append(list, info)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return compareUsers(sortBy, list[i], store.NewUserCursor(sortBy, list[j])) < 0
+	})
+
+	if v := find.Limit; v != nil && *v >= 0 && len(list) > *v {
+		list = list[:*v]
+	}
+	return list
+}
+
+// compareUsers orders user against the cursor position c the way
+// ORDER BY <field>, id does in the SQL drivers, flipped for descending sorts.
+func compareUsers(sortBy store.UserSort, user *store.UserInfo, c *store.UserCursor) int {
+	var n int
+	switch sortBy.Field() {
+	case "updated_at":
+		n = user.UpdatedAt.Compare(c.Time)
+	case "email":
+		n = strings.Compare(emailKey(user.Email), emailKey(c.Email))
+	default:
+		n = user.CreatedAt.Compare(c.Time)
+	}
+	if n == 0 {
+		n = cmp.Compare(user.ID, c.ID)
+	}
+	if sortBy.Desc() {
+		return -n
+	}
+	return n
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+func (d *DB) insertUser(email string, emailLocked bool, status store.UserStatus, role store.Role) *userRow {
+	now := d.now()
+	d.lastUserID++
+	user := &userRow{
+		id:          d.lastUserID,
+		email:       email,
+		emailLocked: emailLocked,
+		status:      status,
+		role:        role,
+		createdAt:   now,
+		updatedAt:   now,
+	}
+	d.users[user.id] = user
+	d.emails[emailKey(email)] = user.id
+	return user
+}
+
+func (d *DB) insertIdentity(user *userRow, provider store.Provider, subject, passwordHash string) {
+	now := d.now()
+	d.lastIdentityID++
+	user.identities = append(user.identities, &identityRow{
+		id:              d.lastIdentityID,
+		provider:        provider,
+		providerSubject: subject,
+		passwordHash:    passwordHash,
+		createdAt:       now,
+		updatedAt:       now,
+	})
+	if provider != store.ProviderLocal {
+		d.providerSubs[providerSub{provider, subject}] = user.id
+	}
+}
+
+func findIdentity(user *userRow, provider store.Provider) *identityRow {
+	for _, identity := range user.identities {
+		if identity.provider == provider {
+			return identity
+		}
+	}
+	return nil
+}
+
+// newUserInfo copies a row so callers never share memory with the store.
+func newUserInfo(user *userRow) *store.UserInfo {
+	fullName, telephone, avatarURL := user.fullName, user.telephone, user.avatarURL
+	info := &store.UserInfo{
+		ID:          user.id,
+		Email:       user.email,
+		EmailLocked: user.emailLocked,
+		Role:        user.role,
+		Status:      user.status,
+		FullName:    &fullName,
+		Telephone:   &telephone,
+		AvatarURL:   &avatarURL,
+		Identities:  make([]*store.Identity, 0, len(user.identities)),
+		CreatedAt:   user.createdAt,
+		UpdatedAt:   user.updatedAt,
+	}
+	for _, identity := range user.identities {
+		if identity.provider == store.ProviderLocal {
+			info.PasswordHash = identity.passwordHash
+		}
+		info.Identities = append(info.Identities, &store.Identity{
+			Provider:    identity.provider,
+			Subject:     identity.providerSubject,
+			HasPassword: identity.passwordHash != "",
+			CreatedAt:   identity.createdAt,
+		})
+	}
+	return info
+}
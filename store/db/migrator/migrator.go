@@ -0,0 +1,195 @@
+// Package migrator applies the schema migrations embedded in the store drivers.
+package migrator
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	migrate "github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	migrateMySQL "github.com/golang-migrate/migrate/v4/database/mysql"
+	migratePostgres "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	migrateSQLite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	"github.com/sagarsuperuser/userprofile/store/db/mysql"
+	"github.com/sagarsuperuser/userprofile/store/db/postgres"
+	"github.com/sagarsuperuser/userprofile/store/db/sqlite"
+)
+
+// migrationsTable is the golang-migrate default for every database driver.
+const migrationsTable = "schema_migrations"
+
+var ErrNotSupported = errors.New("driver has no migrations")
+
+// Status describes the schema version of a database.
+type Status struct {
+	// Version is the applied migration, 0 if none was applied yet.
+	Version uint
+	// Dirty is set when a migration failed half way and needs a force.
+	Dirty bool
+	// Latest is the newest migration embedded in this binary.
+	Latest uint
+}
+
+// Behind reports whether migrations embedded in this binary are not applied yet.
+func (s *Status) Behind() bool {
+	return s.Version < s.Latest
+}
+
+// Ahead reports whether the database was migrated by a newer binary.
+func (s *Status) Ahead() bool {
+	return s.Version > s.Latest
+}
+
+// Migrator wraps golang-migrate with the embedded migrations of a driver.
+type Migrator struct {
+	m      *migrate.Migrate
+	latest uint
+}
+
+// New creates a Migrator for the database driver named driverName.
+// Closing the Migrator also closes db.
+func New(driverName string, db *sql.DB) (*Migrator, error) {
+	src, err := newSource(driverName)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := latestVersion(src)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+
+	var inst database.Driver
+	switch driverName {
+	case "mysql":
+		inst, err = migrateMySQL.WithInstance(db, &migrateMySQL.Config{})
+	case "postgres":
+		inst, err = migratePostgres.WithInstance(db, &migratePostgres.Config{})
+	case "sqlite":
+		inst, err = migrateSQLite.WithInstance(db, &migrateSQLite.Config{})
+	}
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("init migrate instance: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, driverName, inst)
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("init migrate: %w", err)
+	}
+	return &Migrator{m: m, latest: latest}, nil
+}
+
+// Status returns the current schema version.
+func (m *Migrator) Status() (*Status, error) {
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, err
+	}
+	return &Status{Version: version, Dirty: dirty, Latest: m.latest}, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	return ignoreNoChange(m.m.Up())
+}
+
+// Down rolls back the last n migrations.
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid number of migrations %d", n)
+	}
+	return ignoreNoChange(m.m.Steps(-n))
+}
+
+// Goto migrates up or down to version v.
+func (m *Migrator) Goto(v uint) error {
+	return ignoreNoChange(m.m.Migrate(v))
+}
+
+// Force sets the schema version without running any migration, clearing the dirty flag.
+// Use -1 to mark the database as not migrated at all.
+func (m *Migrator) Force(v int) error {
+	return m.m.Force(v)
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// CheckSchema reads the schema version of db without taking the migration lock,
+// so it is cheap enough to run on every startup.
+func CheckSchema(driverName string, db *sql.DB) (*Status, error) {
+	src, err := newSource(driverName)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	latest, err := latestVersion(src)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{Latest: latest}
+	var version int64
+	err = db.QueryRow("SELECT version, dirty FROM "+migrationsTable+" LIMIT 1").Scan(&version, &status.Dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("read schema version: %w", err)
+	}
+	// golang-migrate stores -1 for a database forced to "no version".
+	if version > 0 {
+		status.Version = uint(version)
+	}
+	return status, nil
+}
+
+func newSource(driverName string) (source.Driver, error) {
+	var fsys fs.FS
+	switch driverName {
+	case "mysql":
+		fsys = mysql.Migrations
+	case "postgres":
+		fsys = postgres.Migrations
+	case "sqlite":
+		fsys = sqlite.Migrations
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNotSupported, driverName)
+	}
+	return iofs.New(fsys, "migrations")
+}
+
+func latestVersion(src source.Driver) (uint, error) {
+	v, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("read migrations: %w", err)
+	}
+	for {
+		next, err := src.Next(v)
+		if errors.Is(err, os.ErrNotExist) {
+			return v, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("read migrations: %w", err)
+		}
+		v = next
+	}
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
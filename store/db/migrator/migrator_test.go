@@ -0,0 +1,84 @@
+package migrator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store/db/sqlite"
+)
+
+func TestMigrator(t *testing.T) {
+	drv := sqlite.NewDB(&settings.Settings{SQLitePath: ":memory:"}, common.NowUTC)
+	m, err := New("sqlite", drv.GetDB())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer m.Close()
+
+	assertStatus := func(t *testing.T, wantVersion uint, wantDirty bool) {
+		t.Helper()
+		status, err := m.Status()
+		if err != nil {
+			t.Fatalf("Status: %v", err)
+		}
+		if status.Version != wantVersion || status.Dirty != wantDirty {
+			t.Errorf("Status = (%d, %t), want (%d, %t)", status.Version, status.Dirty, wantVersion, wantDirty)
+		}
+		checked, err := CheckSchema("sqlite", drv.GetDB())
+		if err != nil {
+			t.Fatalf("CheckSchema: %v", err)
+		}
+		if *checked != *status {
+			t.Errorf("CheckSchema = %+v, want %+v", *checked, *status)
+		}
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Latest == 0 || status.Version != 0 || !status.Behind() {
+		t.Fatalf("fresh database status = %+v, want behind", *status)
+	}
+	latest := status.Latest
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	assertStatus(t, latest, false)
+
+	// up again is a no-op
+	if err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	if err := m.Down(1); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	assertStatus(t, latest-1, false)
+
+	if err := m.Goto(latest); err != nil {
+		t.Fatalf("Goto: %v", err)
+	}
+	assertStatus(t, latest, false)
+
+	if err := m.Force(int(latest)); err != nil {
+		t.Fatalf("Force: %v", err)
+	}
+	assertStatus(t, latest, false)
+
+	if err := m.Down(0); err == nil {
+		t.Errorf("Down(0) should fail")
+	}
+}
+
+func TestNotSupported(t *testing.T) {
+	if _, err := New("memory", nil); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("New(memory) error = %v, want %v", err, ErrNotSupported)
+	}
+	if _, err := CheckSchema("memory", nil); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("CheckSchema(memory) error = %v, want %v", err, ErrNotSupported)
+	}
+}
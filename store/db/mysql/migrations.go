@@ -0,0 +1,8 @@
+package mysql
+
+import "embed"
+
+// Migrations holds the versioned schema migrations of the MySQL driver.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,12 @@
+-- cache_invalidations: changes other instances must drop from their caches.
+-- Rows are polled by id and deleted after a retention period.
+CREATE TABLE cache_invalidations (
+  id          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  source      VARCHAR(64) NOT NULL,   -- instance that published it
+  kind        VARCHAR(32) NOT NULL,
+  user_id     BIGINT UNSIGNED NOT NULL,
+  token_hash  BINARY(32) NULL,        -- sha256(token) for session invalidations
+  created_at  TIMESTAMP NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_cache_invalidations_created (created_at)
+);
@@ -0,0 +1,5 @@
+ALTER TABLE sessions
+  DROP COLUMN ip,
+  DROP COLUMN user_agent,
+  DROP COLUMN name,
+  DROP COLUMN last_seen_at;
@@ -0,0 +1,33 @@
+-- refresh_tokens: opaque refresh tokens, rotated on every use.
+-- Tokens rotated from one another share a family_id, the whole family
+-- is revoked when an already rotated token is presented again.
+CREATE TABLE refresh_tokens (
+  id          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id     BIGINT UNSIGNED NOT NULL,
+  family_id   VARCHAR(64) NOT NULL,
+  token_hash  BINARY(32) NOT NULL,    -- sha256(token)
+  expires_at  TIMESTAMP NOT NULL,
+  used_at     TIMESTAMP NULL,         -- set when rotated
+  revoked_at  TIMESTAMP NULL,
+  created_at  TIMESTAMP NOT NULL,
+  PRIMARY KEY (id),
+
+  CONSTRAINT fk_refresh_tokens_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  UNIQUE KEY uq_refresh_tokens_token_hash (token_hash),
+  KEY idx_refresh_tokens_family (family_id),
+  KEY idx_refresh_tokens_expires (expires_at)
+);
+
+-- revoked_access_tokens: jti denylist of access tokens revoked before they expire.
+-- Rows are deleted once the token has expired anyway.
+CREATE TABLE revoked_access_tokens (
+  jti         VARCHAR(64) NOT NULL,
+  user_id     BIGINT UNSIGNED NOT NULL,
+  expires_at  TIMESTAMP NOT NULL,
+  created_at  TIMESTAMP NOT NULL,
+  PRIMARY KEY (jti),
+  KEY idx_revoked_access_tokens_expires (expires_at)
+);
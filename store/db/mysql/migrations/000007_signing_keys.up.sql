@@ -0,0 +1,11 @@
+-- signing_keys: keys that sign the JWTs issued by the service, published on /.well-known/jwks.json.
+-- The newest key that is not retired signs new tokens, retired keys still verify
+-- the tokens they signed until they are deleted.
+CREATE TABLE signing_keys (
+  id           VARCHAR(64) NOT NULL,    -- kid, RFC 7638 thumbprint
+  algorithm    VARCHAR(16) NOT NULL,
+  private_key  BLOB NOT NULL,           -- PKCS #8, encrypted with SECRET_KEY
+  created_at   TIMESTAMP NOT NULL,
+  retired_at   TIMESTAMP NULL,
+  PRIMARY KEY (id)
+);
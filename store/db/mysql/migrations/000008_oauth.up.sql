@@ -0,0 +1,53 @@
+-- oauth_clients: applications that sign their users in with this service (OpenID Connect).
+-- Public clients have no secret and rely on PKCE alone.
+CREATE TABLE oauth_clients (
+  id             VARCHAR(64) NOT NULL,    -- client_id
+  name           VARCHAR(100) NOT NULL,
+  secret_hash    BINARY(32) NULL,         -- sha256(client_secret), NULL for public clients
+  redirect_uris  TEXT NOT NULL,           -- space separated, matched exactly
+  created_at     TIMESTAMP NOT NULL,
+  PRIMARY KEY (id)
+);
+
+-- oauth_consents: scopes a user allowed a client to access, the consent screen
+-- is skipped while a client asks for no more than that.
+CREATE TABLE oauth_consents (
+  user_id     BIGINT UNSIGNED NOT NULL,
+  client_id   VARCHAR(64) NOT NULL,
+  scope       VARCHAR(255) NOT NULL,
+  created_at  TIMESTAMP NOT NULL,
+  updated_at  TIMESTAMP NOT NULL,
+  PRIMARY KEY (user_id, client_id),
+
+  CONSTRAINT fk_oauth_consents_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_oauth_consents_client
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id)
+    ON DELETE CASCADE
+);
+
+-- oauth_authorization_codes: single use codes exchanged by clients for tokens.
+CREATE TABLE oauth_authorization_codes (
+  code_hash       BINARY(32) NOT NULL,  -- sha256(code)
+  client_id       VARCHAR(64) NOT NULL,
+  user_id         BIGINT UNSIGNED NOT NULL,
+  redirect_uri    TEXT NOT NULL,
+  scope           VARCHAR(255) NOT NULL,
+  nonce           VARCHAR(255) NOT NULL,
+  code_challenge  VARCHAR(128) NOT NULL, -- PKCE S256
+  auth_time       TIMESTAMP NOT NULL,    -- when the user signed in
+  expires_at      TIMESTAMP NOT NULL,
+  used_at         TIMESTAMP NULL,
+  created_at      TIMESTAMP NOT NULL,
+  PRIMARY KEY (code_hash),
+
+  CONSTRAINT fk_oauth_authorization_codes_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_oauth_authorization_codes_client
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id)
+    ON DELETE CASCADE,
+
+  KEY idx_oauth_authorization_codes_expires (expires_at)
+);
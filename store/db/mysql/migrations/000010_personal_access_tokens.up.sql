@@ -0,0 +1,21 @@
+-- personal_access_tokens: named, scoped API keys created by users for scripts.
+-- Only the hash of the token is stored, the token itself is shown once.
+CREATE TABLE personal_access_tokens (
+  id            BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id       BIGINT UNSIGNED NOT NULL,
+  name          VARCHAR(100) NOT NULL,
+  token_hash    BINARY(32) NOT NULL,    -- sha256(token)
+  scopes        VARCHAR(255) NOT NULL,  -- space separated
+  expires_at    TIMESTAMP NOT NULL,
+  last_used_at  TIMESTAMP NULL,
+  revoked_at    TIMESTAMP NULL,
+  created_at    TIMESTAMP NOT NULL,
+  PRIMARY KEY (id),
+
+  CONSTRAINT fk_personal_access_tokens_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  UNIQUE KEY uq_personal_access_tokens_token_hash (token_hash),
+  KEY idx_personal_access_tokens_expires (expires_at)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE users DROP FOREIGN KEY fk_users_role;
+UPDATE users SET role = 'user' WHERE role NOT IN ('user','admin');
+ALTER TABLE users MODIFY role ENUM('user','admin') NOT NULL DEFAULT 'user';
+
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
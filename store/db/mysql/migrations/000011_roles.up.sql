@@ -0,0 +1,44 @@
+-- roles: built-in and custom roles users are assigned to.
+-- Built-in roles are seeded below and can't be changed or deleted.
+CREATE TABLE roles (
+  name         VARCHAR(64) NOT NULL,
+  description  VARCHAR(255) NOT NULL DEFAULT '',
+  builtin      BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (name)
+);
+
+-- role_permissions: what each role is allowed to do, e.g. users:write.
+CREATE TABLE role_permissions (
+  role        VARCHAR(64) NOT NULL,
+  permission  VARCHAR(64) NOT NULL,
+  PRIMARY KEY (role, permission),
+
+  CONSTRAINT fk_role_permissions_role
+    FOREIGN KEY (role) REFERENCES roles(name)
+    ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description, builtin) VALUES
+  ('admin', 'Full access', TRUE),
+  ('support', 'Read access to users, for customer support', TRUE),
+  ('user', 'No administrative access', TRUE);
+
+INSERT INTO role_permissions (role, permission) VALUES
+  ('admin', 'oauth_clients:read'),
+  ('admin', 'oauth_clients:write'),
+  ('admin', 'roles:read'),
+  ('admin', 'roles:write'),
+  ('admin', 'service_accounts:read'),
+  ('admin', 'service_accounts:write'),
+  ('admin', 'stats:read'),
+  ('admin', 'users:read'),
+  ('admin', 'users:write'),
+  ('support', 'roles:read'),
+  ('support', 'users:read');
+
+-- users can be assigned custom roles from now on.
+ALTER TABLE users
+  MODIFY role VARCHAR(64) NOT NULL DEFAULT 'user',
+  ADD CONSTRAINT fk_users_role FOREIGN KEY (role) REFERENCES roles(name);
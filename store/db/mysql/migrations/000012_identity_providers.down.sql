@@ -0,0 +1,2 @@
+DELETE FROM auth_identities WHERE provider NOT IN ('local','google');
+ALTER TABLE auth_identities MODIFY provider ENUM('local','google') NOT NULL;
@@ -0,0 +1,3 @@
+-- auth_identities.provider names any identity provider configured in the settings
+-- (google, github, or a generic OpenID Connect issuer), not only google.
+ALTER TABLE auth_identities MODIFY provider VARCHAR(32) NOT NULL;
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"os"
+	"testing"
+
+	migrate "github.com/golang-migrate/migrate/v4"
+	migrateMySQL "github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/storetest"
+)
+
+// TestDriver needs a disposable database, configured through the usual MYSQL_* variables.
+// Every table is emptied before each test.
+func TestDriver(t *testing.T) {
+	if os.Getenv("TEST_MYSQL") == "" {
+		t.Skip("set TEST_MYSQL=1 to run the MySQL driver tests")
+	}
+	s := settings.NewSettings()
+
+	storetest.RunDriverTests(t, func(t *testing.T, now common.NowFunc) store.Driver {
+		drv := NewDB(s, now)
+		t.Cleanup(func() { drv.Close() })
+
+		inst, err := migrateMySQL.WithInstance(drv.GetDB(), &migrateMySQL.Config{})
+		if err != nil {
+			t.Fatalf("init migrate instance: %v", err)
+		}
+		src, err := iofs.New(Migrations, "migrations")
+		if err != nil {
+			t.Fatalf("read migrations: %v", err)
+		}
+		m, err := migrate.NewWithInstance("iofs", src, "mysql", inst)
+		if err != nil {
+			t.Fatalf("init migrate: %v", err)
+		}
+		if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+			t.Fatalf("apply migrations: %v", err)
+		}
+
+		// auth_identities, user_profiles and sessions cascade.
+		if _, err := drv.GetDB().Exec("DELETE FROM users"); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		return drv
+	})
+}
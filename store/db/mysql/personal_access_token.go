@@ -0,0 +1,129 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const personalAccessTokenColumns = "id, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at, created_at"
+
+func scanPersonalAccessToken(row interface{ Scan(...any) error }) (*store.PersonalAccessTokenInfo, error) {
+	var (
+		t         store.PersonalAccessTokenInfo
+		tokenHash []byte
+		scopes    string
+	)
+	if err := row.Scan(
+		&t.ID,
+		&t.UserID,
+		&t.Name,
+		&tokenHash,
+		&scopes,
+		&t.ExpiresAt,
+		&t.LastUsedAt,
+		&t.RevokedAt,
+		&t.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	copy(t.TokenHash[:], tokenHash)
+	t.Scopes = strings.Fields(scopes)
+	return &t, nil
+}
+
+func (d *DB) CreatePersonalAccessToken(ctx context.Context, create *store.CreatePersonalAccessToken) (*store.PersonalAccessTokenInfo, error) {
+	now := d.now()
+	res, err := d.db.ExecContext(ctx, `
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, create.UserID, create.Name, create.TokenHash[:], strings.Join(create.Scopes, " "), create.ExpiresAt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.PersonalAccessTokenInfo{
+		ID:        id,
+		UserID:    create.UserID,
+		Name:      create.Name,
+		TokenHash: create.TokenHash,
+		Scopes:    create.Scopes,
+		ExpiresAt: create.ExpiresAt,
+		CreatedAt: now,
+	}, nil
+}
+
+func (d *DB) GetPersonalAccessTokenByHash(ctx context.Context, hash [32]byte) (*store.PersonalAccessTokenInfo, error) {
+	row := d.db.QueryRowContext(ctx, "SELECT "+personalAccessTokenColumns+" FROM personal_access_tokens WHERE token_hash = ?", hash[:])
+	t, err := scanPersonalAccessToken(row)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrPersonalAccessTokenNotFound
+	}
+	return t, err
+}
+
+func (d *DB) ListPersonalAccessTokens(ctx context.Context, userID int64) ([]*store.PersonalAccessTokenInfo, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+personalAccessTokenColumns+`
+		FROM personal_access_tokens
+		WHERE user_id = ? AND revoked_at IS NULL
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*store.PersonalAccessTokenInfo, 0)
+	for rows.Next() {
+		t, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+
+	return list, rows.Err()
+}
+
+func (d *DB) RevokePersonalAccessToken(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE personal_access_tokens
+		SET revoked_at = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, d.now(), id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (d *DB) TouchPersonalAccessToken(ctx context.Context, id int64, lastUsedAt time.Time) error {
+	_, err := d.db.ExecContext(ctx, "UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?", lastUsedAt, id)
+	return err
+}
+
+func (d *DB) DeletePersonalAccessTokens(ctx context.Context, before time.Time) (int64, error) {
+	res, err := d.db.ExecContext(ctx, `
+		DELETE FROM personal_access_tokens
+		WHERE expires_at < ? OR revoked_at < ?
+	`, before, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
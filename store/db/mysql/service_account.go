@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const serviceAccountColumns = "id, name, secret_hash, scopes, owner_id, created_at"
+
+func scanServiceAccount(row interface{ Scan(...any) error }) (*store.ServiceAccountInfo, error) {
+	var (
+		a      store.ServiceAccountInfo
+		scopes string
+	)
+	if err := row.Scan(&a.ID, &a.Name, &a.SecretHash, &scopes, &a.OwnerID, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	a.Scopes = strings.Fields(scopes)
+	return &a, nil
+}
+
+func (d *DB) CreateServiceAccount(ctx context.Context, create *store.CreateServiceAccount) (*store.ServiceAccountInfo, error) {
+	now := d.now()
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO service_accounts (`+serviceAccountColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, create.ID, create.Name, create.SecretHash, strings.Join(create.Scopes, " "), create.OwnerID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.ServiceAccountInfo{
+		ID:         create.ID,
+		Name:       create.Name,
+		SecretHash: create.SecretHash,
+		Scopes:     create.Scopes,
+		OwnerID:    create.OwnerID,
+		CreatedAt:  now,
+	}, nil
+}
+
+func (d *DB) GetServiceAccount(ctx context.Context, id string) (*store.ServiceAccountInfo, error) {
+	row := d.db.QueryRowContext(ctx, "SELECT "+serviceAccountColumns+" FROM service_accounts WHERE id = ?", id)
+	a, err := scanServiceAccount(row)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrServiceAccountNotFound
+	}
+	return a, err
+}
+
+func (d *DB) ListServiceAccounts(ctx context.Context) ([]*store.ServiceAccountInfo, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT "+serviceAccountColumns+" FROM service_accounts ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*store.ServiceAccountInfo, 0)
+	for rows.Next() {
+		a, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+
+	return list, rows.Err()
+}
+
+func (d *DB) DeleteServiceAccount(ctx context.Context, id string) error {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM service_accounts WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrServiceAccountNotFound
+	}
+	return nil
+}
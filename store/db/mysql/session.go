@@ -3,20 +3,49 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"strings"
+	"time"
 
 	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
 	"github.com/sagarsuperuser/userprofile/store"
 )
 
-func (d *DB) CreateSession(ctx context.Context, userID int64, hash [32]byte) (*store.SessionInfo, error) {
+const sessionColumns = "id, user_id, token_hash, ip, user_agent, name, remember, expires_at, absolute_expires_at, last_seen_at, created_at, revoked_at"
+
+func scanSession(row interface{ Scan(...any) error }) (*store.SessionInfo, error) {
+	var (
+		s         store.SessionInfo
+		tokenHash []byte
+	)
+	if err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&tokenHash,
+		&s.IP,
+		&s.UserAgent,
+		&s.Name,
+		&s.Remember,
+		&s.ExpiresAt,
+		&s.AbsoluteExpiresAt,
+		&s.LastSeenAt,
+		&s.CreatedAt,
+		&s.RevokedAt,
+	); err != nil {
+		return nil, err
+	}
+	copy(s.TokenHash[:], tokenHash)
+	s.IsActive = s.RevokedAt == nil
+	return &s, nil
+}
+
+func (d *DB) CreateSession(ctx context.Context, create *store.CreateSession) (*store.SessionInfo, error) {
 	now := d.now()
-	expiresAt := now.Add(sessionUtils.SessionDuration)
 
 	// insert sesssion
 	res, err := d.db.ExecContext(ctx, `
-			INSERT INTO sessions (user_id, token_hash, expires_at, created_at)
-			VALUES (?, ?, ?, ?)
-	`, userID, hash[:], expiresAt, now)
+			INSERT INTO sessions (user_id, token_hash, ip, user_agent, remember, expires_at, absolute_expires_at, last_seen_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, create.UserID, create.TokenHash[:], create.IP, create.UserAgent, create.Remember, create.ExpiresAt, create.AbsoluteExpiresAt, now, now)
 
 	if err != nil {
 		return nil, err
@@ -28,37 +57,31 @@ func (d *DB) CreateSession(ctx context.Context, userID int64, hash [32]byte) (*s
 	}
 
 	return &store.SessionInfo{
-		ID:        id,
-		UserID:    userID,
-		TokenHash: hash,
-		ExpiresAt: expiresAt,
-		CreatedAt: now,
-		RevokedAt: nil,
-		IsActive:  true,
+		ID:                id,
+		UserID:            create.UserID,
+		TokenHash:         create.TokenHash,
+		IP:                create.IP,
+		UserAgent:         create.UserAgent,
+		Remember:          create.Remember,
+		ExpiresAt:         create.ExpiresAt,
+		AbsoluteExpiresAt: create.AbsoluteExpiresAt,
+		LastSeenAt:        now,
+		CreatedAt:         now,
+		RevokedAt:         nil,
+		IsActive:          true,
 	}, nil
 }
 
 func (d *DB) GetActiveSessionByHash(ctx context.Context, hash [32]byte) (*store.SessionInfo, error) {
 	now := d.now()
-	var (
-		s         store.SessionInfo
-		tokenHash []byte
-	)
-	err := d.db.QueryRowContext(ctx, `
-		SELECT id, user_id, token_hash, expires_at, created_at, revoked_at
+	s, err := scanSession(d.db.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+`
 		FROM sessions
 		WHERE token_hash = ?
 		AND revoked_at IS NULL
 		AND expires_at > ?
 		LIMIT 1
-	`, hash[:], now).Scan(
-		&s.ID,
-		&s.UserID,
-		&tokenHash,
-		&s.ExpiresAt,
-		&s.CreatedAt,
-		&s.RevokedAt,
-	)
+	`, hash[:], now))
 
 	if err == sql.ErrNoRows {
 		return nil, sessionUtils.ErrSesssionExpired
@@ -66,10 +89,72 @@ func (d *DB) GetActiveSessionByHash(ctx context.Context, hash [32]byte) (*store.
 	if err != nil {
 		return nil, err
 	}
+	return s, nil
+}
 
-	copy(s.TokenHash[:], tokenHash)
-	s.IsActive = true
-	return &s, nil
+func (d *DB) ListSessions(ctx context.Context, find *store.FindSession) ([]*store.SessionInfo, error) {
+	where, args := []string{"revoked_at IS NULL", "expires_at > ?"}, []any{d.now()}
+	if v := find.ID; v != nil {
+		where, args = append(where, "id = ?"), append(args, *v)
+	}
+	if v := find.UserID; v != nil {
+		where, args = append(where, "user_id = ?"), append(args, *v)
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY last_seen_at DESC, id DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*store.SessionInfo, 0)
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+
+	return list, rows.Err()
+}
+
+func (d *DB) UpdateSession(ctx context.Context, update *store.UpdateSession) (*store.SessionInfo, error) {
+	set, args := []string{}, []any{}
+	if v := update.Name; v != nil {
+		set, args = append(set, "name = ?"), append(args, *v)
+	}
+	if v := update.IP; v != nil {
+		set, args = append(set, "ip = ?"), append(args, *v)
+	}
+	if v := update.LastSeenAt; v != nil {
+		set, args = append(set, "last_seen_at = ?"), append(args, *v)
+	}
+	if v := update.ExpiresAt; v != nil {
+		set, args = append(set, "expires_at = ?"), append(args, *v)
+	}
+
+	if len(set) > 0 {
+		args = append(args, update.ID, d.now())
+		query := "UPDATE sessions SET " + strings.Join(set, ", ") + " WHERE id = ? AND revoked_at IS NULL AND expires_at > ?"
+		if _, err := d.db.ExecContext(ctx, query, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	list, err := d.ListSessions(ctx, &store.FindSession{ID: &update.ID})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, store.ErrSessionNotFound
+	}
+	return list[0], nil
 }
 
 func (d *DB) RevokeSession(ctx context.Context, hash [32]byte) (bool, error) {
@@ -89,3 +174,69 @@ func (d *DB) RevokeSession(ctx context.Context, hash [32]byte) (bool, error) {
 	}
 	return n == 1, nil // false => already revoked or not found
 }
+
+func (d *DB) RevokeUserSessions(ctx context.Context, userID int64) (int64, error) {
+	now := d.now()
+	res, err := d.db.ExecContext(ctx, `
+        UPDATE sessions
+        SET revoked_at = ?
+        WHERE user_id = ? AND revoked_at IS NULL
+    `, now, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (d *DB) DeleteSessions(ctx context.Context, before time.Time, limit int) ([][32]byte, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// MySQL has no RETURNING, read the batch first.
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, token_hash
+		FROM sessions
+		WHERE expires_at < ? OR revoked_at < ?
+		LIMIT ?
+		FOR UPDATE
+	`, before, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		ids    []any
+		hashes [][32]byte
+	)
+	for rows.Next() {
+		var (
+			id        int64
+			tokenHash []byte
+			hash      [32]byte
+		)
+		if err := rows.Scan(&id, &tokenHash); err != nil {
+			return nil, err
+		}
+		copy(hash[:], tokenHash)
+		ids, hashes = append(ids, id), append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sessions WHERE id IN ("+placeholders(len(ids))+")", ids...); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateSigningKey(ctx context.Context, create *store.CreateSigningKey) (*store.SigningKeyInfo, error) {
+	now := d.now()
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO signing_keys (id, algorithm, private_key, created_at)
+		VALUES (?, ?, ?, ?)
+	`, create.ID, create.Algorithm, create.PrivateKey, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.SigningKeyInfo{
+		ID:         create.ID,
+		Algorithm:  create.Algorithm,
+		PrivateKey: create.PrivateKey,
+		CreatedAt:  now,
+	}, nil
+}
+
+func (d *DB) ListSigningKeys(ctx context.Context, find *store.FindSigningKey) ([]*store.SigningKeyInfo, error) {
+	query, args := "SELECT id, algorithm, private_key, created_at, retired_at FROM signing_keys", []any{}
+	if v := find.RetiredAfter; v != nil {
+		query, args = query+" WHERE retired_at IS NULL OR retired_at > ?", append(args, *v)
+	}
+
+	rows, err := d.db.QueryContext(ctx, query+" ORDER BY created_at DESC, id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*store.SigningKeyInfo, 0)
+	for rows.Next() {
+		var k store.SigningKeyInfo
+		if err := rows.Scan(&k.ID, &k.Algorithm, &k.PrivateKey, &k.CreatedAt, &k.RetiredAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &k)
+	}
+
+	return list, rows.Err()
+}
+
+func (d *DB) RetireSigningKeys(ctx context.Context, keepID string) (int64, error) {
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE signing_keys
+		SET retired_at = ?
+		WHERE id <> ? AND retired_at IS NULL
+	`, d.now(), keepID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (d *DB) DeleteSigningKeys(ctx context.Context, retiredBefore time.Time) (int64, error) {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM signing_keys WHERE retired_at < ?", retiredBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,145 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const refreshTokenColumns = "id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at"
+
+func (d *DB) CreateRefreshToken(ctx context.Context, create *store.CreateRefreshToken) (*store.RefreshTokenInfo, error) {
+	now := d.now()
+	res, err := d.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, create.UserID, create.FamilyID, create.TokenHash[:], create.ExpiresAt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.RefreshTokenInfo{
+		ID:        id,
+		UserID:    create.UserID,
+		FamilyID:  create.FamilyID,
+		TokenHash: create.TokenHash,
+		ExpiresAt: create.ExpiresAt,
+		CreatedAt: now,
+	}, nil
+}
+
+func (d *DB) GetRefreshTokenByHash(ctx context.Context, hash [32]byte) (*store.RefreshTokenInfo, error) {
+	var (
+		t         store.RefreshTokenInfo
+		tokenHash []byte
+	)
+	err := d.db.QueryRowContext(ctx, `
+		SELECT `+refreshTokenColumns+`
+		FROM refresh_tokens
+		WHERE token_hash = ?
+	`, hash[:]).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.FamilyID,
+		&tokenHash,
+		&t.ExpiresAt,
+		&t.UsedAt,
+		&t.RevokedAt,
+		&t.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	copy(t.TokenHash[:], tokenHash)
+	return &t, nil
+}
+
+func (d *DB) UseRefreshToken(ctx context.Context, id int64) (bool, error) {
+	now := d.now()
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET used_at = ?
+		WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?
+	`, now, id, now)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (d *DB) RevokeRefreshTokenFamily(ctx context.Context, familyID string) (int64, error) {
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = ?
+		WHERE family_id = ? AND revoked_at IS NULL
+	`, d.now(), familyID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (d *DB) RevokeUserRefreshTokens(ctx context.Context, userID int64) (int64, error) {
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = ?
+		WHERE user_id = ? AND revoked_at IS NULL
+	`, d.now(), userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (d *DB) DeleteRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE expires_at < ? OR revoked_at < ?", before, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (d *DB) RevokeAccessToken(ctx context.Context, revoke *store.RevokeAccessToken) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO revoked_access_tokens (jti, user_id, expires_at, created_at)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE jti = jti
+	`, revoke.JTI, revoke.UserID, revoke.ExpiresAt, d.now())
+	return err
+}
+
+func (d *DB) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var n int
+	err := d.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM revoked_access_tokens WHERE jti = ?", jti).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (d *DB) DeleteRevokedAccessTokens(ctx context.Context, before time.Time) (int64, error) {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM revoked_access_tokens WHERE expires_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -23,6 +23,9 @@ func (d *DB) CreateLocalUser(ctx context.Context, in *store.CreateLocalUser) (*s
 		VALUES (?, FALSE, ?, ?)
 	`, in.Email, in.Status, in.Role)
 	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, store.ErrUserAlreadyExists
+		}
 		return nil, err
 	}
 
@@ -53,83 +56,125 @@ func (d *DB) CreateLocalUser(ctx context.Context, in *store.CreateLocalUser) (*s
 	return d.GetUser(ctx, &store.FindUser{ID: &userID})
 }
 
-func (d *DB) UpsertGoogleUser(ctx context.Context, email, sub string) (*store.UserInfo, error) {
+func (d *DB) UpsertProviderUser(ctx context.Context, upsert *store.UpsertProviderUser) (*store.UserInfo, error) {
 	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// 1) If google sub already linked -> use same user
+	// 1) If the provider sub is already linked -> use same user
 	var userID int64
 	err = tx.QueryRowContext(ctx, `
 		SELECT user_id
 		FROM auth_identities
-		WHERE provider = 'google' AND provider_subject = ?
+		WHERE provider = ? AND provider_subject = ?
 		FOR UPDATE
-	`, sub).Scan(&userID)
+	`, upsert.Provider, upsert.Subject).Scan(&userID)
 
 	if err == nil {
-		// TODO - check if sync email is needed.
-		// may cause issues if user already registered locally.
-
+		// release the connection before syncing and reading the user back.
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		if upsert.EmailVerified {
+			if err := d.syncProviderEmail(ctx, userID, upsert.Provider, upsert.Email); err != nil {
+				return nil, err
+			}
+		}
 		return d.GetUser(ctx, &store.FindUser{ID: &userID})
 	}
 	if !errors.Is(err, sql.ErrNoRows) {
 		return nil, err
 	}
+	if !upsert.EmailVerified {
+		return nil, store.ErrProviderEmailNotVerified
+	}
 
-	// 2) sub not linked yet -> find user by email (lock if exists)
+	// 2) sub not linked yet -> users with the same email must link it themselves
 	err = tx.QueryRowContext(ctx, `
 		SELECT id
 		FROM users
 		WHERE email = ?
-		FOR UPDATE
-	`, email).Scan(&userID)
-
-	if errors.Is(err, sql.ErrNoRows) {
-		// Create new google user
-		res, err := tx.ExecContext(ctx, `
-			INSERT INTO users (email, email_locked, status, role)
-			VALUES (?, TRUE, ?, ?)
-		`, email, store.StatusActive, store.RoleUser)
-		if err != nil {
-			return nil, err
-		}
-		userID, err = res.LastInsertId()
-		if err != nil {
-			return nil, err
-		}
+	`, upsert.Email).Scan(&userID)
+	if err == nil {
+		return nil, store.ErrIdentityNotLinked
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
 
-		if _, err := tx.ExecContext(ctx, `INSERT INTO user_profiles (user_id) VALUES (?)`, userID); err != nil {
-			return nil, err
+	// 3) Create new provider user with its identity
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO users (email, email_locked, status, role)
+		VALUES (?, TRUE, ?, ?)
+	`, upsert.Email, store.StatusActive, store.RoleUser)
+	if err != nil {
+		if isDuplicateKey(err) {
+			// the same email was just signed up with concurrently
+			return nil, store.ErrIdentityNotLinked
 		}
-	} else if err != nil {
+		return nil, err
+	}
+	userID, err = res.LastInsertId()
+	if err != nil {
 		return nil, err
 	}
 
-	// 3) Link google identity (idempotent)
+	if _, err := tx.ExecContext(ctx, `INSERT INTO user_profiles (user_id) VALUES (?)`, userID); err != nil {
+		return nil, err
+	}
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO auth_identities (user_id, provider, provider_subject)
 		VALUES (?, ?, ?)
-		ON DUPLICATE KEY UPDATE updated_at = CURRENT_TIMESTAMP
-	`, userID, store.ProviderGoogle, sub)
+	`, userID, upsert.Provider, upsert.Subject)
 	if err != nil {
+		if isDuplicateKey(err) {
+			// the same subject was just signed in with concurrently
+			return nil, store.ErrIdentityNotLinked
+		}
 		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
+		if isDuplicateKey(err) {
+			return nil, store.ErrIdentityNotLinked
+		}
 		return nil, err
 	}
 
 	return d.GetUser(ctx, &store.FindUser{ID: &userID})
 }
 
+// syncProviderEmail updates the email of a user created by a provider to the one
+// the provider now has. Only the identity that created the user, its oldest one,
+// manages the email, and it is kept when another user has the new email already.
+func (d *DB) syncProviderEmail(ctx context.Context, userID int64, provider store.Provider, email string) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE users
+		SET email = ?
+		WHERE id = ? AND email_locked = TRUE AND email <> ?
+			AND ? = (
+				SELECT provider FROM auth_identities
+				WHERE user_id = ?
+				ORDER BY id
+				LIMIT 1
+			)
+	`, email, userID, email, provider, userID)
+	if err != nil && !isDuplicateKey(err) {
+		return err
+	}
+	return nil
+}
+
 func (d *DB) UpdateUser(ctx context.Context, update *store.UpdateUser) (*store.UserInfo, error) {
 	// check if email updation is locked
 	if update.Email != nil {
 		var emailLocked bool
 		err := d.db.QueryRowContext(ctx, "SELECT email_locked FROM users WHERE id = ?", update.ID).Scan(&emailLocked)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrUserNotFound
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -172,6 +217,9 @@ func (d *DB) UpdateUser(ctx context.Context, update *store.UpdateUser) (*store.U
 	if v := update.Role; v != nil {
 		set, args = append(set, "role = ?"), append(args, *v)
 	}
+	if v := update.Status; v != nil {
+		set, args = append(set, "status = ?"), append(args, *v)
+	}
 
 	if v := update.Email; v != nil {
 		set, args = append(set, "email = ?"), append(args, *v)
@@ -181,6 +229,9 @@ func (d *DB) UpdateUser(ctx context.Context, update *store.UpdateUser) (*store.U
 		args = append(args, update.ID)
 		query := "UPDATE users SET " + strings.Join(set, ", ") + " WHERE id = ?"
 		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			if isDuplicateKey(err) {
+				return nil, store.ErrUserAlreadyExists
+			}
 			return nil, err
 		}
 	}
@@ -211,15 +262,55 @@ func (d *DB) ListUsers(ctx context.Context, find *store.FindUser) ([]*store.User
 	}
 
 	if v := find.Provider; v != nil {
-		where, args = append(where, "ai.provider = ?"), append(args, *v)
+		where, args = append(where, "EXISTS (SELECT 1 FROM auth_identities pi WHERE pi.user_id = u.id AND pi.provider = ?)"), append(args, *v)
+	}
+
+	if v := find.Status; v != nil {
+		where, args = append(where, "u.status = ?"), append(args, *v)
+	}
+
+	if v := find.EmailPrefix; v != nil {
+		where, args = append(where, "u.email LIKE ? ESCAPE '!'"), append(args, likePrefix(*v))
+	}
+
+	if v := find.NamePrefix; v != nil {
+		where, args = append(where, "p.full_name LIKE ? ESCAPE '!'"), append(args, likePrefix(*v))
+	}
+
+	if v := find.CreatedAfter; v != nil {
+		where, args = append(where, "u.created_at > ?"), append(args, *v)
+	}
+
+	if v := find.CreatedBefore; v != nil {
+		where, args = append(where, "u.created_at < ?"), append(args, *v)
+	}
+
+	if v := find.UpdatedAfter; v != nil {
+		where, args = append(where, "u.updated_at > ?"), append(args, *v)
+	}
+
+	if v := find.UpdatedBefore; v != nil {
+		where, args = append(where, "u.updated_at < ?"), append(args, *v)
+	}
+
+	// keyset pagination: continue right after the cursor in sort order.
+	sort := find.Sort.OrDefault()
+	column, op, dir := "u."+sort.Field(), ">", "ASC"
+	if sort.Desc() {
+		op, dir = "<", "DESC"
+	}
+	if c := find.Cursor; c != nil {
+		where = append(where, fmt.Sprintf("(%s %s ? OR (%s = ? AND u.id %s ?))", column, op, column, op))
+		args = append(args, c.Value(), c.Value(), c.ID)
 	}
 
 	joins := []string{
 		"JOIN user_profiles p ON u.id = p.user_id",
-		"JOIN auth_identities ai ON ai.user_id = u.id",
+		// only the local identity carries a password.
+		"LEFT JOIN auth_identities ai ON ai.user_id = u.id AND ai.provider = 'local'",
 	}
 
-	orderBy := []string{"u.created_at DESC"}
+	orderBy := []string{column + " " + dir, "u.id " + dir}
 
 	query := `
 		SELECT
@@ -239,7 +330,7 @@ func (d *DB) ListUsers(ctx context.Context, find *store.FindUser) ([]*store.User
 		WHERE ` + strings.Join(where, " AND ") + `
 		ORDER BY ` + strings.Join(orderBy, ", ")
 	if v := find.Limit; v != nil {
-		query += fmt.Sprintf(" LIMIT %d", *v)
+		query, args = query+" LIMIT ?", append(args, *v)
 	}
 	rows, err := d.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -276,6 +367,10 @@ func (d *DB) ListUsers(ctx context.Context, find *store.FindUser) ([]*store.User
 		return nil, err
 	}
 
+	if err := d.loadIdentities(ctx, list); err != nil {
+		return nil, err
+	}
+
 	return list, nil
 }
 
@@ -292,8 +387,49 @@ func (d *DB) GetUser(ctx context.Context, find *store.FindUser) (*store.UserInfo
 
 }
 
+// loadIdentities fills in the auth identities of every user in list.
+// They are read separately so linked accounts don't multiply user rows.
+func (d *DB) loadIdentities(ctx context.Context, list []*store.UserInfo) error {
+	if len(list) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*store.UserInfo, len(list))
+	args := make([]any, 0, len(list))
+	for _, user := range list {
+		byID[user.ID] = user
+		args = append(args, user.ID)
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT user_id, provider, provider_subject, password_hash IS NOT NULL, created_at
+		FROM auth_identities
+		WHERE user_id IN (`+placeholders(len(args))+`)
+		ORDER BY created_at, id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			userID   int64
+			subject  sql.NullString
+			identity store.Identity
+		)
+		if err := rows.Scan(&userID, &identity.Provider, &subject, &identity.HasPassword, &identity.CreatedAt); err != nil {
+			return err
+		}
+		identity.Subject = subject.String
+		user := byID[userID]
+		user.Identities = append(user.Identities, &identity)
+	}
+
+	return rows.Err()
+}
+
 func (d *DB) DeleteUser(ctx context.Context, delete *store.DeleteUser) (bool, error) {
-	result, err := d.db.ExecContext(ctx, "DELETE FROM user WHERE id = ?", delete.ID)
+	result, err := d.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", delete.ID)
 	if err != nil {
 		return false, err
 	}
@@ -316,3 +452,8 @@ func placeholders(n int) string {
 	return strings.Join(list, ", ")
 
 }
+
+// likePrefix turns prefix into a LIKE pattern, escaping wildcards with '!'.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) LinkIdentity(ctx context.Context, link *store.LinkIdentity) error {
+	// uq_user_provider and uq_provider_subject reject the identity if it is linked already
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO auth_identities (user_id, provider, provider_subject)
+		VALUES ($1, $2, $3)
+	`, link.UserID, link.Provider, link.Subject)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return store.ErrIdentityAlreadyLinked
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *DB) ListIdentities(ctx context.Context, userID int64) ([]*store.Identity, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT provider, provider_subject, password_hash IS NOT NULL, created_at
+		FROM auth_identities
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanIdentities(rows)
+}
+
+func (d *DB) DeleteIdentity(ctx context.Context, delete *store.DeleteIdentity) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// lock the identities of the user so two deletions can't each
+	// leave the other identity as the last one.
+	rows, err := tx.QueryContext(ctx, `
+		SELECT provider, provider_subject, password_hash IS NOT NULL, created_at
+		FROM auth_identities
+		WHERE user_id = $1
+		ORDER BY created_at, id
+		FOR UPDATE
+	`, delete.UserID)
+	if err != nil {
+		return err
+	}
+	identities, err := scanIdentities(rows)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(identities, func(identity *store.Identity) bool { return identity.Provider == delete.Provider }) {
+		return store.ErrIdentityNotFound
+	}
+	if !delete.KeepsLoginMethod(identities) {
+		return store.ErrLastLoginMethod
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM auth_identities
+		WHERE user_id = $1 AND provider = $2
+	`, delete.UserID, delete.Provider)
+	if err != nil {
+		return err
+	}
+	// the email of a provider user follows the identity that created it, so it
+	// becomes the user's own to change once that identity is gone.
+	if identities[0].Provider == delete.Provider {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE users
+			SET email_locked = FALSE
+			WHERE id = $1
+		`, delete.UserID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func scanIdentities(rows *sql.Rows) ([]*store.Identity, error) {
+	defer rows.Close()
+
+	list := make([]*store.Identity, 0)
+	for rows.Next() {
+		var (
+			subject  sql.NullString
+			identity store.Identity
+		)
+		if err := rows.Scan(&identity.Provider, &subject, &identity.HasPassword, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identity.Subject = subject.String
+		list = append(list, &identity)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateInvalidation(ctx context.Context, inv *store.Invalidation) error {
+	var tokenHash []byte
+	if inv.TokenHash != ([32]byte{}) {
+		tokenHash = inv.TokenHash[:]
+	}
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO cache_invalidations (source, kind, user_id, token_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, inv.Source, inv.Kind, inv.UserID, tokenHash, d.now())
+	return err
+}
+
+func (d *DB) ListInvalidations(ctx context.Context, afterID int64, limit int) ([]*store.Invalidation, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, source, kind, user_id, token_hash, created_at
+		FROM cache_invalidations
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*store.Invalidation, 0)
+	for rows.Next() {
+		var (
+			inv       store.Invalidation
+			tokenHash []byte
+		)
+		if err := rows.Scan(&inv.ID, &inv.Source, &inv.Kind, &inv.UserID, &tokenHash, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		copy(inv.TokenHash[:], tokenHash)
+		list = append(list, &inv)
+	}
+
+	return list, rows.Err()
+}
+
+func (d *DB) LatestInvalidationID(ctx context.Context) (int64, error) {
+	var id int64
+	err := d.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM cache_invalidations").Scan(&id)
+	return id, err
+}
+
+func (d *DB) DeleteInvalidations(ctx context.Context, before time.Time) (int64, error) {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM cache_invalidations WHERE created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
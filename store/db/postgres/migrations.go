@@ -0,0 +1,8 @@
+package postgres
+
+import "embed"
+
+// Migrations holds the versioned schema migrations of the PostgreSQL driver.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS user_profiles;
+DROP TABLE IF EXISTS auth_identities;
+DROP TABLE IF EXISTS users;
+DROP FUNCTION IF EXISTS set_updated_at();
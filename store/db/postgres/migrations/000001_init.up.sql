@@ -0,0 +1,81 @@
+-- keeps updated_at current, Postgres has no ON UPDATE CURRENT_TIMESTAMP.
+CREATE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at = now();
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- users table:
+CREATE TABLE users (
+  id            BIGSERIAL PRIMARY KEY,
+  email         VARCHAR(320) NOT NULL,
+  email_locked  BOOLEAN NOT NULL, -- TRUE for Google auth users
+  status        VARCHAR(16) NOT NULL DEFAULT 'active' CHECK (status IN ('active','disabled')),
+  role          VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (role IN ('user','admin')),
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- emails are unique regardless of case, like the MySQL default collation.
+CREATE UNIQUE INDEX uq_users_email ON users (lower(email));
+
+CREATE TRIGGER trg_users_updated_at BEFORE UPDATE ON users
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- auth_identities: supports local password OR google sso
+CREATE TABLE auth_identities (
+  id                BIGSERIAL PRIMARY KEY,
+  user_id           BIGINT NOT NULL,
+  provider          VARCHAR(16) NOT NULL CHECK (provider IN ('local','google')),
+  provider_subject  VARCHAR(255) NULL,  -- for Google: "sub"
+  password_hash     VARCHAR(255) NULL,  -- for password: bcrypt hash
+  created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT fk_auth_identities_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_user_provider UNIQUE (user_id, provider),
+  CONSTRAINT uq_provider_subject UNIQUE (provider, provider_subject)
+);
+
+CREATE TRIGGER trg_auth_identities_updated_at BEFORE UPDATE ON auth_identities
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- user_profiles table: stores additional user info
+CREATE TABLE user_profiles (
+  user_id      BIGINT NOT NULL PRIMARY KEY,
+  full_name    VARCHAR(255) NOT NULL DEFAULT '',
+  telephone    VARCHAR(30)  NOT NULL DEFAULT '',
+  avatar_url   VARCHAR(2048) NOT NULL DEFAULT '',
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT fk_profiles_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE TRIGGER trg_user_profiles_updated_at BEFORE UPDATE ON user_profiles
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- sessions table: stores user sessions
+CREATE TABLE sessions (
+  id          BIGSERIAL PRIMARY KEY,
+  user_id     BIGINT NOT NULL,
+  token_hash  BYTEA NOT NULL,          -- sha256(token)
+  expires_at  TIMESTAMPTZ NOT NULL,
+  revoked_at  TIMESTAMPTZ NULL,
+  created_at  TIMESTAMPTZ NOT NULL,
+
+  CONSTRAINT fk_sessions_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_sessions_token_hash UNIQUE (token_hash)
+);
+
+CREATE INDEX idx_sessions_expires ON sessions (expires_at);
@@ -0,0 +1,12 @@
+-- cache_invalidations: changes other instances must drop from their caches.
+-- Rows are polled by id and deleted after a retention period.
+CREATE TABLE cache_invalidations (
+  id          BIGSERIAL PRIMARY KEY,
+  source      VARCHAR(64) NOT NULL,   -- instance that published it
+  kind        VARCHAR(32) NOT NULL,
+  user_id     BIGINT NOT NULL,
+  token_hash  BYTEA NULL,             -- sha256(token) for session invalidations
+  created_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_cache_invalidations_created ON cache_invalidations (created_at);
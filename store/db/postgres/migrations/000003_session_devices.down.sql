@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_sessions_user;
+
+ALTER TABLE sessions
+  DROP COLUMN ip,
+  DROP COLUMN user_agent,
+  DROP COLUMN name,
+  DROP COLUMN last_seen_at;
@@ -0,0 +1,12 @@
+-- device info shown on the sessions page
+ALTER TABLE sessions
+  ADD COLUMN ip            VARCHAR(45)  NOT NULL DEFAULT '',
+  ADD COLUMN user_agent    VARCHAR(512) NOT NULL DEFAULT '',
+  ADD COLUMN name          VARCHAR(100) NOT NULL DEFAULT '', -- set by the user
+  ADD COLUMN last_seen_at  TIMESTAMPTZ NULL;
+
+UPDATE sessions SET last_seen_at = created_at;
+
+ALTER TABLE sessions ALTER COLUMN last_seen_at SET NOT NULL;
+
+CREATE INDEX idx_sessions_user ON sessions (user_id);
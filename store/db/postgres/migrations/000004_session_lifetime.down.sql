@@ -0,0 +1,3 @@
+ALTER TABLE sessions
+  DROP COLUMN remember,
+  DROP COLUMN absolute_expires_at;
@@ -0,0 +1,8 @@
+-- expires_at slides with use, absolute_expires_at caps it
+ALTER TABLE sessions
+  ADD COLUMN remember            BOOLEAN NOT NULL DEFAULT FALSE, -- "remember me" policy
+  ADD COLUMN absolute_expires_at TIMESTAMPTZ NULL;
+
+UPDATE sessions SET absolute_expires_at = expires_at;
+
+ALTER TABLE sessions ALTER COLUMN absolute_expires_at SET NOT NULL;
@@ -0,0 +1,2 @@
+-- lets the session sweeper find revoked sessions without a full scan
+CREATE INDEX idx_sessions_revoked ON sessions (revoked_at);
@@ -0,0 +1,32 @@
+-- refresh_tokens: opaque refresh tokens, rotated on every use.
+-- Tokens rotated from one another share a family_id, the whole family
+-- is revoked when an already rotated token is presented again.
+CREATE TABLE refresh_tokens (
+  id          BIGSERIAL PRIMARY KEY,
+  user_id     BIGINT NOT NULL,
+  family_id   VARCHAR(64) NOT NULL,
+  token_hash  BYTEA NOT NULL,          -- sha256(token)
+  expires_at  TIMESTAMPTZ NOT NULL,
+  used_at     TIMESTAMPTZ NULL,        -- set when rotated
+  revoked_at  TIMESTAMPTZ NULL,
+  created_at  TIMESTAMPTZ NOT NULL,
+
+  CONSTRAINT fk_refresh_tokens_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_refresh_tokens_token_hash UNIQUE (token_hash)
+);
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens (user_id);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
+CREATE INDEX idx_refresh_tokens_expires ON refresh_tokens (expires_at);
+
+-- revoked_access_tokens: jti denylist of access tokens revoked before they expire.
+-- Rows are deleted once the token has expired anyway.
+CREATE TABLE revoked_access_tokens (
+  jti         VARCHAR(64) PRIMARY KEY,
+  user_id     BIGINT NOT NULL,
+  expires_at  TIMESTAMPTZ NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_revoked_access_tokens_expires ON revoked_access_tokens (expires_at);
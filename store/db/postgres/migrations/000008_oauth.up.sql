@@ -0,0 +1,53 @@
+-- oauth_clients: applications that sign their users in with this service (OpenID Connect).
+-- Public clients have no secret and rely on PKCE alone.
+CREATE TABLE oauth_clients (
+  id             VARCHAR(64) PRIMARY KEY,  -- client_id
+  name           VARCHAR(100) NOT NULL,
+  secret_hash    BYTEA NULL,               -- sha256(client_secret), NULL for public clients
+  redirect_uris  TEXT NOT NULL,            -- space separated, matched exactly
+  created_at     TIMESTAMPTZ NOT NULL
+);
+
+-- oauth_consents: scopes a user allowed a client to access, the consent screen
+-- is skipped while a client asks for no more than that.
+CREATE TABLE oauth_consents (
+  user_id     BIGINT NOT NULL,
+  client_id   VARCHAR(64) NOT NULL,
+  scope       VARCHAR(255) NOT NULL,
+  created_at  TIMESTAMPTZ NOT NULL,
+  updated_at  TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (user_id, client_id),
+
+  CONSTRAINT fk_oauth_consents_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_oauth_consents_client
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX idx_oauth_consents_client ON oauth_consents (client_id);
+
+-- oauth_authorization_codes: single use codes exchanged by clients for tokens.
+CREATE TABLE oauth_authorization_codes (
+  code_hash       BYTEA PRIMARY KEY,     -- sha256(code)
+  client_id       VARCHAR(64) NOT NULL,
+  user_id         BIGINT NOT NULL,
+  redirect_uri    TEXT NOT NULL,
+  scope           VARCHAR(255) NOT NULL,
+  nonce           VARCHAR(255) NOT NULL,
+  code_challenge  VARCHAR(128) NOT NULL, -- PKCE S256
+  auth_time       TIMESTAMPTZ NOT NULL,  -- when the user signed in
+  expires_at      TIMESTAMPTZ NOT NULL,
+  used_at         TIMESTAMPTZ NULL,
+  created_at      TIMESTAMPTZ NOT NULL,
+
+  CONSTRAINT fk_oauth_authorization_codes_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_oauth_authorization_codes_client
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX idx_oauth_authorization_codes_user ON oauth_authorization_codes (user_id);
+CREATE INDEX idx_oauth_authorization_codes_client ON oauth_authorization_codes (client_id);
+CREATE INDEX idx_oauth_authorization_codes_expires ON oauth_authorization_codes (expires_at);
@@ -0,0 +1,15 @@
+-- service_accounts: machine callers authenticating with the client_credentials grant.
+-- Their tokens only carry scopes out of the allowed ones.
+CREATE TABLE service_accounts (
+  id           VARCHAR(64) PRIMARY KEY,  -- client_id
+  name         VARCHAR(100) NOT NULL,
+  secret_hash  BYTEA NOT NULL,           -- sha256(client_secret)
+  scopes       VARCHAR(255) NOT NULL,    -- space separated allowed scopes
+  owner_id     BIGINT NOT NULL,          -- user responsible for the account
+  created_at   TIMESTAMPTZ NOT NULL,
+
+  CONSTRAINT fk_service_accounts_owner
+    FOREIGN KEY (owner_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX idx_service_accounts_owner ON service_accounts (owner_id);
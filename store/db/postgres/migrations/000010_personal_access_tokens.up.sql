@@ -0,0 +1,21 @@
+-- personal_access_tokens: named, scoped API keys created by users for scripts.
+-- Only the hash of the token is stored, the token itself is shown once.
+CREATE TABLE personal_access_tokens (
+  id            BIGSERIAL PRIMARY KEY,
+  user_id       BIGINT NOT NULL,
+  name          VARCHAR(100) NOT NULL,
+  token_hash    BYTEA NOT NULL,          -- sha256(token)
+  scopes        VARCHAR(255) NOT NULL,   -- space separated
+  expires_at    TIMESTAMPTZ NOT NULL,
+  last_used_at  TIMESTAMPTZ NULL,
+  revoked_at    TIMESTAMPTZ NULL,
+  created_at    TIMESTAMPTZ NOT NULL,
+
+  CONSTRAINT fk_personal_access_tokens_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_personal_access_tokens_token_hash UNIQUE (token_hash)
+);
+CREATE INDEX idx_personal_access_tokens_user ON personal_access_tokens (user_id);
+CREATE INDEX idx_personal_access_tokens_expires ON personal_access_tokens (expires_at);
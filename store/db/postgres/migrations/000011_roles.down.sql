@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_users_role;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_users_role;
+UPDATE users SET role = 'user' WHERE role NOT IN ('user','admin');
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(16);
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user','admin'));
+
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,3 @@
+DELETE FROM auth_identities WHERE provider NOT IN ('local','google');
+ALTER TABLE auth_identities ALTER COLUMN provider TYPE VARCHAR(16);
+ALTER TABLE auth_identities ADD CONSTRAINT auth_identities_provider_check CHECK (provider IN ('local','google'));
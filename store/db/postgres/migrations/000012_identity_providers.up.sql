@@ -0,0 +1,4 @@
+-- auth_identities.provider names any identity provider configured in the settings
+-- (google, github, or a generic OpenID Connect issuer), not only google.
+ALTER TABLE auth_identities DROP CONSTRAINT IF EXISTS auth_identities_provider_check;
+ALTER TABLE auth_identities ALTER COLUMN provider TYPE VARCHAR(32);
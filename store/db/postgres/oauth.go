@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const (
+	oauthClientColumns       = "id, name, secret_hash, redirect_uris, created_at"
+	authorizationCodeColumns = "code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at, used_at, created_at"
+)
+
+func scanOAuthClient(row interface{ Scan(...any) error }) (*store.OAuthClientInfo, error) {
+	var (
+		c            store.OAuthClientInfo
+		redirectURIs string
+	)
+	if err := row.Scan(&c.ID, &c.Name, &c.SecretHash, &redirectURIs, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	c.RedirectURIs = strings.Fields(redirectURIs)
+	return &c, nil
+}
+
+func (d *DB) CreateOAuthClient(ctx context.Context, create *store.CreateOAuthClient) (*store.OAuthClientInfo, error) {
+	now := d.now()
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, create.ID, create.Name, create.SecretHash, strings.Join(create.RedirectURIs, " "), now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.OAuthClientInfo{
+		ID:           create.ID,
+		Name:         create.Name,
+		SecretHash:   create.SecretHash,
+		RedirectURIs: create.RedirectURIs,
+		CreatedAt:    now,
+	}, nil
+}
+
+func (d *DB) GetOAuthClient(ctx context.Context, id string) (*store.OAuthClientInfo, error) {
+	row := d.db.QueryRowContext(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients WHERE id = $1", id)
+	c, err := scanOAuthClient(row)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrOAuthClientNotFound
+	}
+	return c, err
+}
+
+func (d *DB) ListOAuthClients(ctx context.Context) ([]*store.OAuthClientInfo, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*store.OAuthClientInfo, 0)
+	for rows.Next() {
+		c, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+
+	return list, rows.Err()
+}
+
+func (d *DB) DeleteOAuthClient(ctx context.Context, id string) error {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM oauth_clients WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrOAuthClientNotFound
+	}
+	return nil
+}
+
+func (d *DB) GetOAuthConsent(ctx context.Context, userID int64, clientID string) (*store.OAuthConsentInfo, error) {
+	var (
+		c     store.OAuthConsentInfo
+		scope string
+	)
+	err := d.db.QueryRowContext(ctx, `
+		SELECT user_id, client_id, scope, created_at, updated_at
+		FROM oauth_consents
+		WHERE user_id = $1 AND client_id = $2
+	`, userID, clientID).Scan(&c.UserID, &c.ClientID, &scope, &c.CreatedAt, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrOAuthConsentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Scopes = strings.Fields(scope)
+	return &c, nil
+}
+
+func (d *DB) UpsertOAuthConsent(ctx context.Context, upsert *store.UpsertOAuthConsent) error {
+	now := d.now()
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO oauth_consents (user_id, client_id, scope, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET scope = excluded.scope, updated_at = excluded.updated_at
+	`, upsert.UserID, upsert.ClientID, strings.Join(upsert.Scopes, " "), now, now)
+	return err
+}
+
+func (d *DB) CreateAuthorizationCode(ctx context.Context, create *store.CreateAuthorizationCode) (*store.AuthorizationCodeInfo, error) {
+	now := d.now()
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO oauth_authorization_codes (`+authorizationCodeColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULL, $10)
+	`,
+		create.CodeHash[:],
+		create.ClientID,
+		create.UserID,
+		create.RedirectURI,
+		strings.Join(create.Scopes, " "),
+		create.Nonce,
+		create.CodeChallenge,
+		create.AuthTime,
+		create.ExpiresAt,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.AuthorizationCodeInfo{
+		CodeHash:      create.CodeHash,
+		ClientID:      create.ClientID,
+		UserID:        create.UserID,
+		RedirectURI:   create.RedirectURI,
+		Scopes:        create.Scopes,
+		Nonce:         create.Nonce,
+		CodeChallenge: create.CodeChallenge,
+		AuthTime:      create.AuthTime,
+		ExpiresAt:     create.ExpiresAt,
+		CreatedAt:     now,
+	}, nil
+}
+
+func (d *DB) GetAuthorizationCodeByHash(ctx context.Context, hash [32]byte) (*store.AuthorizationCodeInfo, error) {
+	var (
+		c        store.AuthorizationCodeInfo
+		codeHash []byte
+		scope    string
+	)
+	err := d.db.QueryRowContext(ctx, `
+		SELECT `+authorizationCodeColumns+`
+		FROM oauth_authorization_codes
+		WHERE code_hash = $1
+	`, hash[:]).Scan(
+		&codeHash,
+		&c.ClientID,
+		&c.UserID,
+		&c.RedirectURI,
+		&scope,
+		&c.Nonce,
+		&c.CodeChallenge,
+		&c.AuthTime,
+		&c.ExpiresAt,
+		&c.UsedAt,
+		&c.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrAuthorizationCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	copy(c.CodeHash[:], codeHash)
+	c.Scopes = strings.Fields(scope)
+	return &c, nil
+}
+
+func (d *DB) UseAuthorizationCode(ctx context.Context, hash [32]byte) (bool, error) {
+	now := d.now()
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE oauth_authorization_codes
+		SET used_at = $1
+		WHERE code_hash = $2 AND used_at IS NULL AND expires_at > $3
+	`, now, hash[:], now)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (d *DB) DeleteAuthorizationCodes(ctx context.Context, before time.Time) (int64, error) {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM oauth_authorization_codes WHERE expires_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
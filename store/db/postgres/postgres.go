@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+type DB struct {
+	db       *sql.DB
+	settings *settings.Settings
+	now      common.NowFunc
+}
+
+func NewDB(settings *settings.Settings, now common.NowFunc) store.Driver {
+	driver := DB{settings: settings}
+	dsn := BuildDSN(settings)
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open PostgreSQL connection")
+	}
+	driver.db = db
+
+	// set pool options
+	driver.db.SetMaxOpenConns(settings.PostgresMaxOpenConns)
+	driver.db.SetMaxIdleConns(settings.PostgresMaxIdleConns)
+	driver.db.SetConnMaxLifetime(settings.PostgresConnMaxLifetime)
+	driver.db.SetConnMaxIdleTime(settings.PostgresConnMaxIdleTime)
+
+	// Test the connection
+	if err := driver.db.Ping(); err != nil {
+		log.Debug().Str("host", settings.PostgresHost).Msg("Configured DSN")
+		log.Fatal().Err(err).Msg("Failed to ping PostgreSQL")
+	}
+
+	log.Info().
+		Str("host", settings.PostgresHost).
+		Int("port", settings.PostgresPort).
+		Str("database", settings.PostgresDatabase).
+		Msg("Connected to PostgreSQL")
+
+	driver.now = now
+	return &driver
+}
+
+func (d *DB) GetDB() *sql.DB {
+	return d.db
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// BuildDSN creates a postgres:// connection URL from settings.
+func BuildDSN(settings *settings.Settings) string {
+	q := url.Values{}
+	q.Set("sslmode", settings.PostgresSSLMode)
+	// Timeouts
+	q.Set("connect_timeout", strconv.Itoa(int(settings.PostgresConnectTimeout.Seconds())))
+	q.Set("statement_timeout", strconv.FormatInt(settings.PostgresQueryTimeout.Milliseconds(), 10))
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(settings.PostgresUser, settings.PostgresPassword),
+		Host:     net.JoinHostPort(settings.PostgresHost, strconv.Itoa(settings.PostgresPort)),
+		Path:     "/" + settings.PostgresDatabase,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// bind appends v to args and returns its positional placeholder.
+func bind(args []any, v any) (string, []any) {
+	args = append(args, v)
+	return fmt.Sprintf("$%d", len(args)), args
+}
+
+func isUniqueViolation(err error) bool {
+	var pe *pgconn.PgError
+	if errors.As(err, &pe) {
+		return pe.Code == "23505" // unique_violation
+	}
+	return false
+}
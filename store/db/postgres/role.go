@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const roleColumns = "name, description, builtin, created_at, updated_at"
+
+func scanRole(row interface{ Scan(...any) error }) (*store.RoleInfo, error) {
+	r := store.RoleInfo{Permissions: []store.Permission{}}
+	if err := row.Scan(&r.Name, &r.Description, &r.Builtin, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (d *DB) CreateRole(ctx context.Context, create *store.CreateRole) (*store.RoleInfo, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := d.now()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO roles (name, description, builtin, created_at, updated_at)
+		VALUES ($1, $2, FALSE, $3, $4)
+	`, create.Name, create.Description, now, now)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, store.ErrRoleAlreadyExists
+		}
+		return nil, err
+	}
+	if err := insertRolePermissions(ctx, tx, create.Name, create.Permissions); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return d.GetRole(ctx, create.Name)
+}
+
+func (d *DB) GetRole(ctx context.Context, name store.Role) (*store.RoleInfo, error) {
+	row := d.db.QueryRowContext(ctx, "SELECT "+roleColumns+" FROM roles WHERE name = $1", name)
+	role, err := scanRole(row)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.loadRolePermissions(ctx, map[store.Role]*store.RoleInfo{name: role}, "WHERE role = $1", name); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (d *DB) ListRoles(ctx context.Context) ([]*store.RoleInfo, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT "+roleColumns+" FROM roles ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*store.RoleInfo, 0)
+	byName := make(map[store.Role]*store.RoleInfo)
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, role)
+		byName[role.Name] = role
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := d.loadRolePermissions(ctx, byName, ""); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (d *DB) UpdateRole(ctx context.Context, update *store.UpdateRole) (*store.RoleInfo, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE roles
+		SET description = COALESCE($1, description), updated_at = $2
+		WHERE name = $3
+	`, update.Description, d.now(), update.Name)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, store.ErrRoleNotFound
+	}
+
+	if update.Permissions != nil {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role = $1", update.Name); err != nil {
+			return nil, err
+		}
+		if err := insertRolePermissions(ctx, tx, update.Name, update.Permissions); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return d.GetRole(ctx, update.Name)
+}
+
+func (d *DB) DeleteRole(ctx context.Context, name store.Role) (bool, error) {
+	// role_permissions are deleted by ON DELETE CASCADE
+	res, err := d.db.ExecContext(ctx, `
+		DELETE FROM roles
+		WHERE name = $1 AND builtin = FALSE
+		AND NOT EXISTS (SELECT 1 FROM users WHERE role = $2)
+	`, name, name)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func insertRolePermissions(ctx context.Context, tx *sql.Tx, name store.Role, permissions []store.Permission) error {
+	for _, permission := range permissions {
+		_, err := tx.ExecContext(ctx, "INSERT INTO role_permissions (role, permission) VALUES ($1, $2)", name, permission)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRolePermissions appends the permissions matching where to roles, sorted.
+func (d *DB) loadRolePermissions(ctx context.Context, roles map[store.Role]*store.RoleInfo, where string, args ...any) error {
+	rows, err := d.db.QueryContext(ctx, "SELECT role, permission FROM role_permissions "+where+" ORDER BY role, permission", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name       store.Role
+			permission store.Permission
+		)
+		if err := rows.Scan(&name, &permission); err != nil {
+			return err
+		}
+		if role, ok := roles[name]; ok {
+			role.Permissions = append(role.Permissions, permission)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+const sessionColumns = "id, user_id, token_hash, ip, user_agent, name, remember, expires_at, absolute_expires_at, last_seen_at, created_at, revoked_at"
+
+func scanSession(row interface{ Scan(...any) error }) (*store.SessionInfo, error) {
+	var (
+		s         store.SessionInfo
+		tokenHash []byte
+	)
+	if err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&tokenHash,
+		&s.IP,
+		&s.UserAgent,
+		&s.Name,
+		&s.Remember,
+		&s.ExpiresAt,
+		&s.AbsoluteExpiresAt,
+		&s.LastSeenAt,
+		&s.CreatedAt,
+		&s.RevokedAt,
+	); err != nil {
+		return nil, err
+	}
+	copy(s.TokenHash[:], tokenHash)
+	s.IsActive = s.RevokedAt == nil
+	return &s, nil
+}
+
+func (d *DB) CreateSession(ctx context.Context, create *store.CreateSession) (*store.SessionInfo, error) {
+	now := d.now()
+
+	// insert sesssion
+	var id int64
+	err := d.db.QueryRowContext(ctx, `
+			INSERT INTO sessions (user_id, token_hash, ip, user_agent, remember, expires_at, absolute_expires_at, last_seen_at, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id
+	`, create.UserID, create.TokenHash[:], create.IP, create.UserAgent, create.Remember, create.ExpiresAt, create.AbsoluteExpiresAt, now, now).Scan(&id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.SessionInfo{
+		ID:                id,
+		UserID:            create.UserID,
+		TokenHash:         create.TokenHash,
+		IP:                create.IP,
+		UserAgent:         create.UserAgent,
+		Remember:          create.Remember,
+		ExpiresAt:         create.ExpiresAt,
+		AbsoluteExpiresAt: create.AbsoluteExpiresAt,
+		LastSeenAt:        now,
+		CreatedAt:         now,
+		RevokedAt:         nil,
+		IsActive:          true,
+	}, nil
+}
+
+func (d *DB) GetActiveSessionByHash(ctx context.Context, hash [32]byte) (*store.SessionInfo, error) {
+	now := d.now()
+	s, err := scanSession(d.db.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE token_hash = $1
+		AND revoked_at IS NULL
+		AND expires_at > $2
+		LIMIT 1
+	`, hash[:], now))
+
+	if err == sql.ErrNoRows {
+		return nil, sessionUtils.ErrSesssionExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (d *DB) ListSessions(ctx context.Context, find *store.FindSession) ([]*store.SessionInfo, error) {
+	var p string
+	where, args := []string{"revoked_at IS NULL", "expires_at > $1"}, []any{d.now()}
+	if v := find.ID; v != nil {
+		p, args = bind(args, *v)
+		where = append(where, "id = "+p)
+	}
+	if v := find.UserID; v != nil {
+		p, args = bind(args, *v)
+		where = append(where, "user_id = "+p)
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY last_seen_at DESC, id DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*store.SessionInfo, 0)
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+
+	return list, rows.Err()
+}
+
+func (d *DB) UpdateSession(ctx context.Context, update *store.UpdateSession) (*store.SessionInfo, error) {
+	var p string
+	set, args := []string{}, []any{}
+	if v := update.Name; v != nil {
+		p, args = bind(args, *v)
+		set = append(set, "name = "+p)
+	}
+	if v := update.IP; v != nil {
+		p, args = bind(args, *v)
+		set = append(set, "ip = "+p)
+	}
+	if v := update.LastSeenAt; v != nil {
+		p, args = bind(args, *v)
+		set = append(set, "last_seen_at = "+p)
+	}
+	if v := update.ExpiresAt; v != nil {
+		p, args = bind(args, *v)
+		set = append(set, "expires_at = "+p)
+	}
+
+	if len(set) > 0 {
+		var pid, pnow string
+		pid, args = bind(args, update.ID)
+		pnow, args = bind(args, d.now())
+		query := "UPDATE sessions SET " + strings.Join(set, ", ") + " WHERE id = " + pid + " AND revoked_at IS NULL AND expires_at > " + pnow
+		if _, err := d.db.ExecContext(ctx, query, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	list, err := d.ListSessions(ctx, &store.FindSession{ID: &update.ID})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, store.ErrSessionNotFound
+	}
+	return list[0], nil
+}
+
+func (d *DB) RevokeSession(ctx context.Context, hash [32]byte) (bool, error) {
+	now := d.now()
+	res, err := d.db.ExecContext(ctx, `
+        UPDATE sessions
+        SET revoked_at = $1
+        WHERE token_hash = $2 AND revoked_at IS NULL
+    `, now, hash[:])
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil // false => already revoked or not found
+}
+
+func (d *DB) RevokeUserSessions(ctx context.Context, userID int64) (int64, error) {
+	now := d.now()
+	res, err := d.db.ExecContext(ctx, `
+        UPDATE sessions
+        SET revoked_at = $1
+        WHERE user_id = $2 AND revoked_at IS NULL
+    `, now, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (d *DB) DeleteSessions(ctx context.Context, before time.Time, limit int) ([][32]byte, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		DELETE FROM sessions
+		WHERE id IN (
+			SELECT id FROM sessions
+			WHERE expires_at < $1 OR revoked_at < $2
+			LIMIT $3
+		)
+		RETURNING token_hash
+	`, before, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes [][32]byte
+	for rows.Next() {
+		var (
+			tokenHash []byte
+			hash      [32]byte
+		)
+		if err := rows.Scan(&tokenHash); err != nil {
+			return nil, err
+		}
+		copy(hash[:], tokenHash)
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
@@ -0,0 +1,8 @@
+package sqlite
+
+import "embed"
+
+// Migrations holds the versioned schema migrations of the SQLite driver.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS trg_user_profiles_updated_at;
+DROP TRIGGER IF EXISTS trg_auth_identities_updated_at;
+DROP TRIGGER IF EXISTS trg_users_updated_at;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS user_profiles;
+DROP TABLE IF EXISTS auth_identities;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,81 @@
+-- users table:
+CREATE TABLE users (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  email         TEXT NOT NULL COLLATE NOCASE, -- MySQL default collation is case-insensitive
+  email_locked  BOOLEAN NOT NULL, -- TRUE for Google auth users
+  status        TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active','disabled')),
+  role          TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user','admin')),
+  created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT uq_users_email UNIQUE (email)
+);
+
+
+-- auth_identities: supports local password OR google sso
+CREATE TABLE auth_identities (
+  id                INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id           INTEGER NOT NULL,
+  provider          TEXT NOT NULL CHECK (provider IN ('local','google')),
+  provider_subject  TEXT NULL,  -- for Google: "sub"
+  password_hash     TEXT NULL,  -- for password: bcrypt hash
+  created_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  CONSTRAINT fk_auth_identities_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_user_provider UNIQUE (user_id, provider),
+  CONSTRAINT uq_provider_subject UNIQUE (provider, provider_subject)
+);
+
+
+-- user_profiles table: stores additional user info
+CREATE TABLE user_profiles (
+  user_id      INTEGER NOT NULL PRIMARY KEY,
+  full_name    TEXT NOT NULL DEFAULT '',
+  telephone    TEXT NOT NULL DEFAULT '',
+  avatar_url   TEXT NOT NULL DEFAULT '',
+  created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT fk_profiles_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- sessions table: stores user sessions
+CREATE TABLE sessions (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id     INTEGER NOT NULL,
+  token_hash  BLOB NOT NULL,          -- sha256(token)
+  expires_at  TIMESTAMP NOT NULL,
+  revoked_at  TIMESTAMP NULL,
+  created_at  TIMESTAMP NOT NULL,
+
+  CONSTRAINT fk_sessions_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_sessions_token_hash UNIQUE (token_hash)
+);
+
+CREATE INDEX idx_sessions_expires ON sessions (expires_at);
+
+-- SQLite has no ON UPDATE CURRENT_TIMESTAMP, emulate it with triggers.
+CREATE TRIGGER trg_users_updated_at AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+  UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER trg_auth_identities_updated_at AFTER UPDATE ON auth_identities
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+  UPDATE auth_identities SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER trg_user_profiles_updated_at AFTER UPDATE ON user_profiles
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+  UPDATE user_profiles SET updated_at = CURRENT_TIMESTAMP WHERE user_id = NEW.user_id;
+END;
@@ -0,0 +1,12 @@
+-- cache_invalidations: changes other instances must drop from their caches.
+-- Rows are polled by id and deleted after a retention period.
+CREATE TABLE cache_invalidations (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  source      TEXT NOT NULL,   -- instance that published it
+  kind        TEXT NOT NULL,
+  user_id     INTEGER NOT NULL,
+  token_hash  BLOB NULL,       -- sha256(token) for session invalidations
+  created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_cache_invalidations_created ON cache_invalidations (created_at);
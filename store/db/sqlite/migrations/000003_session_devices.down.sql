@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_sessions_user;
+
+ALTER TABLE sessions DROP COLUMN last_seen_at;
+ALTER TABLE sessions DROP COLUMN name;
+ALTER TABLE sessions DROP COLUMN user_agent;
+ALTER TABLE sessions DROP COLUMN ip;
@@ -0,0 +1,10 @@
+-- device info shown on the sessions page
+ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN name TEXT NOT NULL DEFAULT ''; -- set by the user
+-- SQLite can't add a NOT NULL column without a constant default, drivers always set it.
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMP NULL;
+
+UPDATE sessions SET last_seen_at = created_at;
+
+CREATE INDEX idx_sessions_user ON sessions (user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN absolute_expires_at;
+ALTER TABLE sessions DROP COLUMN remember;
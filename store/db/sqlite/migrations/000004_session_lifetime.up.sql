@@ -0,0 +1,6 @@
+-- expires_at slides with use, absolute_expires_at caps it
+ALTER TABLE sessions ADD COLUMN remember BOOLEAN NOT NULL DEFAULT FALSE; -- "remember me" policy
+-- SQLite can't add a NOT NULL column without a constant default, drivers always set it.
+ALTER TABLE sessions ADD COLUMN absolute_expires_at TIMESTAMP NULL;
+
+UPDATE sessions SET absolute_expires_at = expires_at;
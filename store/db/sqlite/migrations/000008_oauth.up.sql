@@ -0,0 +1,53 @@
+-- oauth_clients: applications that sign their users in with this service (OpenID Connect).
+-- Public clients have no secret and rely on PKCE alone.
+CREATE TABLE oauth_clients (
+  id             TEXT PRIMARY KEY,  -- client_id
+  name           TEXT NOT NULL,
+  secret_hash    BLOB NULL,         -- sha256(client_secret), NULL for public clients
+  redirect_uris  TEXT NOT NULL,     -- space separated, matched exactly
+  created_at     TIMESTAMP NOT NULL
+);
+
+-- oauth_consents: scopes a user allowed a client to access, the consent screen
+-- is skipped while a client asks for no more than that.
+CREATE TABLE oauth_consents (
+  user_id     INTEGER NOT NULL,
+  client_id   TEXT NOT NULL,
+  scope       TEXT NOT NULL,
+  created_at  TIMESTAMP NOT NULL,
+  updated_at  TIMESTAMP NOT NULL,
+  PRIMARY KEY (user_id, client_id),
+
+  CONSTRAINT fk_oauth_consents_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_oauth_consents_client
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX idx_oauth_consents_client ON oauth_consents (client_id);
+
+-- oauth_authorization_codes: single use codes exchanged by clients for tokens.
+CREATE TABLE oauth_authorization_codes (
+  code_hash       BLOB PRIMARY KEY,   -- sha256(code)
+  client_id       TEXT NOT NULL,
+  user_id         INTEGER NOT NULL,
+  redirect_uri    TEXT NOT NULL,
+  scope           TEXT NOT NULL,
+  nonce           TEXT NOT NULL,
+  code_challenge  TEXT NOT NULL,      -- PKCE S256
+  auth_time       TIMESTAMP NOT NULL, -- when the user signed in
+  expires_at      TIMESTAMP NOT NULL,
+  used_at         TIMESTAMP NULL,
+  created_at      TIMESTAMP NOT NULL,
+
+  CONSTRAINT fk_oauth_authorization_codes_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+  CONSTRAINT fk_oauth_authorization_codes_client
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX idx_oauth_authorization_codes_user ON oauth_authorization_codes (user_id);
+CREATE INDEX idx_oauth_authorization_codes_client ON oauth_authorization_codes (client_id);
+CREATE INDEX idx_oauth_authorization_codes_expires ON oauth_authorization_codes (expires_at);
@@ -0,0 +1,15 @@
+-- service_accounts: machine callers authenticating with the client_credentials grant.
+-- Their tokens only carry scopes out of the allowed ones.
+CREATE TABLE service_accounts (
+  id           TEXT PRIMARY KEY,   -- client_id
+  name         TEXT NOT NULL,
+  secret_hash  BLOB NOT NULL,      -- sha256(client_secret)
+  scopes       TEXT NOT NULL,      -- space separated allowed scopes
+  owner_id     INTEGER NOT NULL,   -- user responsible for the account
+  created_at   TIMESTAMP NOT NULL,
+
+  CONSTRAINT fk_service_accounts_owner
+    FOREIGN KEY (owner_id) REFERENCES users(id)
+    ON DELETE CASCADE
+);
+CREATE INDEX idx_service_accounts_owner ON service_accounts (owner_id);
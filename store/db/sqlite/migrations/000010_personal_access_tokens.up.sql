@@ -0,0 +1,21 @@
+-- personal_access_tokens: named, scoped API keys created by users for scripts.
+-- Only the hash of the token is stored, the token itself is shown once.
+CREATE TABLE personal_access_tokens (
+  id            INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id       INTEGER NOT NULL,
+  name          TEXT NOT NULL,
+  token_hash    BLOB NOT NULL,          -- sha256(token)
+  scopes        TEXT NOT NULL,          -- space separated
+  expires_at    TIMESTAMP NOT NULL,
+  last_used_at  TIMESTAMP NULL,
+  revoked_at    TIMESTAMP NULL,
+  created_at    TIMESTAMP NOT NULL,
+
+  CONSTRAINT fk_personal_access_tokens_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_personal_access_tokens_token_hash UNIQUE (token_hash)
+);
+CREATE INDEX idx_personal_access_tokens_user ON personal_access_tokens (user_id);
+CREATE INDEX idx_personal_access_tokens_expires ON personal_access_tokens (expires_at);
@@ -0,0 +1,15 @@
+DROP TRIGGER trg_users_updated_at;
+DROP INDEX IF EXISTS idx_users_role;
+ALTER TABLE users ADD COLUMN role_name TEXT NOT NULL DEFAULT 'user' CHECK (role_name IN ('user','admin'));
+UPDATE users SET role_name = role WHERE role IN ('user','admin');
+ALTER TABLE users DROP COLUMN role;
+ALTER TABLE users RENAME COLUMN role_name TO role;
+
+CREATE TRIGGER trg_users_updated_at AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+  UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
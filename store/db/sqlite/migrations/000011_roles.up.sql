@@ -0,0 +1,55 @@
+-- roles: built-in and custom roles users are assigned to.
+-- Built-in roles are seeded below and can't be changed or deleted.
+CREATE TABLE roles (
+  name         TEXT PRIMARY KEY,
+  description  TEXT NOT NULL DEFAULT '',
+  builtin      BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- role_permissions: what each role is allowed to do, e.g. users:write.
+CREATE TABLE role_permissions (
+  role        TEXT NOT NULL,
+  permission  TEXT NOT NULL,
+  PRIMARY KEY (role, permission),
+
+  CONSTRAINT fk_role_permissions_role
+    FOREIGN KEY (role) REFERENCES roles(name)
+    ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description, builtin) VALUES
+  ('admin', 'Full access', TRUE),
+  ('support', 'Read access to users, for customer support', TRUE),
+  ('user', 'No administrative access', TRUE);
+
+INSERT INTO role_permissions (role, permission) VALUES
+  ('admin', 'oauth_clients:read'),
+  ('admin', 'oauth_clients:write'),
+  ('admin', 'roles:read'),
+  ('admin', 'roles:write'),
+  ('admin', 'service_accounts:read'),
+  ('admin', 'service_accounts:write'),
+  ('admin', 'stats:read'),
+  ('admin', 'users:read'),
+  ('admin', 'users:write'),
+  ('support', 'roles:read'),
+  ('support', 'users:read');
+
+-- users can be assigned custom roles from now on. SQLite can't drop the CHECK
+-- constraint of users.role, so the column is replaced. Dropping the table instead
+-- would cascade to every table referencing users. The updated_at trigger is
+-- recreated around the copy so it doesn't touch every user.
+DROP TRIGGER trg_users_updated_at;
+ALTER TABLE users ADD COLUMN role_name TEXT NOT NULL DEFAULT 'user';
+UPDATE users SET role_name = role;
+ALTER TABLE users DROP COLUMN role;
+ALTER TABLE users RENAME COLUMN role_name TO role;
+CREATE INDEX idx_users_role ON users (role);
+
+CREATE TRIGGER trg_users_updated_at AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+  UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
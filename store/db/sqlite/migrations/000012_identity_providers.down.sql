@@ -0,0 +1,30 @@
+CREATE TABLE auth_identities_old (
+  id                INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id           INTEGER NOT NULL,
+  provider          TEXT NOT NULL CHECK (provider IN ('local','google')),
+  provider_subject  TEXT NULL,  -- for Google: "sub"
+  password_hash     TEXT NULL,  -- for password: bcrypt hash
+  created_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  CONSTRAINT fk_auth_identities_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_user_provider UNIQUE (user_id, provider),
+  CONSTRAINT uq_provider_subject UNIQUE (provider, provider_subject)
+);
+
+INSERT INTO auth_identities_old (id, user_id, provider, provider_subject, password_hash, created_at, updated_at)
+SELECT id, user_id, provider, provider_subject, password_hash, created_at, updated_at FROM auth_identities
+WHERE provider IN ('local','google');
+
+DROP TRIGGER trg_auth_identities_updated_at;
+DROP TABLE auth_identities;
+ALTER TABLE auth_identities_old RENAME TO auth_identities;
+
+CREATE TRIGGER trg_auth_identities_updated_at AFTER UPDATE ON auth_identities
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+  UPDATE auth_identities SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
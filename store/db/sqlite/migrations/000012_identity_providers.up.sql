@@ -0,0 +1,33 @@
+-- auth_identities.provider names any identity provider configured in the settings
+-- (google, github, or a generic OpenID Connect issuer), not only google.
+-- SQLite can't drop the CHECK constraint and the column is part of both unique
+-- constraints, so the table is rebuilt. Nothing references auth_identities.
+CREATE TABLE auth_identities_new (
+  id                INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id           INTEGER NOT NULL,
+  provider          TEXT NOT NULL,
+  provider_subject  TEXT NULL,  -- for external providers: "sub"
+  password_hash     TEXT NULL,  -- for password: bcrypt hash
+  created_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  CONSTRAINT fk_auth_identities_user
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    ON DELETE CASCADE,
+
+  CONSTRAINT uq_user_provider UNIQUE (user_id, provider),
+  CONSTRAINT uq_provider_subject UNIQUE (provider, provider_subject)
+);
+
+INSERT INTO auth_identities_new (id, user_id, provider, provider_subject, password_hash, created_at, updated_at)
+SELECT id, user_id, provider, provider_subject, password_hash, created_at, updated_at FROM auth_identities;
+
+DROP TRIGGER trg_auth_identities_updated_at;
+DROP TABLE auth_identities;
+ALTER TABLE auth_identities_new RENAME TO auth_identities;
+
+CREATE TRIGGER trg_auth_identities_updated_at AFTER UPDATE ON auth_identities
+FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+  UPDATE auth_identities SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
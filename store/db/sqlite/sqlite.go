@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+
+	"github.com/rs/zerolog/log"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// memoryPath opens a private in-memory database, handy for tests.
+const memoryPath = ":memory:"
+
+type DB struct {
+	db       *sql.DB
+	settings *settings.Settings
+	now      common.NowFunc
+}
+
+func NewDB(settings *settings.Settings, now common.NowFunc) store.Driver {
+	driver := DB{settings: settings}
+	dsn := BuildDSN(settings.SQLitePath)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open SQLite database")
+	}
+	driver.db = db
+
+	// every connection to ":memory:" gets its own database,
+	// so pin the pool to a single connection.
+	if settings.SQLitePath == memoryPath {
+		driver.db.SetMaxOpenConns(1)
+		driver.db.SetConnMaxLifetime(0)
+		driver.db.SetConnMaxIdleTime(0)
+	}
+
+	// Test the connection
+	if err := driver.db.Ping(); err != nil {
+		log.Debug().Str("dsn", dsn).Msg("Configured DSN")
+		log.Fatal().Err(err).Msg("Failed to ping SQLite")
+	}
+
+	log.Info().
+		Str("path", settings.SQLitePath).
+		Msg("Connected to SQLite")
+
+	driver.now = now
+	return &driver
+}
+
+func (d *DB) GetDB() *sql.DB {
+	return d.db
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// BuildDSN creates a modernc.org/sqlite DSN for the database file at path.
+func BuildDSN(path string) string {
+	q := url.Values{}
+	// foreign keys are off by default in SQLite, ON DELETE CASCADE relies on them.
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	if path != memoryPath {
+		q.Add("_pragma", "journal_mode(WAL)")
+	}
+	// write times in a sortable format so expires_at comparisons work on TEXT.
+	q.Set("_time_format", "sqlite")
+	// take the write lock up front, SQLite has no SELECT ... FOR UPDATE.
+	q.Set("_txlock", "immediate")
+	return path + "?" + q.Encode()
+}
+
+func isUniqueViolation(err error) bool {
+	var se *sqlite.Error
+	if errors.As(err, &se) {
+		return se.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+			se.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
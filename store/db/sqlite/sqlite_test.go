@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"testing"
+
+	migrate "github.com/golang-migrate/migrate/v4"
+	migrateSQLite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/storetest"
+)
+
+func TestDriver(t *testing.T) {
+	storetest.RunDriverTests(t, func(t *testing.T, now common.NowFunc) store.Driver {
+		drv := NewDB(&settings.Settings{SQLitePath: memoryPath}, now)
+		t.Cleanup(func() { drv.Close() })
+
+		inst, err := migrateSQLite.WithInstance(drv.GetDB(), &migrateSQLite.Config{})
+		if err != nil {
+			t.Fatalf("init migrate instance: %v", err)
+		}
+		src, err := iofs.New(Migrations, "migrations")
+		if err != nil {
+			t.Fatalf("read migrations: %v", err)
+		}
+		m, err := migrate.NewWithInstance("iofs", src, "sqlite", inst)
+		if err != nil {
+			t.Fatalf("init migrate: %v", err)
+		}
+		if err := m.Up(); err != nil {
+			t.Fatalf("apply migrations: %v", err)
+		}
+		return drv
+	})
+}
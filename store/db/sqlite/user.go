@@ -0,0 +1,465 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func (d *DB) CreateLocalUser(ctx context.Context, in *store.CreateLocalUser) (*store.UserInfo, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// users row (local => email is editable later, so email_locked=false)
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO users (email, email_locked, status, role)
+		VALUES (?, FALSE, ?, ?)
+	`, in.Email, in.Status, in.Role)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, store.ErrUserAlreadyExists
+		}
+		return nil, err
+	}
+
+	userID, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	// local identity row
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO auth_identities (user_id, provider, password_hash)
+		VALUES (?, 'local', ?)
+	`, userID, in.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// profile row stub
+	_, err = tx.ExecContext(ctx, `INSERT INTO user_profiles (user_id) VALUES (?)`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return d.GetUser(ctx, &store.FindUser{ID: &userID})
+}
+
+func (d *DB) UpsertProviderUser(ctx context.Context, upsert *store.UpsertProviderUser) (*store.UserInfo, error) {
+	// the transaction is started with BEGIN IMMEDIATE (see BuildDSN),
+	// which holds the write lock that FOR UPDATE provides on MySQL.
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 1) If the provider sub is already linked -> use same user
+	var userID int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id
+		FROM auth_identities
+		WHERE provider = ? AND provider_subject = ?
+	`, upsert.Provider, upsert.Subject).Scan(&userID)
+
+	if err == nil {
+		// release the connection before syncing and reading the user back.
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		if upsert.EmailVerified {
+			if err := d.syncProviderEmail(ctx, userID, upsert.Provider, upsert.Email); err != nil {
+				return nil, err
+			}
+		}
+		return d.GetUser(ctx, &store.FindUser{ID: &userID})
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if !upsert.EmailVerified {
+		return nil, store.ErrProviderEmailNotVerified
+	}
+
+	// 2) sub not linked yet -> users with the same email must link it themselves
+	err = tx.QueryRowContext(ctx, `
+		SELECT id
+		FROM users
+		WHERE email = ?
+	`, upsert.Email).Scan(&userID)
+	if err == nil {
+		return nil, store.ErrIdentityNotLinked
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// 3) Create new provider user with its identity
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO users (email, email_locked, status, role)
+		VALUES (?, TRUE, ?, ?)
+	`, upsert.Email, store.StatusActive, store.RoleUser)
+	if err != nil {
+		if isUniqueViolation(err) {
+			// the same email was just signed up with concurrently
+			return nil, store.ErrIdentityNotLinked
+		}
+		return nil, err
+	}
+	userID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO user_profiles (user_id) VALUES (?)`, userID); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO auth_identities (user_id, provider, provider_subject)
+		VALUES (?, ?, ?)
+	`, userID, upsert.Provider, upsert.Subject)
+	if err != nil {
+		if isUniqueViolation(err) {
+			// the same subject was just signed in with concurrently
+			return nil, store.ErrIdentityNotLinked
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isUniqueViolation(err) {
+			return nil, store.ErrIdentityNotLinked
+		}
+		return nil, err
+	}
+
+	return d.GetUser(ctx, &store.FindUser{ID: &userID})
+}
+
+// syncProviderEmail updates the email of a user created by a provider to the one
+// the provider now has. Only the identity that created the user, its oldest one,
+// manages the email, and it is kept when another user has the new email already.
+func (d *DB) syncProviderEmail(ctx context.Context, userID int64, provider store.Provider, email string) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE users
+		SET email = ?
+		WHERE id = ? AND email_locked = TRUE AND email <> ?
+			AND ? = (
+				SELECT provider FROM auth_identities
+				WHERE user_id = ?
+				ORDER BY id
+				LIMIT 1
+			)
+	`, email, userID, email, provider, userID)
+	if err != nil && !isUniqueViolation(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *DB) UpdateUser(ctx context.Context, update *store.UpdateUser) (*store.UserInfo, error) {
+	// check if email updation is locked
+	if update.Email != nil {
+		var emailLocked bool
+		err := d.db.QueryRowContext(ctx, "SELECT email_locked FROM users WHERE id = ?", update.ID).Scan(&emailLocked)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, store.ErrUserNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if emailLocked {
+			return nil, store.ErrEmailUpdateNotAllowed
+		}
+	}
+
+	// start transaction
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// update user_profiles table
+	set := []string{}
+	args := []any{}
+	if v := update.FullName; v != nil {
+		set, args = append(set, "full_name = ?"), append(args, *v)
+	}
+	if v := update.Telephone; v != nil {
+		set, args = append(set, "telephone = ?"), append(args, *v)
+	}
+	if v := update.AvatarURL; v != nil {
+		set, args = append(set, "avatar_url = ?"), append(args, *v)
+	}
+
+	if len(set) > 0 {
+		args = append(args, update.ID)
+		query := "UPDATE user_profiles SET " + strings.Join(set, ", ") + " WHERE user_id = ?"
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	// update user table
+	set = []string{}
+	args = []any{}
+	if v := update.Role; v != nil {
+		set, args = append(set, "role = ?"), append(args, *v)
+	}
+	if v := update.Status; v != nil {
+		set, args = append(set, "status = ?"), append(args, *v)
+	}
+
+	if v := update.Email; v != nil {
+		set, args = append(set, "email = ?"), append(args, *v)
+	}
+
+	if len(set) > 0 {
+		args = append(args, update.ID)
+		query := "UPDATE users SET " + strings.Join(set, ", ") + " WHERE id = ?"
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			if isUniqueViolation(err) {
+				return nil, store.ErrUserAlreadyExists
+			}
+			return nil, err
+		}
+	}
+
+	// commit transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// fetch and return the updated user
+	return d.GetUser(ctx, &store.FindUser{ID: &update.ID})
+}
+
+func (d *DB) ListUsers(ctx context.Context, find *store.FindUser) ([]*store.UserInfo, error) {
+	where, args := []string{"1 = 1"}, []any{}
+
+	// user table where clauses
+	if v := find.ID; v != nil {
+		where, args = append(where, "u.id = ?"), append(args, *v)
+	}
+
+	if v := find.Email; v != nil {
+		where, args = append(where, "u.email = ?"), append(args, *v)
+	}
+
+	if v := find.Role; v != nil {
+		where, args = append(where, "u.role = ?"), append(args, *v)
+	}
+
+	if v := find.Provider; v != nil {
+		where, args = append(where, "EXISTS (SELECT 1 FROM auth_identities pi WHERE pi.user_id = u.id AND pi.provider = ?)"), append(args, *v)
+	}
+
+	if v := find.Status; v != nil {
+		where, args = append(where, "u.status = ?"), append(args, *v)
+	}
+
+	if v := find.EmailPrefix; v != nil {
+		where, args = append(where, "u.email LIKE ? ESCAPE '!'"), append(args, likePrefix(*v))
+	}
+
+	if v := find.NamePrefix; v != nil {
+		where, args = append(where, "p.full_name LIKE ? ESCAPE '!'"), append(args, likePrefix(*v))
+	}
+
+	// timestamps are stored as UTC text, datetime() brings parameters to the same form.
+	if v := find.CreatedAfter; v != nil {
+		where, args = append(where, "u.created_at > datetime(?)"), append(args, *v)
+	}
+
+	if v := find.CreatedBefore; v != nil {
+		where, args = append(where, "u.created_at < datetime(?)"), append(args, *v)
+	}
+
+	if v := find.UpdatedAfter; v != nil {
+		where, args = append(where, "u.updated_at > datetime(?)"), append(args, *v)
+	}
+
+	if v := find.UpdatedBefore; v != nil {
+		where, args = append(where, "u.updated_at < datetime(?)"), append(args, *v)
+	}
+
+	// keyset pagination: continue right after the cursor in sort order.
+	sort := find.Sort.OrDefault()
+	column, op, dir := "u."+sort.Field(), ">", "ASC"
+	if sort.Desc() {
+		op, dir = "<", "DESC"
+	}
+	if c := find.Cursor; c != nil {
+		p := "?"
+		if sort.Field() != "email" {
+			p = "datetime(?)"
+		}
+		where = append(where, fmt.Sprintf("(%s %s %s OR (%s = %s AND u.id %s ?))", column, op, p, column, p, op))
+		args = append(args, c.Value(), c.Value(), c.ID)
+	}
+
+	joins := []string{
+		"JOIN user_profiles p ON u.id = p.user_id",
+		// only the local identity carries a password.
+		"LEFT JOIN auth_identities ai ON ai.user_id = u.id AND ai.provider = 'local'",
+	}
+
+	orderBy := []string{column + " " + dir, "u.id " + dir}
+
+	query := `
+		SELECT
+		u.id,
+		u.email,
+		u.email_locked,
+		u.status,
+		u.role,
+		ai.password_hash,
+		p.full_name,
+		p.telephone,
+		p.avatar_url,
+		u.created_at,
+		u.updated_at
+		FROM users u
+		` + strings.Join(joins, "\n") + `
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY ` + strings.Join(orderBy, ", ")
+	if v := find.Limit; v != nil {
+		query, args = query+" LIMIT ?", append(args, *v)
+	}
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passwordHash sql.NullString
+	list := make([]*store.UserInfo, 0)
+	for rows.Next() {
+		var user store.UserInfo
+		if err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.EmailLocked,
+			&user.Status,
+			&user.Role,
+			&passwordHash,
+			&user.FullName,
+			&user.Telephone,
+			&user.AvatarURL,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if passwordHash.Valid {
+			user.PasswordHash = passwordHash.String
+		}
+		list = append(list, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := d.loadIdentities(ctx, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (d *DB) GetUser(ctx context.Context, find *store.FindUser) (*store.UserInfo, error) {
+	list, err := d.ListUsers(ctx, find)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) != 1 {
+		return nil, store.ErrUserNotFound
+	}
+
+	return list[0], nil
+
+}
+
+// loadIdentities fills in the auth identities of every user in list.
+// They are read separately so linked accounts don't multiply user rows.
+func (d *DB) loadIdentities(ctx context.Context, list []*store.UserInfo) error {
+	if len(list) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*store.UserInfo, len(list))
+	args := make([]any, 0, len(list))
+	for _, user := range list {
+		byID[user.ID] = user
+		args = append(args, user.ID)
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT user_id, provider, provider_subject, password_hash IS NOT NULL, created_at
+		FROM auth_identities
+		WHERE user_id IN (`+placeholders(len(args))+`)
+		ORDER BY created_at, id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			userID   int64
+			subject  sql.NullString
+			identity store.Identity
+		)
+		if err := rows.Scan(&userID, &identity.Provider, &subject, &identity.HasPassword, &identity.CreatedAt); err != nil {
+			return err
+		}
+		identity.Subject = subject.String
+		user := byID[userID]
+		user.Identities = append(user.Identities, &identity)
+	}
+
+	return rows.Err()
+}
+
+func (d *DB) DeleteUser(ctx context.Context, delete *store.DeleteUser) (bool, error) {
+	result, err := d.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", delete.ID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	list := make([]string, n)
+	for i := range n {
+		list[i] = "?"
+	}
+
+	return strings.Join(list, ", ")
+
+}
+
+// likePrefix turns prefix into a LIKE pattern, escaping wildcards with '!'.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+}
@@ -3,10 +3,13 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 // Driver is an interface for store driver.
 // It contains all methods that store database driver should implement.
+// Drivers translate their own errors into the store sentinel errors
+// (e.g. ErrUserAlreadyExists, ErrUserNotFound).
 type Driver interface {
 	GetDB() *sql.DB
 	Close() error
@@ -16,11 +19,114 @@ type Driver interface {
 	GetUser(ctx context.Context, find *FindUser) (*UserInfo, error)
 	UpdateUser(ctx context.Context, update *UpdateUser) (*UserInfo, error)
 	DeleteUser(ctx context.Context, delete *DeleteUser) (bool, error)
-	UpsertGoogleUser(ctx context.Context, email, sub string) (*UserInfo, error)
+	// UpsertProviderUser returns ErrIdentityNotLinked rather than linking the
+	// identity to the user with the same email.
+	UpsertProviderUser(ctx context.Context, upsert *UpsertProviderUser) (*UserInfo, error)
 	ListUsers(ctx context.Context, find *FindUser) ([]*UserInfo, error)
 
+	// identities model related methods.
+	// LinkIdentity returns ErrIdentityAlreadyLinked if the subject is linked to
+	// any user, or the user already has an identity with the provider.
+	LinkIdentity(ctx context.Context, link *LinkIdentity) error
+	// ListIdentities returns the identities of a user, oldest first.
+	ListIdentities(ctx context.Context, userID int64) ([]*Identity, error)
+	// DeleteIdentity returns ErrIdentityNotFound if the user has no identity with
+	// the provider, and ErrLastLoginMethod unless delete.KeepsLoginMethod.
+	// Both are checked in the transaction that deletes it.
+	DeleteIdentity(ctx context.Context, delete *DeleteIdentity) error
+
 	// sessions model related methods
-	CreateSession(ctx context.Context, userID int64, hash [32]byte) (*SessionInfo, error)
+	CreateSession(ctx context.Context, create *CreateSession) (*SessionInfo, error)
 	GetActiveSessionByHash(ctx context.Context, hash [32]byte) (*SessionInfo, error)
+	// ListSessions returns active sessions, most recently seen first.
+	ListSessions(ctx context.Context, find *FindSession) ([]*SessionInfo, error)
+	// UpdateSession returns ErrSessionNotFound unless the session is active.
+	UpdateSession(ctx context.Context, update *UpdateSession) (*SessionInfo, error)
 	RevokeSession(ctx context.Context, hash [32]byte) (bool, error)
+	// RevokeUserSessions revokes all active sessions of a user and
+	// returns how many were revoked.
+	RevokeUserSessions(ctx context.Context, userID int64) (int64, error)
+	// DeleteSessions deletes up to limit sessions that expired or were
+	// revoked before the given time and returns their token hashes.
+	DeleteSessions(ctx context.Context, before time.Time, limit int) ([][32]byte, error)
+
+	// refresh tokens and revoked access tokens
+	CreateRefreshToken(ctx context.Context, create *CreateRefreshToken) (*RefreshTokenInfo, error)
+	// GetRefreshTokenByHash returns ErrRefreshTokenNotFound if there is none,
+	// used, revoked and expired tokens are returned too.
+	GetRefreshTokenByHash(ctx context.Context, hash [32]byte) (*RefreshTokenInfo, error)
+	// UseRefreshToken marks an active refresh token as rotated, it returns
+	// false if the token was already used, revoked or expired.
+	UseRefreshToken(ctx context.Context, id int64) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) (int64, error)
+	RevokeUserRefreshTokens(ctx context.Context, userID int64) (int64, error)
+	// DeleteRefreshTokens deletes the refresh tokens that expired or were revoked before the given time.
+	DeleteRefreshTokens(ctx context.Context, before time.Time) (int64, error)
+	// RevokeAccessToken is a no-op for access tokens already revoked.
+	RevokeAccessToken(ctx context.Context, revoke *RevokeAccessToken) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// DeleteRevokedAccessTokens deletes the revoked access tokens that expired before the given time.
+	DeleteRevokedAccessTokens(ctx context.Context, before time.Time) (int64, error)
+
+	// OAuth clients of the OpenID Connect provider
+	CreateOAuthClient(ctx context.Context, create *CreateOAuthClient) (*OAuthClientInfo, error)
+	GetOAuthClient(ctx context.Context, id string) (*OAuthClientInfo, error)
+	ListOAuthClients(ctx context.Context) ([]*OAuthClientInfo, error)
+	// DeleteOAuthClient cascades to the consents and codes of the client.
+	DeleteOAuthClient(ctx context.Context, id string) error
+	GetOAuthConsent(ctx context.Context, userID int64, clientID string) (*OAuthConsentInfo, error)
+	// UpsertOAuthConsent replaces the scopes of an existing consent.
+	UpsertOAuthConsent(ctx context.Context, upsert *UpsertOAuthConsent) error
+	CreateAuthorizationCode(ctx context.Context, create *CreateAuthorizationCode) (*AuthorizationCodeInfo, error)
+	GetAuthorizationCodeByHash(ctx context.Context, hash [32]byte) (*AuthorizationCodeInfo, error)
+	// UseAuthorizationCode atomically marks an unused, unexpired code as used,
+	// false if it was not.
+	UseAuthorizationCode(ctx context.Context, hash [32]byte) (bool, error)
+	DeleteAuthorizationCodes(ctx context.Context, before time.Time) (int64, error)
+
+	// personal access tokens
+	CreatePersonalAccessToken(ctx context.Context, create *CreatePersonalAccessToken) (*PersonalAccessTokenInfo, error)
+	// GetPersonalAccessTokenByHash returns ErrPersonalAccessTokenNotFound if there is none,
+	// revoked and expired tokens are returned too.
+	GetPersonalAccessTokenByHash(ctx context.Context, hash [32]byte) (*PersonalAccessTokenInfo, error)
+	// ListPersonalAccessTokens returns the tokens of a user that were not revoked, newest first.
+	ListPersonalAccessTokens(ctx context.Context, userID int64) ([]*PersonalAccessTokenInfo, error)
+	// RevokePersonalAccessToken returns false unless the token belongs to the user and was not revoked yet.
+	RevokePersonalAccessToken(ctx context.Context, userID, id int64) (bool, error)
+	TouchPersonalAccessToken(ctx context.Context, id int64, lastUsedAt time.Time) error
+	// DeletePersonalAccessTokens deletes the tokens that expired or were revoked before the given time.
+	DeletePersonalAccessTokens(ctx context.Context, before time.Time) (int64, error)
+
+	// roles and their permissions
+	CreateRole(ctx context.Context, create *CreateRole) (*RoleInfo, error)
+	GetRole(ctx context.Context, name Role) (*RoleInfo, error)
+	// ListRoles returns all roles ordered by name.
+	ListRoles(ctx context.Context) ([]*RoleInfo, error)
+	UpdateRole(ctx context.Context, update *UpdateRole) (*RoleInfo, error)
+	// DeleteRole deletes a role that is not built-in, it returns false
+	// while users are assigned to it.
+	DeleteRole(ctx context.Context, name Role) (bool, error)
+
+	// service accounts, authenticated with the client_credentials grant
+	CreateServiceAccount(ctx context.Context, create *CreateServiceAccount) (*ServiceAccountInfo, error)
+	GetServiceAccount(ctx context.Context, id string) (*ServiceAccountInfo, error)
+	// ListServiceAccounts returns accounts ordered by created_at, oldest first.
+	ListServiceAccounts(ctx context.Context) ([]*ServiceAccountInfo, error)
+	DeleteServiceAccount(ctx context.Context, id string) error
+
+	// JWT signing keys
+	CreateSigningKey(ctx context.Context, create *CreateSigningKey) (*SigningKeyInfo, error)
+	// ListSigningKeys returns keys ordered by created_at, newest first.
+	ListSigningKeys(ctx context.Context, find *FindSigningKey) ([]*SigningKeyInfo, error)
+	// RetireSigningKeys retires all keys still signing except keepID.
+	RetireSigningKeys(ctx context.Context, keepID string) (int64, error)
+	DeleteSigningKeys(ctx context.Context, retiredBefore time.Time) (int64, error)
+
+	// cache invalidation log, used by PollingBus.
+	CreateInvalidation(ctx context.Context, inv *Invalidation) error
+	// ListInvalidations returns up to limit invalidations with an id above afterID, oldest first.
+	ListInvalidations(ctx context.Context, afterID int64, limit int) ([]*Invalidation, error)
+	// LatestInvalidationID returns 0 when there are none.
+	LatestInvalidationID(ctx context.Context) (int64, error)
+	DeleteInvalidations(ctx context.Context, before time.Time) (int64, error)
 }
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+var (
+	ErrIdentityAlreadyLinked = errors.New("identity is already linked to an account")
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrLastLoginMethod       = errors.New("the last way to sign in to the account can't be removed")
+)
+
+type LinkIdentity struct {
+	UserID   int64
+	Provider Provider
+	Subject  string
+}
+
+type DeleteIdentity struct {
+	UserID   int64
+	Provider Provider
+	// Usable are the providers users can sign in with at the moment, local
+	// identities only count with a password. The identity is only deleted
+	// while the user keeps another usable one.
+	Usable []Provider
+}
+
+// KeepsLoginMethod reports whether identities, all identities of the user,
+// leave a usable one once the deleted one is gone.
+func (d *DeleteIdentity) KeepsLoginMethod(identities []*Identity) bool {
+	for _, identity := range identities {
+		if identity.Provider == d.Provider || !slices.Contains(d.Usable, identity.Provider) {
+			continue
+		}
+		if identity.Provider != ProviderLocal || identity.HasPassword {
+			return true
+		}
+	}
+	return false
+}
+
+// LinkIdentity lets the user sign in with the subject of an external identity provider.
+func (s *Store) LinkIdentity(ctx context.Context, link *LinkIdentity) error {
+	if err := s.driver.LinkIdentity(ctx, link); err != nil {
+		return err
+	}
+
+	s.userCache.Delete(link.UserID)
+	s.publish(ctx, &Invalidation{Kind: InvalidateUser, UserID: link.UserID})
+	return nil
+}
+
+func (s *Store) ListIdentities(ctx context.Context, userID int64) ([]*Identity, error) {
+	return s.driver.ListIdentities(ctx, userID)
+}
+
+// DeleteIdentity unlinks an identity, see DeleteIdentity.Usable.
+func (s *Store) DeleteIdentity(ctx context.Context, delete *DeleteIdentity) error {
+	if err := s.driver.DeleteIdentity(ctx, delete); err != nil {
+		return err
+	}
+
+	s.userCache.Delete(delete.UserID)
+	s.publish(ctx, &Invalidation{Kind: InvalidateUser, UserID: delete.UserID})
+	return nil
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+)
+
+// InvalidationKind is what an invalidation drops from the caches.
+type InvalidationKind string
+
+const (
+	// InvalidateUser drops the cached user.
+	InvalidateUser InvalidationKind = "user"
+	// InvalidateSession drops the cached session with TokenHash.
+	InvalidateSession InvalidationKind = "session"
+	// InvalidateUserSessions drops all cached sessions of the user.
+	InvalidateUserSessions InvalidationKind = "user_sessions"
+	// InvalidateAccessToken drops the cached denylist lookup of the
+	// access token whose jti hashes to TokenHash.
+	InvalidateAccessToken InvalidationKind = "access_token"
+	// InvalidateRoles drops all cached permissions after a role changed.
+	InvalidateRoles InvalidationKind = "roles"
+)
+
+// Invalidation tells other instances to drop something from their caches.
+type Invalidation struct {
+	ID int64
+	// Source is the id of the instance that published it.
+	Source    string
+	Kind      InvalidationKind
+	UserID    int64
+	TokenHash [32]byte
+	CreatedAt time.Time
+}
+
+// InvalidationBus carries cache invalidations between instances.
+// PollingBus works on top of any Driver, other transports
+// (e.g. Redis pub/sub) only need to implement this interface.
+type InvalidationBus interface {
+	// Publish sends inv to all other instances.
+	Publish(ctx context.Context, inv *Invalidation) error
+	// Subscribe calls apply for every invalidation published by other
+	// instances until ctx is done.
+	Subscribe(ctx context.Context, apply func(inv *Invalidation)) error
+}
+
+// UseInvalidationBus makes the store publish its invalidations to bus and
+// apply the ones of other instances until ctx is done.
+// It must be called before the store is used.
+func (s *Store) UseInvalidationBus(ctx context.Context, bus InvalidationBus) {
+	s.bus = bus
+	go func() {
+		if err := bus.Subscribe(ctx, s.applyInvalidation); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Cache invalidation subscription stopped")
+		}
+	}()
+}
+
+// publish sends invalidations to other instances.
+// The change is already in the database at this point, so failures
+// are only logged: other instances catch up once their entries expire.
+func (s *Store) publish(ctx context.Context, invs ...*Invalidation) {
+	if s.bus == nil {
+		return
+	}
+	for _, inv := range invs {
+		if err := s.bus.Publish(ctx, inv); err != nil {
+			log.Warn().Err(err).Str("kind", string(inv.Kind)).Int64("user_id", inv.UserID).
+				Msg("Failed to publish cache invalidation")
+		}
+	}
+}
+
+func (s *Store) applyInvalidation(inv *Invalidation) {
+	switch inv.Kind {
+	case InvalidateUser:
+		s.userCache.Delete(inv.UserID)
+		s.permissionCache.Delete(inv.UserID)
+	case InvalidateSession:
+		s.sessionCache.Delete(inv.TokenHash)
+	case InvalidateUserSessions:
+		s.forgetUserSessions(inv.UserID)
+	case InvalidateAccessToken:
+		s.revokedTokenCache.Delete(inv.TokenHash)
+	case InvalidateRoles:
+		s.permissionCache.DeleteFunc(func(int64, []Permission) bool { return true })
+	}
+}
+
+// PollingBusOptions configures a PollingBus.
+type PollingBusOptions struct {
+	// Interval between two polls of the invalidation table.
+	Interval time.Duration
+	// Retention is how long published invalidations are kept.
+	Retention time.Duration
+	// BatchSize is the maximum number of invalidations read per query.
+	BatchSize int
+}
+
+// PollingBus is an InvalidationBus backed by the cache_invalidations table
+// of the store database, which every instance polls.
+type PollingBus struct {
+	driver Driver
+	source string
+	opts   PollingBusOptions
+	now    common.NowFunc
+}
+
+// NewPollingBus creates a PollingBus with a random instance id.
+func NewPollingBus(driver Driver, now common.NowFunc, opts PollingBusOptions) *PollingBus {
+	return &PollingBus{
+		driver: driver,
+		source: uuid.NewString(),
+		opts:   opts,
+		now:    now,
+	}
+}
+
+func (b *PollingBus) Publish(ctx context.Context, inv *Invalidation) error {
+	published := *inv
+	published.Source = b.source
+	return b.driver.CreateInvalidation(ctx, &published)
+}
+
+func (b *PollingBus) Subscribe(ctx context.Context, apply func(inv *Invalidation)) error {
+	// only what is published from now on matters, the caches start empty.
+	last, err := b.driver.LatestInvalidationID(ctx)
+	if err != nil {
+		return err
+	}
+	// rows are read from the previous poll's position as well: an insert
+	// can commit after one with a higher id was already read. Applying an
+	// invalidation twice only costs a cache miss.
+	prev := last
+
+	ticker := time.NewTicker(b.opts.Interval)
+	defer ticker.Stop()
+	lastCleanup := b.now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		newest, err := b.poll(ctx, prev, apply)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to poll cache invalidations")
+			continue
+		}
+		prev, last = last, max(last, newest)
+
+		if now := b.now(); now.Sub(lastCleanup) >= b.opts.Retention {
+			lastCleanup = now
+			if _, err := b.driver.DeleteInvalidations(ctx, now.Add(-b.opts.Retention)); err != nil {
+				log.Warn().Err(err).Msg("Failed to delete old cache invalidations")
+			}
+		}
+	}
+}
+
+// poll applies everything published by other instances after id
+// and returns the newest id read.
+func (b *PollingBus) poll(ctx context.Context, after int64, apply func(inv *Invalidation)) (int64, error) {
+	for {
+		list, err := b.driver.ListInvalidations(ctx, after, b.opts.BatchSize)
+		if err != nil {
+			return after, err
+		}
+		for _, inv := range list {
+			if inv.Source != b.source {
+				apply(inv)
+			}
+			after = inv.ID
+		}
+		if len(list) < b.opts.BatchSize {
+			return after, nil
+		}
+	}
+}
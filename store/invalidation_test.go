@@ -0,0 +1,94 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/db/memory"
+)
+
+// newReplicas returns two stores sharing one database, like two instances
+// of the service behind a load balancer.
+func newReplicas(t *testing.T) (*store.Store, *store.Store) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	driver := memory.NewDB(&settings.Settings{}, common.NowUTC)
+	opts := store.Options{Cache: store.CacheOptions{UserCacheSize: 100, UserCacheTTL: time.Hour, SessionCacheSize: 100, SessionCacheTTL: time.Hour}}
+	busOpts := store.PollingBusOptions{Interval: 5 * time.Millisecond, Retention: time.Hour, BatchSize: 100}
+
+	replicas := make([]*store.Store, 2)
+	for i := range replicas {
+		replicas[i] = store.New(driver, common.NowUTC, opts)
+		replicas[i].UseInvalidationBus(ctx, store.NewPollingBus(driver, common.NowUTC, busOpts))
+	}
+	// let both subscribers read the starting position
+	time.Sleep(20 * time.Millisecond)
+	return replicas[0], replicas[1]
+}
+
+func eventually(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestInvalidationBusUser(t *testing.T) {
+	ctx := context.Background()
+	a, b := newReplicas(t)
+
+	user, err := a.CreateLocalUser(ctx, &store.CreateLocalUser{Email: "jane@example.com", Status: store.StatusActive, Role: store.RoleUser})
+	if err != nil {
+		t.Fatalf("CreateLocalUser: %v", err)
+	}
+	// cache the user on b
+	if _, err := b.GetUser(ctx, &store.FindUser{ID: &user.ID}); err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+
+	name := "Jane Doe"
+	if _, err := a.UpdateUser(ctx, &store.UpdateUser{ID: user.ID, FullName: &name}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	eventually(t, "update on a never reached the cache of b", func() bool {
+		got, err := b.GetUser(ctx, &store.FindUser{ID: &user.ID})
+		return err == nil && got.FullName != nil && *got.FullName == name
+	})
+}
+
+func TestInvalidationBusSession(t *testing.T) {
+	ctx := context.Background()
+	a, b := newReplicas(t)
+
+	user, err := a.CreateLocalUser(ctx, &store.CreateLocalUser{Email: "jane@example.com", Status: store.StatusActive, Role: store.RoleUser})
+	if err != nil {
+		t.Fatalf("CreateLocalUser: %v", err)
+	}
+	created, err := a.CreateSession(ctx, &store.CreateSession{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	// cache the session on b
+	sInfo, err := b.GetActiveSessionByToken(ctx, created.Token)
+	if err != nil {
+		t.Fatalf("GetActiveSessionByToken: %v", err)
+	}
+
+	if _, err := a.RevokeSession(ctx, sInfo); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	eventually(t, "session revoked on a is still valid on b", func() bool {
+		_, err := b.GetActiveSessionByToken(ctx, created.Token)
+		return err != nil
+	})
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	// ErrInvalidClientCredentials is returned for unknown clients as well,
+	// so that client ids can't be probed.
+	ErrInvalidClientCredentials  = errors.New("invalid client credentials")
+	ErrOAuthConsentNotFound      = errors.New("oauth consent not found")
+	ErrAuthorizationCodeNotFound = errors.New("authorization code expired or not found")
+)
+
+// AuthorizationCodeTTL is how long a client has to exchange an authorization code.
+const AuthorizationCodeTTL = time.Minute
+
+// OAuthClientInfo is an application that signs its users in with this service.
+type OAuthClientInfo struct {
+	// ID is the client_id.
+	ID   string
+	Name string
+	// SHA-256 hash of the client secret, nil for public clients
+	SecretHash   []byte
+	RedirectURIs []string
+	CreatedAt    time.Time
+}
+
+// IsPublic reports whether the client has no secret, e.g. a single page or a native app.
+func (c *OAuthClientInfo) IsPublic() bool {
+	return len(c.SecretHash) == 0
+}
+
+// HasRedirectURI reports whether uri is registered, URIs must match exactly.
+func (c *OAuthClientInfo) HasRedirectURI(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+type CreateOAuthClient struct {
+	ID           string
+	Name         string
+	SecretHash   []byte
+	RedirectURIs []string
+}
+
+// RegisterOAuthClient describes a client to register.
+type RegisterOAuthClient struct {
+	Name         string
+	RedirectURIs []string
+	// Public clients get no secret.
+	Public bool
+}
+
+type RegisterOAuthClientResult struct {
+	// Raw secret to be handed to the client developer, empty for public clients
+	Secret string
+	Client *OAuthClientInfo
+}
+
+// OAuthConsentInfo is the scopes a user allowed a client to access.
+type OAuthConsentInfo struct {
+	UserID    int64
+	ClientID  string
+	Scopes    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Covers reports whether the user already allowed all of scopes.
+func (c *OAuthConsentInfo) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+type UpsertOAuthConsent struct {
+	UserID   int64
+	ClientID string
+	Scopes   []string
+}
+
+type AuthorizationCodeInfo struct {
+	// SHA-256 hash of the code
+	CodeHash    [32]byte
+	ClientID    string
+	UserID      int64
+	RedirectURI string
+	Scopes      []string
+	Nonce       string
+	// CodeChallenge is the PKCE S256 challenge.
+	CodeChallenge string
+	// AuthTime is when the user signed in.
+	AuthTime  time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time // nil - not exchanged yet
+	CreatedAt time.Time
+}
+
+// CreateAuthorizationCode describes the grant of an authorization code,
+// CodeHash and ExpiresAt are set by the store.
+type CreateAuthorizationCode struct {
+	CodeHash      [32]byte
+	ClientID      string
+	UserID        int64
+	RedirectURI   string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+}
+
+// RegisterOAuthClient creates a client with a random client_id and secret.
+func (s *Store) RegisterOAuthClient(ctx context.Context, register *RegisterOAuthClient) (*RegisterOAuthClientResult, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	create := &CreateOAuthClient{
+		ID:           id,
+		Name:         register.Name,
+		RedirectURIs: register.RedirectURIs,
+	}
+
+	var secret string
+	if !register.Public {
+		if secret, err = randomToken(32); err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256([]byte(secret))
+		create.SecretHash = hash[:]
+	}
+
+	client, err := s.driver.CreateOAuthClient(ctx, create)
+	if err != nil {
+		return nil, err
+	}
+	return &RegisterOAuthClientResult{Secret: secret, Client: client}, nil
+}
+
+// GetOAuthClient returns ErrOAuthClientNotFound if there is no client with the given id.
+func (s *Store) GetOAuthClient(ctx context.Context, id string) (*OAuthClientInfo, error) {
+	return s.driver.GetOAuthClient(ctx, id)
+}
+
+// ListOAuthClients returns all clients, oldest first.
+func (s *Store) ListOAuthClients(ctx context.Context) ([]*OAuthClientInfo, error) {
+	return s.driver.ListOAuthClients(ctx)
+}
+
+// DeleteOAuthClient deletes a client along with its consents and pending codes.
+func (s *Store) DeleteOAuthClient(ctx context.Context, id string) error {
+	return s.driver.DeleteOAuthClient(ctx, id)
+}
+
+// AuthenticateOAuthClient checks the credentials of a client. Public clients
+// authenticate with their id alone and must not send a secret.
+func (s *Store) AuthenticateOAuthClient(ctx context.Context, id, secret string) (*OAuthClientInfo, error) {
+	client, err := s.driver.GetOAuthClient(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrOAuthClientNotFound) {
+			return nil, ErrInvalidClientCredentials
+		}
+		return nil, err
+	}
+
+	if client.IsPublic() {
+		if secret != "" {
+			return nil, ErrInvalidClientCredentials
+		}
+		return client, nil
+	}
+	if !checkSecret(client.SecretHash, secret) {
+		return nil, ErrInvalidClientCredentials
+	}
+	return client, nil
+}
+
+// checkSecret compares secret to its SHA-256 hash in constant time.
+func checkSecret(hash []byte, secret string) bool {
+	sum := sha256.Sum256([]byte(secret))
+	return secret != "" && subtle.ConstantTimeCompare(sum[:], hash) == 1
+}
+
+// GetOAuthConsent returns ErrOAuthConsentNotFound if the user never allowed the client.
+func (s *Store) GetOAuthConsent(ctx context.Context, userID int64, clientID string) (*OAuthConsentInfo, error) {
+	return s.driver.GetOAuthConsent(ctx, userID, clientID)
+}
+
+// GrantOAuthConsent adds scopes to the ones the user allowed the client to access.
+func (s *Store) GrantOAuthConsent(ctx context.Context, userID int64, clientID string, scopes []string) error {
+	granted := slices.Clone(scopes)
+	consent, err := s.driver.GetOAuthConsent(ctx, userID, clientID)
+	if err != nil && !errors.Is(err, ErrOAuthConsentNotFound) {
+		return err
+	}
+	if err == nil {
+		granted = append(granted, consent.Scopes...)
+	}
+	slices.Sort(granted)
+
+	return s.driver.UpsertOAuthConsent(ctx, &UpsertOAuthConsent{
+		UserID:   userID,
+		ClientID: clientID,
+		Scopes:   slices.Compact(granted),
+	})
+}
+
+// CreateAuthorizationCode returns a code valid for AuthorizationCodeTTL.
+func (s *Store) CreateAuthorizationCode(ctx context.Context, create *CreateAuthorizationCode) (string, error) {
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	c := *create
+	c.CodeHash = sha256.Sum256([]byte(code))
+	c.ExpiresAt = s.now().Add(AuthorizationCodeTTL)
+	if _, err := s.driver.CreateAuthorizationCode(ctx, &c); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// UseAuthorizationCode marks code as used and returns it. A code can only be used
+// once, ErrAuthorizationCodeNotFound is returned for used and expired codes.
+func (s *Store) UseAuthorizationCode(ctx context.Context, code string) (*AuthorizationCodeInfo, error) {
+	hash := sha256.Sum256([]byte(code))
+	info, err := s.driver.GetAuthorizationCodeByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if info.UsedAt != nil || !info.ExpiresAt.After(s.now()) {
+		return nil, ErrAuthorizationCodeNotFound
+	}
+
+	used, err := s.driver.UseAuthorizationCode(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		// exchanged by a concurrent request in the meantime
+		return nil, ErrAuthorizationCodeNotFound
+	}
+	return info, nil
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"time"
+)
+
+var ErrPersonalAccessTokenNotFound = errors.New("personal access token expired or not found")
+
+const (
+	// PersonalAccessTokenPrefix starts every personal access token, so that
+	// secret scanners can recognize leaked ones.
+	PersonalAccessTokenPrefix = "upat_"
+	// MaxPersonalAccessTokenLifetime caps the expiry of personal access tokens.
+	MaxPersonalAccessTokenLifetime = 366 * 24 * time.Hour
+	// personalAccessTokenTouchInterval throttles the updates of last_used_at.
+	personalAccessTokenTouchInterval = time.Minute
+
+	patRandomLength   = 30 // about 178 random bits
+	patChecksumLength = 6
+	base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// PersonalAccessTokenInfo is an API key created by a user.
+type PersonalAccessTokenInfo struct {
+	ID     int64
+	UserID int64
+	Name   string
+	// SHA-256 hash of the token
+	TokenHash  [32]byte
+	Scopes     []string
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time // nil - never used
+	RevokedAt  *time.Time // nil - not revoked
+	CreatedAt  time.Time
+}
+
+// IsActive reports whether the token can be used at now.
+func (t *PersonalAccessTokenInfo) IsActive(now time.Time) bool {
+	return t.RevokedAt == nil && t.ExpiresAt.After(now)
+}
+
+type CreatePersonalAccessToken struct {
+	UserID    int64
+	Name      string
+	TokenHash [32]byte
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// IssuePersonalAccessToken describes a token to create for a user.
+type IssuePersonalAccessToken struct {
+	UserID    int64
+	Name      string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+type IssuePersonalAccessTokenResult struct {
+	// Raw token, only shown to the user once
+	Token               string
+	PersonalAccessToken *PersonalAccessTokenInfo
+}
+
+// IssuePersonalAccessToken creates a personal access token.
+func (s *Store) IssuePersonalAccessToken(ctx context.Context, issue *IssuePersonalAccessToken) (*IssuePersonalAccessTokenResult, error) {
+	token, err := generatePersonalAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	pat, err := s.driver.CreatePersonalAccessToken(ctx, &CreatePersonalAccessToken{
+		UserID:    issue.UserID,
+		Name:      issue.Name,
+		TokenHash: sha256.Sum256([]byte(token)),
+		Scopes:    issue.Scopes,
+		ExpiresAt: issue.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &IssuePersonalAccessTokenResult{Token: token, PersonalAccessToken: pat}, nil
+}
+
+// AuthenticatePersonalAccessToken returns the active token matching raw, and
+// records that it was used. ErrPersonalAccessTokenNotFound is returned for
+// malformed, unknown, expired and revoked tokens.
+func (s *Store) AuthenticatePersonalAccessToken(ctx context.Context, raw string) (*PersonalAccessTokenInfo, error) {
+	if !IsPersonalAccessToken(raw) {
+		return nil, ErrPersonalAccessTokenNotFound
+	}
+	pat, err := s.driver.GetPersonalAccessTokenByHash(ctx, sha256.Sum256([]byte(raw)))
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if !pat.IsActive(now) {
+		return nil, ErrPersonalAccessTokenNotFound
+	}
+
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) >= personalAccessTokenTouchInterval {
+		if err := s.driver.TouchPersonalAccessToken(ctx, pat.ID, now); err != nil {
+			return nil, err
+		}
+		pat.LastUsedAt = &now
+	}
+	return pat, nil
+}
+
+// ListPersonalAccessTokens returns the tokens of a user that were not revoked, newest first.
+func (s *Store) ListPersonalAccessTokens(ctx context.Context, userID int64) ([]*PersonalAccessTokenInfo, error) {
+	return s.driver.ListPersonalAccessTokens(ctx, userID)
+}
+
+// RevokePersonalAccessToken returns ErrPersonalAccessTokenNotFound unless the
+// user has a token with the given id that was not revoked yet.
+func (s *Store) RevokePersonalAccessToken(ctx context.Context, userID, id int64) error {
+	revoked, err := s.driver.RevokePersonalAccessToken(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// IsPersonalAccessToken reports whether raw has the prefix, length and checksum
+// of a personal access token, whether it exists is not checked.
+func IsPersonalAccessToken(raw string) bool {
+	body, ok := strings.CutPrefix(raw, PersonalAccessTokenPrefix)
+	if !ok || len(body) != patRandomLength+patChecksumLength {
+		return false
+	}
+	random, checksum := body[:patRandomLength], body[patRandomLength:]
+	return strings.Trim(random, base62Alphabet) == "" && patChecksum(random) == checksum
+}
+
+// generatePersonalAccessToken returns the prefix, random base62 characters and
+// the base62 CRC-32 of those, so that typos and look-alikes can be told apart
+// from real tokens without a lookup.
+func generatePersonalAccessToken() (string, error) {
+	random := make([]byte, 0, patRandomLength)
+	buf := make([]byte, patRandomLength)
+	for len(random) < patRandomLength {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			// 248 is the largest multiple of 62 below 256, keeps the characters uniform
+			if b < 248 && len(random) < patRandomLength {
+				random = append(random, base62Alphabet[b%62])
+			}
+		}
+	}
+	return PersonalAccessTokenPrefix + string(random) + patChecksum(string(random)), nil
+}
+
+func patChecksum(random string) string {
+	sum := crc32.ChecksumIEEE([]byte(random))
+	out := make([]byte, patChecksumLength)
+	for i := patChecksumLength - 1; i >= 0; i-- {
+		out[i] = base62Alphabet[sum%62]
+		sum /= 62
+	}
+	return string(out)
+}
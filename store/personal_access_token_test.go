@@ -0,0 +1,76 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func TestAuthenticatePersonalAccessToken(t *testing.T) {
+	ctx := context.Background()
+	s, clock, user := newSessionStore(t)
+	issued, err := s.IssuePersonalAccessToken(ctx, &store.IssuePersonalAccessToken{
+		UserID: user.ID, Name: "CI", Scopes: []string{"user:read"}, ExpiresAt: clock.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("IssuePersonalAccessToken: %v", err)
+	}
+	token := issued.Token
+	if !strings.HasPrefix(token, store.PersonalAccessTokenPrefix) || !store.IsPersonalAccessToken(token) {
+		t.Fatalf("IssuePersonalAccessToken = %q, want a valid %s token", token, store.PersonalAccessTokenPrefix)
+	}
+
+	got, err := s.AuthenticatePersonalAccessToken(ctx, token)
+	if err != nil {
+		t.Fatalf("AuthenticatePersonalAccessToken: %v", err)
+	}
+	if got.ID != issued.PersonalAccessToken.ID || got.UserID != user.ID || got.LastUsedAt == nil || !got.LastUsedAt.Equal(clock.Now()) {
+		t.Errorf("AuthenticatePersonalAccessToken = %+v, want token %d used at %v", got, issued.PersonalAccessToken.ID, clock.Now())
+	}
+
+	// a typo breaks the checksum
+	typo := []byte(token)
+	if i := len(store.PersonalAccessTokenPrefix); typo[i] == 'x' {
+		typo[i] = 'y'
+	} else {
+		typo[i] = 'x'
+	}
+	for name, raw := range map[string]string{
+		"typo":      string(typo),
+		"no prefix": strings.TrimPrefix(token, store.PersonalAccessTokenPrefix),
+		"truncated": token[:len(token)-1],
+		"empty":     "",
+	} {
+		if store.IsPersonalAccessToken(raw) {
+			t.Errorf("IsPersonalAccessToken(%s) = true, want false", name)
+		}
+		if _, err := s.AuthenticatePersonalAccessToken(ctx, raw); !errors.Is(err, store.ErrPersonalAccessTokenNotFound) {
+			t.Errorf("AuthenticatePersonalAccessToken(%s) error = %v, want %v", name, err, store.ErrPersonalAccessTokenNotFound)
+		}
+	}
+
+	clock.Advance(2 * time.Hour)
+	if _, err := s.AuthenticatePersonalAccessToken(ctx, token); !errors.Is(err, store.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("AuthenticatePersonalAccessToken(expired) error = %v, want %v", err, store.ErrPersonalAccessTokenNotFound)
+	}
+
+	issued, err = s.IssuePersonalAccessToken(ctx, &store.IssuePersonalAccessToken{
+		UserID: user.ID, Name: "CI", Scopes: []string{"user:read"}, ExpiresAt: clock.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("IssuePersonalAccessToken: %v", err)
+	}
+	if err := s.RevokePersonalAccessToken(ctx, user.ID, issued.PersonalAccessToken.ID); err != nil {
+		t.Fatalf("RevokePersonalAccessToken: %v", err)
+	}
+	if _, err := s.AuthenticatePersonalAccessToken(ctx, issued.Token); !errors.Is(err, store.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("AuthenticatePersonalAccessToken(revoked) error = %v, want %v", err, store.ErrPersonalAccessTokenNotFound)
+	}
+	if err := s.RevokePersonalAccessToken(ctx, user.ID, issued.PersonalAccessToken.ID); !errors.Is(err, store.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("RevokePersonalAccessToken(again) error = %v, want %v", err, store.ErrPersonalAccessTokenNotFound)
+	}
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleAlreadyExists = errors.New("role already exists")
+	ErrRoleBuiltin       = errors.New("built-in roles can't be changed")
+	ErrRoleInUse         = errors.New("role is assigned to users")
+)
+
+// Permission allows a role to use a set of endpoints, e.g. users:write.
+type Permission string
+
+const (
+	PermissionUsersRead            Permission = "users:read"
+	PermissionUsersWrite           Permission = "users:write"
+	PermissionRolesRead            Permission = "roles:read"
+	PermissionRolesWrite           Permission = "roles:write"
+	PermissionOAuthClientsRead     Permission = "oauth_clients:read"
+	PermissionOAuthClientsWrite    Permission = "oauth_clients:write"
+	PermissionServiceAccountsRead  Permission = "service_accounts:read"
+	PermissionServiceAccountsWrite Permission = "service_accounts:write"
+	PermissionStatsRead            Permission = "stats:read"
+)
+
+// Permissions are all permissions roles can be granted.
+var Permissions = []Permission{
+	PermissionOAuthClientsRead,
+	PermissionOAuthClientsWrite,
+	PermissionRolesRead,
+	PermissionRolesWrite,
+	PermissionServiceAccountsRead,
+	PermissionServiceAccountsWrite,
+	PermissionStatsRead,
+	PermissionUsersRead,
+	PermissionUsersWrite,
+}
+
+// BuiltinRoles are created by the migrations, they can't be changed or deleted.
+var BuiltinRoles = []*CreateRole{
+	{Name: RoleAdmin, Description: "Full access", Permissions: Permissions},
+	{Name: RoleSupport, Description: "Read access to users, for customer support", Permissions: []Permission{PermissionRolesRead, PermissionUsersRead}},
+	{Name: RoleUser, Description: "No administrative access", Permissions: []Permission{}},
+}
+
+type RoleInfo struct {
+	Name        Role
+	Description string
+	Builtin     bool
+	// Permissions are sorted.
+	Permissions []Permission
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// HasPermissions reports whether the role was granted all of permissions.
+func (r *RoleInfo) HasPermissions(permissions ...Permission) bool {
+	for _, permission := range permissions {
+		if !slices.Contains(r.Permissions, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+type CreateRole struct {
+	Name        Role
+	Description string
+	Permissions []Permission
+}
+
+type UpdateRole struct {
+	Name        Role
+	Description *string
+	// Permissions replace the permissions of the role, nil leaves them as they are.
+	Permissions []Permission
+}
+
+// CreateRole creates a custom role, ErrRoleAlreadyExists is returned if the name is taken.
+func (s *Store) CreateRole(ctx context.Context, create *CreateRole) (*RoleInfo, error) {
+	create.Permissions = sortedPermissions(create.Permissions)
+	return s.driver.CreateRole(ctx, create)
+}
+
+func (s *Store) GetRole(ctx context.Context, name Role) (*RoleInfo, error) {
+	return s.driver.GetRole(ctx, name)
+}
+
+// ListRoles returns all roles ordered by name.
+func (s *Store) ListRoles(ctx context.Context) ([]*RoleInfo, error) {
+	return s.driver.ListRoles(ctx)
+}
+
+// UpdateRole changes a custom role, the permissions of its users change along.
+func (s *Store) UpdateRole(ctx context.Context, update *UpdateRole) (*RoleInfo, error) {
+	if err := s.checkCustomRole(ctx, update.Name); err != nil {
+		return nil, err
+	}
+	if update.Permissions != nil {
+		update.Permissions = sortedPermissions(update.Permissions)
+	}
+	role, err := s.driver.UpdateRole(ctx, update)
+	if err != nil {
+		return nil, err
+	}
+
+	s.forgetPermissions(ctx)
+	return role, nil
+}
+
+// DeleteRole deletes a custom role, ErrRoleInUse is returned while users are assigned to it.
+func (s *Store) DeleteRole(ctx context.Context, name Role) error {
+	if err := s.checkCustomRole(ctx, name); err != nil {
+		return err
+	}
+	deleted, err := s.driver.DeleteRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRoleInUse
+	}
+
+	s.forgetPermissions(ctx)
+	return nil
+}
+
+// UserPermissions returns the permissions granted to the role of a user.
+// They are cached per user until the user or any role changes.
+func (s *Store) UserPermissions(ctx context.Context, userID int64) ([]Permission, error) {
+	return s.permissionCache.GetOrLoad(userID, func() ([]Permission, error) {
+		user, err := s.GetUser(ctx, &FindUser{ID: &userID})
+		if err != nil {
+			return nil, err
+		}
+		role, err := s.driver.GetRole(ctx, user.Role)
+		if err != nil {
+			return nil, err
+		}
+		return role.Permissions, nil
+	})
+}
+
+func (s *Store) checkCustomRole(ctx context.Context, name Role) error {
+	role, err := s.driver.GetRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return ErrRoleBuiltin
+	}
+	return nil
+}
+
+// forgetPermissions drops every cached permission, here and on other instances,
+// roles change rarely enough not to bother finding their users.
+func (s *Store) forgetPermissions(ctx context.Context) {
+	s.permissionCache.DeleteFunc(func(int64, []Permission) bool { return true })
+	s.publish(ctx, &Invalidation{Kind: InvalidateRoles})
+}
+
+func sortedPermissions(permissions []Permission) []Permission {
+	permissions = slices.Clone(permissions)
+	slices.Sort(permissions)
+	return slices.Compact(permissions)
+}
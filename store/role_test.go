@@ -0,0 +1,70 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/db/memory"
+	"github.com/sagarsuperuser/userprofile/store/storetest"
+)
+
+func TestUserPermissions(t *testing.T) {
+	ctx := context.Background()
+	clock := storetest.NewClock(time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC))
+	s := store.New(memory.NewDB(&settings.Settings{}, clock.Now), clock.Now, store.Options{
+		Cache: store.CacheOptions{UserCacheSize: 100, UserCacheTTL: time.Hour},
+	})
+	user, err := s.CreateLocalUser(ctx, &store.CreateLocalUser{
+		Email: "jane@example.com", Status: store.StatusActive, Role: store.RoleUser,
+	})
+	if err != nil {
+		t.Fatalf("CreateLocalUser: %v", err)
+	}
+	check := func(want ...store.Permission) {
+		t.Helper()
+		got, err := s.UserPermissions(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("UserPermissions: %v", err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("UserPermissions = %v, want %v", got, want)
+		}
+	}
+	check()
+
+	// the cached permissions follow role changes of the user and of the role
+	if _, err := s.CreateRole(ctx, &store.CreateRole{
+		Name:        "auditor",
+		Permissions: []store.Permission{store.PermissionUsersRead, store.PermissionRolesRead, store.PermissionUsersRead},
+	}); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	role := store.Role("auditor")
+	if _, err := s.UpdateUser(ctx, &store.UpdateUser{ID: user.ID, Role: &role}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	check(store.PermissionRolesRead, store.PermissionUsersRead)
+	if _, err := s.UpdateRole(ctx, &store.UpdateRole{Name: role, Permissions: []store.Permission{store.PermissionStatsRead}}); err != nil {
+		t.Fatalf("UpdateRole: %v", err)
+	}
+	check(store.PermissionStatsRead)
+
+	missing := store.Role("missing")
+	if _, err := s.UpdateUser(ctx, &store.UpdateUser{ID: user.ID, Role: &missing}); !errors.Is(err, store.ErrRoleNotFound) {
+		t.Errorf("UpdateUser(missing role) error = %v, want %v", err, store.ErrRoleNotFound)
+	}
+	if err := s.DeleteRole(ctx, role); !errors.Is(err, store.ErrRoleInUse) {
+		t.Errorf("DeleteRole(in use) error = %v, want %v", err, store.ErrRoleInUse)
+	}
+	if _, err := s.UpdateRole(ctx, &store.UpdateRole{Name: store.RoleAdmin, Permissions: []store.Permission{}}); !errors.Is(err, store.ErrRoleBuiltin) {
+		t.Errorf("UpdateRole(admin) error = %v, want %v", err, store.ErrRoleBuiltin)
+	}
+	if err := s.DeleteRole(ctx, store.RoleUser); !errors.Is(err, store.ErrRoleBuiltin) {
+		t.Errorf("DeleteRole(user) error = %v, want %v", err, store.ErrRoleBuiltin)
+	}
+}
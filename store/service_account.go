@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrServiceAccountNotFound = errors.New("service account not found")
+
+// ServiceAccountInfo is a machine caller, it gets tokens with the client_credentials grant.
+type ServiceAccountInfo struct {
+	// ID is the client_id.
+	ID   string
+	Name string
+	// SHA-256 hash of the client secret
+	SecretHash []byte
+	// Scopes the account may request.
+	Scopes []string
+	// OwnerID is the user responsible for the account, deleting the user deletes it.
+	OwnerID   int64
+	CreatedAt time.Time
+}
+
+// AllowsScopes reports whether the account may request all of scopes.
+func (a *ServiceAccountInfo) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(a.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+type CreateServiceAccount struct {
+	ID         string
+	Name       string
+	SecretHash []byte
+	Scopes     []string
+	OwnerID    int64
+}
+
+// RegisterServiceAccount describes a service account to register.
+type RegisterServiceAccount struct {
+	Name    string
+	Scopes  []string
+	OwnerID int64
+}
+
+type RegisterServiceAccountResult struct {
+	// Raw secret to be handed to the owner
+	Secret         string
+	ServiceAccount *ServiceAccountInfo
+}
+
+// RegisterServiceAccount creates a service account with a random client_id and secret.
+func (s *Store) RegisterServiceAccount(ctx context.Context, register *RegisterServiceAccount) (*RegisterServiceAccountResult, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(secret))
+
+	account, err := s.driver.CreateServiceAccount(ctx, &CreateServiceAccount{
+		ID:         id,
+		Name:       register.Name,
+		SecretHash: hash[:],
+		Scopes:     register.Scopes,
+		OwnerID:    register.OwnerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RegisterServiceAccountResult{Secret: secret, ServiceAccount: account}, nil
+}
+
+// GetServiceAccount returns ErrServiceAccountNotFound if there is no account with the given id.
+func (s *Store) GetServiceAccount(ctx context.Context, id string) (*ServiceAccountInfo, error) {
+	return s.driver.GetServiceAccount(ctx, id)
+}
+
+// ListServiceAccounts returns all service accounts, oldest first.
+func (s *Store) ListServiceAccounts(ctx context.Context) ([]*ServiceAccountInfo, error) {
+	return s.driver.ListServiceAccounts(ctx)
+}
+
+// DeleteServiceAccount deletes an account, tokens already issued to it are
+// rejected from then on.
+func (s *Store) DeleteServiceAccount(ctx context.Context, id string) error {
+	return s.driver.DeleteServiceAccount(ctx, id)
+}
+
+// AuthenticateServiceAccount checks the credentials of a service account.
+// ErrInvalidClientCredentials is returned for unknown accounts as well.
+func (s *Store) AuthenticateServiceAccount(ctx context.Context, id, secret string) (*ServiceAccountInfo, error) {
+	account, err := s.driver.GetServiceAccount(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrServiceAccountNotFound) {
+			return nil, ErrInvalidClientCredentials
+		}
+		return nil, err
+	}
+	if !checkSecret(account.SecretHash, secret) {
+		return nil, ErrInvalidClientCredentials
+	}
+	return account, nil
+}
@@ -0,0 +1,39 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func TestAuthenticateServiceAccount(t *testing.T) {
+	ctx := context.Background()
+	s, _, owner := newSessionStore(t)
+	registered, err := s.RegisterServiceAccount(ctx, &store.RegisterServiceAccount{
+		Name: "Billing", Scopes: []string{"users:read"}, OwnerID: owner.ID,
+	})
+	if err != nil {
+		t.Fatalf("RegisterServiceAccount: %v", err)
+	}
+	id := registered.ServiceAccount.ID
+
+	got, err := s.AuthenticateServiceAccount(ctx, id, registered.Secret)
+	if err != nil {
+		t.Fatalf("AuthenticateServiceAccount: %v", err)
+	}
+	if got.ID != id || !got.AllowsScopes([]string{"users:read"}) || got.AllowsScopes([]string{"users:write"}) {
+		t.Errorf("AuthenticateServiceAccount = %+v, want account %s allowed users:read only", got, id)
+	}
+
+	for name, tc := range map[string]struct{ id, secret string }{
+		"wrong secret":    {id, "wrong"},
+		"empty secret":    {id, ""},
+		"unknown account": {"unknown", registered.Secret},
+	} {
+		if _, err := s.AuthenticateServiceAccount(ctx, tc.id, tc.secret); !errors.Is(err, store.ErrInvalidClientCredentials) {
+			t.Errorf("AuthenticateServiceAccount(%s) error = %v, want %v", name, err, store.ErrInvalidClientCredentials)
+		}
+	}
+}
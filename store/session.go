@@ -3,20 +3,119 @@ package store
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"time"
+	"unicode/utf8"
 
 	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
 )
 
+var ErrSessionNotFound = errors.New("session not found")
+
+const (
+	maxSessionIPLength        = 45
+	maxSessionUserAgentLength = 512
+)
+
 type SessionInfo struct {
 	ID     int64
 	UserID int64
 	// SHA-256 hash of [Token]
 	TokenHash [32]byte
-	ExpiresAt time.Time
-	RevokedAt *time.Time // nil - not revoked
-	CreatedAt time.Time
-	IsActive  bool
+	// IP and UserAgent of the device, IP is updated as the session is used.
+	IP        string
+	UserAgent string
+	// Name is set by the user, empty by default.
+	Name string
+	// Remember selects the "remember me" SessionPolicy.
+	Remember bool
+	// ExpiresAt moves forward as the session is used, up to AbsoluteExpiresAt.
+	ExpiresAt         time.Time
+	AbsoluteExpiresAt time.Time
+	LastSeenAt        time.Time
+	RevokedAt         *time.Time // nil - not revoked
+	CreatedAt         time.Time
+	IsActive          bool
+}
+
+// SessionPolicy bounds how long a session lives.
+type SessionPolicy struct {
+	// IdleTimeout expires sessions that are not used for that long.
+	IdleTimeout time.Duration
+	// MaxLifetime expires sessions that long after sign in, however much they are used.
+	MaxLifetime time.Duration
+}
+
+// SessionOptions configures the lifetime of sessions.
+type SessionOptions struct {
+	Default SessionPolicy
+	// Remember is used for "remember me" sign ins.
+	Remember SessionPolicy
+	// RefreshInterval throttles the writes that extend a session
+	// and record when it was last seen.
+	RefreshInterval time.Duration
+}
+
+// DefaultSessionOptions fill the fields left empty in the options given to New.
+var DefaultSessionOptions = SessionOptions{
+	Default:         SessionPolicy{IdleTimeout: 24 * time.Hour, MaxLifetime: 7 * 24 * time.Hour},
+	Remember:        SessionPolicy{IdleTimeout: 30 * 24 * time.Hour, MaxLifetime: 90 * 24 * time.Hour},
+	RefreshInterval: time.Minute,
+}
+
+func (o SessionOptions) withDefaults() SessionOptions {
+	orDefault := func(v *time.Duration, def time.Duration) {
+		if *v <= 0 {
+			*v = def
+		}
+	}
+	orDefault(&o.Default.IdleTimeout, DefaultSessionOptions.Default.IdleTimeout)
+	orDefault(&o.Default.MaxLifetime, DefaultSessionOptions.Default.MaxLifetime)
+	orDefault(&o.Remember.IdleTimeout, DefaultSessionOptions.Remember.IdleTimeout)
+	orDefault(&o.Remember.MaxLifetime, DefaultSessionOptions.Remember.MaxLifetime)
+	orDefault(&o.RefreshInterval, DefaultSessionOptions.RefreshInterval)
+	return o
+}
+
+func (o SessionOptions) policy(remember bool) SessionPolicy {
+	if remember {
+		return o.Remember
+	}
+	return o.Default
+}
+
+// expiresAt is when a session used at now expires if it stays idle.
+func (p SessionPolicy) expiresAt(now, absoluteExpiresAt time.Time) time.Time {
+	if idle := now.Add(p.IdleTimeout); idle.Before(absoluteExpiresAt) {
+		return idle
+	}
+	return absoluteExpiresAt
+}
+
+type CreateSession struct {
+	UserID    int64
+	TokenHash [32]byte
+	IP        string
+	UserAgent string
+	// Remember selects the "remember me" SessionPolicy.
+	Remember bool
+	// set by Store from its SessionOptions
+	ExpiresAt         time.Time
+	AbsoluteExpiresAt time.Time
+}
+
+// FindSession only matches active sessions.
+type FindSession struct {
+	ID     *int64
+	UserID *int64
+}
+
+type UpdateSession struct {
+	ID         int64
+	Name       *string
+	IP         *string
+	LastSeenAt *time.Time
+	ExpiresAt  *time.Time
 }
 
 type CreateSessionResult struct {
@@ -26,18 +125,29 @@ type CreateSessionResult struct {
 	Session SessionInfo
 }
 
-// create new session
-func (s *Store) CreateSession(ctx context.Context, userID int64) (*CreateSessionResult, error) {
+// create new session, TokenHash is generated
+func (s *Store) CreateSession(ctx context.Context, create *CreateSession) (*CreateSessionResult, error) {
 	// generate token
 	token := sessionUtils.GenerateSessionID()
 	hash := sha256.Sum256([]byte(token))
 
-	session, err := s.driver.CreateSession(ctx, userID, hash)
+	now := s.now()
+	policy := s.sessions.policy(create.Remember)
+	absoluteExpiresAt := now.Add(policy.MaxLifetime)
+	session, err := s.driver.CreateSession(ctx, &CreateSession{
+		UserID:            create.UserID,
+		TokenHash:         hash,
+		IP:                truncate(create.IP, maxSessionIPLength),
+		UserAgent:         truncate(create.UserAgent, maxSessionUserAgentLength),
+		Remember:          create.Remember,
+		ExpiresAt:         policy.expiresAt(now, absoluteExpiresAt),
+		AbsoluteExpiresAt: absoluteExpiresAt,
+	})
 	if err != nil {
 		return nil, err
 	}
 	// Cache the SHA-256 hash of the session token
-	s.sessionCache.Store(hash, session)
+	s.sessionCache.Set(hash, session)
 
 	res := CreateSessionResult{}
 	res.Token = token
@@ -48,28 +158,138 @@ func (s *Store) CreateSession(ctx context.Context, userID int64) (*CreateSession
 // get user active session, used by auth middleware
 func (s *Store) GetActiveSessionByToken(ctx context.Context, token string) (*SessionInfo, error) {
 	hash := sha256.Sum256([]byte(token))
-	// check first in cache
-	if v, ok := s.sessionCache.Load(hash); ok {
-		sInfo := v.(*SessionInfo)
-		if sInfo.ExpiresAt.Before(s.now()) {
-			return nil, sessionUtils.ErrSesssionExpired
-		}
-		return sInfo, nil
+	load := func() (*SessionInfo, error) {
+		return s.driver.GetActiveSessionByHash(ctx, hash)
 	}
-	sInfo, err := s.driver.GetActiveSessionByHash(ctx, hash)
+	// check first in cache, concurrent misses share one database read
+	sInfo, err := s.sessionCache.GetOrLoad(hash, load)
 	if err != nil {
 		return nil, err
 	}
-	// cache it for next requests
-	s.sessionCache.Store(hash, sInfo)
+	if sInfo.ExpiresAt.Before(s.now()) {
+		// another instance may have extended it, the database decides.
+		s.sessionCache.Delete(hash)
+		return s.sessionCache.GetOrLoad(hash, load)
+	}
 	return sInfo, nil
 }
 
+// list the active sessions of a user, most recently used first
+func (s *Store) ListSessions(ctx context.Context, userID int64) ([]*SessionInfo, error) {
+	return s.driver.ListSessions(ctx, &FindSession{UserID: &userID})
+}
+
+// get an active session, returns ErrSessionNotFound if there is none
+func (s *Store) GetSession(ctx context.Context, find *FindSession) (*SessionInfo, error) {
+	list, err := s.driver.ListSessions(ctx, find)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return list[0], nil
+}
+
+func (s *Store) UpdateSession(ctx context.Context, update *UpdateSession) (*SessionInfo, error) {
+	session, err := s.driver.UpdateSession(ctx, update)
+	if err != nil {
+		return nil, err
+	}
+	// the next lookup reloads it from the database
+	s.sessionCache.Delete(session.TokenHash)
+	s.publish(ctx, &Invalidation{Kind: InvalidateSession, UserID: session.UserID, TokenHash: session.TokenHash})
+	return session, nil
+}
+
+// RefreshSession records that session was used from ip and pushes its
+// expiry back by the idle timeout of its policy, never past AbsoluteExpiresAt.
+// Writes are throttled to one per RefreshInterval per session, session is
+// returned as is in between.
+func (s *Store) RefreshSession(ctx context.Context, session *SessionInfo, ip string) (*SessionInfo, error) {
+	now := s.now()
+	if now.Sub(session.LastSeenAt) < s.sessions.RefreshInterval {
+		return session, nil
+	}
+	ip = truncate(ip, maxSessionIPLength)
+	expiresAt := s.sessions.policy(session.Remember).expiresAt(now, session.AbsoluteExpiresAt)
+	refreshed, err := s.driver.UpdateSession(ctx, &UpdateSession{
+		ID:         session.ID,
+		IP:         &ip,
+		LastSeenAt: &now,
+		ExpiresAt:  &expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// other instances reload it once their copy looks expired, no need to publish.
+	s.sessionCache.Delete(session.TokenHash)
+	return refreshed, nil
+}
+
 // expire session, used by handlers
 func (s *Store) RevokeSession(ctx context.Context, session *SessionInfo) (bool, error) {
 	// clear cache first
 	s.sessionCache.Delete(session.TokenHash)
 
 	// delete from database
-	return s.driver.RevokeSession(ctx, session.TokenHash)
+	revoked, err := s.driver.RevokeSession(ctx, session.TokenHash)
+	if err != nil {
+		return false, err
+	}
+
+	s.publish(ctx, &Invalidation{Kind: InvalidateSession, UserID: session.UserID, TokenHash: session.TokenHash})
+	return revoked, nil
+}
+
+// revoke all sessions of the user except current, used to sign out other devices
+func (s *Store) RevokeOtherSessions(ctx context.Context, current *SessionInfo) (int64, error) {
+	list, err := s.ListSessions(ctx, current.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, session := range list {
+		if session.ID == current.ID {
+			continue
+		}
+		revoked, err := s.RevokeSession(ctx, session)
+		if err != nil {
+			return n, err
+		}
+		if revoked {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// revoke all sessions of a user, used when the user gets disabled
+func (s *Store) RevokeUserSessions(ctx context.Context, userID int64) (int64, error) {
+	n, err := s.driver.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	// clear cache after the database so a concurrent lookup can't re-cache them
+	s.forgetUserSessions(userID)
+	s.publish(ctx, &Invalidation{Kind: InvalidateUserSessions, UserID: userID})
+	return n, nil
+}
+
+func (s *Store) forgetUserSessions(userID int64) {
+	s.sessionCache.DeleteFunc(func(_ [32]byte, sInfo *SessionInfo) bool {
+		return sInfo.UserID == userID
+	})
+}
+
+// truncate cuts v to at most n bytes without splitting a UTF-8 sequence.
+func truncate(v string, n int) string {
+	if len(v) <= n {
+		return v
+	}
+	for n > 0 && !utf8.RuneStart(v[n]) {
+		n--
+	}
+	return v[:n]
 }
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultPurgeBatchSize = 500
+
+// SessionSweeperOptions configures RunSessionSweeper.
+type SessionSweeperOptions struct {
+	// Interval between two sweeps.
+	Interval time.Duration
+	// Retention is how long expired and revoked sessions are kept,
+	// e.g. to still show up in audits.
+	Retention time.Duration
+	// BatchSize is the maximum number of sessions deleted per query.
+	BatchSize int
+}
+
+// PurgeSessions deletes the sessions that expired or were revoked before the
+// given time, batchSize at a time, and returns how many were deleted.
+func (s *Store) PurgeSessions(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = defaultPurgeBatchSize
+	}
+	var purged int64
+	for {
+		hashes, err := s.driver.DeleteSessions(ctx, before, batchSize)
+		if err != nil {
+			return purged, err
+		}
+		// no need to publish: other instances never accept an expired or
+		// revoked session, their copies only take up space until they expire.
+		for _, hash := range hashes {
+			s.sessionCache.Delete(hash)
+		}
+		purged += int64(len(hashes))
+		if len(hashes) < batchSize {
+			return purged, nil
+		}
+	}
+}
+
+// RunSessionSweeper purges old sessions and tokens every opts.Interval until ctx is done.
+func (s *Store) RunSessionSweeper(ctx context.Context, opts SessionSweeperOptions) {
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		before := s.now().Add(-opts.Retention)
+		purged, err := s.PurgeSessions(ctx, before, opts.BatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn().Err(err).Int64("purged", purged).Msg("Failed to purge old sessions")
+			}
+			continue
+		}
+		if purged > 0 {
+			log.Info().Int64("purged", purged).Msg("Purged old sessions")
+		}
+
+		purged, err = s.PurgeTokens(ctx, before)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn().Err(err).Msg("Failed to purge old tokens")
+			}
+			continue
+		}
+		if purged > 0 {
+			log.Info().Int64("purged", purged).Msg("Purged old tokens")
+		}
+	}
+}
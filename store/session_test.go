@@ -0,0 +1,164 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
+	"github.com/sagarsuperuser/userprofile/server/settings"
+	"github.com/sagarsuperuser/userprofile/store"
+	"github.com/sagarsuperuser/userprofile/store/db/memory"
+	"github.com/sagarsuperuser/userprofile/store/storetest"
+)
+
+var testSessionOptions = store.SessionOptions{
+	Default:         store.SessionPolicy{IdleTimeout: time.Hour, MaxLifetime: 3 * time.Hour},
+	Remember:        store.SessionPolicy{IdleTimeout: 24 * time.Hour, MaxLifetime: 48 * time.Hour},
+	RefreshInterval: time.Minute,
+}
+
+func newSessionStore(t *testing.T) (*store.Store, *storetest.Clock, *store.UserInfo) {
+	t.Helper()
+	clock := storetest.NewClock(time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC))
+	driver := memory.NewDB(&settings.Settings{}, clock.Now)
+	s := store.New(driver, clock.Now, store.Options{
+		Cache:    store.CacheOptions{SessionCacheSize: 100, SessionCacheTTL: time.Hour},
+		Sessions: testSessionOptions,
+	})
+	user, err := s.CreateLocalUser(context.Background(), &store.CreateLocalUser{
+		Email: "jane@example.com", Status: store.StatusActive, Role: store.RoleUser,
+	})
+	if err != nil {
+		t.Fatalf("CreateLocalUser: %v", err)
+	}
+	return s, clock, user
+}
+
+// use authenticates with token and refreshes the session like router.AuthSession.
+func use(ctx context.Context, s *store.Store, token string) (*store.SessionInfo, error) {
+	sess, err := s.GetActiveSessionByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return s.RefreshSession(ctx, sess, "203.0.113.7")
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	ctx := context.Background()
+	s, clock, user := newSessionStore(t)
+	created, err := s.CreateSession(ctx, &store.CreateSession{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if want := clock.Now().Add(time.Hour); !created.Session.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", created.Session.ExpiresAt, want)
+	}
+
+	// within the refresh interval nothing is written
+	clock.Advance(30 * time.Second)
+	sess, err := use(ctx, s, created.Token)
+	if err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	if !sess.ExpiresAt.Equal(created.Session.ExpiresAt) {
+		t.Errorf("session extended within the refresh interval")
+	}
+
+	// used every 50 minutes, it outlives the idle timeout
+	for range 2 {
+		clock.Advance(50 * time.Minute)
+		if sess, err = use(ctx, s, created.Token); err != nil {
+			t.Fatalf("use: %v", err)
+		}
+		if want := clock.Now().Add(time.Hour); !sess.ExpiresAt.Equal(want) {
+			t.Errorf("ExpiresAt = %v, want %v", sess.ExpiresAt, want)
+		}
+	}
+
+	clock.Advance(time.Hour)
+	if _, err := s.GetActiveSessionByToken(ctx, created.Token); !errors.Is(err, sessionUtils.ErrSesssionExpired) {
+		t.Errorf("GetActiveSessionByToken after the idle timeout error = %v, want %v", err, sessionUtils.ErrSesssionExpired)
+	}
+}
+
+func TestSessionMaxLifetime(t *testing.T) {
+	ctx := context.Background()
+	s, clock, user := newSessionStore(t)
+	created, err := s.CreateSession(ctx, &store.CreateSession{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	absolute := clock.Now().Add(3 * time.Hour)
+
+	var sess *store.SessionInfo
+	for range 5 {
+		clock.Advance(30 * time.Minute)
+		if sess, err = use(ctx, s, created.Token); err != nil {
+			t.Fatalf("use: %v", err)
+		}
+	}
+	// 2h30 after sign in the idle timeout would go past the max lifetime
+	if !sess.ExpiresAt.Equal(absolute) {
+		t.Errorf("ExpiresAt = %v, want the max lifetime %v", sess.ExpiresAt, absolute)
+	}
+
+	clock.Advance(30 * time.Minute)
+	if _, err := s.GetActiveSessionByToken(ctx, created.Token); !errors.Is(err, sessionUtils.ErrSesssionExpired) {
+		t.Errorf("GetActiveSessionByToken after the max lifetime error = %v, want %v", err, sessionUtils.ErrSesssionExpired)
+	}
+}
+
+func TestSessionRememberPolicy(t *testing.T) {
+	ctx := context.Background()
+	s, clock, user := newSessionStore(t)
+	created, err := s.CreateSession(ctx, &store.CreateSession{UserID: user.ID, Remember: true})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if !created.Session.Remember {
+		t.Errorf("Remember = false")
+	}
+	if want := clock.Now().Add(24 * time.Hour); !created.Session.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", created.Session.ExpiresAt, want)
+	}
+	if want := clock.Now().Add(48 * time.Hour); !created.Session.AbsoluteExpiresAt.Equal(want) {
+		t.Errorf("AbsoluteExpiresAt = %v, want %v", created.Session.AbsoluteExpiresAt, want)
+	}
+
+	clock.Advance(12 * time.Hour)
+	sess, err := use(ctx, s, created.Token)
+	if err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	if want := clock.Now().Add(24 * time.Hour); !sess.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", sess.ExpiresAt, want)
+	}
+}
+
+func TestPurgeSessions(t *testing.T) {
+	ctx := context.Background()
+	s, clock, user := newSessionStore(t)
+	for range 3 {
+		if _, err := s.CreateSession(ctx, &store.CreateSession{UserID: user.ID}); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+	}
+	if size := s.CacheStats().Sessions.Size; size != 3 {
+		t.Fatalf("cached sessions = %d, want 3", size)
+	}
+
+	// all expired a day ago
+	clock.Advance(time.Hour + 24*time.Hour)
+	purged, err := s.PurgeSessions(ctx, clock.Now().Add(-12*time.Hour), 2)
+	if err != nil {
+		t.Fatalf("PurgeSessions: %v", err)
+	}
+	if purged != 3 {
+		t.Errorf("PurgeSessions = %d, want 3", purged)
+	}
+	if size := s.CacheStats().Sessions.Size; size != 0 {
+		t.Errorf("cached sessions after PurgeSessions = %d, want 0", size)
+	}
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// SigningKeyInfo is a key that signs the JWTs issued by the service.
+type SigningKeyInfo struct {
+	// ID is the "kid" of the key.
+	ID        string
+	Algorithm string
+	// PrivateKey is encrypted by the caller, the store keeps it as is.
+	PrivateKey []byte
+	CreatedAt  time.Time
+	// RetiredAt is set once a newer key signs tokens, nil - still signing.
+	RetiredAt *time.Time
+}
+
+type CreateSigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey []byte
+}
+
+type FindSigningKey struct {
+	// RetiredAfter only matches keys still signing or retired after the given time.
+	RetiredAfter *time.Time
+}
+
+func (s *Store) CreateSigningKey(ctx context.Context, create *CreateSigningKey) (*SigningKeyInfo, error) {
+	return s.driver.CreateSigningKey(ctx, create)
+}
+
+// ListSigningKeys returns signing keys, newest first.
+func (s *Store) ListSigningKeys(ctx context.Context, find *FindSigningKey) ([]*SigningKeyInfo, error) {
+	return s.driver.ListSigningKeys(ctx, find)
+}
+
+// RetireSigningKeys retires every key but the one with keepID, used after a rotation.
+func (s *Store) RetireSigningKeys(ctx context.Context, keepID string) (int64, error) {
+	return s.driver.RetireSigningKeys(ctx, keepID)
+}
+
+// DeleteSigningKeys deletes the keys retired before the given time,
+// the tokens they signed must have expired by then.
+func (s *Store) DeleteSigningKeys(ctx context.Context, retiredBefore time.Time) (int64, error) {
+	return s.driver.DeleteSigningKeys(ctx, retiredBefore)
+}
@@ -1,28 +1,100 @@
 package store
 
 import (
-	"sync"
+	"time"
 
+	"github.com/sagarsuperuser/userprofile/internal/cache"
 	"github.com/sagarsuperuser/userprofile/internal/common"
 )
 
+// Options configures a Store.
+type Options struct {
+	Cache    CacheOptions
+	Sessions SessionOptions
+	Tokens   TokenOptions
+}
+
+// CacheOptions bounds the in-process caches of Store.
+// A size of 0 disables the cache. Permissions are cached with the user
+// settings, revoked access token lookups with the session settings.
+type CacheOptions struct {
+	UserCacheSize    int
+	UserCacheTTL     time.Duration
+	SessionCacheSize int
+	SessionCacheTTL  time.Duration
+}
+
+// CacheStats are the counters of the Store caches.
+type CacheStats struct {
+	Users        cache.Stats `json:"users"`
+	Sessions     cache.Stats `json:"sessions"`
+	AccessTokens cache.Stats `json:"access_tokens"`
+	Permissions  cache.Stats `json:"permissions"`
+}
+
 // Store provides database access to all raw objects.
 // Can be used to create cache layer on top of Database.
 type Store struct {
 	driver       Driver
-	userCache    sync.Map
-	sessionCache sync.Map
-	now          common.NowFunc
+	userCache    *cache.Cache[int64, *UserInfo]
+	sessionCache *cache.Cache[[32]byte, *SessionInfo]
+	// revokedTokenCache is keyed by the SHA-256 hash of the access token jti.
+	revokedTokenCache *cache.Cache[[32]byte, bool]
+	// permissionCache holds the permissions of the role of each user.
+	permissionCache *cache.Cache[int64, []Permission]
+	// bus is nil when running a single instance.
+	bus      InvalidationBus
+	sessions SessionOptions
+	tokens   TokenOptions
+	now      common.NowFunc
 }
 
 // New creates a new instance of Store.
-func New(driver Driver, now common.NowFunc) *Store {
+func New(driver Driver, now common.NowFunc, opts Options) *Store {
 	return &Store{
 		driver: driver,
-		now:    now,
+		userCache: cache.New[int64, *UserInfo](cache.Options{
+			Size: opts.Cache.UserCacheSize,
+			TTL:  opts.Cache.UserCacheTTL,
+			Now:  now,
+		}),
+		sessionCache: cache.New[[32]byte, *SessionInfo](cache.Options{
+			Size: opts.Cache.SessionCacheSize,
+			TTL:  opts.Cache.SessionCacheTTL,
+			Now:  now,
+		}),
+		revokedTokenCache: cache.New[[32]byte, bool](cache.Options{
+			Size: opts.Cache.SessionCacheSize,
+			TTL:  opts.Cache.SessionCacheTTL,
+			Now:  now,
+		}),
+		permissionCache: cache.New[int64, []Permission](cache.Options{
+			Size: opts.Cache.UserCacheSize,
+			TTL:  opts.Cache.UserCacheTTL,
+			Now:  now,
+		}),
+		sessions: opts.Sessions.withDefaults(),
+		tokens:   opts.Tokens.withDefaults(),
+		now:      now,
 	}
 }
 
+// Now reads the clock of the store, handlers use it so that the times
+// they check agree with the ones the store records.
+func (s *Store) Now() time.Time {
+	return s.now()
+}
+
 func (s *Store) Close() error {
 	return s.driver.Close()
 }
+
+// CacheStats returns the hit, miss and eviction counters of the caches.
+func (s *Store) CacheStats() CacheStats {
+	return CacheStats{
+		Users:        s.userCache.Stats(),
+		Sessions:     s.sessionCache.Stats(),
+		AccessTokens: s.revokedTokenCache.Stats(),
+		Permissions:  s.permissionCache.Stats(),
+	}
+}
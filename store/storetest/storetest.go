@@ -0,0 +1,1929 @@
+// Package storetest provides a conformance suite for store.Driver implementations.
+//
+// Every driver should run it from its own tests so that all backends
+// behave identically:
+//
+//	func TestDriver(t *testing.T) {
+//		storetest.RunDriverTests(t, func(t *testing.T, now common.NowFunc) store.Driver {
+//			return newTestDB(t, now)
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/internal/common"
+	sessionUtils "github.com/sagarsuperuser/userprofile/internal/session"
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+// DriverFactory returns a driver backed by an empty database.
+// The driver must read the current time from now.
+// Factories are responsible for cleaning up, e.g. with t.Cleanup.
+type DriverFactory func(t *testing.T, now common.NowFunc) store.Driver
+
+// RunDriverTests runs the conformance suite against drivers built by factory.
+// Each subtest gets a fresh driver.
+func RunDriverTests(t *testing.T, factory DriverFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, d store.Driver, clock *Clock)
+	}{
+		{"CreateLocalUser", testCreateLocalUser},
+		{"CreateLocalUserDuplicate", testCreateLocalUserDuplicate},
+		{"GetUser", testGetUser},
+		{"UpdateUser", testUpdateUser},
+		{"UpdateUserLockedEmail", testUpdateUserLockedEmail},
+		{"UpsertGoogleUser", testUpsertGoogleUser},
+		{"UpsertGoogleUserLinksLocalUser", testUpsertGoogleUserLinksLocalUser},
+		{"UpsertProviderUserUnverifiedEmail", testUpsertProviderUserUnverifiedEmail},
+		{"UpsertProviderUserSyncsEmail", testUpsertProviderUserSyncsEmail},
+		{"UpsertProviderUserSyncsEmailFromOrigin", testUpsertProviderUserSyncsEmailFromOrigin},
+		{"UpsertProviderUserSeveralProviders", testUpsertProviderUserSeveralProviders},
+		{"ListIdentities", testListIdentities},
+		{"DeleteIdentity", testDeleteIdentity},
+		{"ListUsers", testListUsers},
+		{"ListUsersFilters", testListUsersFilters},
+		{"ListUsersPagination", testListUsersPagination},
+		{"ListUsersPage", testListUsersPage},
+		{"DeleteUser", testDeleteUser},
+		{"CreateSession", testCreateSession},
+		{"SessionExpiry", testSessionExpiry},
+		{"ListSessions", testListSessions},
+		{"UpdateSession", testUpdateSession},
+		{"RevokeSession", testRevokeSession},
+		{"RevokeUserSessions", testRevokeUserSessions},
+		{"DeleteSessions", testDeleteSessions},
+		{"RefreshTokens", testRefreshTokens},
+		{"RevokeRefreshTokens", testRevokeRefreshTokens},
+		{"DeleteRefreshTokens", testDeleteRefreshTokens},
+		{"RevokedAccessTokens", testRevokedAccessTokens},
+		{"SigningKeys", testSigningKeys},
+		{"OAuthClients", testOAuthClients},
+		{"OAuthConsents", testOAuthConsents},
+		{"AuthorizationCodes", testAuthorizationCodes},
+		{"ServiceAccounts", testServiceAccounts},
+		{"PersonalAccessTokens", testPersonalAccessTokens},
+		{"BuiltinRoles", testBuiltinRoles},
+		{"Roles", testRoles},
+		{"Invalidations", testInvalidations},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := NewClock(time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC))
+			d := factory(t, clock.Now)
+			tc.fn(t, d, clock)
+		})
+	}
+}
+
+func createLocalUser(t *testing.T, d store.Driver, email string) *store.UserInfo {
+	t.Helper()
+	user, err := d.CreateLocalUser(context.Background(), &store.CreateLocalUser{
+		Email:        email,
+		PasswordHash: "hash:" + email,
+		Status:       store.StatusActive,
+		Role:         store.RoleUser,
+	})
+	if err != nil {
+		t.Fatalf("CreateLocalUser(%q): %v", email, err)
+	}
+	return user
+}
+
+// sessionTTL is the expiry of sessions made by createSession.
+const sessionTTL = 24 * time.Hour
+
+func createSession(t *testing.T, d store.Driver, clock *Clock, userID int64, token string) (*store.SessionInfo, [32]byte) {
+	t.Helper()
+	hash := sha256.Sum256([]byte(token))
+	s, err := d.CreateSession(context.Background(), &store.CreateSession{
+		UserID:            userID,
+		TokenHash:         hash,
+		IP:                "203.0.113.7",
+		UserAgent:         "Mozilla/5.0 (" + token + ")",
+		ExpiresAt:         clock.Now().Add(sessionTTL),
+		AbsoluteExpiresAt: clock.Now().Add(2 * sessionTTL),
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return s, hash
+}
+
+func strValue(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func testCreateLocalUser(t *testing.T, d store.Driver, _ *Clock) {
+	user, err := d.CreateLocalUser(context.Background(), &store.CreateLocalUser{
+		Email:        "jane@example.com",
+		PasswordHash: "secret-hash",
+		Status:       store.StatusActive,
+		Role:         store.RoleAdmin,
+	})
+	if err != nil {
+		t.Fatalf("CreateLocalUser: %v", err)
+	}
+
+	if user.ID == 0 {
+		t.Errorf("expected non zero id")
+	}
+	if user.Email != "jane@example.com" {
+		t.Errorf("email = %q, want %q", user.Email, "jane@example.com")
+	}
+	if user.EmailLocked {
+		t.Errorf("local users must not have a locked email")
+	}
+	if user.Role != store.RoleAdmin {
+		t.Errorf("role = %q, want %q", user.Role, store.RoleAdmin)
+	}
+	if user.Status != store.StatusActive {
+		t.Errorf("status = %q, want %q", user.Status, store.StatusActive)
+	}
+	if user.PasswordHash != "secret-hash" {
+		t.Errorf("password hash = %q, want %q", user.PasswordHash, "secret-hash")
+	}
+	if strValue(user.FullName) != "" || strValue(user.Telephone) != "" || strValue(user.AvatarURL) != "" {
+		t.Errorf("expected an empty profile, got %q %q %q", strValue(user.FullName), strValue(user.Telephone), strValue(user.AvatarURL))
+	}
+	if user.CreatedAt.IsZero() || user.UpdatedAt.IsZero() {
+		t.Errorf("expected timestamps to be set")
+	}
+	if len(user.Identities) != 1 {
+		t.Fatalf("expected 1 identity, got %d", len(user.Identities))
+	}
+	if identity := user.Identities[0]; identity.Provider != store.ProviderLocal || !identity.HasPassword || identity.Subject != "" {
+		t.Errorf("identity = (%q, %q, %t), want a local identity with a password", identity.Provider, identity.Subject, identity.HasPassword)
+	}
+}
+
+func testCreateLocalUserDuplicate(t *testing.T, d store.Driver, _ *Clock) {
+	createLocalUser(t, d, "jane@example.com")
+
+	for _, email := range []string{"jane@example.com", "JANE@example.com"} {
+		_, err := d.CreateLocalUser(context.Background(), &store.CreateLocalUser{
+			Email:        email,
+			PasswordHash: "hash",
+			Status:       store.StatusActive,
+			Role:         store.RoleUser,
+		})
+		if !errors.Is(err, store.ErrUserAlreadyExists) {
+			t.Errorf("CreateLocalUser(%q) error = %v, want %v", email, err, store.ErrUserAlreadyExists)
+		}
+	}
+}
+
+func testGetUser(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	want := createLocalUser(t, d, "jane@example.com")
+	createLocalUser(t, d, "john@example.com")
+
+	got, err := d.GetUser(ctx, &store.FindUser{ID: &want.ID})
+	if err != nil {
+		t.Fatalf("GetUser by id: %v", err)
+	}
+	if got.ID != want.ID || got.Email != want.Email {
+		t.Errorf("GetUser by id = (%d, %q), want (%d, %q)", got.ID, got.Email, want.ID, want.Email)
+	}
+
+	email := "jane@example.com"
+	got, err = d.GetUser(ctx, &store.FindUser{Email: &email})
+	if err != nil {
+		t.Fatalf("GetUser by email: %v", err)
+	}
+	if got.ID != want.ID {
+		t.Errorf("GetUser by email id = %d, want %d", got.ID, want.ID)
+	}
+
+	missingID := want.ID + 1000
+	missingEmail := "nobody@example.com"
+	for _, find := range []*store.FindUser{{ID: &missingID}, {Email: &missingEmail}} {
+		if _, err := d.GetUser(ctx, find); !errors.Is(err, store.ErrUserNotFound) {
+			t.Errorf("GetUser(missing) error = %v, want %v", err, store.ErrUserNotFound)
+		}
+	}
+}
+
+func testUpdateUser(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	other := createLocalUser(t, d, "john@example.com")
+
+	fullName, telephone, avatarURL := "Jane Doe", "+1 555 123 4567", "https://example.com/a.png"
+	email, role, status := "jane.doe@example.com", store.RoleAdmin, store.StatusDisabled
+	got, err := d.UpdateUser(ctx, &store.UpdateUser{
+		ID:        user.ID,
+		Email:     &email,
+		Role:      &role,
+		Status:    &status,
+		FullName:  &fullName,
+		Telephone: &telephone,
+		AvatarURL: &avatarURL,
+	})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if got.Email != email || got.Role != role || got.Status != status {
+		t.Errorf("UpdateUser = (%q, %q, %q), want (%q, %q, %q)", got.Email, got.Role, got.Status, email, role, status)
+	}
+	if strValue(got.FullName) != fullName || strValue(got.Telephone) != telephone || strValue(got.AvatarURL) != avatarURL {
+		t.Errorf("UpdateUser profile = (%q, %q, %q)", strValue(got.FullName), strValue(got.Telephone), strValue(got.AvatarURL))
+	}
+	if got.PasswordHash != user.PasswordHash {
+		t.Errorf("UpdateUser must not touch the password hash")
+	}
+
+	// partial updates keep other fields
+	newName := "J. Doe"
+	got, err = d.UpdateUser(ctx, &store.UpdateUser{ID: user.ID, FullName: &newName})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if strValue(got.FullName) != newName || strValue(got.Telephone) != telephone || got.Email != email {
+		t.Errorf("partial UpdateUser = (%q, %q, %q)", strValue(got.FullName), strValue(got.Telephone), got.Email)
+	}
+
+	// the other user is untouched
+	got, err = d.GetUser(ctx, &store.FindUser{ID: &other.ID})
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if got.Email != other.Email || strValue(got.FullName) != "" {
+		t.Errorf("UpdateUser changed another user: (%q, %q)", got.Email, strValue(got.FullName))
+	}
+
+	// email taken by another user
+	if _, err := d.UpdateUser(ctx, &store.UpdateUser{ID: other.ID, Email: &email}); !errors.Is(err, store.ErrUserAlreadyExists) {
+		t.Errorf("UpdateUser(taken email) error = %v, want %v", err, store.ErrUserAlreadyExists)
+	}
+
+	missingID := other.ID + 1000
+	if _, err := d.UpdateUser(ctx, &store.UpdateUser{ID: missingID, Email: &email}); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("UpdateUser(missing) error = %v, want %v", err, store.ErrUserNotFound)
+	}
+}
+
+func testUpdateUserLockedEmail(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	user, err := d.UpsertProviderUser(ctx, &store.UpsertProviderUser{Provider: store.ProviderGoogle, Subject: "google-sub-1", Email: "jane@gmail.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+
+	email := "other@example.com"
+	if _, err := d.UpdateUser(ctx, &store.UpdateUser{ID: user.ID, Email: &email}); !errors.Is(err, store.ErrEmailUpdateNotAllowed) {
+		t.Errorf("UpdateUser(locked email) error = %v, want %v", err, store.ErrEmailUpdateNotAllowed)
+	}
+
+	// profile updates are still allowed
+	fullName := "Jane"
+	got, err := d.UpdateUser(ctx, &store.UpdateUser{ID: user.ID, FullName: &fullName})
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if strValue(got.FullName) != fullName || got.Email != "jane@gmail.com" {
+		t.Errorf("UpdateUser = (%q, %q)", strValue(got.FullName), got.Email)
+	}
+}
+
+func testUpsertGoogleUser(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	user, err := d.UpsertProviderUser(ctx, &store.UpsertProviderUser{Provider: store.ProviderGoogle, Subject: "google-sub-1", Email: "jane@gmail.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	if !user.EmailLocked {
+		t.Errorf("google users must have a locked email")
+	}
+	if user.Role != store.RoleUser || user.Status != store.StatusActive {
+		t.Errorf("UpsertProviderUser = (%q, %q), want (%q, %q)", user.Role, user.Status, store.RoleUser, store.StatusActive)
+	}
+	if user.PasswordHash != "" {
+		t.Errorf("google users must not have a password hash")
+	}
+	if len(user.Identities) != 1 {
+		t.Fatalf("expected 1 identity, got %d", len(user.Identities))
+	}
+	if identity := user.Identities[0]; identity.Provider != store.ProviderGoogle || identity.HasPassword || identity.Subject != "google-sub-1" {
+		t.Errorf("identity = (%q, %q, %t), want the google identity", identity.Provider, identity.Subject, identity.HasPassword)
+	}
+
+	// same subject -> same user
+	again, err := d.UpsertProviderUser(ctx, &store.UpsertProviderUser{Provider: store.ProviderGoogle, Subject: "google-sub-1", Email: "jane@gmail.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	if again.ID != user.ID {
+		t.Errorf("UpsertProviderUser with the same subject returned user %d, want %d", again.ID, user.ID)
+	}
+
+	// another subject -> another user
+	other, err := d.UpsertProviderUser(ctx, &store.UpsertProviderUser{Provider: store.ProviderGoogle, Subject: "google-sub-2", Email: "john@gmail.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	if other.ID == user.ID {
+		t.Errorf("UpsertProviderUser with another subject returned the same user")
+	}
+}
+
+func testUpsertGoogleUserLinksLocalUser(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	local := createLocalUser(t, d, "jane@example.com")
+	createLocalUser(t, d, "john@example.com")
+
+	// the email alone doesn't link the identity, the user has to confirm it
+	upsert := &store.UpsertProviderUser{Provider: store.ProviderGoogle, Subject: "google-sub-1", Email: "JANE@example.com", EmailVerified: true}
+	if _, err := d.UpsertProviderUser(ctx, upsert); !errors.Is(err, store.ErrIdentityNotLinked) {
+		t.Fatalf("UpsertProviderUser(existing email) error = %v, want %v", err, store.ErrIdentityNotLinked)
+	}
+	if err := d.LinkIdentity(ctx, &store.LinkIdentity{UserID: local.ID, Provider: store.ProviderGoogle, Subject: "google-sub-1"}); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+	linked, err := d.UpsertProviderUser(ctx, upsert)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	if linked.ID != local.ID {
+		t.Errorf("UpsertProviderUser returned user %d, want existing user %d", linked.ID, local.ID)
+	}
+	// local users keep their email
+	if linked.Email != "jane@example.com" {
+		t.Errorf("email = %q, want jane@example.com", linked.Email)
+	}
+	if !linked.HasIdentity(store.ProviderLocal) || !linked.HasIdentity(store.ProviderGoogle) || len(linked.Identities) != 2 {
+		t.Errorf("expected a local and a google identity, got %d identities", len(linked.Identities))
+	}
+
+	// the password login keeps working
+	email := "jane@example.com"
+	got, err := d.GetUser(ctx, &store.FindUser{Email: &email})
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if got.PasswordHash != local.PasswordHash {
+		t.Errorf("password hash = %q, want %q", got.PasswordHash, local.PasswordHash)
+	}
+
+	// linked users are listed once
+	list, err := d.ListUsers(ctx, &store.FindUser{})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("ListUsers returned %d rows, want 2", len(list))
+	}
+
+	for _, provider := range []store.Provider{store.ProviderLocal, store.ProviderGoogle} {
+		list, err := d.ListUsers(ctx, &store.FindUser{Provider: &provider})
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		wantLen := 2
+		if provider == store.ProviderGoogle {
+			wantLen = 1
+		}
+		if len(list) != wantLen {
+			t.Errorf("ListUsers(provider=%s) returned %d rows, want %d", provider, len(list), wantLen)
+		}
+		// newest first, jane is the oldest user
+		if last := list[len(list)-1]; last.ID != local.ID || len(last.Identities) != 2 {
+			t.Errorf("ListUsers(provider=%s) must return the linked user with all identities", provider)
+		}
+	}
+}
+
+func testUpsertProviderUserSeveralProviders(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	google, err := d.UpsertProviderUser(ctx, &store.UpsertProviderUser{Provider: store.ProviderGoogle, Subject: "sub-1", Email: "jane@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+
+	// subjects are only unique per provider
+	other, err := d.UpsertProviderUser(ctx, &store.UpsertProviderUser{Provider: store.ProviderGitHub, Subject: "sub-1", Email: "john@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	if other.ID == google.ID {
+		t.Errorf("UpsertProviderUser with the subject of another provider returned the same user")
+	}
+
+	// providers named in the settings are stored as is
+	link := &store.LinkIdentity{UserID: google.ID, Provider: "corp-sso", Subject: "corp-sub-1"}
+	if err := d.LinkIdentity(ctx, link); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+	// a subject links one user, and a user one subject per provider
+	if err := d.LinkIdentity(ctx, link); !errors.Is(err, store.ErrIdentityAlreadyLinked) {
+		t.Errorf("LinkIdentity(again) error = %v, want %v", err, store.ErrIdentityAlreadyLinked)
+	}
+	if err := d.LinkIdentity(ctx, &store.LinkIdentity{UserID: other.ID, Provider: "corp-sso", Subject: "corp-sub-1"}); !errors.Is(err, store.ErrIdentityAlreadyLinked) {
+		t.Errorf("LinkIdentity(linked subject) error = %v, want %v", err, store.ErrIdentityAlreadyLinked)
+	}
+	if err := d.LinkIdentity(ctx, &store.LinkIdentity{UserID: google.ID, Provider: "corp-sso", Subject: "corp-sub-2"}); !errors.Is(err, store.ErrIdentityAlreadyLinked) {
+		t.Errorf("LinkIdentity(second subject) error = %v, want %v", err, store.ErrIdentityAlreadyLinked)
+	}
+	linked, err := d.UpsertProviderUser(ctx, &store.UpsertProviderUser{Provider: "corp-sso", Subject: "corp-sub-1", Email: "jane@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	if linked.ID != google.ID {
+		t.Errorf("UpsertProviderUser returned user %d, want existing user %d", linked.ID, google.ID)
+	}
+	if !linked.HasIdentity(store.ProviderGoogle) || !linked.HasIdentity("corp-sso") || len(linked.Identities) != 2 {
+		t.Errorf("expected a google and a corp-sso identity, got %d identities", len(linked.Identities))
+	}
+
+	provider := store.Provider("corp-sso")
+	list, err := d.ListUsers(ctx, &store.FindUser{Provider: &provider})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != google.ID {
+		t.Errorf("ListUsers(provider=corp-sso) returned %d users, want user %d", len(list), google.ID)
+	}
+
+	// deleting the user frees its subjects
+	if _, err := d.DeleteUser(ctx, &store.DeleteUser{ID: google.ID}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	again, err := d.UpsertProviderUser(ctx, &store.UpsertProviderUser{Provider: provider, Subject: "corp-sub-1", Email: "jane@example.com", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	if again.ID == google.ID || len(again.Identities) != 1 {
+		t.Errorf("UpsertProviderUser after delete returned user %d with %d identities, want a new user", again.ID, len(again.Identities))
+	}
+}
+
+func testUpsertProviderUserUnverifiedEmail(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	upsert := &store.UpsertProviderUser{Provider: store.ProviderGoogle, Subject: "google-sub-1", Email: "jane@example.com"}
+	if _, err := d.UpsertProviderUser(ctx, upsert); !errors.Is(err, store.ErrProviderEmailNotVerified) {
+		t.Fatalf("UpsertProviderUser(unverified) error = %v, want %v", err, store.ErrProviderEmailNotVerified)
+	}
+	email := "jane@example.com"
+	if _, err := d.GetUser(ctx, &store.FindUser{Email: &email}); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("GetUser error = %v, want %v", err, store.ErrUserNotFound)
+	}
+
+	// linked identities still sign in, without syncing the email
+	upsert.EmailVerified = true
+	user, err := d.UpsertProviderUser(ctx, upsert)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	upsert.Email, upsert.EmailVerified = "jane@other.example.com", false
+	got, err := d.UpsertProviderUser(ctx, upsert)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser(unverified, linked) error = %v", err)
+	}
+	if got.ID != user.ID || got.Email != "jane@example.com" {
+		t.Errorf("UpsertProviderUser = (%d, %q), want (%d, jane@example.com)", got.ID, got.Email, user.ID)
+	}
+}
+
+func testUpsertProviderUserSyncsEmail(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	upsert := &store.UpsertProviderUser{Provider: store.ProviderGoogle, Subject: "google-sub-1", Email: "jane@example.com", EmailVerified: true}
+	user, err := d.UpsertProviderUser(ctx, upsert)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+
+	upsert.Email = "jane.doe@example.com"
+	got, err := d.UpsertProviderUser(ctx, upsert)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	if got.ID != user.ID || got.Email != "jane.doe@example.com" {
+		t.Errorf("UpsertProviderUser = (%d, %q), want (%d, jane.doe@example.com)", got.ID, got.Email, user.ID)
+	}
+
+	// the email of another user is not taken over
+	createLocalUser(t, d, "john@example.com")
+	upsert.Email = "john@example.com"
+	got, err = d.UpsertProviderUser(ctx, upsert)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser(conflicting email): %v", err)
+	}
+	if got.ID != user.ID || got.Email != "jane.doe@example.com" {
+		t.Errorf("UpsertProviderUser = (%d, %q), want (%d, jane.doe@example.com)", got.ID, got.Email, user.ID)
+	}
+}
+
+func testUpsertProviderUserSyncsEmailFromOrigin(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	google := &store.UpsertProviderUser{Provider: store.ProviderGoogle, Subject: "google-sub-1", Email: "jane@example.com", EmailVerified: true}
+	user, err := d.UpsertProviderUser(ctx, google)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser: %v", err)
+	}
+	if err := d.LinkIdentity(ctx, &store.LinkIdentity{UserID: user.ID, Provider: store.ProviderGitHub, Subject: "gh-1"}); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+
+	// a linked provider with another email doesn't take over the email
+	github := &store.UpsertProviderUser{Provider: store.ProviderGitHub, Subject: "gh-1", Email: "jane@work.example.com", EmailVerified: true}
+	got, err := d.UpsertProviderUser(ctx, github)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser(github): %v", err)
+	}
+	if got.ID != user.ID || got.Email != "jane@example.com" {
+		t.Errorf("UpsertProviderUser(github) = (%d, %q), want (%d, jane@example.com)", got.ID, got.Email, user.ID)
+	}
+
+	google.Email = "jane.doe@example.com"
+	got, err = d.UpsertProviderUser(ctx, google)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser(google): %v", err)
+	}
+	if got.Email != "jane.doe@example.com" {
+		t.Errorf("UpsertProviderUser(google) email = %q, want jane.doe@example.com", got.Email)
+	}
+	got, err = d.UpsertProviderUser(ctx, github)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser(github): %v", err)
+	}
+	if got.Email != "jane.doe@example.com" {
+		t.Errorf("UpsertProviderUser(github) email = %q, want jane.doe@example.com", got.Email)
+	}
+
+	// without the identity that created it, the email is the user's own
+	del := &store.DeleteIdentity{UserID: user.ID, Provider: store.ProviderGoogle, Usable: []store.Provider{store.ProviderGoogle, store.ProviderGitHub}}
+	if err := d.DeleteIdentity(ctx, del); err != nil {
+		t.Fatalf("DeleteIdentity(google): %v", err)
+	}
+	got, err = d.UpsertProviderUser(ctx, github)
+	if err != nil {
+		t.Fatalf("UpsertProviderUser(github): %v", err)
+	}
+	if got.EmailLocked || got.Email != "jane.doe@example.com" {
+		t.Errorf("UpsertProviderUser(github) = (%q, locked %v), want (jane.doe@example.com, unlocked)", got.Email, got.EmailLocked)
+	}
+}
+
+func testListIdentities(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	clock.Advance(time.Minute)
+	if err := d.LinkIdentity(ctx, &store.LinkIdentity{UserID: user.ID, Provider: store.ProviderGitHub, Subject: "gh-1"}); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+
+	list, err := d.ListIdentities(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ListIdentities: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("ListIdentities returned %d identities, want 2", len(list))
+	}
+	if local := list[0]; local.Provider != store.ProviderLocal || !local.HasPassword || local.Subject != "" {
+		t.Errorf("first identity = %+v, want the local one with a password", local)
+	}
+	if github := list[1]; github.Provider != store.ProviderGitHub || github.HasPassword || github.Subject != "gh-1" {
+		t.Errorf("second identity = %+v, want github gh-1", github)
+	}
+
+	list, err = d.ListIdentities(ctx, user.ID+100)
+	if err != nil {
+		t.Fatalf("ListIdentities(unknown user): %v", err)
+	}
+	if list == nil || len(list) != 0 {
+		t.Errorf("ListIdentities(unknown user) = %v, want an empty list", list)
+	}
+}
+
+func testDeleteIdentity(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	if err := d.LinkIdentity(ctx, &store.LinkIdentity{UserID: user.ID, Provider: store.ProviderGitHub, Subject: "gh-1"}); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+	usable := []store.Provider{store.ProviderLocal, store.ProviderGitHub}
+
+	if err := d.DeleteIdentity(ctx, &store.DeleteIdentity{UserID: user.ID, Provider: store.ProviderGoogle, Usable: usable}); !errors.Is(err, store.ErrIdentityNotFound) {
+		t.Errorf("DeleteIdentity(not linked) error = %v, want %v", err, store.ErrIdentityNotFound)
+	}
+	// github is not usable when it was disabled in the meantime
+	del := &store.DeleteIdentity{UserID: user.ID, Provider: store.ProviderLocal, Usable: []store.Provider{store.ProviderLocal}}
+	if err := d.DeleteIdentity(ctx, del); !errors.Is(err, store.ErrLastLoginMethod) {
+		t.Errorf("DeleteIdentity(github disabled) error = %v, want %v", err, store.ErrLastLoginMethod)
+	}
+
+	del.Usable = usable
+	if err := d.DeleteIdentity(ctx, del); err != nil {
+		t.Fatalf("DeleteIdentity(local): %v", err)
+	}
+	got, err := d.GetUser(ctx, &store.FindUser{ID: &user.ID})
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if got.HasIdentity(store.ProviderLocal) || got.PasswordHash != "" || len(got.Identities) != 1 {
+		t.Errorf("expected only the github identity left, got %d identities", len(got.Identities))
+	}
+
+	if err := d.DeleteIdentity(ctx, &store.DeleteIdentity{UserID: user.ID, Provider: store.ProviderGitHub, Usable: usable}); !errors.Is(err, store.ErrLastLoginMethod) {
+		t.Errorf("DeleteIdentity(last) error = %v, want %v", err, store.ErrLastLoginMethod)
+	}
+
+	// the subject of a deleted identity can be linked again
+	other := createLocalUser(t, d, "john@example.com")
+	if err := d.LinkIdentity(ctx, &store.LinkIdentity{UserID: other.ID, Provider: store.ProviderGoogle, Subject: "google-1"}); err != nil {
+		t.Fatalf("LinkIdentity: %v", err)
+	}
+	if err := d.DeleteIdentity(ctx, &store.DeleteIdentity{UserID: other.ID, Provider: store.ProviderGoogle, Usable: usable}); err != nil {
+		t.Fatalf("DeleteIdentity(google): %v", err)
+	}
+	if err := d.LinkIdentity(ctx, &store.LinkIdentity{UserID: user.ID, Provider: store.ProviderGoogle, Subject: "google-1"}); err != nil {
+		t.Errorf("LinkIdentity(after delete): %v", err)
+	}
+}
+
+func testListUsers(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	var ids []int64
+	for i := range 3 {
+		ids = append(ids, createLocalUser(t, d, fmt.Sprintf("user%d@example.com", i)).ID)
+		clock.Advance(time.Second)
+	}
+	role := store.RoleAdmin
+	if _, err := d.UpdateUser(ctx, &store.UpdateUser{ID: ids[1], Role: &role}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	list, err := d.ListUsers(ctx, &store.FindUser{})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("ListUsers returned %d users, want 3", len(list))
+	}
+	// newest first
+	for i, user := range list {
+		if want := ids[len(ids)-1-i]; user.ID != want {
+			t.Errorf("ListUsers[%d] = %d, want %d", i, user.ID, want)
+		}
+	}
+
+	limit := 2
+	list, err = d.ListUsers(ctx, &store.FindUser{Limit: &limit})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("ListUsers with limit returned %d users, want 2", len(list))
+	}
+
+	list, err = d.ListUsers(ctx, &store.FindUser{Role: &role})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != ids[1] {
+		t.Errorf("ListUsers by role returned %d users, want user %d", len(list), ids[1])
+	}
+
+	provider := store.ProviderGoogle
+	list, err = d.ListUsers(ctx, &store.FindUser{Provider: &provider})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("ListUsers by provider returned %d users, want 0", len(list))
+	}
+}
+
+func testListUsersFilters(t *testing.T, d store.Driver, _ *Clock) {
+	ctx := context.Background()
+	jane := createLocalUser(t, d, "jane@example.com")
+	john := createLocalUser(t, d, "john@example.com")
+	createLocalUser(t, d, "j_doe@example.com")
+
+	status, name := store.StatusDisabled, "Johnny 100%"
+	if _, err := d.UpdateUser(ctx, &store.UpdateUser{ID: john.ID, Status: &status, FullName: &name}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	ids := func(find *store.FindUser) []int64 {
+		t.Helper()
+		list, err := d.ListUsers(ctx, find)
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		ids := make([]int64, 0, len(list))
+		for _, user := range list {
+			ids = append(ids, user.ID)
+		}
+		return ids
+	}
+	ptr := func(s string) *string { return &s }
+
+	if got := ids(&store.FindUser{Status: &status}); len(got) != 1 || got[0] != john.ID {
+		t.Errorf("ListUsers by status = %v, want [%d]", got, john.ID)
+	}
+	if got := ids(&store.FindUser{EmailPrefix: ptr("JA")}); len(got) != 1 || got[0] != jane.ID {
+		t.Errorf("ListUsers by email prefix = %v, want [%d]", got, jane.ID)
+	}
+	// wildcards in the prefix match literally
+	if got := ids(&store.FindUser{EmailPrefix: ptr("j_")}); len(got) != 1 || got[0] == jane.ID || got[0] == john.ID {
+		t.Errorf("ListUsers by email prefix with wildcard = %v, want only j_doe", got)
+	}
+	if got := ids(&store.FindUser{NamePrefix: ptr("johnny 100%")}); len(got) != 1 || got[0] != john.ID {
+		t.Errorf("ListUsers by name prefix = %v, want [%d]", got, john.ID)
+	}
+	if got := ids(&store.FindUser{NamePrefix: ptr("johnny 1000")}); len(got) != 0 {
+		t.Errorf("ListUsers by name prefix = %v, want none", got)
+	}
+
+	hourAgo, inAnHour := jane.CreatedAt.Add(-time.Hour), jane.CreatedAt.Add(time.Hour)
+	if got := ids(&store.FindUser{CreatedAfter: &hourAgo, CreatedBefore: &inAnHour}); len(got) != 3 {
+		t.Errorf("ListUsers created in range = %v, want all 3 users", got)
+	}
+	if got := ids(&store.FindUser{CreatedAfter: &inAnHour}); len(got) != 0 {
+		t.Errorf("ListUsers created after = %v, want none", got)
+	}
+	if got := ids(&store.FindUser{UpdatedBefore: &hourAgo}); len(got) != 0 {
+		t.Errorf("ListUsers updated before = %v, want none", got)
+	}
+	if got := ids(&store.FindUser{UpdatedAfter: &hourAgo, Status: &status}); len(got) != 1 {
+		t.Errorf("ListUsers updated after with status = %v, want [%d]", got, john.ID)
+	}
+}
+
+func testListUsersPagination(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	for _, email := range []string{"carol@example.com", "alice@example.com", "erin@example.com", "bob@example.com", "dave@example.com"} {
+		createLocalUser(t, d, email)
+		clock.Advance(time.Second)
+	}
+
+	sorts := []store.UserSort{
+		store.SortCreatedDesc, store.SortCreatedAsc,
+		store.SortUpdatedDesc, store.SortUpdatedAsc,
+		store.SortEmailAsc, store.SortEmailDesc,
+	}
+	for _, sort := range sorts {
+		all, err := d.ListUsers(ctx, &store.FindUser{Sort: sort})
+		if err != nil {
+			t.Fatalf("ListUsers(%s): %v", sort, err)
+		}
+		if len(all) != 5 {
+			t.Fatalf("ListUsers(%s) returned %d users, want 5", sort, len(all))
+		}
+
+		// walk the pages and expect the same users in the same order
+		var (
+			paged  []*store.UserInfo
+			cursor *store.UserCursor
+		)
+		limit := 2
+		for range len(all) {
+			page, err := d.ListUsers(ctx, &store.FindUser{Sort: sort, Cursor: cursor, Limit: &limit})
+			if err != nil {
+				t.Fatalf("ListUsers(%s) page: %v", sort, err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			paged = append(paged, page...)
+			cursor = store.NewUserCursor(sort, page[len(page)-1])
+		}
+		if len(paged) != len(all) {
+			t.Fatalf("paging %s returned %d users, want %d", sort, len(paged), len(all))
+		}
+		for i := range all {
+			if paged[i].ID != all[i].ID {
+				t.Errorf("paging %s [%d] = %d, want %d", sort, i, paged[i].ID, all[i].ID)
+			}
+		}
+	}
+
+	list, err := d.ListUsers(ctx, &store.FindUser{Sort: store.SortEmailAsc})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	for i, want := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		if list[i].Email != want+"@example.com" {
+			t.Errorf("ListUsers(email_asc)[%d] = %q, want %q", i, list[i].Email, want+"@example.com")
+		}
+	}
+}
+
+// testListUsersPage walks the pages handed out to clients, whose cursors go
+// through Encode and DecodeUserCursor.
+func testListUsersPage(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	s := store.New(d, clock.Now, store.Options{})
+	// users created at the same time are ordered by id
+	for _, email := range []string{"carol@example.com", "alice@example.com", "erin@example.com", "bob@example.com"} {
+		createLocalUser(t, d, email)
+	}
+	clock.Advance(time.Second)
+	for _, email := range []string{"frank@example.com", "dave@example.com", "grace@example.com"} {
+		createLocalUser(t, d, email)
+	}
+
+	for _, sort := range []store.UserSort{store.SortCreatedDesc, store.SortCreatedAsc} {
+		all, err := d.ListUsers(ctx, &store.FindUser{Sort: sort})
+		if err != nil {
+			t.Fatalf("ListUsers(%s): %v", sort, err)
+		}
+		for i := 1; i < len(all); i++ {
+			prev, user := all[i-1], all[i]
+			if prev.CreatedAt.Equal(user.CreatedAt) && (prev.ID < user.ID) != !sort.Desc() {
+				t.Errorf("ListUsers(%s) lists %d before %d created at the same time", sort, prev.ID, user.ID)
+			}
+		}
+
+		var (
+			paged  []*store.UserInfo
+			pages  int
+			cursor string
+		)
+		limit := 2
+		for {
+			find := &store.FindUser{Sort: sort, Limit: &limit}
+			if cursor != "" {
+				if find.Cursor, err = store.DecodeUserCursor(cursor); err != nil {
+					t.Fatalf("DecodeUserCursor(%q): %v", cursor, err)
+				}
+			}
+			page, err := s.ListUsersPage(ctx, find)
+			if err != nil {
+				t.Fatalf("ListUsersPage(%s): %v", sort, err)
+			}
+			paged = append(paged, page.Users...)
+			pages++
+			if page.NextCursor == "" {
+				break
+			}
+			if pages > len(all) {
+				t.Fatalf("ListUsersPage(%s) never reached the last page", sort)
+			}
+			cursor = page.NextCursor
+		}
+		if pages != 4 || len(paged) != len(all) {
+			t.Fatalf("paging %s returned %d users in %d pages, want %d in 4", sort, len(paged), pages, len(all))
+		}
+		for i := range all {
+			if paged[i].ID != all[i].ID {
+				t.Errorf("paging %s [%d] = %d, want %d", sort, i, paged[i].ID, all[i].ID)
+			}
+		}
+	}
+
+	// a full last page has no next page either
+	limit := 7
+	page, err := s.ListUsersPage(ctx, &store.FindUser{Limit: &limit})
+	if err != nil || len(page.Users) != 7 || page.NextCursor != "" {
+		t.Errorf("ListUsersPage(limit 7) = %d users, cursor %q, %v, want 7 and no cursor", len(page.Users), page.NextCursor, err)
+	}
+
+	for _, raw := range []string{"garbage!", "bm90IGpzb24", "eyJzIjoiYm9ndXMiLCJpIjoxfQ", "eyJzIjoiY3JlYXRlZF9kZXNjIn0"} {
+		if _, err := store.DecodeUserCursor(raw); !errors.Is(err, store.ErrInvalidCursor) {
+			t.Errorf("DecodeUserCursor(%q) error = %v, want %v", raw, err, store.ErrInvalidCursor)
+		}
+	}
+	// a cursor only continues the order it was made for
+	cursor := store.NewUserCursor(store.SortEmailAsc, page.Users[0])
+	if _, err := s.ListUsersPage(ctx, &store.FindUser{Sort: store.SortCreatedDesc, Cursor: cursor}); !errors.Is(err, store.ErrInvalidCursor) {
+		t.Errorf("ListUsersPage(cursor of another sort) error = %v, want %v", err, store.ErrInvalidCursor)
+	}
+}
+
+func testDeleteUser(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	other := createLocalUser(t, d, "john@example.com")
+	_, hash := createSession(t, d, clock, user.ID, "token-1")
+
+	deleted, err := d.DeleteUser(ctx, &store.DeleteUser{ID: user.ID})
+	if err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if !deleted {
+		t.Errorf("DeleteUser returned false for an existing user")
+	}
+
+	if _, err := d.GetUser(ctx, &store.FindUser{ID: &user.ID}); !errors.Is(err, store.ErrUserNotFound) {
+		t.Errorf("GetUser(deleted) error = %v, want %v", err, store.ErrUserNotFound)
+	}
+	if _, err := d.GetActiveSessionByHash(ctx, hash); !errors.Is(err, sessionUtils.ErrSesssionExpired) {
+		t.Errorf("sessions of a deleted user must be gone, got error %v", err)
+	}
+	if _, err := d.GetUser(ctx, &store.FindUser{ID: &other.ID}); err != nil {
+		t.Errorf("DeleteUser removed another user: %v", err)
+	}
+
+	deleted, err = d.DeleteUser(ctx, &store.DeleteUser{ID: user.ID})
+	if err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if deleted {
+		t.Errorf("DeleteUser returned true for a missing user")
+	}
+
+	// the email can be registered again
+	createLocalUser(t, d, "jane@example.com")
+}
+
+func testCreateSession(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	s, hash := createSession(t, d, clock, user.ID, "token-1")
+
+	if s.ID == 0 || s.UserID != user.ID || s.TokenHash != hash {
+		t.Errorf("CreateSession = (%d, %d), want user %d", s.ID, s.UserID, user.ID)
+	}
+	if !s.IsActive || s.RevokedAt != nil {
+		t.Errorf("new sessions must be active")
+	}
+	if want := clock.Now().Add(sessionTTL); !s.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", s.ExpiresAt, want)
+	}
+	if !s.LastSeenAt.Equal(clock.Now()) {
+		t.Errorf("LastSeenAt = %v, want %v", s.LastSeenAt, clock.Now())
+	}
+
+	got, err := d.GetActiveSessionByHash(ctx, hash)
+	if err != nil {
+		t.Fatalf("GetActiveSessionByHash: %v", err)
+	}
+	if got.ID != s.ID || got.UserID != user.ID || got.TokenHash != hash || !got.IsActive {
+		t.Errorf("GetActiveSessionByHash = (%d, %d), want (%d, %d)", got.ID, got.UserID, s.ID, user.ID)
+	}
+	if !got.AbsoluteExpiresAt.Equal(s.AbsoluteExpiresAt) || got.Remember {
+		t.Errorf("(AbsoluteExpiresAt, Remember) = (%v, %t), want (%v, false)", got.AbsoluteExpiresAt, got.Remember, s.AbsoluteExpiresAt)
+	}
+	if !got.ExpiresAt.Equal(s.ExpiresAt) || !got.LastSeenAt.Equal(s.LastSeenAt) {
+		t.Errorf("(ExpiresAt, LastSeenAt) = (%v, %v), want (%v, %v)", got.ExpiresAt, got.LastSeenAt, s.ExpiresAt, s.LastSeenAt)
+	}
+	if got.IP != "203.0.113.7" || got.UserAgent != "Mozilla/5.0 (token-1)" || got.Name != "" {
+		t.Errorf("device = (%q, %q, %q), want (%q, %q, \"\")", got.IP, got.UserAgent, got.Name, "203.0.113.7", "Mozilla/5.0 (token-1)")
+	}
+
+	if _, err := d.GetActiveSessionByHash(ctx, sha256.Sum256([]byte("unknown"))); !errors.Is(err, sessionUtils.ErrSesssionExpired) {
+		t.Errorf("GetActiveSessionByHash(unknown) error = %v, want %v", err, sessionUtils.ErrSesssionExpired)
+	}
+}
+
+func testSessionExpiry(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	_, hash := createSession(t, d, clock, user.ID, "token-1")
+
+	clock.Advance(sessionTTL - time.Minute)
+	if _, err := d.GetActiveSessionByHash(ctx, hash); err != nil {
+		t.Fatalf("session expired too early: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := d.GetActiveSessionByHash(ctx, hash); !errors.Is(err, sessionUtils.ErrSesssionExpired) {
+		t.Errorf("GetActiveSessionByHash(expired) error = %v, want %v", err, sessionUtils.ErrSesssionExpired)
+	}
+}
+
+func testListSessions(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	other := createLocalUser(t, d, "john@example.com")
+	first, _ := createSession(t, d, clock, user.ID, "token-1")
+	clock.Advance(time.Minute)
+	second, _ := createSession(t, d, clock, user.ID, "token-2")
+	_, revokedHash := createSession(t, d, clock, user.ID, "token-3")
+	createSession(t, d, clock, other.ID, "token-4")
+
+	if _, err := d.RevokeSession(ctx, revokedHash); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+
+	list, err := d.ListSessions(ctx, &store.FindSession{UserID: &user.ID})
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != second.ID || list[1].ID != first.ID {
+		t.Fatalf("ListSessions returned %d sessions, want [%d %d] without revoked or other users' sessions", len(list), second.ID, first.ID)
+	}
+
+	// the most recently seen session comes first
+	lastSeen := clock.Now().Add(time.Minute)
+	if _, err := d.UpdateSession(ctx, &store.UpdateSession{ID: first.ID, LastSeenAt: &lastSeen}); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	list, err = d.ListSessions(ctx, &store.FindSession{UserID: &user.ID})
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != first.ID {
+		t.Errorf("ListSessions after UpdateSession did not start with session %d", first.ID)
+	}
+
+	list, err = d.ListSessions(ctx, &store.FindSession{ID: &first.ID, UserID: &other.ID})
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("ListSessions(id of another user's session) returned %d sessions", len(list))
+	}
+
+	clock.Advance(sessionTTL)
+	list, err = d.ListSessions(ctx, &store.FindSession{UserID: &user.ID})
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("ListSessions returned %d expired sessions", len(list))
+	}
+}
+
+func testUpdateSession(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	s, hash := createSession(t, d, clock, user.ID, "token-1")
+
+	clock.Advance(time.Hour)
+	name, ip, lastSeen, expiresAt := "Work laptop", "198.51.100.2", clock.Now(), clock.Now().Add(sessionTTL)
+	updated, err := d.UpdateSession(ctx, &store.UpdateSession{ID: s.ID, Name: &name, IP: &ip, LastSeenAt: &lastSeen, ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+	if updated.ID != s.ID || updated.Name != name || updated.IP != ip || !updated.LastSeenAt.Equal(lastSeen) {
+		t.Errorf("UpdateSession = (%d, %q, %q, %v), want (%d, %q, %q, %v)",
+			updated.ID, updated.Name, updated.IP, updated.LastSeenAt, s.ID, name, ip, lastSeen)
+	}
+	if !updated.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", updated.ExpiresAt, expiresAt)
+	}
+	if updated.UserAgent != s.UserAgent || !updated.AbsoluteExpiresAt.Equal(s.AbsoluteExpiresAt) {
+		t.Errorf("UpdateSession changed fields that were not set")
+	}
+
+	// the extended expiry is what GetActiveSessionByHash checks
+	clock.Advance(sessionTTL - time.Minute)
+	if _, err := d.GetActiveSessionByHash(ctx, hash); err != nil {
+		t.Errorf("GetActiveSessionByHash after extending the session: %v", err)
+	}
+
+	if _, err := d.RevokeSession(ctx, hash); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, err := d.UpdateSession(ctx, &store.UpdateSession{ID: s.ID, Name: &name}); !errors.Is(err, store.ErrSessionNotFound) {
+		t.Errorf("UpdateSession(revoked) error = %v, want %v", err, store.ErrSessionNotFound)
+	}
+	missing := s.ID + 100
+	if _, err := d.UpdateSession(ctx, &store.UpdateSession{ID: missing, Name: &name}); !errors.Is(err, store.ErrSessionNotFound) {
+		t.Errorf("UpdateSession(unknown) error = %v, want %v", err, store.ErrSessionNotFound)
+	}
+}
+
+func testRevokeSession(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	_, hash := createSession(t, d, clock, user.ID, "token-1")
+	_, otherHash := createSession(t, d, clock, user.ID, "token-2")
+
+	revoked, err := d.RevokeSession(ctx, hash)
+	if err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if !revoked {
+		t.Errorf("RevokeSession returned false for an active session")
+	}
+	if _, err := d.GetActiveSessionByHash(ctx, hash); !errors.Is(err, sessionUtils.ErrSesssionExpired) {
+		t.Errorf("GetActiveSessionByHash(revoked) error = %v, want %v", err, sessionUtils.ErrSesssionExpired)
+	}
+	if _, err := d.GetActiveSessionByHash(ctx, otherHash); err != nil {
+		t.Errorf("RevokeSession revoked another session: %v", err)
+	}
+
+	revoked, err = d.RevokeSession(ctx, hash)
+	if err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if revoked {
+		t.Errorf("RevokeSession returned true for an already revoked session")
+	}
+
+	revoked, err = d.RevokeSession(ctx, sha256.Sum256([]byte("unknown")))
+	if err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if revoked {
+		t.Errorf("RevokeSession returned true for an unknown session")
+	}
+}
+
+func testRevokeUserSessions(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	other := createLocalUser(t, d, "john@example.com")
+	_, hash := createSession(t, d, clock, user.ID, "token-1")
+	_, revokedHash := createSession(t, d, clock, user.ID, "token-2")
+	_, otherHash := createSession(t, d, clock, other.ID, "token-3")
+
+	if _, err := d.RevokeSession(ctx, revokedHash); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+
+	n, err := d.RevokeUserSessions(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("RevokeUserSessions: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("RevokeUserSessions = %d, want 1", n)
+	}
+	if _, err := d.GetActiveSessionByHash(ctx, hash); !errors.Is(err, sessionUtils.ErrSesssionExpired) {
+		t.Errorf("GetActiveSessionByHash(revoked) error = %v, want %v", err, sessionUtils.ErrSesssionExpired)
+	}
+	if _, err := d.GetActiveSessionByHash(ctx, otherHash); err != nil {
+		t.Errorf("RevokeUserSessions revoked another user's session: %v", err)
+	}
+
+	n, err = d.RevokeUserSessions(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("RevokeUserSessions: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("RevokeUserSessions(again) = %d, want 0", n)
+	}
+}
+
+func testDeleteSessions(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	_, expiredHash := createSession(t, d, clock, user.ID, "token-1")
+	_, revokedHash := createSession(t, d, clock, user.ID, "token-2")
+	clock.Advance(time.Hour)
+	_, recentRevokedHash := createSession(t, d, clock, user.ID, "token-3")
+	active, activeHash := createSession(t, d, clock, user.ID, "token-4")
+	// still in use after the cutoff
+	expiresAt := clock.Now().Add(3 * sessionTTL)
+	if _, err := d.UpdateSession(ctx, &store.UpdateSession{ID: active.ID, ExpiresAt: &expiresAt}); err != nil {
+		t.Fatalf("UpdateSession: %v", err)
+	}
+
+	if _, err := d.RevokeSession(ctx, revokedHash); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	// token-1 expires, token-2 was revoked a day before the cutoff
+	clock.Advance(sessionTTL)
+	before := clock.Now()
+	clock.Advance(time.Hour)
+	if _, err := d.RevokeSession(ctx, recentRevokedHash); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+
+	first, err := d.DeleteSessions(ctx, before, 1)
+	if err != nil {
+		t.Fatalf("DeleteSessions: %v", err)
+	}
+	if len(first) != 1 {
+		t.Fatalf("DeleteSessions(limit 1) deleted %d sessions", len(first))
+	}
+	rest, err := d.DeleteSessions(ctx, before, 10)
+	if err != nil {
+		t.Fatalf("DeleteSessions: %v", err)
+	}
+	deleted := map[[32]byte]bool{first[0]: true}
+	for _, hash := range rest {
+		deleted[hash] = true
+	}
+	if len(deleted) != 2 || !deleted[expiredHash] || !deleted[revokedHash] {
+		t.Errorf("DeleteSessions deleted %d sessions, want token-1 and token-2", len(deleted))
+	}
+
+	if _, err := d.GetActiveSessionByHash(ctx, activeHash); err != nil {
+		t.Errorf("DeleteSessions deleted an active session: %v", err)
+	}
+	if again, err := d.DeleteSessions(ctx, before, 10); err != nil || len(again) != 0 {
+		t.Errorf("DeleteSessions(again) = (%d, %v), want nothing left to delete", len(again), err)
+	}
+	// revoked after the cutoff, it goes in a later sweep
+	if later, err := d.DeleteSessions(ctx, clock.Now().Add(time.Second), 10); err != nil || len(later) != 1 || later[0] != recentRevokedHash {
+		t.Errorf("DeleteSessions(later) = (%d, %v), want token-3", len(later), err)
+	}
+}
+
+// refreshTokenTTL is the expiry of refresh tokens made by createRefreshToken.
+const refreshTokenTTL = 24 * time.Hour
+
+func createRefreshToken(t *testing.T, d store.Driver, clock *Clock, userID int64, familyID, token string) (*store.RefreshTokenInfo, [32]byte) {
+	t.Helper()
+	hash := sha256.Sum256([]byte(token))
+	rt, err := d.CreateRefreshToken(context.Background(), &store.CreateRefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: clock.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	return rt, hash
+}
+
+func testRefreshTokens(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	created, hash := createRefreshToken(t, d, clock, user.ID, "family-1", "token-1")
+	if created.ID == 0 || created.UserID != user.ID || created.FamilyID != "family-1" || created.TokenHash != hash {
+		t.Errorf("CreateRefreshToken = %+v", created)
+	}
+	if !created.IsActive(clock.Now()) {
+		t.Errorf("new refresh token is not active")
+	}
+
+	got, err := d.GetRefreshTokenByHash(ctx, hash)
+	if err != nil {
+		t.Fatalf("GetRefreshTokenByHash: %v", err)
+	}
+	if got.ID != created.ID || got.FamilyID != "family-1" || got.TokenHash != hash || got.UsedAt != nil || got.RevokedAt != nil {
+		t.Errorf("GetRefreshTokenByHash = %+v, want %+v", got, created)
+	}
+	if !got.ExpiresAt.Equal(created.ExpiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, created.ExpiresAt)
+	}
+	if _, err := d.GetRefreshTokenByHash(ctx, sha256.Sum256([]byte("unknown"))); !errors.Is(err, store.ErrRefreshTokenNotFound) {
+		t.Errorf("GetRefreshTokenByHash(unknown) error = %v, want %v", err, store.ErrRefreshTokenNotFound)
+	}
+
+	// a token is used once
+	clock.Advance(time.Minute)
+	if used, err := d.UseRefreshToken(ctx, created.ID); err != nil || !used {
+		t.Fatalf("UseRefreshToken = (%v, %v), want true", used, err)
+	}
+	if used, err := d.UseRefreshToken(ctx, created.ID); err != nil || used {
+		t.Errorf("UseRefreshToken(again) = (%v, %v), want false", used, err)
+	}
+	// used tokens are still found, to detect their reuse
+	got, err = d.GetRefreshTokenByHash(ctx, hash)
+	if err != nil {
+		t.Fatalf("GetRefreshTokenByHash(used): %v", err)
+	}
+	if got.UsedAt == nil || !got.UsedAt.Equal(clock.Now()) {
+		t.Errorf("UsedAt = %v, want %v", got.UsedAt, clock.Now())
+	}
+	if got.IsActive(clock.Now()) {
+		t.Errorf("used refresh token is still active")
+	}
+
+	// expired tokens can't be used
+	expired, _ := createRefreshToken(t, d, clock, user.ID, "family-2", "token-2")
+	clock.Advance(refreshTokenTTL + time.Second)
+	if used, err := d.UseRefreshToken(ctx, expired.ID); err != nil || used {
+		t.Errorf("UseRefreshToken(expired) = (%v, %v), want false", used, err)
+	}
+}
+
+func testRevokeRefreshTokens(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	jane := createLocalUser(t, d, "jane@example.com")
+	john := createLocalUser(t, d, "john@example.com")
+	_, rotatedHash := createRefreshToken(t, d, clock, jane.ID, "family-1", "token-1")
+	latest, latestHash := createRefreshToken(t, d, clock, jane.ID, "family-1", "token-2")
+	_, otherFamilyHash := createRefreshToken(t, d, clock, jane.ID, "family-2", "token-3")
+	_, johnHash := createRefreshToken(t, d, clock, john.ID, "family-3", "token-4")
+
+	n, err := d.RevokeRefreshTokenFamily(ctx, "family-1")
+	if err != nil {
+		t.Fatalf("RevokeRefreshTokenFamily: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("RevokeRefreshTokenFamily = %d, want 2", n)
+	}
+	for _, hash := range [][32]byte{rotatedHash, latestHash} {
+		if got, err := d.GetRefreshTokenByHash(ctx, hash); err != nil || got.RevokedAt == nil {
+			t.Errorf("GetRefreshTokenByHash after RevokeRefreshTokenFamily = (%+v, %v), want revoked", got, err)
+		}
+	}
+	if used, err := d.UseRefreshToken(ctx, latest.ID); err != nil || used {
+		t.Errorf("UseRefreshToken(revoked) = (%v, %v), want false", used, err)
+	}
+	if n, err := d.RevokeRefreshTokenFamily(ctx, "family-1"); err != nil || n != 0 {
+		t.Errorf("RevokeRefreshTokenFamily(again) = (%d, %v), want 0", n, err)
+	}
+
+	if n, err := d.RevokeUserRefreshTokens(ctx, jane.ID); err != nil || n != 1 {
+		t.Errorf("RevokeUserRefreshTokens = (%d, %v), want 1", n, err)
+	}
+	if got, err := d.GetRefreshTokenByHash(ctx, otherFamilyHash); err != nil || got.RevokedAt == nil {
+		t.Errorf("GetRefreshTokenByHash after RevokeUserRefreshTokens = (%+v, %v), want revoked", got, err)
+	}
+	if got, err := d.GetRefreshTokenByHash(ctx, johnHash); err != nil || got.RevokedAt != nil {
+		t.Errorf("RevokeUserRefreshTokens revoked the token of another user: (%+v, %v)", got, err)
+	}
+}
+
+func testDeleteRefreshTokens(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	_, expiredHash := createRefreshToken(t, d, clock, user.ID, "family-1", "token-1")
+	_, revokedHash := createRefreshToken(t, d, clock, user.ID, "family-2", "token-2")
+	if _, err := d.RevokeRefreshTokenFamily(ctx, "family-2"); err != nil {
+		t.Fatalf("RevokeRefreshTokenFamily: %v", err)
+	}
+	clock.Advance(time.Hour)
+	used, usedHash := createRefreshToken(t, d, clock, user.ID, "family-3", "token-3")
+	if _, err := d.UseRefreshToken(ctx, used.ID); err != nil {
+		t.Fatalf("UseRefreshToken: %v", err)
+	}
+
+	// token-1 expired, token-2 was revoked before the cutoff
+	clock.Advance(refreshTokenTTL - time.Minute)
+	n, err := d.DeleteRefreshTokens(ctx, clock.Now())
+	if err != nil {
+		t.Fatalf("DeleteRefreshTokens: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("DeleteRefreshTokens = %d, want 2", n)
+	}
+	for _, hash := range [][32]byte{expiredHash, revokedHash} {
+		if _, err := d.GetRefreshTokenByHash(ctx, hash); !errors.Is(err, store.ErrRefreshTokenNotFound) {
+			t.Errorf("GetRefreshTokenByHash after DeleteRefreshTokens error = %v, want %v", err, store.ErrRefreshTokenNotFound)
+		}
+	}
+	// used tokens are kept until they expire to detect their reuse
+	if _, err := d.GetRefreshTokenByHash(ctx, usedHash); err != nil {
+		t.Errorf("DeleteRefreshTokens deleted a used token before it expired: %v", err)
+	}
+}
+
+func testRevokedAccessTokens(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	revoke := &store.RevokeAccessToken{JTI: "jti-1", UserID: 1, ExpiresAt: clock.Now().Add(15 * time.Minute)}
+
+	if revoked, err := d.IsAccessTokenRevoked(ctx, "jti-1"); err != nil || revoked {
+		t.Errorf("IsAccessTokenRevoked before RevokeAccessToken = (%v, %v), want false", revoked, err)
+	}
+	if err := d.RevokeAccessToken(ctx, revoke); err != nil {
+		t.Fatalf("RevokeAccessToken: %v", err)
+	}
+	if err := d.RevokeAccessToken(ctx, revoke); err != nil {
+		t.Errorf("RevokeAccessToken(again): %v", err)
+	}
+	if revoked, err := d.IsAccessTokenRevoked(ctx, "jti-1"); err != nil || !revoked {
+		t.Errorf("IsAccessTokenRevoked = (%v, %v), want true", revoked, err)
+	}
+	if revoked, err := d.IsAccessTokenRevoked(ctx, "jti-2"); err != nil || revoked {
+		t.Errorf("IsAccessTokenRevoked(other) = (%v, %v), want false", revoked, err)
+	}
+
+	// kept until the token expires
+	if n, err := d.DeleteRevokedAccessTokens(ctx, clock.Now()); err != nil || n != 0 {
+		t.Errorf("DeleteRevokedAccessTokens before expiry = (%d, %v), want 0", n, err)
+	}
+	clock.Advance(time.Hour)
+	if n, err := d.DeleteRevokedAccessTokens(ctx, clock.Now()); err != nil || n != 1 {
+		t.Errorf("DeleteRevokedAccessTokens = (%d, %v), want 1", n, err)
+	}
+	if revoked, err := d.IsAccessTokenRevoked(ctx, "jti-1"); err != nil || revoked {
+		t.Errorf("IsAccessTokenRevoked after DeleteRevokedAccessTokens = (%v, %v), want false", revoked, err)
+	}
+}
+
+func testSigningKeys(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	create := func(id string) {
+		t.Helper()
+		k, err := d.CreateSigningKey(ctx, &store.CreateSigningKey{ID: id, Algorithm: "EdDSA", PrivateKey: []byte("sealed-" + id)})
+		if err != nil {
+			t.Fatalf("CreateSigningKey: %v", err)
+		}
+		if k.ID != id || k.RetiredAt != nil || !k.CreatedAt.Equal(clock.Now()) {
+			t.Errorf("CreateSigningKey = %+v", k)
+		}
+	}
+	ids := func(find *store.FindSigningKey) []string {
+		t.Helper()
+		list, err := d.ListSigningKeys(ctx, find)
+		if err != nil {
+			t.Fatalf("ListSigningKeys: %v", err)
+		}
+		ids := make([]string, 0, len(list))
+		for _, k := range list {
+			ids = append(ids, k.ID)
+		}
+		return ids
+	}
+
+	create("key-1")
+	clock.Advance(time.Hour)
+	create("key-2")
+	if got := ids(&store.FindSigningKey{}); !slices.Equal(got, []string{"key-2", "key-1"}) {
+		t.Errorf("ListSigningKeys = %v, want newest first", got)
+	}
+	list, err := d.ListSigningKeys(ctx, &store.FindSigningKey{})
+	if err != nil || string(list[0].PrivateKey) != "sealed-key-2" || list[0].Algorithm != "EdDSA" {
+		t.Errorf("ListSigningKeys()[0] = (%+v, %v)", list[0], err)
+	}
+
+	if n, err := d.RetireSigningKeys(ctx, "key-2"); err != nil || n != 1 {
+		t.Errorf("RetireSigningKeys = (%d, %v), want 1", n, err)
+	}
+	retiredAt := clock.Now()
+	clock.Advance(time.Hour)
+	if got := ids(&store.FindSigningKey{RetiredAfter: &retiredAt}); !slices.Equal(got, []string{"key-2"}) {
+		t.Errorf("ListSigningKeys(RetiredAfter) = %v, want [key-2]", got)
+	}
+	before := retiredAt.Add(-time.Minute)
+	if got := ids(&store.FindSigningKey{RetiredAfter: &before}); !slices.Equal(got, []string{"key-2", "key-1"}) {
+		t.Errorf("ListSigningKeys(RetiredAfter) = %v, want [key-2 key-1]", got)
+	}
+
+	// only retired keys are deleted
+	if n, err := d.DeleteSigningKeys(ctx, clock.Now()); err != nil || n != 1 {
+		t.Errorf("DeleteSigningKeys = (%d, %v), want 1", n, err)
+	}
+	if got := ids(&store.FindSigningKey{}); !slices.Equal(got, []string{"key-2"}) {
+		t.Errorf("ListSigningKeys after DeleteSigningKeys = %v, want [key-2]", got)
+	}
+}
+
+func createOAuthClient(t *testing.T, d store.Driver, id string) *store.OAuthClientInfo {
+	t.Helper()
+	hash := sha256.Sum256([]byte("secret-" + id))
+	client, err := d.CreateOAuthClient(context.Background(), &store.CreateOAuthClient{
+		ID:           id,
+		Name:         "App " + id,
+		SecretHash:   hash[:],
+		RedirectURIs: []string{"https://app.example.com/callback", "http://localhost:3000/callback"},
+	})
+	if err != nil {
+		t.Fatalf("CreateOAuthClient: %v", err)
+	}
+	return client
+}
+
+func testOAuthClients(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	created := createOAuthClient(t, d, "client-1")
+	clock.Advance(time.Minute)
+	public, err := d.CreateOAuthClient(ctx, &store.CreateOAuthClient{
+		ID:           "client-2",
+		Name:         "Public app",
+		RedirectURIs: []string{"http://127.0.0.1/cb"},
+	})
+	if err != nil {
+		t.Fatalf("CreateOAuthClient(public): %v", err)
+	}
+
+	got, err := d.GetOAuthClient(ctx, "client-1")
+	if err != nil {
+		t.Fatalf("GetOAuthClient: %v", err)
+	}
+	if got.Name != created.Name || !slices.Equal(got.SecretHash, created.SecretHash) ||
+		!slices.Equal(got.RedirectURIs, created.RedirectURIs) || !got.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("GetOAuthClient = %+v, want %+v", got, created)
+	}
+	if got, err := d.GetOAuthClient(ctx, public.ID); err != nil || !got.IsPublic() {
+		t.Errorf("GetOAuthClient(public) = (%+v, %v), want a public client", got, err)
+	}
+	if _, err := d.GetOAuthClient(ctx, "missing"); !errors.Is(err, store.ErrOAuthClientNotFound) {
+		t.Errorf("GetOAuthClient(missing) error = %v, want %v", err, store.ErrOAuthClientNotFound)
+	}
+
+	list, err := d.ListOAuthClients(ctx)
+	if err != nil {
+		t.Fatalf("ListOAuthClients: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "client-1" || list[1].ID != "client-2" {
+		t.Errorf("ListOAuthClients = %+v, want oldest first", list)
+	}
+
+	if err := d.DeleteOAuthClient(ctx, "client-1"); err != nil {
+		t.Fatalf("DeleteOAuthClient: %v", err)
+	}
+	if err := d.DeleteOAuthClient(ctx, "client-1"); !errors.Is(err, store.ErrOAuthClientNotFound) {
+		t.Errorf("DeleteOAuthClient(again) error = %v, want %v", err, store.ErrOAuthClientNotFound)
+	}
+}
+
+func testOAuthConsents(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	client := createOAuthClient(t, d, "client-1")
+
+	if _, err := d.GetOAuthConsent(ctx, user.ID, client.ID); !errors.Is(err, store.ErrOAuthConsentNotFound) {
+		t.Errorf("GetOAuthConsent before UpsertOAuthConsent error = %v, want %v", err, store.ErrOAuthConsentNotFound)
+	}
+	upsert := &store.UpsertOAuthConsent{UserID: user.ID, ClientID: client.ID, Scopes: []string{"openid"}}
+	if err := d.UpsertOAuthConsent(ctx, upsert); err != nil {
+		t.Fatalf("UpsertOAuthConsent: %v", err)
+	}
+	createdAt := clock.Now()
+	clock.Advance(time.Minute)
+	upsert.Scopes = []string{"email", "openid"}
+	if err := d.UpsertOAuthConsent(ctx, upsert); err != nil {
+		t.Fatalf("UpsertOAuthConsent(again): %v", err)
+	}
+
+	got, err := d.GetOAuthConsent(ctx, user.ID, client.ID)
+	if err != nil {
+		t.Fatalf("GetOAuthConsent: %v", err)
+	}
+	if !slices.Equal(got.Scopes, upsert.Scopes) || !got.CreatedAt.Equal(createdAt) || !got.UpdatedAt.Equal(clock.Now()) {
+		t.Errorf("GetOAuthConsent = %+v, want scopes %v created at %v", got, upsert.Scopes, createdAt)
+	}
+
+	// ON DELETE CASCADE
+	if err := d.DeleteOAuthClient(ctx, client.ID); err != nil {
+		t.Fatalf("DeleteOAuthClient: %v", err)
+	}
+	if _, err := d.GetOAuthConsent(ctx, user.ID, client.ID); !errors.Is(err, store.ErrOAuthConsentNotFound) {
+		t.Errorf("GetOAuthConsent after DeleteOAuthClient error = %v, want %v", err, store.ErrOAuthConsentNotFound)
+	}
+}
+
+func testAuthorizationCodes(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	client := createOAuthClient(t, d, "client-1")
+	create := func(code string) [32]byte {
+		t.Helper()
+		hash := sha256.Sum256([]byte(code))
+		_, err := d.CreateAuthorizationCode(ctx, &store.CreateAuthorizationCode{
+			CodeHash:      hash,
+			ClientID:      client.ID,
+			UserID:        user.ID,
+			RedirectURI:   client.RedirectURIs[0],
+			Scopes:        []string{"openid", "email"},
+			Nonce:         "nonce",
+			CodeChallenge: "challenge",
+			AuthTime:      clock.Now().Add(-time.Hour),
+			ExpiresAt:     clock.Now().Add(time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("CreateAuthorizationCode: %v", err)
+		}
+		return hash
+	}
+
+	hash := create("code-1")
+	got, err := d.GetAuthorizationCodeByHash(ctx, hash)
+	if err != nil {
+		t.Fatalf("GetAuthorizationCodeByHash: %v", err)
+	}
+	if got.CodeHash != hash || got.UserID != user.ID || got.ClientID != client.ID || got.Nonce != "nonce" ||
+		got.CodeChallenge != "challenge" || !slices.Equal(got.Scopes, []string{"openid", "email"}) ||
+		!got.AuthTime.Equal(clock.Now().Add(-time.Hour)) || got.UsedAt != nil {
+		t.Errorf("GetAuthorizationCodeByHash = %+v", got)
+	}
+
+	// single use
+	if used, err := d.UseAuthorizationCode(ctx, hash); err != nil || !used {
+		t.Errorf("UseAuthorizationCode = (%v, %v), want true", used, err)
+	}
+	if used, err := d.UseAuthorizationCode(ctx, hash); err != nil || used {
+		t.Errorf("UseAuthorizationCode(again) = (%v, %v), want false", used, err)
+	}
+	if got, err := d.GetAuthorizationCodeByHash(ctx, hash); err != nil || got.UsedAt == nil {
+		t.Errorf("GetAuthorizationCodeByHash after use = (%+v, %v), want used", got, err)
+	}
+
+	expired := create("code-2")
+	clock.Advance(2 * time.Minute)
+	if used, err := d.UseAuthorizationCode(ctx, expired); err != nil || used {
+		t.Errorf("UseAuthorizationCode(expired) = (%v, %v), want false", used, err)
+	}
+	if n, err := d.DeleteAuthorizationCodes(ctx, clock.Now()); err != nil || n != 2 {
+		t.Errorf("DeleteAuthorizationCodes = (%d, %v), want 2", n, err)
+	}
+
+	// ON DELETE CASCADE
+	hash = create("code-3")
+	if _, err := d.DeleteUser(ctx, &store.DeleteUser{ID: user.ID}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := d.GetAuthorizationCodeByHash(ctx, hash); !errors.Is(err, store.ErrAuthorizationCodeNotFound) {
+		t.Errorf("GetAuthorizationCodeByHash after DeleteUser error = %v, want %v", err, store.ErrAuthorizationCodeNotFound)
+	}
+}
+
+func testServiceAccounts(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	owner := createLocalUser(t, d, "jane@example.com")
+	hash := sha256.Sum256([]byte("secret"))
+	created, err := d.CreateServiceAccount(ctx, &store.CreateServiceAccount{
+		ID:         "service-1",
+		Name:       "Billing",
+		SecretHash: hash[:],
+		Scopes:     []string{"users:read", "users:write"},
+		OwnerID:    owner.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateServiceAccount: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if _, err := d.CreateServiceAccount(ctx, &store.CreateServiceAccount{
+		ID:         "service-2",
+		Name:       "Reports",
+		SecretHash: hash[:],
+		Scopes:     []string{"users:read"},
+		OwnerID:    owner.ID,
+	}); err != nil {
+		t.Fatalf("CreateServiceAccount(second): %v", err)
+	}
+
+	got, err := d.GetServiceAccount(ctx, "service-1")
+	if err != nil {
+		t.Fatalf("GetServiceAccount: %v", err)
+	}
+	if got.Name != created.Name || !slices.Equal(got.SecretHash, created.SecretHash) || !slices.Equal(got.Scopes, created.Scopes) ||
+		got.OwnerID != owner.ID || !got.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("GetServiceAccount = %+v, want %+v", got, created)
+	}
+	if _, err := d.GetServiceAccount(ctx, "missing"); !errors.Is(err, store.ErrServiceAccountNotFound) {
+		t.Errorf("GetServiceAccount(missing) error = %v, want %v", err, store.ErrServiceAccountNotFound)
+	}
+
+	list, err := d.ListServiceAccounts(ctx)
+	if err != nil {
+		t.Fatalf("ListServiceAccounts: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "service-1" || list[1].ID != "service-2" {
+		t.Errorf("ListServiceAccounts = %+v, want oldest first", list)
+	}
+
+	if err := d.DeleteServiceAccount(ctx, "service-1"); err != nil {
+		t.Fatalf("DeleteServiceAccount: %v", err)
+	}
+	if err := d.DeleteServiceAccount(ctx, "service-1"); !errors.Is(err, store.ErrServiceAccountNotFound) {
+		t.Errorf("DeleteServiceAccount(again) error = %v, want %v", err, store.ErrServiceAccountNotFound)
+	}
+
+	// ON DELETE CASCADE
+	if _, err := d.DeleteUser(ctx, &store.DeleteUser{ID: owner.ID}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := d.GetServiceAccount(ctx, "service-2"); !errors.Is(err, store.ErrServiceAccountNotFound) {
+		t.Errorf("GetServiceAccount after DeleteUser error = %v, want %v", err, store.ErrServiceAccountNotFound)
+	}
+}
+
+func testPersonalAccessTokens(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	user := createLocalUser(t, d, "jane@example.com")
+	other := createLocalUser(t, d, "john@example.com")
+	create := func(userID int64, token string, ttl time.Duration) *store.PersonalAccessTokenInfo {
+		t.Helper()
+		pat, err := d.CreatePersonalAccessToken(ctx, &store.CreatePersonalAccessToken{
+			UserID:    userID,
+			Name:      token,
+			TokenHash: sha256.Sum256([]byte(token)),
+			Scopes:    []string{"user:read", "user:write"},
+			ExpiresAt: clock.Now().Add(ttl),
+		})
+		if err != nil {
+			t.Fatalf("CreatePersonalAccessToken: %v", err)
+		}
+		return pat
+	}
+
+	first := create(user.ID, "token-1", time.Hour)
+	clock.Advance(time.Minute)
+	second := create(user.ID, "token-2", 24*time.Hour)
+	create(other.ID, "token-3", time.Hour)
+
+	got, err := d.GetPersonalAccessTokenByHash(ctx, sha256.Sum256([]byte("token-1")))
+	if err != nil {
+		t.Fatalf("GetPersonalAccessTokenByHash: %v", err)
+	}
+	if got.ID != first.ID || got.UserID != user.ID || got.Name != "token-1" || got.TokenHash != first.TokenHash ||
+		!slices.Equal(got.Scopes, first.Scopes) || !got.ExpiresAt.Equal(first.ExpiresAt) ||
+		!got.CreatedAt.Equal(first.CreatedAt) || got.LastUsedAt != nil || got.RevokedAt != nil {
+		t.Errorf("GetPersonalAccessTokenByHash = %+v, want %+v", got, first)
+	}
+	if _, err := d.GetPersonalAccessTokenByHash(ctx, sha256.Sum256([]byte("missing"))); !errors.Is(err, store.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("GetPersonalAccessTokenByHash(missing) error = %v, want %v", err, store.ErrPersonalAccessTokenNotFound)
+	}
+
+	if err := d.TouchPersonalAccessToken(ctx, first.ID, clock.Now()); err != nil {
+		t.Fatalf("TouchPersonalAccessToken: %v", err)
+	}
+	if got, err := d.GetPersonalAccessTokenByHash(ctx, first.TokenHash); err != nil || got.LastUsedAt == nil || !got.LastUsedAt.Equal(clock.Now()) {
+		t.Errorf("GetPersonalAccessTokenByHash after touch = (%+v, %v), want last used at %v", got, err, clock.Now())
+	}
+
+	list, err := d.ListPersonalAccessTokens(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ListPersonalAccessTokens: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != second.ID || list[1].ID != first.ID {
+		t.Errorf("ListPersonalAccessTokens = %+v, want newest first", list)
+	}
+
+	// only the owner can revoke a token
+	if revoked, err := d.RevokePersonalAccessToken(ctx, other.ID, first.ID); err != nil || revoked {
+		t.Errorf("RevokePersonalAccessToken(other user) = (%v, %v), want false", revoked, err)
+	}
+	if revoked, err := d.RevokePersonalAccessToken(ctx, user.ID, first.ID); err != nil || !revoked {
+		t.Errorf("RevokePersonalAccessToken = (%v, %v), want true", revoked, err)
+	}
+	if revoked, err := d.RevokePersonalAccessToken(ctx, user.ID, first.ID); err != nil || revoked {
+		t.Errorf("RevokePersonalAccessToken(again) = (%v, %v), want false", revoked, err)
+	}
+	if got, err := d.GetPersonalAccessTokenByHash(ctx, first.TokenHash); err != nil || got.RevokedAt == nil {
+		t.Errorf("GetPersonalAccessTokenByHash after revoke = (%+v, %v), want revoked", got, err)
+	}
+	if list, err := d.ListPersonalAccessTokens(ctx, user.ID); err != nil || len(list) != 1 || list[0].ID != second.ID {
+		t.Errorf("ListPersonalAccessTokens after revoke = (%+v, %v), want only %d", list, err, second.ID)
+	}
+
+	// the revoked token and the expired one of the other user
+	clock.Advance(2 * time.Hour)
+	if n, err := d.DeletePersonalAccessTokens(ctx, clock.Now()); err != nil || n != 2 {
+		t.Errorf("DeletePersonalAccessTokens = (%d, %v), want 2", n, err)
+	}
+
+	// ON DELETE CASCADE
+	if _, err := d.DeleteUser(ctx, &store.DeleteUser{ID: user.ID}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := d.GetPersonalAccessTokenByHash(ctx, second.TokenHash); !errors.Is(err, store.ErrPersonalAccessTokenNotFound) {
+		t.Errorf("GetPersonalAccessTokenByHash after DeleteUser error = %v, want %v", err, store.ErrPersonalAccessTokenNotFound)
+	}
+}
+
+func testBuiltinRoles(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	for _, want := range store.BuiltinRoles {
+		got, err := d.GetRole(ctx, want.Name)
+		if err != nil {
+			t.Fatalf("GetRole(%s): %v", want.Name, err)
+		}
+		permissions := slices.Sorted(slices.Values(want.Permissions))
+		if !got.Builtin || got.Description != want.Description || !slices.Equal(got.Permissions, permissions) {
+			t.Errorf("GetRole(%s) = %+v, want built-in with %v", want.Name, got, permissions)
+		}
+	}
+}
+
+func testRoles(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+	created, err := d.CreateRole(ctx, &store.CreateRole{
+		Name:        "auditor",
+		Description: "Reads users",
+		Permissions: []store.Permission{store.PermissionRolesRead, store.PermissionUsersRead},
+	})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if created.Name != "auditor" || created.Builtin || !created.CreatedAt.Equal(clock.Now()) ||
+		!slices.Equal(created.Permissions, []store.Permission{store.PermissionRolesRead, store.PermissionUsersRead}) {
+		t.Errorf("CreateRole = %+v", created)
+	}
+	if _, err := d.CreateRole(ctx, &store.CreateRole{Name: "auditor"}); !errors.Is(err, store.ErrRoleAlreadyExists) {
+		t.Errorf("CreateRole(duplicate) error = %v, want %v", err, store.ErrRoleAlreadyExists)
+	}
+	if _, err := d.GetRole(ctx, "missing"); !errors.Is(err, store.ErrRoleNotFound) {
+		t.Errorf("GetRole(missing) error = %v, want %v", err, store.ErrRoleNotFound)
+	}
+
+	list, err := d.ListRoles(ctx)
+	if err != nil {
+		t.Fatalf("ListRoles: %v", err)
+	}
+	var names []store.Role
+	for _, role := range list {
+		names = append(names, role.Name)
+	}
+	if want := []store.Role{store.RoleAdmin, "auditor", store.RoleSupport, store.RoleUser}; !slices.Equal(names, want) {
+		t.Errorf("ListRoles = %v, want %v", names, want)
+	}
+	if !slices.Equal(list[1].Permissions, created.Permissions) {
+		t.Errorf("ListRoles permissions of auditor = %v, want %v", list[1].Permissions, created.Permissions)
+	}
+
+	clock.Advance(time.Minute)
+	description := "Reads and writes users"
+	updated, err := d.UpdateRole(ctx, &store.UpdateRole{
+		Name:        "auditor",
+		Description: &description,
+		Permissions: []store.Permission{store.PermissionUsersRead, store.PermissionUsersWrite},
+	})
+	if err != nil {
+		t.Fatalf("UpdateRole: %v", err)
+	}
+	if updated.Description != description || !updated.UpdatedAt.Equal(clock.Now()) ||
+		!slices.Equal(updated.Permissions, []store.Permission{store.PermissionUsersRead, store.PermissionUsersWrite}) {
+		t.Errorf("UpdateRole = %+v", updated)
+	}
+	// nil permissions are left alone, empty ones clear them
+	if updated, err := d.UpdateRole(ctx, &store.UpdateRole{Name: "auditor"}); err != nil || len(updated.Permissions) != 2 {
+		t.Errorf("UpdateRole(no permissions) = (%+v, %v), want permissions kept", updated, err)
+	}
+	if updated, err := d.UpdateRole(ctx, &store.UpdateRole{Name: "auditor", Permissions: []store.Permission{}}); err != nil || len(updated.Permissions) != 0 {
+		t.Errorf("UpdateRole(empty permissions) = (%+v, %v), want none", updated, err)
+	}
+	if _, err := d.UpdateRole(ctx, &store.UpdateRole{Name: "missing"}); !errors.Is(err, store.ErrRoleNotFound) {
+		t.Errorf("UpdateRole(missing) error = %v, want %v", err, store.ErrRoleNotFound)
+	}
+
+	// custom roles can be assigned to users
+	user := createLocalUser(t, d, "jane@example.com")
+	role := store.Role("auditor")
+	if _, err := d.UpdateUser(ctx, &store.UpdateUser{ID: user.ID, Role: &role}); err != nil {
+		t.Fatalf("UpdateUser(role): %v", err)
+	}
+	if got, err := d.GetUser(ctx, &store.FindUser{Role: &role}); err != nil || got.ID != user.ID || got.Role != role {
+		t.Errorf("GetUser(role) = (%+v, %v), want %d with role %s", got, err, user.ID, role)
+	}
+
+	if deleted, err := d.DeleteRole(ctx, role); err != nil || deleted {
+		t.Errorf("DeleteRole(in use) = (%v, %v), want false", deleted, err)
+	}
+	if deleted, err := d.DeleteRole(ctx, store.RoleSupport); err != nil || deleted {
+		t.Errorf("DeleteRole(built-in) = (%v, %v), want false", deleted, err)
+	}
+	role = store.RoleUser
+	if _, err := d.UpdateUser(ctx, &store.UpdateUser{ID: user.ID, Role: &role}); err != nil {
+		t.Fatalf("UpdateUser(role): %v", err)
+	}
+	if deleted, err := d.DeleteRole(ctx, "auditor"); err != nil || !deleted {
+		t.Errorf("DeleteRole = (%v, %v), want true", deleted, err)
+	}
+	if _, err := d.GetRole(ctx, "auditor"); !errors.Is(err, store.ErrRoleNotFound) {
+		t.Errorf("GetRole after DeleteRole error = %v, want %v", err, store.ErrRoleNotFound)
+	}
+}
+
+func testInvalidations(t *testing.T, d store.Driver, clock *Clock) {
+	ctx := context.Background()
+
+	latest, err := d.LatestInvalidationID(ctx)
+	if err != nil {
+		t.Fatalf("LatestInvalidationID: %v", err)
+	}
+	if latest != 0 {
+		t.Errorf("LatestInvalidationID on an empty log = %d, want 0", latest)
+	}
+
+	hash := sha256.Sum256([]byte("token"))
+	published := []*store.Invalidation{
+		{Source: "a", Kind: store.InvalidateUser, UserID: 1},
+		{Source: "b", Kind: store.InvalidateSession, UserID: 1, TokenHash: hash},
+	}
+	for _, inv := range published {
+		if err := d.CreateInvalidation(ctx, inv); err != nil {
+			t.Fatalf("CreateInvalidation: %v", err)
+		}
+	}
+	clock.Advance(time.Hour)
+	if err := d.CreateInvalidation(ctx, &store.Invalidation{Source: "a", Kind: store.InvalidateUserSessions, UserID: 2}); err != nil {
+		t.Fatalf("CreateInvalidation: %v", err)
+	}
+
+	list, err := d.ListInvalidations(ctx, 0, 2)
+	if err != nil {
+		t.Fatalf("ListInvalidations: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("ListInvalidations returned %d invalidations, want 2", len(list))
+	}
+	if list[0].Kind != store.InvalidateUser || list[0].Source != "a" || list[0].TokenHash != ([32]byte{}) {
+		t.Errorf("ListInvalidations[0] = %+v", list[0])
+	}
+	if list[1].Kind != store.InvalidateSession || list[1].TokenHash != hash || list[1].ID <= list[0].ID {
+		t.Errorf("ListInvalidations[1] = %+v", list[1])
+	}
+
+	rest, err := d.ListInvalidations(ctx, list[1].ID, 10)
+	if err != nil {
+		t.Fatalf("ListInvalidations: %v", err)
+	}
+	if len(rest) != 1 || rest[0].Kind != store.InvalidateUserSessions || rest[0].UserID != 2 {
+		t.Fatalf("ListInvalidations after %d = %+v, want the user_sessions invalidation", list[1].ID, rest)
+	}
+
+	latest, err = d.LatestInvalidationID(ctx)
+	if err != nil {
+		t.Fatalf("LatestInvalidationID: %v", err)
+	}
+	if latest != rest[0].ID {
+		t.Errorf("LatestInvalidationID = %d, want %d", latest, rest[0].ID)
+	}
+
+	n, err := d.DeleteInvalidations(ctx, clock.Now().Add(-30*time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteInvalidations: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("DeleteInvalidations = %d, want 2", n)
+	}
+	if list, err := d.ListInvalidations(ctx, 0, 10); err != nil || len(list) != 1 {
+		t.Errorf("ListInvalidations after delete = (%d, %v), want 1 invalidation", len(list), err)
+	}
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token expired or not found")
+	// ErrRefreshTokenReused is returned when a refresh token is used after it was
+	// rotated, its whole family is revoked since it was likely stolen.
+	ErrRefreshTokenReused = errors.New("refresh token already used")
+)
+
+// TokenOptions configures the lifetime of refresh tokens.
+type TokenOptions struct {
+	// RefreshTokenTTL is how long a refresh token can be used,
+	// every rotation issues a new one valid for as long.
+	RefreshTokenTTL time.Duration
+}
+
+// DefaultTokenOptions fill the fields left empty in the options given to New.
+var DefaultTokenOptions = TokenOptions{
+	RefreshTokenTTL: 30 * 24 * time.Hour,
+}
+
+func (o TokenOptions) withDefaults() TokenOptions {
+	if o.RefreshTokenTTL <= 0 {
+		o.RefreshTokenTTL = DefaultTokenOptions.RefreshTokenTTL
+	}
+	return o
+}
+
+type RefreshTokenInfo struct {
+	ID     int64
+	UserID int64
+	// FamilyID is shared by all the tokens rotated from the same grant.
+	FamilyID string
+	// SHA-256 hash of the token
+	TokenHash [32]byte
+	ExpiresAt time.Time
+	UsedAt    *time.Time // nil - not rotated yet
+	RevokedAt *time.Time // nil - not revoked
+	CreatedAt time.Time
+}
+
+// IsActive reports whether the token can still be rotated at now.
+func (t *RefreshTokenInfo) IsActive(now time.Time) bool {
+	return t.UsedAt == nil && t.RevokedAt == nil && t.ExpiresAt.After(now)
+}
+
+type CreateRefreshToken struct {
+	UserID    int64
+	FamilyID  string
+	TokenHash [32]byte
+	ExpiresAt time.Time
+}
+
+type CreateRefreshTokenResult struct {
+	// Raw token to be sent to the client
+	Token string
+	// DB refresh token metadata
+	RefreshToken RefreshTokenInfo
+}
+
+// RevokeAccessToken adds an access token to the denylist until it expires.
+type RevokeAccessToken struct {
+	// JTI is the "jti" claim of the token.
+	JTI       string
+	UserID    int64
+	ExpiresAt time.Time
+}
+
+// CreateRefreshToken issues the first refresh token of a new family.
+func (s *Store) CreateRefreshToken(ctx context.Context, userID int64) (*CreateRefreshTokenResult, error) {
+	return s.createRefreshToken(ctx, userID, uuid.NewString())
+}
+
+func (s *Store) createRefreshToken(ctx context.Context, userID int64, familyID string) (*CreateRefreshTokenResult, error) {
+	token, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := s.driver.CreateRefreshToken(ctx, &CreateRefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: sha256.Sum256([]byte(token)),
+		ExpiresAt: s.now().Add(s.tokens.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CreateRefreshTokenResult{Token: token, RefreshToken: *refreshToken}, nil
+}
+
+// GetRefreshToken returns the refresh token whatever its state,
+// ErrRefreshTokenNotFound if there is none.
+func (s *Store) GetRefreshToken(ctx context.Context, token string) (*RefreshTokenInfo, error) {
+	return s.driver.GetRefreshTokenByHash(ctx, sha256.Sum256([]byte(token)))
+}
+
+// RotateRefreshToken exchanges token for a new refresh token of the same family.
+// Each token can only be rotated once: using it again revokes its family
+// and returns ErrRefreshTokenReused.
+func (s *Store) RotateRefreshToken(ctx context.Context, token string) (*CreateRefreshTokenResult, error) {
+	current, err := s.GetRefreshToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if current.UsedAt != nil && current.RevokedAt == nil {
+		return nil, s.revokeReusedFamily(ctx, current)
+	}
+	if !current.IsActive(s.now()) {
+		return nil, ErrRefreshTokenNotFound
+	}
+
+	used, err := s.driver.UseRefreshToken(ctx, current.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		// rotated or revoked by a concurrent request in the meantime
+		return nil, s.revokeReusedFamily(ctx, current)
+	}
+	return s.createRefreshToken(ctx, current.UserID, current.FamilyID)
+}
+
+func (s *Store) revokeReusedFamily(ctx context.Context, reused *RefreshTokenInfo) error {
+	if _, err := s.driver.RevokeRefreshTokenFamily(ctx, reused.FamilyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// RevokeRefreshTokenFamily revokes all the tokens rotated from the same grant.
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string) (int64, error) {
+	return s.driver.RevokeRefreshTokenFamily(ctx, familyID)
+}
+
+// RevokeUserRefreshTokens revokes all refresh tokens of a user.
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID int64) (int64, error) {
+	return s.driver.RevokeUserRefreshTokens(ctx, userID)
+}
+
+// RevokeAccessToken denylists an access token until it expires.
+func (s *Store) RevokeAccessToken(ctx context.Context, revoke *RevokeAccessToken) error {
+	if err := s.driver.RevokeAccessToken(ctx, revoke); err != nil {
+		return err
+	}
+	key := sha256.Sum256([]byte(revoke.JTI))
+	s.revokedTokenCache.Set(key, true)
+	s.publish(ctx, &Invalidation{Kind: InvalidateAccessToken, UserID: revoke.UserID, TokenHash: key})
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether the access token with the given jti
+// was revoked, used by the JWT middleware.
+func (s *Store) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.revokedTokenCache.GetOrLoad(sha256.Sum256([]byte(jti)), func() (bool, error) {
+		return s.driver.IsAccessTokenRevoked(ctx, jti)
+	})
+}
+
+// PurgeTokens deletes the refresh tokens and personal access tokens that expired
+// or were revoked before the given time, and the denylisted access tokens and
+// authorization codes that expired before it.
+func (s *Store) PurgeTokens(ctx context.Context, before time.Time) (int64, error) {
+	var total int64
+	for _, purge := range []func(context.Context, time.Time) (int64, error){
+		s.driver.DeleteRefreshTokens,
+		s.driver.DeleteRevokedAccessTokens,
+		s.driver.DeleteAuthorizationCodes,
+		s.driver.DeletePersonalAccessTokens,
+	} {
+		n, err := purge(ctx, before)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// generateRefreshToken returns 256 random bits, base64url encoded.
+func generateRefreshToken() (string, error) {
+	return randomToken(32)
+}
+
+// randomToken returns size random bytes, base64url encoded.
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,97 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sagarsuperuser/userprofile/store"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	s, clock, user := newSessionStore(t)
+	first, err := s.CreateRefreshToken(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	second, err := s.RotateRefreshToken(ctx, first.Token)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	if second.Token == first.Token || second.RefreshToken.FamilyID != first.RefreshToken.FamilyID {
+		t.Errorf("RotateRefreshToken = %+v, want a new token of family %s", second.RefreshToken, first.RefreshToken.FamilyID)
+	}
+	if want := clock.Now().Add(store.DefaultTokenOptions.RefreshTokenTTL); !second.RefreshToken.ExpiresAt.Equal(want) {
+		t.Errorf("ExpiresAt = %v, want %v", second.RefreshToken.ExpiresAt, want)
+	}
+
+	// the first token was stolen: using it again revokes the whole family
+	if _, err := s.RotateRefreshToken(ctx, first.Token); !errors.Is(err, store.ErrRefreshTokenReused) {
+		t.Fatalf("RotateRefreshToken(reused) error = %v, want %v", err, store.ErrRefreshTokenReused)
+	}
+	if _, err := s.RotateRefreshToken(ctx, second.Token); !errors.Is(err, store.ErrRefreshTokenNotFound) {
+		t.Errorf("RotateRefreshToken after reuse error = %v, want %v", err, store.ErrRefreshTokenNotFound)
+	}
+
+	// other families are left alone
+	other, err := s.CreateRefreshToken(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	if _, err := s.RotateRefreshToken(ctx, other.Token); err != nil {
+		t.Errorf("RotateRefreshToken(other family): %v", err)
+	}
+	if _, err := s.RotateRefreshToken(ctx, "unknown"); !errors.Is(err, store.ErrRefreshTokenNotFound) {
+		t.Errorf("RotateRefreshToken(unknown) error = %v, want %v", err, store.ErrRefreshTokenNotFound)
+	}
+}
+
+func TestRefreshTokenRevokedWhenUserDisabled(t *testing.T) {
+	ctx := context.Background()
+	s, _, user := newSessionStore(t)
+	created, err := s.CreateRefreshToken(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+
+	status := store.StatusDisabled
+	if _, err := s.UpdateUser(ctx, &store.UpdateUser{ID: user.ID, Status: &status}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := s.RotateRefreshToken(ctx, created.Token); !errors.Is(err, store.ErrRefreshTokenNotFound) {
+		t.Errorf("RotateRefreshToken of a disabled user error = %v, want %v", err, store.ErrRefreshTokenNotFound)
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	ctx := context.Background()
+	s, clock, user := newSessionStore(t)
+
+	// the negative lookup is cached, revoking must replace it
+	if revoked, err := s.IsAccessTokenRevoked(ctx, "jti-1"); err != nil || revoked {
+		t.Fatalf("IsAccessTokenRevoked = (%v, %v), want false", revoked, err)
+	}
+	if err := s.RevokeAccessToken(ctx, &store.RevokeAccessToken{
+		JTI:       "jti-1",
+		UserID:    user.ID,
+		ExpiresAt: clock.Now().Add(15 * time.Minute),
+	}); err != nil {
+		t.Fatalf("RevokeAccessToken: %v", err)
+	}
+	if revoked, err := s.IsAccessTokenRevoked(ctx, "jti-1"); err != nil || !revoked {
+		t.Errorf("IsAccessTokenRevoked after RevokeAccessToken = (%v, %v), want true", revoked, err)
+	}
+
+	clock.Advance(time.Hour)
+	purged, err := s.PurgeTokens(ctx, clock.Now())
+	if err != nil {
+		t.Fatalf("PurgeTokens: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("PurgeTokens = %d, want 1", purged)
+	}
+}
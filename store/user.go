@@ -4,14 +4,17 @@ import (
 	"context"
 	"errors"
 	"time"
-
-	"github.com/go-sql-driver/mysql"
 )
 
 var (
 	ErrUserAlreadyExists     = errors.New("user already exists")
 	ErrUserNotFound          = errors.New("user not found")
 	ErrEmailUpdateNotAllowed = errors.New("email update is not allowed")
+	ErrUserDisabled          = errors.New("user account is disabled")
+	// ErrIdentityNotLinked is returned on the first sign-in with a provider when a user
+	// already has its email, the user has to log in and link the provider first.
+	ErrIdentityNotLinked        = errors.New("an account with this email already exists, log in with your password to link it")
+	ErrProviderEmailNotVerified = errors.New("the email of the provider account is not verified")
 )
 
 // Role is the type of a role.
@@ -22,17 +25,12 @@ const (
 	RoleAdmin Role = "admin"
 	// RoleUser is the USER role.
 	RoleUser Role = "user"
+	// RoleSupport is the SUPPORT role.
+	RoleSupport Role = "support"
 )
 
 func (e Role) String() string {
-	switch e {
-	case RoleAdmin:
-		return "admin"
-	case RoleUser:
-		return "user"
-	default:
-		return "unknown"
-	}
+	return string(e)
 }
 
 type UserStatus string
@@ -44,8 +42,10 @@ const (
 
 type Provider string
 
+// Providers other than local are external identity providers, named in the settings.
 const (
 	ProviderGoogle Provider = "google"
+	ProviderGitHub Provider = "github"
 	ProviderLocal  Provider = "local"
 )
 
@@ -56,6 +56,15 @@ type CreateLocalUser struct {
 	Role         Role
 }
 
+type UpsertProviderUser struct {
+	Provider Provider
+	Subject  string
+	Email    string
+	// EmailVerified tells whether the provider verified Email. Unverified emails
+	// can't create users and aren't synced to linked users.
+	EmailVerified bool
+}
+
 type UpdateUser struct {
 	ID        int64
 	Email     *string
@@ -66,26 +75,65 @@ type UpdateUser struct {
 	AvatarURL *string
 }
 
+// Identity is a login method linked to a user (auth_identities row).
+type Identity struct {
+	Provider Provider
+	// Subject is the provider's user id, empty for local identities.
+	Subject     string
+	HasPassword bool
+	CreatedAt   time.Time
+}
+
 type UserInfo struct {
-	ID           int64
-	Email        string
-	EmailLocked  bool
-	Role         Role
-	Status       UserStatus
+	ID          int64
+	Email       string
+	EmailLocked bool
+	Role        Role
+	Status      UserStatus
+	// PasswordHash of the local identity, empty if the user has none.
 	PasswordHash string
 	FullName     *string
 	Telephone    *string
 	AvatarURL    *string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	// Identities are all login methods of the user, oldest first.
+	Identities []*Identity
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// HasIdentity reports whether the user can log in with provider.
+func (u *UserInfo) HasIdentity(provider Provider) bool {
+	for _, identity := range u.Identities {
+		if identity.Provider == provider {
+			return true
+		}
+	}
+	return false
 }
 
 type FindUser struct {
-	ID       *int64
-	Email    *string
-	Role     *Role
+	ID     *int64
+	Email  *string
+	Role   *Role
+	Status *UserStatus
+	// Provider matches users having an identity with this provider.
 	Provider *Provider
 	Password *string
+
+	// EmailPrefix and NamePrefix match case-insensitively.
+	EmailPrefix *string
+	NamePrefix  *string
+	// Time ranges are exclusive.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+
+	// Sort defaults to SortCreatedDesc.
+	Sort UserSort
+	// Cursor skips users up to and including the cursor position,
+	// its sort must match Sort.
+	Cursor *UserCursor
 	// The maximum number of users to return.
 	Limit *int
 }
@@ -97,41 +145,61 @@ type DeleteUser struct {
 func (s *Store) CreateLocalUser(ctx context.Context, create *CreateLocalUser) (*UserInfo, error) {
 	user, err := s.driver.CreateLocalUser(ctx, create)
 	if err != nil {
-		if isDuplicateKey(err) {
-			return nil, ErrUserAlreadyExists
-		}
 		return nil, err
 	}
 
-	s.userCache.Store(user.ID, user)
+	s.userCache.Set(user.ID, user)
 	return user, nil
 }
 
-func (s *Store) UpsertGoogleUser(ctx context.Context, email, sub string) (*UserInfo, error) {
-	user, err := s.driver.UpsertGoogleUser(ctx, email, sub)
+// UpsertProviderUser returns the user signed in with the subject of an external identity
+// provider, creating one if the subject and the email are both unknown. Users created
+// by a provider have their email synced on later sign-ins, unless another user has it.
+func (s *Store) UpsertProviderUser(ctx context.Context, upsert *UpsertProviderUser) (*UserInfo, error) {
+	user, err := s.driver.UpsertProviderUser(ctx, upsert)
 	if err != nil {
 		return nil, err
 	}
 
-	s.userCache.Store(user.ID, user)
+	s.userCache.Set(user.ID, user)
+	// the email may have just been synced.
+	s.publish(ctx, &Invalidation{Kind: InvalidateUser, UserID: user.ID})
 	return user, nil
 }
 
 func (s *Store) UpdateUser(ctx context.Context, update *UpdateUser) (*UserInfo, error) {
+	// not every driver has a foreign key on the role, check it up front.
+	if update.Role != nil {
+		if _, err := s.driver.GetRole(ctx, *update.Role); err != nil {
+			return nil, err
+		}
+	}
 	user, err := s.driver.UpdateUser(ctx, update)
 	if err != nil {
 		return nil, err
 	}
 
-	s.userCache.Store(user.ID, user)
+	s.userCache.Set(user.ID, user)
+	s.permissionCache.Delete(user.ID)
+	s.publish(ctx, &Invalidation{Kind: InvalidateUser, UserID: user.ID})
+	// a disabled user must not stay logged in anywhere.
+	if update.Status != nil && *update.Status == StatusDisabled {
+		if _, err := s.RevokeUserSessions(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		if _, err := s.RevokeUserRefreshTokens(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
 	return user, nil
 }
 
 func (s *Store) GetUser(ctx context.Context, find *FindUser) (*UserInfo, error) {
-	if find.ID != nil {
-		if cache, ok := s.userCache.Load(*find.ID); ok {
-			return cache.(*UserInfo), nil
-		}
+	// only plain lookups by id go through the cache.
+	if find.ID != nil && *find == (FindUser{ID: find.ID}) {
+		return s.userCache.GetOrLoad(*find.ID, func() (*UserInfo, error) {
+			return s.driver.GetUser(ctx, find)
+		})
 	}
 
 	user, err := s.driver.GetUser(ctx, find)
@@ -139,31 +207,29 @@ func (s *Store) GetUser(ctx context.Context, find *FindUser) (*UserInfo, error)
 		return nil, err
 	}
 
-	s.userCache.Store(user.ID, user)
+	s.userCache.Set(user.ID, user)
 	return user, nil
 }
 
 func (s *Store) ListUsers(ctx context.Context, find *FindUser) ([]*UserInfo, error) {
-	list, err := s.driver.ListUsers(ctx, find)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, user := range list {
-		s.userCache.Store(user.ID, user)
-	}
-	return list, nil
+	return s.driver.ListUsers(ctx, find)
 }
 
 func (s *Store) DeleteUser(ctx context.Context, delete *DeleteUser) (bool, error) {
 	s.userCache.Delete(delete.ID)
-	return s.driver.DeleteUser(ctx, delete)
-}
+	s.permissionCache.Delete(delete.ID)
+	// sessions are deleted along with the user, drop the cached ones too.
+	s.forgetUserSessions(delete.ID)
+	deleted, err := s.driver.DeleteUser(ctx, delete)
+	if err != nil {
+		return false, err
+	}
 
-func isDuplicateKey(err error) bool {
-	var me *mysql.MySQLError
-	if errors.As(err, &me) {
-		return me.Number == 1062 // ER_DUP_ENTRY
+	if deleted {
+		s.publish(ctx,
+			&Invalidation{Kind: InvalidateUser, UserID: delete.ID},
+			&Invalidation{Kind: InvalidateUserSessions, UserID: delete.ID},
+		)
 	}
-	return false
+	return deleted, nil
 }